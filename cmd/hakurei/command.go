@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -58,6 +59,7 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 	{
 		var (
 			flagIdentifierFile int
+			flagDBusLearn      bool
 		)
 		c.NewCommand("app", "Load and start container from configuration file", func(args []string) error {
 			if len(args) < 1 {
@@ -68,12 +70,20 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			if config != nil && config.Container != nil {
 				config.Container.Args = append(config.Container.Args, args[1:]...)
 			}
+			if flagDBusLearn {
+				if config == nil || config.Enablements.Unwrap()&hst.EDBus == 0 {
+					log.Fatal("dbus-learn requires D-Bus to be enabled")
+				}
+				learnDBus(config)
+			}
 
-			outcome.Main(ctx, msg, config, flagIdentifierFile)
+			outcome.Main(ctx, msg, config, flagIdentifierFile, flagJSON)
 			panic("unreachable")
 		}).
 			Flag(&flagIdentifierFile, "identifier-fd", command.IntFlag(-1),
-				"Write identifier of current instance to fd after successful startup")
+				"Write identifier of current instance to fd after successful startup, or readiness notification if enabled").
+			Flag(&flagDBusLearn, "dbus-learn", command.BoolFlag(false),
+				"Proxy D-Bus permissively and record messages for dbus-log --learn")
 	}
 
 	{
@@ -82,6 +92,7 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			flagDBusConfigSystem  string
 			flagDBusMpris         bool
 			flagDBusVerbose       bool
+			flagDBusLearn         bool
 
 			flagID       string
 			flagIdentity int
@@ -89,9 +100,9 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			flagHomeDir  string
 			flagUserName string
 
-			flagPrivateRuntime, flagPrivateTmpdir bool
+			flagPrivateRuntime, flagPrivateTmpdir, flagPty, flagFakeRoot bool
 
-			flagWayland, flagX11, flagDBus, flagPulse bool
+			flagWayland, flagX11, flagDBus, flagPulse, flagIntegration, flagPipeWire bool
 		)
 
 		c.NewCommand("run", "Configure and start a permissive container", func(args []string) error {
@@ -149,6 +160,12 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			if flagPulse {
 				et |= hst.EPulse
 			}
+			if flagIntegration {
+				et |= hst.EIntegration
+			}
+			if flagPipeWire {
+				et |= hst.EPipeWire
+			}
 
 			config := &hst.Config{
 				ID:          flagID,
@@ -227,6 +244,13 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			if !flagPrivateTmpdir {
 				config.Container.Flags |= hst.FShareTmpdir
 			}
+			if flagPty {
+				config.Container.Flags &= ^hst.FTty
+				config.Container.Flags |= hst.FPty
+			}
+			if flagFakeRoot {
+				config.Container.Flags |= hst.FFakeRoot
+			}
 
 			// parse D-Bus config file from flags if applicable
 			if flagDBus {
@@ -264,9 +288,13 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 						config.SystemBus.Log = true
 					}
 				}
+
+				if flagDBusLearn {
+					learnDBus(config)
+				}
 			}
 
-			outcome.Main(ctx, msg, config, -1)
+			outcome.Main(ctx, msg, config, -1, flagJSON)
 			panic("unreachable")
 		}).
 			Flag(&flagDBusConfigSession, "dbus-config", command.StringFlag("builtin"),
@@ -277,6 +305,8 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 				"Allow owning MPRIS D-Bus path, has no effect if custom config is available").
 			Flag(&flagDBusVerbose, "dbus-log", command.BoolFlag(false),
 				"Force buffered logging in the D-Bus proxy").
+			Flag(&flagDBusLearn, "dbus-learn", command.BoolFlag(false),
+				"Proxy D-Bus permissively and record messages for dbus-log --learn, overrides D-Bus config").
 			Flag(&flagID, "id", command.StringFlag(""),
 				"Reverse-DNS style Application identifier, leave empty to inherit instance identifier").
 			Flag(&flagIdentity, "a", command.IntFlag(0),
@@ -291,6 +321,10 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 				"Do not share XDG_RUNTIME_DIR between containers under the same identity").
 			Flag(&flagPrivateTmpdir, "private-tmpdir", command.BoolFlag(false),
 				"Do not share TMPDIR between containers under the same identity").
+			Flag(&flagPty, "pty", command.BoolFlag(false),
+				"Relay a new pseudo-terminal instead of sharing the controlling terminal").
+			Flag(&flagFakeRoot, "fake-root", command.BoolFlag(false),
+				"Map the emulated user to root within sandbox").
 			Flag(&flagWayland, "wayland", command.BoolFlag(false),
 				"Enable connection to Wayland via security-context-v1").
 			Flag(&flagX11, "X", command.BoolFlag(false),
@@ -298,7 +332,11 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 			Flag(&flagDBus, "dbus", command.BoolFlag(false),
 				"Enable proxied connection to D-Bus").
 			Flag(&flagPulse, "pulse", command.BoolFlag(false),
-				"Enable direct connection to PulseAudio")
+				"Enable direct connection to PulseAudio").
+			Flag(&flagIntegration, "integration", command.BoolFlag(false),
+				"Expose host locale, timezone, font and theme resources").
+			Flag(&flagPipeWire, "pipewire", command.BoolFlag(false),
+				"Enable connection to PipeWire via a security context")
 	}
 
 	{
@@ -353,6 +391,110 @@ func buildCommand(ctx context.Context, msg message.Msg, early *earlyHardeningErr
 		}).Flag(&flagShort, "short", command.BoolFlag(false), "Print instance id")
 	}
 
+	{
+		var flagSeverity string
+		c.NewCommand("lint", "Report risky settings in a configuration file", func(args []string) error {
+			if len(args) != 1 {
+				log.Fatal("lint requires 1 argument")
+			}
+
+			var severity hst.Severity
+			if err := severity.UnmarshalText([]byte(flagSeverity)); err != nil {
+				log.Fatalf("invalid severity %q", flagSeverity)
+			}
+
+			config := tryPath(msg, args[0])
+			if err := config.Validate(); err != nil {
+				if m, ok := message.GetMessage(err); ok {
+					log.Fatal(m)
+				}
+				log.Fatal(err.Error())
+			}
+
+			if !printLint(os.Stdout, config.Lint(), severity, flagJSON) {
+				os.Exit(1)
+			}
+			return errSuccess
+		}).Flag(&flagSeverity, "severity", command.StringFlag(hst.SeverityInfo.String()),
+			"Only report findings at or above this severity, one of info, warning or critical")
+	}
+
+	{
+		var (
+			flagDenied    bool
+			flagName      string
+			flagInterface string
+			flagLearn     bool
+		)
+		c.NewCommand("dbus-log", "Show D-Bus messages logged for an instance", func(args []string) error {
+			if len(args) != 1 {
+				log.Fatal("dbus-log requires 1 argument")
+			}
+
+			var sc hst.Paths
+			env.CopyPaths().Copy(&sc, new(outcome.Hsu).MustID(nil))
+			pathname := tryInstanceFile(msg, args[0], outcome.DBusLogDir(&sc))
+			if pathname == nil {
+				log.Fatalf("no D-Bus log for instance %q", args[0])
+			}
+
+			f, err := os.Open(pathname.String())
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			events, err := dbus.ReadEvents(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				log.Fatalf("cannot read D-Bus log: %v", err)
+			}
+
+			if flagLearn {
+				encodeJSON(log.Fatal, os.Stdout, false, &struct {
+					SessionBus *hst.BusConfig `json:"session_bus,omitempty"`
+					SystemBus  *hst.BusConfig `json:"system_bus,omitempty"`
+				}{dbus.Learn(events, dbus.SessionBus), dbus.Learn(events, dbus.SystemBus)})
+				return errSuccess
+			}
+			printDBusLog(os.Stdout, events, &dbusLogFilter{flagDenied, flagName, flagInterface}, flagJSON)
+			return errSuccess
+		}).
+			Flag(&flagDenied, "denied", command.BoolFlag(false), "Only show denied messages").
+			Flag(&flagName, "name", command.StringFlag(""), "Only show messages from or to this bus name").
+			Flag(&flagInterface, "interface", command.StringFlag(""), "Only show messages of this interface").
+			Flag(&flagLearn, "learn", command.BoolFlag(false), "Print minimal D-Bus configuration permitting every logged message")
+	}
+
+	c.Command("report", "Show the exit report of an instance", func(args []string) error {
+		if len(args) != 1 {
+			log.Fatal("report requires 1 argument")
+		}
+
+		var sc hst.Paths
+		env.CopyPaths().Copy(&sc, new(outcome.Hsu).MustID(nil))
+		pathname := tryInstanceFile(msg, args[0], outcome.ExitReportDir(&sc))
+		if pathname == nil {
+			log.Fatalf("no exit report for instance %q", args[0])
+		}
+
+		var report hst.ExitReport
+		if data, err := os.ReadFile(pathname.String()); err != nil {
+			log.Fatal(err.Error())
+		} else if err = json.Unmarshal(data, &report); err != nil {
+			log.Fatalf("cannot decode exit report: %v", err)
+		}
+
+		if flagJSON {
+			encodeJSON(log.Fatal, os.Stdout, false, &report)
+			return errSuccess
+		}
+		for _, line := range outcome.DescribeExitReport(&report) {
+			fmt.Println(line)
+		}
+		return errSuccess
+	})
+
 	c.Command("version", "Display version information", func(args []string) error { fmt.Println(info.Version()); return errSuccess })
 	c.Command("license", "Show full license text", func(args []string) error { fmt.Println(license); return errSuccess })
 	c.Command("template", "Produce a config template", func(args []string) error { encodeJSON(log.Fatal, os.Stdout, false, hst.Template()); return errSuccess })
@@ -27,6 +27,9 @@ Commands:
     run         Configure and start a permissive container
     show        Show live or local app configuration
     ps          List active instances
+    lint        Report risky settings in a configuration file
+    dbus-log    Show D-Bus messages logged for an instance
+    report      Show the exit report of an instance
     version     Display version information
     license     Show full license text
     template    Produce a config template
@@ -36,7 +39,7 @@ Commands:
 		},
 		{
 			"run", []string{"run", "-h"}, `
-Usage:	hakurei run [-h | --help] [--dbus-config <value>] [--dbus-system <value>] [--mpris] [--dbus-log] [--id <value>] [-a <int>] [-g <value>] [-d <value>] [-u <value>] [--private-runtime] [--private-tmpdir] [--wayland] [-X] [--dbus] [--pulse] COMMAND [OPTIONS]
+Usage:	hakurei run [-h | --help] [--dbus-config <value>] [--dbus-system <value>] [--mpris] [--dbus-log] [--dbus-learn] [--id <value>] [-a <int>] [-g <value>] [-d <value>] [-u <value>] [--private-runtime] [--private-tmpdir] [--pty] [--fake-root] [--wayland] [-X] [--dbus] [--pulse] [--integration] [--pipewire] COMMAND [OPTIONS]
 
 Flags:
   -X	Enable direct connection to X11
@@ -48,20 +51,30 @@ Flags:
     	Enable proxied connection to D-Bus
   -dbus-config string
     	Path to session bus proxy config file, or "builtin" for defaults (default "builtin")
+  -dbus-learn
+    	Proxy D-Bus permissively and record messages for dbus-log --learn, overrides D-Bus config
   -dbus-log
     	Force buffered logging in the D-Bus proxy
   -dbus-system string
     	Path to system bus proxy config file, or "nil" to disable (default "nil")
+  -fake-root
+    	Map the emulated user to root within sandbox
   -g value
     	Groups inherited by all container processes
   -id string
     	Reverse-DNS style Application identifier, leave empty to inherit instance identifier
+  -integration
+    	Expose host locale, timezone, font and theme resources
   -mpris
     	Allow owning MPRIS D-Bus path, has no effect if custom config is available
+  -pipewire
+    	Enable connection to PipeWire via a security context
   -private-runtime
     	Do not share XDG_RUNTIME_DIR between containers under the same identity
   -private-tmpdir
     	Do not share TMPDIR between containers under the same identity
+  -pty
+    	Relay a new pseudo-terminal instead of sharing the controlling terminal
   -pulse
     	Enable direct connection to PulseAudio
   -u string
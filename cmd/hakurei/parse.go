@@ -10,6 +10,7 @@ import (
 	"strings"
 	"syscall"
 
+	"hakurei.app/container/check"
 	"hakurei.app/hst"
 	"hakurei.app/internal/outcome"
 	"hakurei.app/internal/store"
@@ -170,3 +171,43 @@ func tryIdentifier(msg message.Msg, name string, s *store.Store) *hst.State {
 		panic("unreachable")
 	}
 }
+
+// tryInstanceFile returns the pathname to a file named after an instance in dir, such as its D-Bus event log,
+// matched from a [hex] representation of [hst.ID] or a prefix of its lower half, in the same way as tryIdentifier.
+// Unlike tryIdentifier, this also matches instances that have since exited.
+func tryInstanceFile(msg message.Msg, name string, dir *check.Absolute) *check.Absolute {
+	if len(name) < shortLengthMin || len(name) > hex.EncodedLen(len(hst.ID{})) ||
+		(len(name) > len(hst.ID{}) && len(name) != hex.EncodedLen(len(hst.ID{}))) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir.String())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			msg.GetLogger().Println(getMessage("cannot read "+dir.String()+":", err))
+		}
+		return nil
+	}
+
+	for _, ent := range entries {
+		var id hst.ID
+		if id.UnmarshalText([]byte(ent.Name())) != nil {
+			msg.Verbosef("skipping %q", ent.Name())
+			continue
+		}
+
+		if ent.Name() == name || (len(name) <= len(hst.ID{}) &&
+			strings.HasPrefix(ent.Name()[len(hst.ID{}):], name)) {
+			return dir.Append(ent.Name())
+		}
+	}
+	return nil
+}
+
+// learnDBus replaces D-Bus configuration in config with permissive rules logging every message
+// through the built-in proxy, for learning a minimal configuration via dbus-log.
+func learnDBus(config *hst.Config) {
+	config.SessionBus = &hst.BusConfig{Log: true}
+	config.SystemBus = &hst.BusConfig{Log: true}
+	config.BuiltinDBusProxy = true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -115,3 +116,44 @@ func TestTryIdentifier(t *testing.T) {
 		})
 	}
 }
+
+func TestTryInstanceFile(t *testing.T) {
+	t.Parallel()
+
+	msg := message.New(nil)
+	d := check.MustAbs(t.TempDir())
+	for _, name := range []string{
+		"0123456789abcdeffedcba9876543210",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"fedcba98invalid",
+	} {
+		if err := os.WriteFile(d.Append(name).String(), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		s    string
+		dir  *check.Absolute
+		want *check.Absolute
+	}{
+		{"nonexistent", "fedcba98", d.Append("nonexistent"), nil},
+		{"too short", "fedcba9", d, nil},
+		{"too long", "fedcba9876543210f", d, nil},
+		{"short no match", "fedcba99", d, nil},
+		{"short match", "fedcba98", d, d.Append("0123456789abcdeffedcba9876543210")},
+		{"short match long", "fedcba9876543210", d, d.Append("0123456789abcdeffedcba9876543210")},
+		{"short match higher half", "01234567", d, nil},
+		{"full match", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", d, d.Append("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")},
+		{"full no match", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaab", d, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tryInstanceFile(msg, tc.s, tc.dir); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("tryInstanceFile: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
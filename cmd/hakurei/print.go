@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"hakurei.app/hst"
+	"hakurei.app/internal/dbus"
 	"hakurei.app/internal/env"
 	"hakurei.app/internal/info"
 	"hakurei.app/internal/outcome"
@@ -76,6 +77,12 @@ func printShowInstance(
 		t.Printf("State\n")
 		t.Printf(" Instance:\t%s (%d -> %d)\n", instance.ID.String(), instance.PID, instance.ShimPID)
 		t.Printf(" Uptime:\t%s\n", now.Sub(instance.Time).Round(time.Second).String())
+		if config.Container != nil && config.Container.ReadyTimeout != 0 {
+			t.Printf(" Ready:\t%t\n", instance.Ready)
+		}
+		if instance.Status != "" {
+			t.Printf(" Status:\t%s\n", instance.Status)
+		}
 		t.Printf("\n")
 	}
 
@@ -89,6 +96,9 @@ func printShowInstance(
 	if len(config.Groups) > 0 {
 		t.Printf(" Groups:\t%s\n", strings.Join(config.Groups, ", "))
 	}
+	if config.Pod != "" {
+		t.Printf(" Pod:\t%s\n", config.Pod)
+	}
 	if config.Container != nil {
 		flags := config.Container.Flags.String()
 
@@ -226,7 +236,13 @@ func printPs(msg message.Msg, output io.Writer, now time.Time, s *store.Store, s
 	t := newPrinter(output)
 	defer t.MustFlush()
 
-	t.Println("\tInstance\tPID\tApplication\tUptime")
+	// status column is only present if reported by any instance
+	hasStatus := slices.ContainsFunc(instances, func(instance *hst.State) bool { return instance.Status != "" })
+	if hasStatus {
+		t.Println("\tInstance\tPID\tApplication\tUptime\tStatus")
+	} else {
+		t.Println("\tInstance\tPID\tApplication\tUptime")
+	}
 	for _, instance := range instances {
 		as := "(No configuration information)"
 		if instance.Config != nil {
@@ -237,8 +253,78 @@ func printPs(msg message.Msg, output io.Writer, now time.Time, s *store.Store, s
 			}
 			as += " (" + id + ")"
 		}
-		t.Printf("\t%s\t%d\t%s\t%s\n",
+		t.Printf("\t%s\t%d\t%s\t%s",
 			shortIdentifier(&instance.ID), instance.PID, as, now.Sub(instance.Time).Round(time.Second).String())
+		if instance.Status != "" {
+			t.Printf("\t%s", instance.Status)
+		}
+		t.Printf("\n")
+	}
+}
+
+// printLint writes a representation of [hst.Finding] at or above severity to output,
+// and returns whether none were written.
+func printLint(output io.Writer, findings []hst.Finding, severity hst.Severity, flagJSON bool) (clean bool) {
+	selected := make([]hst.Finding, 0, len(findings))
+	for _, f := range findings {
+		if f.Severity >= severity {
+			selected = append(selected, f)
+		}
+	}
+	clean = len(selected) == 0
+
+	if flagJSON {
+		encodeJSON(log.Fatal, output, false, selected)
+		return
+	}
+
+	t := newPrinter(output)
+	defer t.MustFlush()
+	for _, f := range selected {
+		t.Printf("%s\t%s\t%s\n", f.Severity, f.Field, f.Message)
+	}
+	return
+}
+
+// dbusLogFilter selects [dbus.Event] printed by printDBusLog.
+type dbusLogFilter struct {
+	// Only print denied messages.
+	denied bool
+	// Only print messages from or to this bus name.
+	name string
+	// Only print messages of this interface.
+	iface string
+}
+
+// match returns whether e is selected by f.
+func (f *dbusLogFilter) match(e *dbus.Event) bool {
+	if f.denied && !e.Denied {
+		return false
+	}
+	if f.name != "" && e.Sender != f.name && e.Destination != f.name {
+		return false
+	}
+	return f.iface == "" || e.Interface == f.iface
+}
+
+// printDBusLog writes a representation of [dbus.Event] selected by f to output.
+func printDBusLog(output io.Writer, events []dbus.Event, f *dbusLogFilter, flagJSON bool) {
+	selected := make([]dbus.Event, 0, len(events))
+	for i := range events {
+		if f.match(&events[i]) {
+			selected = append(selected, events[i])
+		}
+	}
+
+	if flagJSON {
+		encodeJSON(log.Fatal, output, false, selected)
+		return
+	}
+
+	t := newPrinter(output)
+	defer t.MustFlush()
+	for i := range selected {
+		t.Printf("%s\t%s\n", selected[i].Time.UTC().Format("2006-01-02 15:04:05.000"), selected[i].String())
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 
 	"hakurei.app/container/check"
 	"hakurei.app/hst"
+	"hakurei.app/internal/dbus"
 	"hakurei.app/internal/store"
 	"hakurei.app/message"
 )
@@ -64,7 +65,7 @@ func TestPrintShowInstance(t *testing.T) {
  Identity:       9 (org.chromium.Chromium)
  Enablements:    wayland, dbus, pulseaudio
  Groups:         video, dialout, plugdev
- Flags:          multiarch, compat, devel, userns, net, abstract, tty, mapuid, device, runtime, tmpdir
+ Flags:          multiarch, compat, devel, userns, net, abstract, tty, mapuid, device, runtime, tmpdir, pty, fakeroot
  Home:           /data/data/org.chromium.Chromium
  Hostname:       localhost
  Path:           /run/current-system/sw/bin/chromium
@@ -161,7 +162,7 @@ App
  Identity:       9 (org.chromium.Chromium)
  Enablements:    wayland, dbus, pulseaudio
  Groups:         video, dialout, plugdev
- Flags:          multiarch, compat, devel, userns, net, abstract, tty, mapuid, device, runtime, tmpdir
+ Flags:          multiarch, compat, devel, userns, net, abstract, tty, mapuid, device, runtime, tmpdir, pty, fakeroot
  Home:           /data/data/org.chromium.Chromium
  Hostname:       localhost
  Path:           /run/current-system/sw/bin/chromium
@@ -356,7 +357,9 @@ App
     "map_real_uid": true,
     "device": true,
     "share_runtime": true,
-    "share_tmpdir": true
+    "share_tmpdir": true,
+    "pty": true,
+    "fake_root": true
   },
   "time": "1970-01-01T00:00:00.000000009Z"
 }
@@ -507,7 +510,9 @@ App
     "map_real_uid": true,
     "device": true,
     "share_runtime": true,
-    "share_tmpdir": true
+    "share_tmpdir": true,
+    "pty": true,
+    "fake_root": true
   }
 }
 `, true},
@@ -551,6 +556,10 @@ func TestPrintPs(t *testing.T) {
 		{"valid single", []hst.State{testState}, false, false, `    Instance    PID      Application                  Uptime
     4cf073bd    51966    9 (org.chromium.Chromium)    1h2m32s
 `, ""},
+		{"valid status", []hst.State{testStateSmall, func() hst.State { s := testState; s.Ready, s.Status = true, "Idle"; return s }()}, false, false, `    Instance    PID      Application                  Uptime     Status
+    4cf073bd    51966    9 (org.chromium.Chromium)    1h2m32s    Idle
+    aaaaaaaa    48879    1 (app.hakurei.aaaaaaaa)     1h2m28s
+`, ""},
 
 		{"valid short", []hst.State{testStateSmall, testState}, true, false, "4cf073bd\naaaaaaaa\n", ""},
 		{"valid short single", []hst.State{testState}, true, false, "4cf073bd\n", ""},
@@ -705,7 +714,9 @@ func TestPrintPs(t *testing.T) {
       "map_real_uid": true,
       "device": true,
       "share_runtime": true,
-      "share_tmpdir": true
+      "share_tmpdir": true,
+      "pty": true,
+      "fake_root": true
     },
     "time": "1970-01-01T00:00:00.000000009Z"
   },
@@ -775,3 +786,113 @@ func TestPrintPs(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintLint(t *testing.T) {
+	t.Parallel()
+
+	findings := []hst.Finding{
+		{Severity: hst.SeverityCritical, Field: "container.device",
+			Message: "host /dev is mounted as is, exposing every device node accessible to the container user"},
+		{Severity: hst.SeverityInfo, Field: "container.host_net",
+			Message: "the host network namespace is shared, exposing services listening on loopback"},
+	}
+
+	testCases := []struct {
+		name      string
+		findings  []hst.Finding
+		severity  hst.Severity
+		json      bool
+		want      string
+		wantClean bool
+	}{
+		{"clean", nil, hst.SeverityInfo, false, "", true},
+		{"clean json", nil, hst.SeverityInfo, true, "[]\n", true},
+		{"all", findings, hst.SeverityInfo, false, `critical    container.device      host /dev is mounted as is, exposing every device node accessible to the container user
+info        container.host_net    the host network namespace is shared, exposing services listening on loopback
+`, false},
+		{"critical", findings, hst.SeverityCritical, false, `critical    container.device    host /dev is mounted as is, exposing every device node accessible to the container user
+`, false},
+		{"json", findings[1:], hst.SeverityWarning, true, "[]\n", true},
+		{"json selected", findings[1:], hst.SeverityInfo, true, `[
+  {
+    "severity": "info",
+    "field": "container.host_net",
+    "message": "the host network namespace is shared, exposing services listening on loopback"
+  }
+]
+`, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			output := new(strings.Builder)
+			clean := printLint(output, tc.findings, tc.severity, tc.json)
+			if got := output.String(); got != tc.want {
+				t.Errorf("printLint: got\n%s\nwant\n%s", got, tc.want)
+			}
+			if clean != tc.wantClean {
+				t.Errorf("printLint: clean = %v, want %v", clean, tc.wantClean)
+			}
+		})
+	}
+}
+
+func TestPrintDBusLog(t *testing.T) {
+	t.Parallel()
+
+	events := []dbus.Event{
+		{Time: testTime, Conn: 1, Direction: dbus.Outgoing, Type: dbus.MessageCall, Serial: 2,
+			Destination: "org.freedesktop.portal.Desktop", Interface: "org.freedesktop.portal.FileChooser",
+			Member: "OpenFile", Path: "/org/freedesktop/portal/desktop"},
+		{Time: testTime.Add(time.Millisecond), Conn: 1, Direction: dbus.Outgoing, Type: dbus.MessageCall, Serial: 3,
+			Destination: "org.freedesktop.secrets", Interface: "org.freedesktop.Secret.Service",
+			Member: "OpenSession", Path: "/org/freedesktop/secrets", Denied: true, Reason: `talk access to "org.freedesktop.secrets" not granted`},
+		{Time: testTime.Add(time.Second), Conn: 1, Direction: dbus.Incoming, Type: dbus.MessageReturn, Serial: 9,
+			ReplySerial: 2, Sender: ":1.4"},
+	}
+
+	testCases := []struct {
+		name   string
+		filter dbusLogFilter
+		json   bool
+		want   string
+	}{
+		{"all", dbusLogFilter{}, false, `1970-01-01 01:02:32.000    C1: -> org.freedesktop.portal.Desktop #2 call org.freedesktop.portal.FileChooser.OpenFile at /org/freedesktop/portal/desktop
+1970-01-01 01:02:32.001    C1: -> org.freedesktop.secrets #3 call org.freedesktop.Secret.Service.OpenSession at /org/freedesktop/secrets denied: talk access to "org.freedesktop.secrets" not granted
+1970-01-01 01:02:33.000    C1: <- :1.4 #9 return for #2
+`},
+		{"denied", dbusLogFilter{denied: true}, false, `1970-01-01 01:02:32.001    C1: -> org.freedesktop.secrets #3 call org.freedesktop.Secret.Service.OpenSession at /org/freedesktop/secrets denied: talk access to "org.freedesktop.secrets" not granted
+`},
+		{"name", dbusLogFilter{name: ":1.4"}, false, `1970-01-01 01:02:33.000    C1: <- :1.4 #9 return for #2
+`},
+		{"interface", dbusLogFilter{iface: "org.freedesktop.portal.FileChooser"}, false, `1970-01-01 01:02:32.000    C1: -> org.freedesktop.portal.Desktop #2 call org.freedesktop.portal.FileChooser.OpenFile at /org/freedesktop/portal/desktop
+`},
+		{"none", dbusLogFilter{name: "org.example"}, true, "[]\n"},
+		{"json", dbusLogFilter{denied: true}, true, `[
+  {
+    "time": "1970-01-01T01:02:32.001000001Z",
+    "conn": 1,
+    "direction": "outgoing",
+    "type": "call",
+    "serial": 3,
+    "destination": "org.freedesktop.secrets",
+    "interface": "org.freedesktop.Secret.Service",
+    "member": "OpenSession",
+    "path": "/org/freedesktop/secrets",
+    "denied": true,
+    "reason": "talk access to \"org.freedesktop.secrets\" not granted"
+  }
+]
+`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			output := new(strings.Builder)
+			printDBusLog(output, events, &tc.filter, tc.json)
+			if got := output.String(); got != tc.want {
+				t.Errorf("printDBusLog: got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -14,3 +14,22 @@ const (
 )
 
 func toUser(userid, appid uint32) uint32 { return userid*userOffset + appStart + appid }
+
+const (
+	subordinateStart = 0x70000000
+	subordinateEnd   = 0x7ffe0000
+	subordinateSize  = 1 << 5
+	subordinateBlock = rangeSize * subordinateSize
+	subordinateUsers = (subordinateEnd - subordinateStart) / subordinateBlock
+)
+
+func toSubordinate(block, appid uint32) uint32 { return block + appid*subordinateSize }
+
+// toSubordinateBlock returns the first id of the default subordinate block of userid.
+// The ok result is false if the block does not fit in the default allocation.
+func toSubordinateBlock(userid uint32) (block uint32, ok bool) {
+	if userid >= subordinateUsers {
+		return 0, false
+	}
+	return subordinateStart + userid*subordinateBlock, true
+}
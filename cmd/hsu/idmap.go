@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// envIDMap is the name of the environment variable holding an id map request,
+// interpreted by parseIDMap.
+const envIDMap = "HAKUREI_IDMAP"
+
+// idMapGroupsMax is the maximum number of supplementary groups in an id map request.
+const idMapGroupsMax = 1 << 6
+
+// idMapRequest describes uid_map and gid_map contents requested for the user namespace of a process.
+type idMapRequest struct {
+	// Target process, must be a child of a process running as the same target uid.
+	pid uint32
+	// Mapped uid and gid of the emulated user in the container user namespace.
+	uid, gid uint32
+	// First subordinate id in the container user namespace.
+	start uint32
+	// Number of subordinate ids, zero if no subordinate ids are mapped.
+	count uint32
+	// Supplementary groups of the target process, mapped to the same gid in the container user namespace.
+	groups []uint32
+}
+
+// parseIDMap parses an id map request. A request consists of the string representations of
+// the target pid, emulated uid, emulated gid, first subordinate id and subordinate id count,
+// optionally followed by supplementary group ids, separated by spaces. All string representations
+// are parsed by calling parseUint32Fast.
+func parseIDMap(s string) (r idMapRequest, err error) {
+	f := strings.Split(s, " ")
+	if len(f) < 5 || len(f) > 5+idMapGroupsMax {
+		return r, errors.New("invalid id map request")
+	}
+
+	v := [...]*uint32{&r.pid, &r.uid, &r.gid, &r.start, &r.count}
+	for i := range v {
+		if *v[i], err = parseUint32Fast(f[i]); err != nil {
+			return r, fmt.Errorf("invalid field %d in id map request", i)
+		}
+	}
+
+	if r.pid < 1 {
+		return r, errors.New("invalid target pid")
+	}
+	if r.count > subordinateSize || r.start > 1<<32-2-r.count {
+		return r, errors.New("subordinate id range out of bounds")
+	}
+	if r.uid >= r.start && r.uid < r.start+r.count {
+		return r, errors.New("emulated uid overlaps subordinate id range")
+	}
+	if r.gid >= r.start && r.gid < r.start+r.count {
+		return r, errors.New("emulated gid overlaps subordinate id range")
+	}
+
+	if len(f) > 5 {
+		r.groups = make([]uint32, len(f)-5)
+		for i, gs := range f[5:] {
+			var gid uint32
+			if gid, err = parseUint32Fast(gs); err != nil || gid < 1 || gid == 1<<32-1 {
+				return r, fmt.Errorf("invalid group %q in id map request", gs)
+			}
+			if gid == r.gid || (gid >= r.start && gid < r.start+r.count) || slices.Contains(r.groups[:i], gid) {
+				return r, fmt.Errorf("group %d overlaps another mapping", gid)
+			}
+			r.groups[i] = gid
+		}
+	}
+	return
+}
+
+// data returns the contents of uid_map or gid_map mapping id to kernel id uid, the subordinate
+// id range to the one starting at sub, and each of groups to the same gid.
+func (r *idMapRequest) data(id, uid, sub uint32, groups []uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(int(id)) + " " + strconv.Itoa(int(uid)) + " 1\n")
+	if r.count > 0 {
+		buf.WriteString(strconv.Itoa(int(r.start)) + " " + strconv.Itoa(int(sub)) + " " + strconv.Itoa(int(r.count)) + "\n")
+	}
+	for _, gid := range groups {
+		buf.WriteString(strconv.Itoa(int(gid)) + " " + strconv.Itoa(int(gid)) + " 1\n")
+	}
+	return buf.Bytes()
+}
+
+// statusGroups returns the supplementary groups listed in the contents of a /proc/pid/status file.
+func statusGroups(status []byte) (groups []uint32, ok bool) {
+	for line := range bytes.SplitSeq(status, []byte{'\n'}) {
+		value, found := bytes.CutPrefix(line, []byte("Groups:"))
+		if !found {
+			continue
+		}
+		for _, s := range bytes.Fields(value) {
+			if gid, err := parseUint32Fast(string(s)); err != nil {
+				return nil, false
+			} else {
+				groups = append(groups, gid)
+			}
+		}
+		return groups, true
+	}
+	return nil, false
+}
+
+// checkStatus interprets the contents of a /proc/pid/status file and returns the ppid of the
+// process if its real, effective, saved set and filesystem uid and gid are all equal to uid.
+func checkStatus(status []byte, uid uint32) (ppid uint32, ok bool) {
+	var uidOk, gidOk bool
+	for line := range bytes.SplitSeq(status, []byte{'\n'}) {
+		key, value, found := bytes.Cut(line, []byte{':'})
+		if !found {
+			continue
+		}
+
+		switch string(key) {
+		case "PPid":
+			var err error
+			if ppid, err = parseUint32Fast(string(bytes.TrimSpace(value))); err != nil {
+				return 0, false
+			}
+
+		case "Uid", "Gid":
+			ids := bytes.Fields(value)
+			if len(ids) != 4 {
+				return 0, false
+			}
+			for _, s := range ids {
+				if id, err := parseUint32Fast(string(s)); err != nil || id != uid {
+					return 0, false
+				}
+			}
+			if key[0] == 'U' {
+				uidOk = true
+			} else {
+				gidOk = true
+			}
+		}
+	}
+	if ppid < 1 || !uidOk || !gidOk {
+		return 0, false
+	}
+	return ppid, true
+}
+
+// mustWriteIDMap interprets the id map request stored in s, and writes the uid_map and gid_map
+// of its target process, terminating the program if the request is malformed or not permitted.
+//
+// The target process must be a direct child of a process running as the target uid of userid and identity,
+// and it must itself be running as the same target uid. The subordinate id range mapped is the one
+// allocated to userid and identity, and these ranges never overlap each other or any range delegated
+// via /etc/subuid or /etc/subgid. Supplementary groups are only
+// mapped if the target process is already a member of them, as set up by hsu when starting the shim.
+func mustWriteIDMap(userid, identity uint32, s string) {
+	r, err := parseIDMap(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uid := toUser(userid, identity)
+	sub := toSubordinate(mustParseSubordinateConfig(userid), identity)
+	if r.count > 0 {
+		for _, name := range []string{subuidPath, subgidPath} {
+			if err = checkSubIDFile(name, sub, subordinateSize); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// the directory file descriptor pins the process, preventing pid reuse
+	var dirfd int
+	if dirfd, err = syscall.Open("/proc/"+strconv.Itoa(int(r.pid)), syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0); err != nil {
+		log.Fatalf("cannot open target process: %v", err)
+	}
+
+	if status, err := readAt(dirfd, "status"); err != nil {
+		log.Fatalf("cannot read target process status: %v", err)
+	} else if ppid, ok := checkStatus(status, uid); !ok {
+		log.Fatal("target process is not running as the target user")
+	} else if groups, ok := statusGroups(status); !ok {
+		log.Fatal("cannot determine supplementary groups of target process")
+	} else if i := slices.IndexFunc(r.groups, func(gid uint32) bool {
+		return !slices.Contains(groups, gid) || gid == uid || (gid >= sub && gid < sub+subordinateSize)
+	}); i != -1 {
+		log.Fatalf("group %d cannot be mapped for target process", r.groups[i])
+	} else if status, err = os.ReadFile("/proc/" + strconv.Itoa(int(ppid)) + "/status"); err != nil {
+		log.Fatalf("cannot read parent process status: %v", err)
+	} else if _, ok = checkStatus(status, uid); !ok {
+		log.Fatal("parent process is not running as the target user")
+	}
+
+	if err = r.write(func(name string, data []byte) error { return writeAt(dirfd, name, data) }, uid, sub); err != nil {
+		log.Fatal(err)
+	}
+	if err = syscall.Close(dirfd); err != nil {
+		log.Fatalf("cannot close target process: %v", err)
+	}
+}
+
+// write writes setgroups, uid_map and gid_map of the target process in that order by calling writeFile.
+//
+// Supplementary groups are carried into the user namespace, so setgroups is denied before gid_map is
+// written to prevent dropping them in the container, which would bypass negative group permissions.
+func (r *idMapRequest) write(writeFile func(name string, data []byte) error, uid, sub uint32) error {
+	if err := writeFile("setgroups", []byte("deny\n")); err != nil {
+		return fmt.Errorf("cannot write setgroups: %w", err)
+	}
+	if err := writeFile("uid_map", r.data(r.uid, uid, sub, nil)); err != nil {
+		return fmt.Errorf("cannot write uid_map: %w", err)
+	}
+	if err := writeFile("gid_map", r.data(r.gid, uid, sub, r.groups)); err != nil {
+		return fmt.Errorf("cannot write gid_map: %w", err)
+	}
+	return nil
+}
+
+// readAt reads the contents of a file relative to dirfd.
+func readAt(dirfd int, name string) ([]byte, error) {
+	fd, err := syscall.Openat(dirfd, name, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), name)
+	defer func() { _ = f.Close() }()
+
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(f)
+	return buf.Bytes(), err
+}
+
+// writeAt writes data to a file relative to dirfd in a single write.
+func writeAt(dirfd int, name string, data []byte) error {
+	fd, err := syscall.Openat(dirfd, name, syscall.O_WRONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+const (
+	// subuidPath is the pathname of the subordinate user id delegation file.
+	subuidPath = "/etc/subuid"
+	// subgidPath is the pathname of the subordinate group id delegation file.
+	subgidPath = "/etc/subgid"
+)
+
+// checkSubIDs returns a non-nil error if any range delegated in the subid(5) file read from r
+// overlaps the range of count ids starting at start.
+func checkSubIDs(r io.Reader, start, count uint32) error {
+	s := bufio.NewScanner(r)
+	var line uintptr
+	for s.Scan() {
+		line++
+
+		t := s.Text()
+		if t == "" || t[0] == '#' {
+			continue
+		}
+
+		// <name>:<start>:<count>
+		f := strings.Split(t, ":")
+		if len(f) != 3 {
+			return fmt.Errorf("invalid subordinate id entry on line %d", line)
+		}
+		b, err := strconv.ParseUint(f[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid subordinate id start on line %d", line)
+		}
+		n, err := strconv.ParseUint(f[2], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid subordinate id count on line %d", line)
+		}
+
+		if b < uint64(start)+uint64(count) && uint64(start) < b+n {
+			return fmt.Errorf("subordinate id range of %q on line %d overlaps ids allocated to hakurei", f[0], line)
+		}
+	}
+	return s.Err()
+}
+
+// checkSubIDFile calls checkSubIDs on the contents of the file at name. A nonexistent file delegates no ranges.
+func checkSubIDFile(name string, start, count uint32) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err = checkSubIDs(f, start, count); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestParseIDMap(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		s       string
+		want    idMapRequest
+		wantErr string
+	}{
+		{"empty", "", idMapRequest{}, "invalid id map request"},
+		{"short", "1 2 3 4", idMapRequest{}, "invalid id map request"},
+		{"invalid field", "1 2 f 4 5", idMapRequest{pid: 1, uid: 2}, "invalid field 2 in id map request"},
+		{"invalid pid", "0 65534 65534 0 32", idMapRequest{uid: 65534, gid: 65534, count: 32}, "invalid target pid"},
+		{"large count", "2 65534 65534 0 33", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 33}, "subordinate id range out of bounds"},
+		{"overflow", "2 65534 65534 4294967264 32", idMapRequest{pid: 2, uid: 65534, gid: 65534, start: 4294967264, count: 32}, "subordinate id range out of bounds"},
+		{"uid overlap", "2 31 65534 0 32", idMapRequest{pid: 2, uid: 31, gid: 65534, count: 32}, "emulated uid overlaps subordinate id range"},
+		{"gid overlap", "2 1000 100 100 32", idMapRequest{pid: 2, uid: 1000, gid: 100, start: 100, count: 32}, "emulated gid overlaps subordinate id range"},
+
+		{"invalid group", "2 65534 65534 0 0 video", idMapRequest{pid: 2, uid: 65534, gid: 65534, groups: []uint32{0}}, `invalid group "video" in id map request`},
+		{"zero group", "2 65534 65534 0 0 0", idMapRequest{pid: 2, uid: 65534, gid: 65534, groups: []uint32{0}}, `invalid group "0" in id map request`},
+		{"group overlap gid", "2 1000 100 0 0 100", idMapRequest{pid: 2, uid: 1000, gid: 100, groups: []uint32{0}}, "group 100 overlaps another mapping"},
+		{"group overlap subordinate", "2 65534 65534 0 32 26", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 32, groups: []uint32{0}}, "group 26 overlaps another mapping"},
+		{"group duplicate", "2 65534 65534 0 0 26 303 26", idMapRequest{pid: 2, uid: 65534, gid: 65534, groups: []uint32{26, 303, 0}}, "group 26 overlaps another mapping"},
+
+		{"groups", "2 65534 65534 0 0 26 303", idMapRequest{pid: 2, uid: 65534, gid: 65534, groups: []uint32{26, 303}}, ""},
+		{"subordinate groups", "2 65534 65534 0 16 26 303", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 16, groups: []uint32{26, 303}}, ""},
+		{"root", "2 65534 65534 0 32", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 32}, ""},
+		{"adjacent", "2 1000 1000 1001 1", idMapRequest{pid: 2, uid: 1000, gid: 1000, start: 1001, count: 1}, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := parseIDMap(tc.s)
+			if (err == nil && tc.wantErr != "") || (err != nil && err.Error() != tc.wantErr) {
+				t.Fatalf("parseIDMap: error = %v; want %q", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(r, tc.want) {
+				t.Errorf("parseIDMap: %#v; want %#v", r, tc.want)
+			}
+		})
+	}
+}
+
+func TestIDMapRequestData(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		r      idMapRequest
+		groups bool
+		want   string
+	}{
+		{"single", idMapRequest{pid: 2, uid: 65534, gid: 65534}, false, "65534 1010127 1\n"},
+		{"subordinate", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 32}, false, "65534 1010127 1\n0 1882252256 32\n"},
+		{"groups uid", idMapRequest{pid: 2, uid: 65534, gid: 65534, groups: []uint32{26, 303}}, false, "65534 1010127 1\n"},
+		{"groups", idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 2, groups: []uint32{26, 303}}, true,
+			"65534 1010127 1\n0 1882252256 2\n26 26 1\n303 303 1\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var groups []uint32
+			if tc.groups {
+				groups = tc.r.groups
+			}
+			if got := string(tc.r.data(tc.r.uid, toUser(10, 127), toSubordinate(subordinateStart+10*subordinateBlock, 127), groups)); got != tc.want {
+				t.Errorf("data: %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatusGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		status string
+		want   []uint32
+		wantOk bool
+	}{
+		{"empty", ``, nil, false},
+		{"none", "PPid:\t2\nGroups:\t\nNStgid:\t3\n", nil, true},
+		{"invalid", "Groups:\t26 f\n", nil, false},
+		{"groups", "PPid:\t2\nFDSize:\t64\nGroups:\t26 303 1010127 \nNStgid:\t3\n", []uint32{26, 303, 1010127}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if groups, ok := statusGroups([]byte(tc.status)); !reflect.DeepEqual(groups, tc.want) || ok != tc.wantOk {
+				t.Errorf("statusGroups: %v, %v; want %v, %v", groups, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		status   string
+		wantPpid uint32
+		wantOk   bool
+	}{
+		{"empty", ``, 0, false},
+		{"match", "Name:\thakurei\nUmask:\t0022\nState:\tS (sleeping)\nTgid:\t3\nNgid:\t0\nPid:\t3\nPPid:\t2\n" +
+			"TracerPid:\t0\nUid:\t1010127\t1010127\t1010127\t1010127\nGid:\t1010127\t1010127\t1010127\t1010127\n", 2, true},
+		{"missing gid", "PPid:\t2\nUid:\t1010127\t1010127\t1010127\t1010127\n", 0, false},
+		{"saved set uid", "PPid:\t2\nUid:\t1010127\t1010127\t0\t1010127\nGid:\t1010127\t1010127\t1010127\t1010127\n", 0, false},
+		{"short uid", "PPid:\t2\nUid:\t1010127\t1010127\t1010127\nGid:\t1010127\t1010127\t1010127\t1010127\n", 0, false},
+		{"invalid ppid", "PPid:\t-1\nUid:\t1010127\t1010127\t1010127\t1010127\nGid:\t1010127\t1010127\t1010127\t1010127\n", 0, false},
+		{"orphan", "PPid:\t0\nUid:\t1010127\t1010127\t1010127\t1010127\nGid:\t1010127\t1010127\t1010127\t1010127\n", 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if ppid, ok := checkStatus([]byte(tc.status), toUser(10, 127)); ppid != tc.wantPpid || ok != tc.wantOk {
+				t.Errorf("checkStatus: %d, %v; want %d, %v", ppid, ok, tc.wantPpid, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestIDMapRequestWrite(t *testing.T) {
+	t.Parallel()
+
+	r := idMapRequest{pid: 2, uid: 65534, gid: 65534, count: 2, groups: []uint32{26}}
+	var got [][2]string
+	if err := r.write(func(name string, data []byte) error {
+		got = append(got, [2]string{name, string(data)})
+		return nil
+	}, toUser(10, 127), toSubordinate(subordinateStart+10*subordinateBlock, 127)); err != nil {
+		t.Fatalf("write: error = %v", err)
+	}
+	// setgroups must be denied before gid_map is written
+	if want := [][2]string{
+		{"setgroups", "deny\n"},
+		{"uid_map", "65534 1010127 1\n0 1882252256 2\n"},
+		{"gid_map", "65534 1010127 1\n0 1882252256 2\n26 26 1\n"},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("write: %q; want %q", got, want)
+	}
+
+	if err := r.write(func(name string, data []byte) error {
+		if name == "gid_map" {
+			t.Errorf("write: gid_map written after setgroups failure")
+		}
+		return syscall.EPERM
+	}, 0, 0); err == nil || err.Error() != "cannot write setgroups: operation not permitted" {
+		t.Errorf("write: error = %v", err)
+	}
+}
+
+func TestCheckSubIDs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"empty", ``, ""},
+		{"comment", "# comment\n\nalice:100000:65536\n", ""},
+		{"adjacent", "alice:1073741792:32\nbob:1073741856:65536\n", ""},
+		{"invalid entry", "alice:100000\n", "invalid subordinate id entry on line 1"},
+		{"invalid start", "alice:f:65536\n", "invalid subordinate id start on line 1"},
+		{"invalid count", "alice:100000:f\n", "invalid subordinate id count on line 1"},
+		{"overlap", "alice:100000:65536\nbob:1073741760:65536\n", `subordinate id range of "bob" on line 2 overlaps ids allocated to hakurei`},
+		{"contains", "alice:0:4294967295\n", `subordinate id range of "alice" on line 1 overlaps ids allocated to hakurei`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkSubIDs(bytes.NewBufferString(tc.data), 1<<30, subordinateSize)
+			if (err == nil && tc.wantErr != "") || (err != nil && err.Error() != tc.wantErr) {
+				t.Errorf("checkSubIDs: error = %v; want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
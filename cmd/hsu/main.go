@@ -78,6 +78,12 @@ func main() {
 	// authenticate before accepting user input
 	userid := mustParseConfig(puid)
 
+	// hakurei requests subordinate id mapping for a container
+	if s, ok := os.LookupEnv(envIDMap); ok {
+		mustWriteIDMap(userid, mustReadIdentity(), s)
+		os.Exit(0)
+	}
+
 	// pass through setup fd to shim
 	var shimSetupFd string
 	if s, ok := os.LookupEnv(envShim); !ok {
@@ -44,8 +44,9 @@ func parseUint32Fast(s string) (uint32, error) {
 //
 // Each line of the file specifies a hakurei userid to kernel uid mapping. A line consists
 // of the string representation of the uid of the user wishing to start hakurei containers,
-// followed by a space, followed by the string representation of its userid. Duplicate uid
-// entries are ignored, with the first occurrence taking effect.
+// followed by a space, followed by the string representation of its userid, optionally followed
+// by a space and the first id of its subordinate block, interpreted by parseSubordinateConfig.
+// Duplicate uid entries are ignored, with the first occurrence taking effect.
 //
 // All string representations are parsed by calling parseUint32Fast.
 func parseConfig(r io.Reader, puid uint32) (userid uint32, ok bool, err error) {
@@ -57,9 +58,9 @@ func parseConfig(r io.Reader, puid uint32) (userid uint32, ok bool, err error) {
 	for s.Scan() {
 		line++
 
-		// <puid> <userid>
-		lf := strings.SplitN(s.Text(), " ", 2)
-		if len(lf) != 2 {
+		// <puid> <userid> [<subordinate block>]
+		lf := strings.Split(s.Text(), " ")
+		if len(lf) != 2 && len(lf) != 3 {
 			return useridEnd + 1, false, fmt.Errorf("invalid entry on line %d", line)
 		}
 
@@ -81,6 +82,63 @@ func parseConfig(r io.Reader, puid uint32) (userid uint32, ok bool, err error) {
 	return useridEnd + 1, false, s.Err()
 }
 
+// parseSubordinateConfig reads every entry from r and returns the first id of the subordinate block
+// allocated to userid. The block of a userid starts at the optional third field of its first entry,
+// or at the default returned by toSubordinateBlock. Blocks of different userids must not overlap
+// each other or the range of app user ids, so the subordinate ids of every identity are disjoint.
+//
+// All string representations are parsed by calling parseUint32Fast.
+func parseSubordinateConfig(r io.Reader, userid uint32) (block uint32, err error) {
+	blocks := make(map[uint32]uint32)
+	s := bufio.NewScanner(r)
+	var line uintptr
+	for s.Scan() {
+		line++
+
+		lf := strings.Split(s.Text(), " ")
+		if len(lf) != 2 && len(lf) != 3 {
+			return 0, fmt.Errorf("invalid entry on line %d", line)
+		}
+		var u, b uint32
+		if u, err = parseUint32Fast(lf[1]); err != nil || u < useridStart || u > useridEnd {
+			return 0, fmt.Errorf("invalid userid on line %d", line)
+		}
+		if _, ok := blocks[u]; ok {
+			continue
+		}
+
+		if len(lf) == 3 {
+			if b, err = parseUint32Fast(lf[2]); err != nil || b > 1<<32-1-subordinateBlock {
+				return 0, fmt.Errorf("invalid subordinate block on line %d", line)
+			}
+		} else if v, ok := toSubordinateBlock(u); ok {
+			b = v
+		} else {
+			// only an error if this block is requested
+			continue
+		}
+
+		if b < toUser(useridEnd, identityEnd)+1 && b+subordinateBlock > toUser(useridStart, identityStart) {
+			return 0, fmt.Errorf("subordinate block on line %d overlaps app user ids", line)
+		}
+		for u0, b0 := range blocks {
+			if b < b0+subordinateBlock && b0 < b+subordinateBlock {
+				return 0, fmt.Errorf("subordinate block on line %d overlaps the block of userid %d", line, u0)
+			}
+		}
+		blocks[u] = b
+	}
+	if err = s.Err(); err != nil {
+		return 0, err
+	}
+
+	var ok bool
+	if block, ok = blocks[userid]; !ok {
+		return 0, fmt.Errorf("no subordinate block for userid %d, it must be configured in hsurc", userid)
+	}
+	return block, nil
+}
+
 // hsuConfPath is an absolute pathname to the hsu configuration file.
 // Its contents are interpreted by parseConfig.
 const hsuConfPath = "/etc/hsurc"
@@ -131,3 +189,16 @@ func mustReadIdentity() uint32 {
 		return identity
 	}
 }
+
+// mustParseSubordinateConfig calls parseSubordinateConfig to interpret the contents of hsuConfPath,
+// terminating the program if an error is encountered.
+func mustParseSubordinateConfig(userid uint32) (block uint32) {
+	if f, err := os.Open(hsuConfPath); err != nil {
+		log.Fatal(err)
+	} else if block, err = parseSubordinateConfig(f, userid); err != nil {
+		log.Fatal(err)
+	} else if err = f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	return
+}
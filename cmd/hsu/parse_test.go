@@ -79,7 +79,9 @@ func TestParseConfig(t *testing.T) {
 		{"invalid field", 0, useridEnd + 1, "invalid entry on line 1", `9`},
 		{"invalid puid", 0, useridEnd + 1, "invalid parent uid on line 1", `f 9`},
 		{"invalid userid", 1000, useridEnd + 1, "invalid userid on line 1", `1000 f`},
+		{"invalid fields", 0, useridEnd + 1, "invalid entry on line 1", `1000 0 1 2`},
 		{"match", 1000, 0, "", `1000 0`},
+		{"match subordinate", 1000, 1, "", `1000 1 1073741824`},
 	}
 
 	for _, tc := range testCases {
@@ -105,3 +107,46 @@ func TestParseConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSubordinateConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		userid  uint32
+		want    uint32
+		wantErr string
+		rc      string
+	}{
+		{"empty", 0, 0, "no subordinate block for userid 0, it must be configured in hsurc", ``},
+		{"invalid field", 0, 0, "invalid entry on line 1", `9`},
+		{"invalid userid", 0, 0, "invalid userid on line 1", `1000 f`},
+		{"invalid block", 0, 0, "invalid subordinate block on line 1", `1000 0 f`},
+		{"block overflow", 0, 0, "invalid subordinate block on line 1", `1000 0 4294967295`},
+		{"default", 10, subordinateStart + 10*subordinateBlock, "", "1000 0\n1001 10"},
+		{"default last", 837, subordinateStart + 837*subordinateBlock, "", `1000 837`},
+		{"default unavailable", 838, 0, "no subordinate block for userid 838, it must be configured in hsurc", `1000 838`},
+		{"default unavailable other", 0, subordinateStart, "", "1000 838\n1001 0"},
+		{"explicit", 838, 1 << 30, "", `1000 838 1073741824`},
+		{"duplicate", 0, 1 << 30, "", "1000 0 1073741824\n1001 0 1"},
+		{"overlap app", 0, 0, "subordinate block on line 1 overlaps app user ids", `1000 0 1000000`},
+		{"overlap app end", 0, 0, "subordinate block on line 1 overlaps app user ids", `1000 0 999919998`},
+		{"overlap", 0, 0, "subordinate block on line 2 overlaps the block of userid 1", "1000 1 1073741824\n1001 0 1073741825"},
+		{"overlap default", 0, 0, "subordinate block on line 2 overlaps the block of userid 1", "1000 1\n1001 0 " + strconv.Itoa(subordinateStart+subordinateBlock+subordinateBlock-1)},
+		{"adjacent", 0, 1<<30 + subordinateBlock, "", "1000 1 1073741824\n1001 0 " + strconv.Itoa(1<<30+subordinateBlock)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			block, err := parseSubordinateConfig(bytes.NewBufferString(tc.rc), tc.userid)
+			if (err == nil && tc.wantErr != "") || (err != nil && err.Error() != tc.wantErr) {
+				t.Fatalf("parseSubordinateConfig: error = %v; want %q", err, tc.wantErr)
+			}
+			if block != tc.want {
+				t.Errorf("parseSubordinateConfig: %d; want %d", block, tc.want)
+			}
+		})
+	}
+}
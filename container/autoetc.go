@@ -3,6 +3,7 @@ package container
 import (
 	"encoding/gob"
 	"fmt"
+	"path"
 
 	"hakurei.app/container/check"
 	"hakurei.app/container/fhs"
@@ -11,6 +12,7 @@ import (
 func init() { gob.Register(new(AutoEtcOp)) }
 
 // Etc appends an [Op] that expands host /etc into a toplevel symlink mirror with /etc semantics.
+// Entries placed in /etc by a [TmpfileOp] are not mirrored.
 // This is not a generic setup op. It is implemented here to reduce ipc overhead.
 func (f *Ops) Etc(host *check.Absolute, prefix string) *Ops {
 	e := &AutoEtcOp{prefix}
@@ -33,6 +35,18 @@ func (e *AutoEtcOp) apply(state *setupState, k syscallDispatcher) error {
 	const target = sysrootPath + fhs.Etc
 	rel := e.hostRel() + "/"
 
+	// generated in place of their host counterparts
+	placed := make(map[string]struct{})
+	if state.Ops != nil {
+		for _, op := range *state.Ops {
+			if t, ok := op.(*TmpfileOp); ok && t.Valid() {
+				if dir, name := path.Split(t.Path.String()); dir == fhs.Etc {
+					placed[name] = struct{}{}
+				}
+			}
+		}
+	}
+
 	if err := k.mkdirAll(target, 0755); err != nil {
 		return err
 	}
@@ -41,6 +55,9 @@ func (e *AutoEtcOp) apply(state *setupState, k syscallDispatcher) error {
 	} else {
 		for _, ent := range d {
 			n := ent.Name()
+			if _, ok := placed[n]; ok {
+				continue
+			}
 			switch n {
 			case ".host", "passwd", "group":
 
@@ -50,6 +50,18 @@ func TestAutoEtcOp(t *testing.T) {
 			call("symlink", stub.ExpectArgs{".host/81ceabb30d37bbdb3868004629cb84e9/alsa", "/sysroot/etc/alsa"}, nil, stub.UniqueError(1)),
 		}, stub.UniqueError(1)},
 
+		{"placed", &Params{Ops: new(Ops).
+			Place(check.MustAbs("/etc/hosts"), nil).
+			Place(check.MustAbs("/etc/machine-id"), nil).
+			Place(check.MustAbs("/etc/ssl/certs/ca-certificates.crt"), nil)}, &AutoEtcOp{
+			Prefix: "81ceabb30d37bbdb3868004629cb84e9",
+		}, nil, nil, []stub.Call{
+			call("mkdirAll", stub.ExpectArgs{"/sysroot/etc/", os.FileMode(0755)}, nil, nil),
+			call("readdir", stub.ExpectArgs{"/sysroot/etc/.host/81ceabb30d37bbdb3868004629cb84e9"}, stubDir(".host",
+				"hosts", "machine-id", "passwd", "ssl"), nil),
+			call("symlink", stub.ExpectArgs{".host/81ceabb30d37bbdb3868004629cb84e9/ssl", "/sysroot/etc/ssl"}, nil, nil),
+		}, nil},
+
 		{"symlink mtab", new(Params), &AutoEtcOp{
 			Prefix: "81ceabb30d37bbdb3868004629cb84e9",
 		}, nil, nil, []stub.Call{
@@ -12,11 +12,33 @@ const (
 	PR_CAP_AMBIENT_RAISE     = 0x2
 	PR_CAP_AMBIENT_CLEAR_ALL = 0x4
 
-	CAP_SYS_ADMIN    = 0x15
-	CAP_SETPCAP      = 0x8
-	CAP_DAC_OVERRIDE = 0x1
+	CAP_SYS_ADMIN       = 0x15
+	CAP_SETPCAP         = 0x8
+	CAP_DAC_OVERRIDE    = 0x1
+	CAP_SYS_TIME        = 0x19
+	CAP_CHOWN           = 0x0
+	CAP_DAC_READ_SEARCH = 0x2
+	CAP_FOWNER          = 0x3
+	CAP_FSETID          = 0x4
+	CAP_KILL            = 0x5
+	CAP_SETGID          = 0x6
+	CAP_SETUID          = 0x7
+	CAP_SETFCAP         = 0x1f
 )
 
+// fakeRootCaps are capabilities retained for [Params.FakeRoot].
+var fakeRootCaps = [...]uintptr{
+	CAP_CHOWN,
+	CAP_DAC_OVERRIDE,
+	CAP_DAC_READ_SEARCH,
+	CAP_FOWNER,
+	CAP_FSETID,
+	CAP_KILL,
+	CAP_SETGID,
+	CAP_SETUID,
+	CAP_SETFCAP,
+}
+
 type (
 	capHeader struct {
 		version uint32
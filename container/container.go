@@ -3,6 +3,7 @@ package container
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/gob"
 	"errors"
 	"fmt"
@@ -19,6 +20,7 @@ import (
 	"hakurei.app/container/fhs"
 	"hakurei.app/container/seccomp"
 	"hakurei.app/container/std"
+	"hakurei.app/hst"
 	"hakurei.app/message"
 )
 
@@ -47,6 +49,10 @@ type (
 		cancel context.CancelFunc
 		// closed after Wait returns
 		wait chan struct{}
+		// closed after report is populated, nil if Report is false
+		reportDone chan struct{}
+		// received from init, only valid after reportDone is closed
+		report *hst.ExitReport
 
 		Stdin  io.Reader
 		Stdout io.Writer
@@ -69,17 +75,46 @@ type (
 		Env []string
 		// Pathname of initial process in the container.
 		Path *check.Absolute
+		// SHA-256 digest of the file at Path, nil to skip verification. If set, init copies the file to a
+		// sealed memfd while verifying it, and executes the copy via execveat on a file descriptor placed
+		// past all extra files instead of resolving Path again. This requires procfs to be mounted at /proc
+		// in the container.
+		PathDigest *[sha256.Size]byte
+		// SHA-256 digest of the interpreter named by the interpreter line of the file at Path. Required
+		// if PathDigest is set and the file is a script, in which case the verified interpreter is executed
+		// with the verified script passed by its file descriptor.
+		InterpreterDigest *[sha256.Size]byte
 		// Initial process argv.
 		Args []string
 		// Deliver SIGINT to the initial process on context cancellation.
 		ForwardCancel bool
+		// Steps applied in order on context cancellation, takes precedence over ForwardCancel.
+		Termination []TerminationStep
 		// Time to wait for processes lingering after the initial process terminates.
 		AdoptWaitDelay time.Duration
+		// Programs supervised alongside the initial process, started in order before it.
+		Programs []Program
 
 		// Mapped Uid in user namespace.
 		Uid int
 		// Mapped Gid in user namespace.
 		Gid int
+		// Map HostUid and HostGid to 0 instead of Uid and Gid, and retain the capabilities required to
+		// act as root on files in the user namespace. These are only held by processes running as root.
+		FakeRoot bool
+		// Leave uid_map, setgroups and gid_map to the caller, which must write them in
+		// between [Container.Start] and [Container.Serve] for the process returned by [Container.Pid].
+		MapExternal bool
+		// Pid of a process whose user, network, IPC and UTS namespaces, and the pid namespace it creates
+		// children in, are joined instead of creating new ones, zero to create every namespace. The id
+		// maps and hostname of the joined namespaces are retained, HostNet has no effect, and Time must
+		// be nil. Init is not pid 1 of the joined pid namespace, and kills its lingering descendants
+		// instead of relying on the kernel to do so when it terminates.
+		Join int
+		// Start time of the process specified by Join in clock ticks since boot, as reported in
+		// proc_pid_stat(5). The process is only joined if its start time matches, so a reused
+		// pid is never joined. Must be set if Join is not zero.
+		JoinStartTime uint64
 		// Hostname value in UTS namespace.
 		Hostname string
 		// Sequential container setup ops.
@@ -99,12 +134,19 @@ type (
 		ParentPerm os.FileMode
 		// Do not syscall.Setsid.
 		RetainSession bool
+		// Make standard input the controlling terminal of the container and place the
+		// initial process in its foreground process group. Has no effect if RetainSession is true.
+		Ctty bool
 		// Do not [syscall.CLONE_NEWNET].
 		HostNet bool
+		// Clock offsets applied in a new [syscall.CLONE_NEWTIME], nil to share the time namespace.
+		Time *TimeOffsets
 		// Do not [LANDLOCK_SCOPE_ABSTRACT_UNIX_SOCKET].
 		HostAbstract bool
 		// Retain CAP_SYS_ADMIN.
 		Privileged bool
+		// Receive an [hst.ExitReport] from init, made available via [Container.ExitReport].
+		Report bool
 	}
 )
 
@@ -209,16 +251,27 @@ func (p *Container) Start() error {
 		return errors.New("container: already started")
 	}
 
+	if p.Join != 0 && p.Time != nil {
+		return errors.New("container: cannot join namespaces with clock offsets")
+	}
+	if p.Join != 0 && p.JoinStartTime == 0 {
+		return errors.New("container: cannot join namespaces of a process with unknown start time")
+	}
+
 	if err := ensureCloseOnExec(); err != nil {
 		return err
 	}
 
-	// map to overflow id to work around ownership checks
-	if p.Uid < 1 {
-		p.Uid = OverflowUid(p.msg)
-	}
-	if p.Gid < 1 {
-		p.Gid = OverflowGid(p.msg)
+	if p.FakeRoot {
+		p.Uid, p.Gid = 0, 0
+	} else {
+		// map to overflow id to work around ownership checks
+		if p.Uid < 1 {
+			p.Uid = OverflowUid(p.msg)
+		}
+		if p.Gid < 1 {
+			p.Gid = OverflowGid(p.msg)
+		}
 	}
 
 	if !p.RetainSession {
@@ -268,12 +321,21 @@ func (p *Container) Start() error {
 
 		UseCgroupFD: p.Cgroup != nil,
 	}
+	if p.Ctty && !p.RetainSession {
+		// standard input is always the first descriptor in the child
+		p.cmd.SysProcAttr.Setctty = true
+		p.cmd.SysProcAttr.Ctty = 0
+	}
 	if p.cmd.SysProcAttr.UseCgroupFD {
 		p.cmd.SysProcAttr.CgroupFD = *p.Cgroup
 	}
 	if !p.HostNet {
 		p.cmd.SysProcAttr.Cloneflags |= CLONE_NEWNET
 	}
+	if p.Time != nil {
+		// the time namespace is created by init, as it is entered on execve
+		p.cmd.SysProcAttr.AmbientCaps = append(p.cmd.SysProcAttr.AmbientCaps, CAP_SYS_TIME)
+	}
 
 	// place setup pipe before user supplied extra files, this is later restored by init
 	if fd, f, err := Setup(&p.cmd.ExtraFiles); err != nil {
@@ -282,8 +344,34 @@ func (p *Container) Start() error {
 		p.setup = f
 		p.cmd.Env = []string{setupEnv + "=" + strconv.Itoa(fd)}
 	}
+	if p.Join != 0 {
+		// joined and created by init before the Go runtime starts, see join.c
+		p.cmd.SysProcAttr.Cloneflags, p.cmd.SysProcAttr.AmbientCaps = 0, nil
+		p.cmd.Env = append(p.cmd.Env, joinEnv+"="+strconv.Itoa(p.Join)+":"+strconv.FormatUint(p.JoinStartTime, 10))
+	}
 	p.cmd.ExtraFiles = append(p.cmd.ExtraFiles, p.ExtraFiles...)
 
+	// place report pipe after user supplied extra files, init locates it via the extra files count
+	var reportW *os.File
+	if p.Report {
+		if r, w, err := os.Pipe(); err != nil {
+			return &StartError{true, "set up exit report pipe", err, false, false}
+		} else {
+			reportW = w
+			p.cmd.ExtraFiles = append(p.cmd.ExtraFiles, w)
+
+			p.reportDone = make(chan struct{})
+			go func() {
+				defer close(p.reportDone)
+				if report, err := receiveReport(r); err != nil {
+					p.msg.Verbosef("cannot receive exit report: %v", err)
+				} else {
+					p.report = report
+				}
+			}()
+		}
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		runtime.LockOSThread()
@@ -346,7 +434,14 @@ func (p *Container) Start() error {
 		// keep this thread alive until Wait returns for cancel
 		<-p.wait
 	}()
-	return <-done
+	err := <-done
+	if reportW != nil {
+		// the only remaining write end is held by init
+		if closeErr := reportW.Close(); closeErr != nil {
+			p.msg.Verbose(closeErr.Error())
+		}
+	}
+	return err
 }
 
 // Serve serves [Container.Params] to the container init.
@@ -366,6 +461,12 @@ func (p *Container) Serve() error {
 		p.cancel()
 		return &StartError{false, "invalid executable pathname", EINVAL, true, false}
 	}
+	for i := range p.Programs {
+		if p.Programs[i].Path == nil {
+			p.cancel()
+			return &StartError{false, "invalid supervised program pathname", EINVAL, true, false}
+		}
+	}
 
 	// do not transmit nil
 	if p.Dir == nil {
@@ -400,9 +501,27 @@ func (p *Container) Wait() error {
 	if p.wait != nil && err == nil {
 		close(p.wait)
 	}
+	if p.reportDone != nil {
+		<-p.reportDone
+	}
 	return err
 }
 
+// ExitReport returns the [hst.ExitReport] sent by init, with Code set to its exit code and Signal and Killed
+// left unset. ExitReport returns nil if [Params.Report] is false, if init terminated without sending a report,
+// or if called before Wait returns.
+func (p *Container) ExitReport() *hst.ExitReport {
+	if p.reportDone == nil {
+		return nil
+	}
+	select {
+	case <-p.reportDone:
+		return p.report
+	default:
+		return nil
+	}
+}
+
 // StdinPipe calls the [exec.Cmd] method with the same name.
 func (p *Container) StdinPipe() (w io.WriteCloser, err error) {
 	if p.Stdin != nil {
@@ -446,6 +565,14 @@ func (p *Container) ProcessState() *os.ProcessState {
 	return p.cmd.ProcessState
 }
 
+// Pid returns the pid of the container init process, or -1 if the container is not started.
+func (p *Container) Pid() int {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return -1
+	}
+	return p.cmd.Process.Pid
+}
+
 // New returns the address to a new instance of [Container] that requires further initialisation before use.
 func New(ctx context.Context, msg message.Msg) *Container {
 	if msg == nil {
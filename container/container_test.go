@@ -428,6 +428,39 @@ func TestContainer(t *testing.T) {
 		}
 	}))
 
+	t.Run("time", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(t.Context(), helperDefaultTimeout)
+		defer cancel()
+
+		c := helperNewContainer(ctx, "timens")
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		c.WaitDelay = helperDefaultTimeout
+		c.Time = &container.TimeOffsets{Monotonic: helperTimeOffset / 2, Boottime: helperTimeOffset}
+		c.Proc(check.MustAbs("/proc"))
+
+		if err := c.Start(); err != nil {
+			if m, ok := container.InternalMessageFromError(err); ok {
+				t.Fatal(m)
+			} else {
+				t.Fatalf("cannot start container: %v", err)
+			}
+		} else if err = c.Serve(); err != nil {
+			if m, ok := container.InternalMessageFromError(err); ok {
+				t.Error(m)
+			} else {
+				t.Errorf("cannot serve setup params: %v", err)
+			}
+		}
+		if err := c.Wait(); err != nil {
+			if m, ok := container.InternalMessageFromError(err); ok {
+				t.Fatal(m)
+			} else {
+				t.Fatalf("wait: %v", err)
+			}
+		}
+	})
+
 	for i, tc := range containerTestCases {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
@@ -625,6 +658,28 @@ func init() {
 			select {}
 		})
 
+		c.Command("timens", command.UsageInternal, func(args []string) error {
+			want := fmt.Sprintf("monotonic %d 0\nboottime %d 0\n",
+				helperTimeOffset/2/time.Second, helperTimeOffset/time.Second)
+			if p, err := os.ReadFile("/proc/self/timens_offsets"); err != nil {
+				return fmt.Errorf("cannot read clock offsets: %v", err)
+			} else if string(p) != want {
+				return fmt.Errorf("timens_offsets: %q, want %q", string(p), want)
+			}
+
+			// uptime is derived from CLOCK_BOOTTIME and includes its offset
+			if p, err := os.ReadFile("/proc/uptime"); err != nil {
+				return fmt.Errorf("cannot read uptime: %v", err)
+			} else if uptime, _, _ := strings.Cut(string(p), " "); uptime == "" {
+				return fmt.Errorf("invalid uptime %q", string(p))
+			} else if v, parseErr := strconv.ParseFloat(uptime, 64); parseErr != nil {
+				return fmt.Errorf("cannot parse uptime: %v", parseErr)
+			} else if time.Duration(v*float64(time.Second)) < helperTimeOffset {
+				return fmt.Errorf("uptime: %s, want at least %s", uptime, helperTimeOffset)
+			}
+			return nil
+		})
+
 		c.Command("container", command.UsageInternal, func(args []string) error {
 			if len(args) != 1 {
 				return syscall.EINVAL
@@ -727,6 +782,7 @@ const (
 	envDoCheck = "HAKUREI_TEST_DO_CHECK"
 
 	helperDefaultTimeout = 5 * time.Second
+	helperTimeOffset     = 1 << 10 * time.Hour
 	helperInnerPath      = "/usr/bin/helper"
 )
 
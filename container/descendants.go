@@ -0,0 +1,96 @@
+package container
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	. "syscall"
+	"time"
+
+	"hakurei.app/container/fhs"
+	"hakurei.app/message"
+)
+
+const (
+	// killDescendantsRounds is the number of times killDescendants looks for children of init.
+	killDescendantsRounds = 1 << 6
+	// killDescendantsInterval is the time killDescendants waits for killed processes to terminate.
+	killDescendantsInterval = 10 * time.Millisecond
+)
+
+// parseStat returns the state and parent pid held by the contents of a proc_pid_stat(5) file.
+func parseStat(stat []byte) (state byte, ppid int, ok bool) {
+	// comm may contain spaces and parentheses, so fields are counted from the last one
+	i := bytes.LastIndexByte(stat, ')')
+	if i == -1 {
+		return
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 2 || len(fields[0]) != 1 {
+		return
+	}
+	var err error
+	if ppid, err = strconv.Atoi(string(fields[1])); err != nil {
+		return
+	}
+	return fields[0][0], ppid, true
+}
+
+// childProcesses returns the pids of processes visible in procfs whose parent is initPid,
+// excluding processes that already terminated.
+func childProcesses(k syscallDispatcher, initPid int) (pids []int, err error) {
+	var entries []os.DirEntry
+	if entries, err = k.readdir(fhs.Proc); err != nil {
+		return
+	}
+
+	for _, ent := range entries {
+		pid, convErr := strconv.Atoi(ent.Name())
+		if convErr != nil || pid == initPid {
+			continue
+		}
+
+		f, openErr := k.openNew(fhs.Proc + ent.Name() + "/stat")
+		if openErr != nil {
+			// terminated and reaped
+			continue
+		}
+		data, readErr := io.ReadAll(io.LimitReader(f, 1<<10))
+		_ = f.Close()
+		if readErr != nil {
+			continue
+		}
+
+		if state, ppid, ok := parseStat(data); ok && ppid == initPid && state != 'Z' && state != 'X' {
+			pids = append(pids, pid)
+		}
+	}
+	return
+}
+
+// killDescendants kills every process started by init, identified by initPid, and every process
+// started by those. This is only required if init is not pid 1 of its pid namespace, as lingering
+// processes would otherwise outlive the container. Init is a child subreaper in that case, so every
+// descendant is reparented to init once its parent terminates, and is killed by a later round.
+func killDescendants(k syscallDispatcher, msg message.Msg, initPid int) {
+	for i := 0; i < killDescendantsRounds; i++ {
+		pids, err := childProcesses(k, initPid)
+		if err != nil {
+			k.printf(msg, "cannot enumerate lingering processes: %v", err)
+			return
+		}
+		if len(pids) == 0 {
+			return
+		}
+
+		for _, pid := range pids {
+			if err = k.kill(pid, SIGKILL); err != nil && !errors.Is(err, ESRCH) {
+				k.printf(msg, "cannot kill lingering process %d: %v", pid, err)
+			}
+		}
+		time.Sleep(killDescendantsInterval)
+	}
+	k.printf(msg, "lingering processes remain after %d rounds", killDescendantsRounds)
+}
@@ -0,0 +1,95 @@
+package container
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/stub"
+)
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		stat  string
+		state byte
+		ppid  int
+		ok    bool
+	}{
+		{"empty", "", 0, 0, false},
+		{"short", "2 (sh) S", 0, 0, false},
+		{"state", "2 (sh) SS 1 2", 0, 0, false},
+		{"ppid", "2 (sh) S one 2", 0, 0, false},
+		{"running", "2 (sh) R 1 2 2 0 -1", 'R', 1, true},
+		{"comm", "3 (a) Z (b) ) S 2 3 3 0 -1", 'S', 2, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if state, ppid, ok := parseStat([]byte(tc.stat)); state != tc.state || ppid != tc.ppid || ok != tc.ok {
+				t.Errorf("parseStat: %q, %d, %v, want %q, %d, %v", state, ppid, ok, tc.state, tc.ppid, tc.ok)
+			}
+		})
+	}
+}
+
+func TestChildProcesses(t *testing.T) {
+	t.Parallel()
+
+	checkSimple(t, "childProcesses", []simpleTestCase{
+		{"readdir", func(k *kstub) error {
+			_, err := childProcesses(k, 0xcafe)
+			return err
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir(), stub.UniqueError(0)),
+		}}, stub.UniqueError(0)},
+
+		{"success", func(k *kstub) error {
+			if pids, err := childProcesses(k, 0xcafe); err != nil {
+				return err
+			} else if want := []int{0xbad}; !reflect.DeepEqual(pids, want) {
+				t.Errorf("childProcesses: %v, want %v", pids, want)
+			}
+			return nil
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("1", "self", "51966", "2989", "2990", "2991", "2992", "2993"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/1/stat"}, newConstFile("1 (init) S 0 1 1 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/stat"}, newConstFile("2989 (clangd) S 51966 2989 2989 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2990/stat"}, newConstFile("2990 (zsh) Z 51966 2990 2990 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2991/stat"}, (*readerOsFile)(nil), stub.UniqueError(1)),
+			call("openNew", stub.ExpectArgs{"/proc/2992/stat"}, newConstFile("2992 (vim) S 2989 2989 2989 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2993/stat"}, newConstFile(""), nil),
+		}}, nil},
+	})
+}
+
+func TestKillDescendants(t *testing.T) {
+	t.Parallel()
+
+	checkSimple(t, "killDescendants", []simpleTestCase{
+		{"readdir", func(k *kstub) error { killDescendants(k, k, 0xcafe); return nil }, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir(), stub.UniqueError(0)),
+			call("printf", stub.ExpectArgs{"cannot enumerate lingering processes: %v", []any{stub.UniqueError(0)}}, nil, nil),
+		}}, nil},
+
+		{"success", func(k *kstub) error { killDescendants(k, k, 0xcafe); return nil }, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("2989", "2990"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/stat"}, newConstFile("2989 (clangd) S 51966 2989 2989 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2990/stat"}, newConstFile("2990 (vim) S 51966 2990 2990 0 -1"), nil),
+			call("kill", stub.ExpectArgs{0xbad, syscall.SIGKILL}, nil, syscall.ESRCH),
+			call("kill", stub.ExpectArgs{0xbae, syscall.SIGKILL}, nil, stub.UniqueError(1)),
+			call("printf", stub.ExpectArgs{"cannot kill lingering process %d: %v", []any{0xbae, stub.UniqueError(1)}}, nil, nil),
+
+			// reparented to init once its parent terminated
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("2989", "2991"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/stat"}, newConstFile("2989 (clangd) Z 51966 2989 2989 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2991/stat"}, newConstFile("2991 (sh) S 51966 2991 2991 0 -1"), nil),
+			call("kill", stub.ExpectArgs{0xbaf, syscall.SIGKILL}, nil, nil),
+
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("2989"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/stat"}, newConstFile("2989 (clangd) Z 51966 2989 2989 0 -1"), nil),
+		}}, nil},
+	})
+}
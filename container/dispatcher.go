@@ -37,6 +37,8 @@ type syscallDispatcher interface {
 	setDumpable(dumpable uintptr) error
 	// setNoNewPrivs provides [SetNoNewPrivs].
 	setNoNewPrivs() error
+	// unshare provides [syscall.Unshare].
+	unshare(flags int) error
 
 	// lastcap provides [LastCap].
 	lastcap(msg message.Msg) uintptr
@@ -70,6 +72,10 @@ type syscallDispatcher interface {
 	start(c *exec.Cmd) error
 	// signal signals the underlying process of [os/exec.Cmd].
 	signal(c *exec.Cmd, sig os.Signal) error
+	// signalGroup signals the process group led by the underlying process of [os/exec.Cmd].
+	signalGroup(c *exec.Cmd, sig syscall.Signal) error
+	// kill provides [syscall.Kill].
+	kill(pid int, sig syscall.Signal) error
 	// evalSymlinks provides [filepath.EvalSymlinks].
 	evalSymlinks(path string) (string, error)
 
@@ -114,6 +120,10 @@ type syscallDispatcher interface {
 	open(path string, mode int, perm uint32) (fd int, err error)
 	// close provides syscall.Close
 	close(fd int) (err error)
+	// memfdCreate provides [MemfdCreate].
+	memfdCreate(name string, flags uintptr) (fd int, err error)
+	// addSeals provides [AddSeals].
+	addSeals(fd int, seals uintptr) (err error)
 	// pivotRoot provides syscall.PivotRoot
 	pivotRoot(newroot, putold string) (err error)
 	// mount provides syscall.Mount
@@ -141,6 +151,7 @@ func (direct) lockOSThread() { runtime.LockOSThread() }
 func (direct) setPtracer(pid uintptr) error       { return SetPtracer(pid) }
 func (direct) setDumpable(dumpable uintptr) error { return SetDumpable(dumpable) }
 func (direct) setNoNewPrivs() error               { return SetNoNewPrivs() }
+func (direct) unshare(flags int) error            { return syscall.Unshare(flags) }
 
 func (direct) lastcap(msg message.Msg) uintptr                 { return LastCap(msg) }
 func (direct) capset(hdrp *capHeader, datap *[2]capData) error { return capset(hdrp, datap) }
@@ -171,7 +182,11 @@ func (direct) seccompLoad(rules []std.NativeRule, flags seccomp.ExportFlag) erro
 func (direct) notify(c chan<- os.Signal, sig ...os.Signal) { signal.Notify(c, sig...) }
 func (direct) start(c *exec.Cmd) error                     { return c.Start() }
 func (direct) signal(c *exec.Cmd, sig os.Signal) error     { return c.Process.Signal(sig) }
-func (direct) evalSymlinks(path string) (string, error)    { return filepath.EvalSymlinks(path) }
+func (direct) signalGroup(c *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-c.Process.Pid, sig)
+}
+func (direct) kill(pid int, sig syscall.Signal) error   { return syscall.Kill(pid, sig) }
+func (direct) evalSymlinks(path string) (string, error) { return filepath.EvalSymlinks(path) }
 
 func (direct) exit(code int)                                 { os.Exit(code) }
 func (direct) getpid() int                                   { return os.Getpid() }
@@ -210,6 +225,12 @@ func (direct) open(path string, mode int, perm uint32) (fd int, err error) {
 func (direct) close(fd int) (err error) {
 	return syscall.Close(fd)
 }
+func (direct) memfdCreate(name string, flags uintptr) (fd int, err error) {
+	return MemfdCreate(name, flags)
+}
+func (direct) addSeals(fd int, seals uintptr) (err error) {
+	return AddSeals(fd, seals)
+}
 func (direct) pivotRoot(newroot, putold string) (err error) {
 	return syscall.PivotRoot(newroot, putold)
 }
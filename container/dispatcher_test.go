@@ -316,7 +316,7 @@ const (
 	// magicWait4Signal must be used in a single pair of signal and wait4 calls across two goroutines
 	// originating from the same toplevel kstub.
 	// To enable this behaviour this value must be the last element of the args field in the wait4 call
-	// and the ret value of the signal call.
+	// and the ret value of the signal or signalGroup call, or the last element of the args field in the start call.
 	magicWait4Signal = 0xdef
 )
 
@@ -342,6 +342,11 @@ func (k *kstub) setDumpable(dumpable uintptr) error {
 }
 
 func (k *kstub) setNoNewPrivs() error { k.Helper(); return k.Expects("setNoNewPrivs").Err }
+func (k *kstub) unshare(flags int) error {
+	k.Helper()
+	return k.Expects("unshare").Error(
+		stub.CheckArg(k.Stub, "flags", flags, 0))
+}
 func (k *kstub) lastcap(msg message.Msg) uintptr {
 	k.Helper()
 	k.checkMsg(msg)
@@ -481,11 +486,24 @@ func (k *kstub) notify(c chan<- os.Signal, sig ...os.Signal) {
 func (k *kstub) start(c *exec.Cmd) error {
 	k.Helper()
 	expect := k.Expects("start")
+	attrOk := true
+	switch v := expect.Args[4].(type) {
+	case int:
+		if v == magicWait4Signal {
+			if k.wait4signal == nil {
+				panic("kstub not initialised for wait4 simulation")
+			}
+			defer func() { close(k.wait4signal) }()
+		}
+	case *syscall.SysProcAttr:
+		attrOk = stub.CheckArgReflect(k.Stub, "c.SysProcAttr", c.SysProcAttr, 4)
+	}
 	err := expect.Error(
 		stub.CheckArg(k.Stub, "c.Path", c.Path, 0),
 		stub.CheckArgReflect(k.Stub, "c.Args", c.Args, 1),
 		stub.CheckArgReflect(k.Stub, "c.Env", c.Env, 2),
-		stub.CheckArg(k.Stub, "c.Dir", c.Dir, 3))
+		stub.CheckArg(k.Stub, "c.Dir", c.Dir, 3),
+		attrOk)
 
 	if process, ok := expect.Ret.(*os.Process); ok && process != nil {
 		c.Process = process
@@ -510,6 +528,28 @@ func (k *kstub) signal(c *exec.Cmd, sig os.Signal) error {
 		stub.CheckArg(k.Stub, "sig", sig, 4))
 }
 
+func (k *kstub) signalGroup(c *exec.Cmd, sig syscall.Signal) error {
+	k.Helper()
+	expect := k.Expects("signalGroup")
+	if v, ok := expect.Ret.(int); ok && v == magicWait4Signal {
+		if k.wait4signal == nil {
+			panic("kstub not initialised for wait4 simulation")
+		}
+		defer func() { close(k.wait4signal) }()
+	}
+	return expect.Error(
+		stub.CheckArg(k.Stub, "c.Path", c.Path, 0),
+		stub.CheckArgReflect(k.Stub, "c.Args", c.Args, 1),
+		stub.CheckArg(k.Stub, "sig", sig, 2))
+}
+
+func (k *kstub) kill(pid int, sig syscall.Signal) error {
+	k.Helper()
+	return k.Expects("kill").Error(
+		stub.CheckArg(k.Stub, "pid", pid, 0),
+		stub.CheckArg(k.Stub, "sig", sig, 1))
+}
+
 func (k *kstub) evalSymlinks(path string) (string, error) {
 	k.Helper()
 	expect := k.Expects("evalSymlinks")
@@ -660,6 +700,21 @@ func (k *kstub) close(fd int) (err error) {
 		stub.CheckArg(k.Stub, "fd", fd, 0))
 }
 
+func (k *kstub) memfdCreate(name string, flags uintptr) (fd int, err error) {
+	k.Helper()
+	expect := k.Expects("memfdCreate")
+	return expect.Ret.(int), expect.Error(
+		stub.CheckArg(k.Stub, "name", name, 0),
+		stub.CheckArg(k.Stub, "flags", flags, 1))
+}
+
+func (k *kstub) addSeals(fd int, seals uintptr) (err error) {
+	k.Helper()
+	return k.Expects("addSeals").Error(
+		stub.CheckArg(k.Stub, "fd", fd, 0),
+		stub.CheckArg(k.Stub, "seals", seals, 1))
+}
+
 func (k *kstub) pivotRoot(newroot, putold string) (err error) {
 	k.Helper()
 	return k.Expects("pivotRoot").Error(
@@ -0,0 +1,83 @@
+#define _GNU_SOURCE
+#include "execfd.h"
+#include <errno.h>
+#include <fcntl.h>
+#include <limits.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#ifndef AT_EMPTY_PATH
+#define AT_EMPTY_PATH 0x1000
+#endif
+
+extern char **environ;
+
+static void hakurei_execfd_fatal(const char *step) {
+    fprintf(stderr, "init: cannot %s: %s\n", step, strerror(errno));
+    _exit(EXIT_FAILURE);
+}
+
+/* Executes the file descriptor specified by HAKUREI_EXECFD_ENV via execveat with AT_EMPTY_PATH,
+ * passing the arguments of the current process and its environment without HAKUREI_EXECFD_ENV.
+ * The Go runtime cannot call execveat between fork and exec, so container init starts its own
+ * executable with this set to run the initial program it verified instead of resolving a path.
+ *
+ * Arguments are read from procfs as not every C library passes them to constructors. */
+__attribute__((constructor)) static void hakurei_execfd(void) {
+    const char *s = getenv(HAKUREI_EXECFD_ENV);
+    if (s == NULL)
+        return;
+
+    char *end;
+    errno = 0;
+    long fd = strtol(s, &end, 10);
+    if (errno == 0 && (*s == '\0' || *end != '\0' || fd < 3 || fd > INT_MAX))
+        errno = EINVAL;
+    if (errno != 0)
+        hakurei_execfd_fatal("parse " HAKUREI_EXECFD_ENV);
+    if (unsetenv(HAKUREI_EXECFD_ENV) != 0)
+        hakurei_execfd_fatal("unset " HAKUREI_EXECFD_ENV);
+
+    /* the verified file is not inherited by the program it holds */
+    if (fcntl((int)fd, F_SETFD, FD_CLOEXEC) != 0)
+        hakurei_execfd_fatal("set close-on-exec flag");
+
+    int cmdline = open("/proc/self/cmdline", O_RDONLY | O_CLOEXEC);
+    if (cmdline == -1)
+        hakurei_execfd_fatal("open arguments");
+    size_t cap = 1 << 12, len = 0;
+    char *buf = malloc(cap);
+    for (;;) {
+        if (buf == NULL)
+            hakurei_execfd_fatal("allocate arguments");
+        ssize_t n = read(cmdline, buf + len, cap - len);
+        if (n == -1) {
+            if (errno == EINTR)
+                continue;
+            hakurei_execfd_fatal("read arguments");
+        }
+        if (n == 0)
+            break;
+        if ((len += (size_t)n) == cap)
+            buf = realloc(buf, cap <<= 1);
+    }
+    close(cmdline);
+
+    size_t argc = 0;
+    for (size_t i = 0; i < len; i++)
+        if (buf[i] == '\0')
+            argc++;
+    char **argv = calloc(argc + 1, sizeof(char *));
+    if (argv == NULL)
+        hakurei_execfd_fatal("allocate arguments");
+    for (size_t i = 0, j = 0; j < argc; j++) {
+        argv[j] = buf + i;
+        i += strlen(buf + i) + 1;
+    }
+
+    syscall(SYS_execveat, (int)fd, "", argv, environ, AT_EMPTY_PATH);
+    hakurei_execfd_fatal("execute verified program");
+}
@@ -0,0 +1,8 @@
+package container
+
+//#include "execfd.h"
+import "C"
+
+// execFdEnv is the environment variable holding the file descriptor executed in place of the
+// current process via execveat. It is interpreted before the Go runtime starts, see execfd.c.
+const execFdEnv = C.HAKUREI_EXECFD_ENV
@@ -0,0 +1,2 @@
+/* see execfd.c for documentation */
+#define HAKUREI_EXECFD_ENV "HAKUREI_EXECFD"
@@ -14,6 +14,7 @@ import (
 
 	"hakurei.app/container/fhs"
 	"hakurei.app/container/seccomp"
+	"hakurei.app/hst"
 	"hakurei.app/message"
 )
 
@@ -105,9 +106,8 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 		panic("attempting to call initEntrypoint with nil msg")
 	}
 
-	if k.getpid() != 1 {
-		k.fatal(msg, "this process must run as pid 1")
-	}
+	// checked once setup parameters are received, as a joined pid namespace already has its pid 1
+	initPid := k.getpid()
 
 	if err := k.setPtracer(0); err != nil {
 		msg.Verbosef("cannot enable ptrace protection via Yama LSM: %v", err)
@@ -142,32 +142,54 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 		closeSetup = f
 		offsetSetup = int(setupFd + 1)
 	}
+	if initPid != 1 && params.Join == 0 {
+		k.fatal(msg, "this process must run as pid 1")
+	}
 
 	// write uid/gid map here so parent does not need to set dumpable
 	if err := k.setDumpable(SUID_DUMP_USER); err != nil {
 		k.fatalf(msg, "cannot set SUID_DUMP_USER: %v", err)
 	}
-	if err := k.writeFile(fhs.Proc+"self/uid_map",
-		append([]byte{}, strconv.Itoa(params.Uid)+" "+strconv.Itoa(params.HostUid)+" 1\n"...),
-		0); err != nil {
-		k.fatalf(msg, "%v", err)
-	}
-	if err := k.writeFile(fhs.Proc+"self/setgroups",
-		[]byte("deny\n"),
-		0); err != nil && !os.IsNotExist(err) {
-		k.fatalf(msg, "%v", err)
+	if params.Join != 0 {
+		// shared with the joined namespaces
+		msg.Verbose("joined user namespace of process", params.Join)
+	} else if params.MapExternal {
+		// written by the parent before setup parameters are sent
+		msg.Verbose("uid/gid map written externally")
+	} else {
+		if err := k.writeFile(fhs.Proc+"self/uid_map",
+			append([]byte{}, strconv.Itoa(params.Uid)+" "+strconv.Itoa(params.HostUid)+" 1\n"...),
+			0); err != nil {
+			k.fatalf(msg, "%v", err)
+		}
+		if err := k.writeFile(fhs.Proc+"self/setgroups",
+			[]byte("deny\n"),
+			0); err != nil && !os.IsNotExist(err) {
+			k.fatalf(msg, "%v", err)
+		}
+		if err := k.writeFile(fhs.Proc+"self/gid_map",
+			append([]byte{}, strconv.Itoa(params.Gid)+" "+strconv.Itoa(params.HostGid)+" 1\n"...),
+			0); err != nil {
+			k.fatalf(msg, "%v", err)
+		}
 	}
-	if err := k.writeFile(fhs.Proc+"self/gid_map",
-		append([]byte{}, strconv.Itoa(params.Gid)+" "+strconv.Itoa(params.HostGid)+" 1\n"...),
-		0); err != nil {
-		k.fatalf(msg, "%v", err)
+	if params.Time != nil {
+		// init remains in the parent time namespace, and processes it starts from this thread enter
+		// the new namespace on execve, so offsets are written before any process enters it;
+		// the namespace belongs to this thread, which is not necessarily the thread group leader
+		if err := k.unshare(CLONE_NEWTIME); err != nil {
+			k.fatalf(msg, "cannot create time namespace: %v", err)
+		}
+		if err := k.writeFile(fhs.Proc+"thread-self/timens_offsets", params.Time.data(), 0); err != nil {
+			k.fatalf(msg, "cannot set clock offsets: %v", err)
+		}
 	}
 	if err := k.setDumpable(SUID_DUMP_DISABLE); err != nil {
 		k.fatalf(msg, "cannot set SUID_DUMP_DISABLE: %v", err)
 	}
 
 	oldmask := k.umask(0)
-	if params.Hostname != "" {
+	if params.Hostname != "" && params.Join == 0 {
 		if err := k.sethostname([]byte(params.Hostname)); err != nil {
 			k.fatalf(msg, "cannot set hostname: %v", err)
 		}
@@ -284,16 +306,25 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 	if err := k.capAmbientClearAll(); err != nil {
 		k.fatalf(msg, "cannot clear the ambient capability set: %v", err)
 	}
+	var keep [2]uint32
+	if params.FakeRoot {
+		// gained by the initial process on execve as root, up to the permitted set under no_new_privs
+		for _, c := range fakeRootCaps {
+			keep[capToIndex(c)] |= capToMask(c)
+		}
+	}
 	for i := uintptr(0); i <= lastcap; i++ {
 		if params.Privileged && i == CAP_SYS_ADMIN {
 			continue
 		}
+		if keep[capToIndex(i)]&capToMask(i) != 0 {
+			continue
+		}
 		if err := k.capBoundingSetDrop(i); err != nil {
 			k.fatalf(msg, "cannot drop capability from bounding set: %v", err)
 		}
 	}
 
-	var keep [2]uint32
 	if params.Privileged {
 		keep[capToIndex(CAP_SYS_ADMIN)] |= capToMask(CAP_SYS_ADMIN)
 
@@ -328,18 +359,63 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 		// setup fd is placed before all extra files
 		extraFiles[i] = k.newFile(uintptr(offsetSetup+i), "extra file "+strconv.Itoa(i))
 	}
+	var (
+		report     *hst.ExitReport
+		reportFile *os.File
+	)
+	if params.Report {
+		report = new(hst.ExitReport)
+		// report pipe is placed after all extra files
+		reportFile = k.newFile(uintptr(offsetSetup+params.Count), "exit report")
+		CloseOnExec(int(reportFile.Fd()))
+	}
 	k.umask(oldmask)
 
 	if err := closeSetup(); err != nil {
 		k.fatalf(msg, "cannot close setup pipe: %v", err)
 	}
 
-	cmd := exec.Command(params.Path.String())
+	pathname, args, env := params.Path.String(), params.Args, params.Env
+	if params.PathDigest != nil {
+		// verified files are placed after all extra files
+		if files, trampolineArgs, trampolineEnv, err := openVerifiedProgram(k, msg, &params.Params, 3+len(extraFiles)); err != nil {
+			k.fatalf(msg, "cannot verify initial program: %v", err)
+		} else {
+			pathname, args, env = execTrampoline, trampolineArgs, append(slices.Clip(env), trampolineEnv)
+			extraFiles = append(extraFiles, files...)
+		}
+	}
+
+	cmd := exec.Command(pathname)
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
-	cmd.Args = params.Args
-	cmd.Env = params.Env
+	cmd.Args = args
+	cmd.Env = env
 	cmd.ExtraFiles = extraFiles
 	cmd.Dir = params.Dir.String()
+	if params.Ctty && !params.RetainSession {
+		// terminal-generated signals are delivered to the initial process instead of init
+		cmd.SysProcAttr = &SysProcAttr{Foreground: true, Ctty: 0}
+	} else if hasGroupStep(params.Termination) {
+		// the initial process leads its own process group to be signalled as a whole
+		cmd.SysProcAttr = &SysProcAttr{Setpgid: true}
+		if params.RetainSession && k.isatty(0) {
+			// a background process group is stopped by SIGTTIN on its first read from the terminal
+			cmd.SysProcAttr = &SysProcAttr{Foreground: true, Ctty: 0}
+		}
+	}
+
+	programs := make([]*supervisedProgram, len(params.Programs))
+	for i := range params.Programs {
+		p := &supervisedProgram{Program: &params.Programs[i]}
+		programs[i] = p
+
+		p.cmd = p.newCmd(&params.Params)
+		msg.Verbosef("starting supervised program %s", p.Path)
+		if err := k.start(p.cmd); err != nil {
+			k.fatalf(msg, "%v", err)
+		}
+		p.started = time.Now()
+	}
 
 	msg.Verbosef("starting initial program %s", params.Path)
 	if err := k.start(cmd); err != nil {
@@ -349,6 +425,7 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 	type winfo struct {
 		wpid    int
 		wstatus WaitStatus
+		rusage  *Rusage
 	}
 
 	// info is closed as the wait4 thread terminates
@@ -362,6 +439,7 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 			err     error
 			wpid    = -2
 			wstatus WaitStatus
+			rusage  *Rusage
 		)
 
 		// keep going until no child process is left
@@ -371,12 +449,17 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 			}
 
 			if wpid != -2 {
-				info <- winfo{wpid, wstatus}
+				info <- winfo{wpid, wstatus, rusage}
+			}
+
+			// only collected for the exit report
+			if report != nil {
+				rusage = new(Rusage)
 			}
 
 			err = EINTR
 			for errors.Is(err, EINTR) {
-				wpid, err = k.wait4(-1, &wstatus, 0, nil)
+				wpid, err = k.wait4(-1, &wstatus, 0, rusage)
 			}
 		}
 		if !errors.Is(err, ECHILD) {
@@ -393,16 +476,82 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 
 	// closed after residualProcessTimeout has elapsed after initial process death
 	timeout := make(chan struct{})
+	// receives index of supervised program to start again
+	restart := make(chan int, len(programs))
+	// set once the container is terminating, supervised programs are no longer started again
+	terminating := false
+	// exit code of the critical program terminating the container, or -1
+	rCritical := -1
+	// receives index of the next termination step
+	escalate := make(chan int, 1)
+	// set once the termination sequence started
+	escalating := false
+	// set once the initial process is reaped, remaining termination steps are skipped
+	exited := false
+	// applyStep applies termination step i and schedules the next step
+	applyStep := func(i int) {
+		step := &params.Termination[i]
+		if step.Signal != 0 {
+			msg.Verbosef("termination step %d: delivering %s", i, step.Signal)
+			var err error
+			if step.Group {
+				err = k.signalGroup(cmd, step.Signal)
+			} else {
+				err = k.signal(cmd, step.Signal)
+			}
+			if err != nil {
+				k.printf(msg, "cannot deliver termination signal: %v", err)
+			}
+			forwardSupervised(k, msg, programs, step.Signal)
+		}
+		if i+1 < len(params.Termination) {
+			go func() { time.Sleep(step.Wait); escalate <- i + 1 }()
+		}
+	}
+	// stopInitial applies the termination sequence to the initial process, or delivers SIGTERM
+	// if no termination sequence is configured
+	stopInitial := func() {
+		if len(params.Termination) == 0 {
+			if err := k.signal(cmd, SIGTERM); err != nil {
+				k.printf(msg, "cannot terminate initial process: %v", err)
+			}
+			return
+		}
+		if !escalating {
+			escalating = true
+			msg.Verbose("applying termination sequence")
+			applyStep(0)
+		}
+	}
+	// terminate stops all supervised programs and starts the timeout for lingering processes
+	terminate := func() {
+		terminating = true
+		go func() { time.Sleep(params.AdoptWaitDelay); close(timeout) }()
+
+		for _, p := range programs {
+			if p.cmd != nil {
+				if err := k.signal(p.cmd, SIGTERM); err != nil {
+					msg.Verbosef("cannot terminate supervised program %s: %v", p.Path, err)
+				}
+			}
+		}
+	}
 
 	r := 2
 	for {
 		select {
 		case s := <-sig:
+			if s == CancelSignal && len(params.Termination) > 0 && cmd.Process != nil {
+				stopInitial()
+				continue
+			}
+
 			if s == CancelSignal && params.ForwardCancel && cmd.Process != nil {
 				msg.Verbose("forwarding context cancellation")
 				if err := k.signal(cmd, os.Interrupt); err != nil {
 					k.printf(msg, "cannot forward cancellation: %v", err)
 				}
+				forwardSupervised(k, msg, programs, os.Interrupt)
 				continue
 			}
 
@@ -411,23 +560,37 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 				if err := k.signal(cmd, s); err != nil {
 					k.printf(msg, "cannot forward signal: %v", err)
 				}
+				forwardSupervised(k, msg, programs, s)
 				continue
 			}
 
 			msg.Verbosef("got %s", s.String())
+			if initPid != 1 {
+				killDescendants(k, msg, initPid)
+			}
+			sendReport(msg, report, reportFile, 0)
 			msg.BeforeExit()
 			k.exit(0)
 
 		case w, ok := <-info:
 			if !ok {
+				sendReport(msg, report, reportFile, r)
 				msg.BeforeExit()
 				k.exit(r)
 				continue // unreachable
 			}
 
+			if report != nil {
+				reportReap(report, w.wpid == cmd.Process.Pid, w.wpid, w.wstatus, w.rusage)
+			}
+
 			if w.wpid == cmd.Process.Pid {
+				exited = true
+
 				// start timeout early
-				go func() { time.Sleep(params.AdoptWaitDelay); close(timeout) }()
+				if !terminating {
+					terminate()
+				}
 
 				// close initial process files; this also keeps them alive
 				for _, f := range extraFiles {
@@ -449,16 +612,102 @@ func initEntrypoint(k syscallDispatcher, msg message.Msg) {
 					r = 255
 					msg.Verbosef("initial process exited with status %#x", w.wstatus)
 				}
+				if rCritical != -1 {
+					r = rCritical
+				}
+				continue
+			}
+
+			for i, p := range programs {
+				if p.cmd == nil || p.cmd.Process == nil || w.wpid != p.cmd.Process.Pid {
+					continue
+				}
+				p.cmd = nil
+				msg.Verbosef("supervised program %s terminated with status %#x", p.Path, w.wstatus)
+
+				if terminating {
+					break
+				}
+				if p.Restart.shouldRestart(w.wstatus) {
+					d := p.nextDelay(time.Now())
+					msg.Verbosef("starting supervised program %s again in %s", p.Path, d)
+					go func() { time.Sleep(d); restart <- i }()
+					break
+				}
+				if p.Critical {
+					msg.Verbosef("critical program %s terminated, terminating container", p.Path)
+					r, rCritical = exitCode(w.wstatus), exitCode(w.wstatus)
+					terminate()
+					stopInitial()
+				}
+				break
 			}
 
+		case i := <-escalate:
+			if exited {
+				continue
+			}
+			applyStep(i)
+
+		case i := <-restart:
+			if terminating {
+				continue
+			}
+
+			p := programs[i]
+			p.cmd = p.newCmd(&params.Params)
+			if err := k.start(p.cmd); err != nil {
+				p.cmd = nil
+				k.printf(msg, "cannot start supervised program %s: %v", p.Path, err)
+				if p.Critical {
+					r, rCritical = 255, 255
+					terminate()
+					stopInitial()
+				}
+				continue
+			}
+			p.started = time.Now()
+
 		case <-timeout:
 			k.printf(msg, "timeout exceeded waiting for lingering processes")
+			if report != nil {
+				report.Timeout = true
+				reportCollectLingering(report, k, msg, initPid)
+			}
+			if initPid != 1 {
+				// lingering processes are killed by the kernel once pid 1 terminates
+				killDescendants(k, msg, initPid)
+			}
+			sendReport(msg, report, reportFile, r)
 			msg.BeforeExit()
 			k.exit(r)
 		}
 	}
 }
 
+// sendReport sends report with exit code r through f if report is not nil.
+func sendReport(msg message.Msg, report *hst.ExitReport, f *os.File, r int) {
+	if report == nil {
+		return
+	}
+	report.Code = r
+	if err := encodeReport(report, f); err != nil {
+		msg.Verbosef("cannot send exit report: %v", err)
+	}
+}
+
+// forwardSupervised delivers sig to all running supervised programs.
+func forwardSupervised(k syscallDispatcher, msg message.Msg, programs []*supervisedProgram, sig os.Signal) {
+	for _, p := range programs {
+		if p.cmd == nil {
+			continue
+		}
+		if err := k.signal(p.cmd, sig); err != nil {
+			k.printf(msg, "cannot forward signal to supervised program %s: %v", p.Path, err)
+		}
+	}
+}
+
 // initName is the prefix used by log.std in the init process.
 const initName = "init"
 
@@ -21,6 +21,25 @@ func TestInitEntrypoint(t *testing.T) {
 			Calls: []stub.Call{
 				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
 				call("getpid", stub.ExpectArgs{}, 1<<10, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1f0), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
 				call("fatal", stub.ExpectArgs{[]any{"this process must run as pid 1"}}, nil, nil),
 			},
 		}, nil},
@@ -197,6 +216,135 @@ func TestInitEntrypoint(t *testing.T) {
 			},
 		}, nil},
 
+		{"unshare time", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 1, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+					Time:           &TimeOffsets{Monotonic: -time.Hour, Boottime: 0xbad},
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e1), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/uid_map", []byte("65536 1000 1\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/setgroups", []byte("deny\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/gid_map", []byte("32768 100 1\n"), os.FileMode(0)}, nil, nil),
+				call("unshare", stub.ExpectArgs{syscall.CLONE_NEWTIME}, nil, stub.UniqueError(0x1e2)),
+				call("fatalf", stub.ExpectArgs{"cannot create time namespace: %v", []any{stub.UniqueError(0x1e2)}}, nil, nil),
+			},
+		}, nil},
+
+		{"map external", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 1, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					MapExternal:    true,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e3), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("verbose", stub.ExpectArgs{[]any{"uid/gid map written externally"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(0)}, nil, stub.UniqueError(0x1e2)),
+				call("fatalf", stub.ExpectArgs{"cannot set SUID_DUMP_DISABLE: %v", []any{stub.UniqueError(0x1e2)}}, nil, nil),
+			},
+		}, nil},
+
+		{"writeFile timens_offsets", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 1, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+					Time:           &TimeOffsets{Monotonic: -time.Hour, Boottime: 0xbad},
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e1), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/uid_map", []byte("65536 1000 1\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/setgroups", []byte("deny\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/gid_map", []byte("32768 100 1\n"), os.FileMode(0)}, nil, nil),
+				call("unshare", stub.ExpectArgs{syscall.CLONE_NEWTIME}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/thread-self/timens_offsets", []byte("monotonic -3600 0\nboottime 0 2989\n"), os.FileMode(0)}, nil, stub.UniqueError(0x1e0)),
+				call("fatalf", stub.ExpectArgs{"cannot set clock offsets: %v", []any{stub.UniqueError(0x1e0)}}, nil, nil),
+			},
+		}, nil},
+
+		{"map external", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 1, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					MapExternal:    true,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e3), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("verbose", stub.ExpectArgs{[]any{"uid/gid map written externally"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(0)}, nil, stub.UniqueError(0x1e2)),
+				call("fatalf", stub.ExpectArgs{"cannot set SUID_DUMP_DISABLE: %v", []any{stub.UniqueError(0x1e2)}}, nil, nil),
+			},
+		}, nil},
+
 		{"setDumpable disable", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
 			Calls: []stub.Call{
 				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
@@ -263,6 +411,40 @@ func TestInitEntrypoint(t *testing.T) {
 			},
 		}, nil},
 
+		{"join", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 0xcafe, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					Uid:            1 << 16,
+					Gid:            1 << 15,
+					Join:           0xbeef,
+					Hostname:       "hakurei-check",
+					Ops:            (*Ops)(sliceAddr(make(Ops, 1))),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+					Privileged:     true,
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e6), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("verbose", stub.ExpectArgs{[]any{"joined user namespace of process", 0xbeef}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("umask", stub.ExpectArgs{0}, 022, nil),
+				call("lastcap", stub.ExpectArgs{}, uintptr(40), nil),
+				call("mount", stub.ExpectArgs{"", "/", "", uintptr(0x8c000), ""}, nil, stub.UniqueError(0x1e7)),
+				call("fatalf", stub.ExpectArgs{"cannot make / rslave: %v", []any{stub.UniqueError(0x1e7)}}, nil, nil),
+			},
+		}, nil},
+
 		{"mount rslave root", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
 			Calls: []stub.Call{
 				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
@@ -1766,6 +1948,103 @@ func TestInitEntrypoint(t *testing.T) {
 			},
 		}, nil},
 
+		{"capset fake root", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+				call("getpid", stub.ExpectArgs{}, 1, nil),
+				call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{Params{
+					Dir:            check.MustAbs("/.hakurei"),
+					Env:            []string{"DISPLAY=:0"},
+					Path:           check.MustAbs("/bin/zsh"),
+					Args:           []string{"zsh", "-c", "exec vim"},
+					ForwardCancel:  true,
+					AdoptWaitDelay: 5 * time.Second,
+					FakeRoot:       true,
+					Hostname:       "hakurei-check",
+					Ops:            new(Ops).Bind(check.MustAbs("/"), check.MustAbs("/"), std.BindDevice).Proc(check.MustAbs("/proc/")),
+					SeccompRules:   make([]std.NativeRule, 0),
+					SeccompPresets: std.PresetStrict,
+					RetainSession:  true,
+				}, 1000, 100, 3, true}, uintptr(9)}, stub.UniqueError(0x1e5), nil),
+				call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+				call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/uid_map", []byte("0 1000 1\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/setgroups", []byte("deny\n"), os.FileMode(0)}, nil, nil),
+				call("writeFile", stub.ExpectArgs{"/proc/self/gid_map", []byte("0 100 1\n"), os.FileMode(0)}, nil, nil),
+				call("setDumpable", stub.ExpectArgs{uintptr(0)}, nil, nil),
+				call("umask", stub.ExpectArgs{0}, 022, nil),
+				call("sethostname", stub.ExpectArgs{[]byte("hakurei-check")}, nil, nil),
+				call("lastcap", stub.ExpectArgs{}, uintptr(40), nil),
+				call("mount", stub.ExpectArgs{"", "/", "", uintptr(0x8c000), ""}, nil, nil),
+				/* begin early */
+				call("evalSymlinks", stub.ExpectArgs{"/"}, "/", nil),
+				/* end early */
+				call("mount", stub.ExpectArgs{"rootfs", "/proc/self/fd", "tmpfs", uintptr(6), ""}, nil, nil),
+				call("chdir", stub.ExpectArgs{"/proc/self/fd"}, nil, nil),
+				call("mkdir", stub.ExpectArgs{"sysroot", os.FileMode(0755)}, nil, nil),
+				call("mount", stub.ExpectArgs{"sysroot", "sysroot", "", uintptr(0xd000), ""}, nil, nil),
+				call("mkdir", stub.ExpectArgs{"host", os.FileMode(0755)}, nil, nil),
+				call("pivotRoot", stub.ExpectArgs{"/proc/self/fd", "host"}, nil, nil),
+				call("chdir", stub.ExpectArgs{"/"}, nil, nil),
+				/* begin apply */
+				call("stat", stub.ExpectArgs{"/host"}, isDirFi(true), nil),
+				call("mkdirAll", stub.ExpectArgs{"/sysroot", os.FileMode(0700)}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"mounting %q flags %#x", []any{"/sysroot", uintptr(0x4001)}}, nil, nil),
+				call("bindMount", stub.ExpectArgs{"/host", "/sysroot", uintptr(0x4001), false}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"%s %s", []any{"mounting", &MountProcOp{Target: check.MustAbs("/proc/")}}}, nil, nil),
+				call("mkdirAll", stub.ExpectArgs{"/sysroot/proc", os.FileMode(0755)}, nil, nil),
+				call("mount", stub.ExpectArgs{"proc", "/sysroot/proc", "proc", uintptr(0xe), ""}, nil, nil),
+				/* end apply */
+				call("mount", stub.ExpectArgs{"host", "host", "", uintptr(0x4c000), ""}, nil, nil),
+				call("unmount", stub.ExpectArgs{"host", 2}, nil, nil),
+				call("open", stub.ExpectArgs{"/", syscall.O_DIRECTORY | syscall.O_RDONLY, uint32(0)}, math.MaxInt, syscall.EINTR),
+				call("open", stub.ExpectArgs{"/", syscall.O_DIRECTORY | syscall.O_RDONLY, uint32(0)}, math.MaxInt, nil),
+				call("chdir", stub.ExpectArgs{"/sysroot"}, nil, nil),
+				call("pivotRoot", stub.ExpectArgs{".", "."}, nil, nil),
+				call("fchdir", stub.ExpectArgs{math.MaxInt}, nil, nil),
+				call("unmount", stub.ExpectArgs{".", 2}, nil, nil),
+				call("chdir", stub.ExpectArgs{"/"}, nil, nil),
+				call("close", stub.ExpectArgs{math.MaxInt}, nil, nil),
+				call("capAmbientClearAll", stub.ExpectArgs{}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x8)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x9)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xa)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xb)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xc)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xd)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xe)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0xf)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x10)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x11)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x12)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x13)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x14)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x15)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x16)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x17)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x18)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x19)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1a)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1b)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1c)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1d)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1e)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x20)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x21)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x22)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x23)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x24)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x25)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x26)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x27)}, nil, nil),
+				call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x28)}, nil, nil),
+				call("capset", stub.ExpectArgs{&capHeader{_LINUX_CAPABILITY_VERSION_3, 0}, &[2]capData{{0, 0x800000ff, 0x800000ff}, {0, 0, 0}}}, nil, stub.UniqueError(0x1e4)),
+				call("fatalf", stub.ExpectArgs{"cannot capset: %v", []any{stub.UniqueError(0x1e4)}}, nil, nil),
+			},
+		}, nil},
+
 		{"seccompLoad", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
 			Calls: []stub.Call{
 				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
@@ -2760,6 +3039,233 @@ func TestInitEntrypoint(t *testing.T) {
 	})
 }
 
+// initSuperviseCalls returns calls of initEntrypoint up to the start of supervised programs
+// for a container with params, followed by calls.
+func initSuperviseCalls(params Params, calls ...stub.Call) []stub.Call {
+	params.Dir = check.MustAbs("/.hakurei/nonexistent")
+	params.Path = check.MustAbs("/run/current-system/sw/bin/bash")
+	params.Args = []string{"bash", "-c", "false"}
+	params.Uid = 1 << 24
+	params.Gid = 1 << 13
+	params.Hostname = "hakurei-check"
+	params.Ops = new(Ops).Bind(check.MustAbs("/"), check.MustAbs("/"), std.BindDevice)
+	params.SeccompRules = make([]std.NativeRule, 0)
+	params.SeccompDisable = true
+	params.ParentPerm = 0750
+
+	return append([]stub.Call{
+		call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+		call("getpid", stub.ExpectArgs{}, 1, nil),
+		call("setPtracer", stub.ExpectArgs{uintptr(0)}, nil, nil),
+		call("receive", stub.ExpectArgs{"HAKUREI_SETUP", new(initParams), new(uintptr), &initParams{params, 1971, 127, 0, false}}, nil, nil),
+		call("swapVerbose", stub.ExpectArgs{false}, false, nil),
+		call("verbose", stub.ExpectArgs{[]any{"received setup parameters"}}, nil, nil),
+		call("setDumpable", stub.ExpectArgs{uintptr(1)}, nil, nil),
+		call("writeFile", stub.ExpectArgs{"/proc/self/uid_map", []byte("16777216 1971 1\n"), os.FileMode(0)}, nil, nil),
+		call("writeFile", stub.ExpectArgs{"/proc/self/setgroups", []byte("deny\n"), os.FileMode(0)}, nil, nil),
+		call("writeFile", stub.ExpectArgs{"/proc/self/gid_map", []byte("8192 127 1\n"), os.FileMode(0)}, nil, nil),
+		call("setDumpable", stub.ExpectArgs{uintptr(0)}, nil, nil),
+		call("umask", stub.ExpectArgs{0}, 022, nil),
+		call("sethostname", stub.ExpectArgs{[]byte("hakurei-check")}, nil, nil),
+		call("lastcap", stub.ExpectArgs{}, uintptr(4), nil),
+		call("mount", stub.ExpectArgs{"", "/", "", uintptr(0x8c000), ""}, nil, nil),
+		/* begin early */
+		call("evalSymlinks", stub.ExpectArgs{"/"}, "/", nil),
+		/* end early */
+		call("mount", stub.ExpectArgs{"rootfs", "/proc/self/fd", "tmpfs", uintptr(6), ""}, nil, nil),
+		call("chdir", stub.ExpectArgs{"/proc/self/fd"}, nil, nil),
+		call("mkdir", stub.ExpectArgs{"sysroot", os.FileMode(0755)}, nil, nil),
+		call("mount", stub.ExpectArgs{"sysroot", "sysroot", "", uintptr(0xd000), ""}, nil, nil),
+		call("mkdir", stub.ExpectArgs{"host", os.FileMode(0755)}, nil, nil),
+		call("pivotRoot", stub.ExpectArgs{"/proc/self/fd", "host"}, nil, nil),
+		call("chdir", stub.ExpectArgs{"/"}, nil, nil),
+		/* begin apply */
+		call("stat", stub.ExpectArgs{"/host"}, isDirFi(true), nil),
+		call("mkdirAll", stub.ExpectArgs{"/sysroot", os.FileMode(0700)}, nil, nil),
+		call("verbosef", stub.ExpectArgs{"mounting %q flags %#x", []any{"/sysroot", uintptr(0x4001)}}, nil, nil),
+		call("bindMount", stub.ExpectArgs{"/host", "/sysroot", uintptr(0x4001), false}, nil, nil),
+		/* end apply */
+		call("mount", stub.ExpectArgs{"host", "host", "", uintptr(0x4c000), ""}, nil, nil),
+		call("unmount", stub.ExpectArgs{"host", 2}, nil, nil),
+		call("open", stub.ExpectArgs{"/", syscall.O_DIRECTORY | syscall.O_RDONLY, uint32(0)}, math.MaxInt, nil),
+		call("chdir", stub.ExpectArgs{"/sysroot"}, nil, nil),
+		call("pivotRoot", stub.ExpectArgs{".", "."}, nil, nil),
+		call("fchdir", stub.ExpectArgs{math.MaxInt}, nil, nil),
+		call("unmount", stub.ExpectArgs{".", 2}, nil, nil),
+		call("chdir", stub.ExpectArgs{"/"}, nil, nil),
+		call("close", stub.ExpectArgs{math.MaxInt}, nil, nil),
+		call("capAmbientClearAll", stub.ExpectArgs{}, nil, nil),
+		call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x0)}, nil, nil),
+		call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x1)}, nil, nil),
+		call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x2)}, nil, nil),
+		call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x3)}, nil, nil),
+		call("capBoundingSetDrop", stub.ExpectArgs{uintptr(0x4)}, nil, nil),
+		call("capset", stub.ExpectArgs{&capHeader{_LINUX_CAPABILITY_VERSION_3, 0}, new([2]capData)}, nil, nil),
+		call("verbose", stub.ExpectArgs{[]any{"syscall filter not configured"}}, nil, nil),
+		call("umask", stub.ExpectArgs{022}, 0, nil),
+	}, calls...)
+}
+
+func TestInitSupervise(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bashArgs     = stub.ExpectArgs{"/run/current-system/sw/bin/bash", []string{"bash", "-c", "false"}, ([]string)(nil), "/.hakurei/nonexistent"}
+		pipewire     = check.MustAbs("/run/current-system/sw/bin/pipewire")
+		pipewireArgs = stub.ExpectArgs{"/run/current-system/sw/bin/pipewire", []string{"/run/current-system/sw/bin/pipewire"}, ([]string)(nil), "/.hakurei/nonexistent"}
+		notifyArgs   = stub.ExpectArgs{nil, []os.Signal{CancelSignal, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}}
+	)
+
+	// withArg returns a copy of args with its last element set to v
+	withArg := func(args stub.ExpectArgs, v any) stub.ExpectArgs { args[4] = v; return args }
+
+	checkSimple(t, "initEntrypoint", []simpleTestCase{
+		{"restart after failure", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: initSuperviseCalls(Params{
+				AdoptWaitDelay: time.Hour,
+				Programs: []Program{{
+					Path:    pipewire,
+					Restart: RestartOnFailure,
+					Backoff: time.Nanosecond,
+				}},
+			},
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s", []any{pipewire}}, nil, nil),
+				call("start", pipewireArgs, &os.Process{Pid: 0xcafe}, nil),
+				call("verbosef", stub.ExpectArgs{"starting initial program %s", []any{check.MustAbs("/run/current-system/sw/bin/bash")}}, nil, nil),
+				call("start", bashArgs, &os.Process{Pid: 0xbad}, nil),
+				call("New", stub.ExpectArgs{}, nil, nil),
+				call("notify", notifyArgs, nil, nil),
+				call("verbosef", stub.ExpectArgs{"supervised program %s terminated with status %#x", []any{pipewire, syscall.WaitStatus(0x100)}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s again in %s", []any{pipewire, time.Nanosecond}}, nil, nil),
+				call("start", withArg(pipewireArgs, magicWait4Signal), &os.Process{Pid: 0xbabe}, nil),
+				call("signal", withArg(pipewireArgs, syscall.SIGTERM), nil, nil),
+				call("verbosef", stub.ExpectArgs{"initial process exited with code %d", []any{0}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"supervised program %s terminated with status %#x", []any{pipewire, syscall.WaitStatus(0xf)}}, nil, nil),
+				call("beforeExit", stub.ExpectArgs{}, nil, nil),
+				call("exit", stub.ExpectArgs{0}, nil, nil),
+			),
+
+			/* wait4 */
+			Tracks: []stub.Expect{{Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0x100), 0, nil}, 0xcafe, nil),
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0), 0, nil, magicWait4Signal}, 0xbad, nil),
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0xf), 0, nil}, 0xbabe, nil),
+				call("wait4", stub.ExpectArgs{-1, nil, 0, nil}, 0, syscall.ECHILD),
+			}}},
+		}, nil},
+
+		{"no restart while terminating", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: initSuperviseCalls(Params{
+				AdoptWaitDelay: time.Hour,
+				Programs: []Program{{
+					Path:    pipewire,
+					Restart: RestartAlways,
+					Backoff: time.Nanosecond,
+				}},
+			},
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s", []any{pipewire}}, nil, nil),
+				call("start", pipewireArgs, &os.Process{Pid: 0xcafe}, nil),
+				call("verbosef", stub.ExpectArgs{"starting initial program %s", []any{check.MustAbs("/run/current-system/sw/bin/bash")}}, nil, nil),
+				call("start", bashArgs, &os.Process{Pid: 0xbad}, nil),
+				call("New", stub.ExpectArgs{}, nil, nil),
+				call("notify", notifyArgs, nil, nil),
+				call("signal", withArg(pipewireArgs, syscall.SIGTERM), magicWait4Signal, nil),
+				call("verbosef", stub.ExpectArgs{"initial process exited with code %d", []any{0}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"supervised program %s terminated with status %#x", []any{pipewire, syscall.WaitStatus(0xf)}}, nil, nil),
+				call("beforeExit", stub.ExpectArgs{}, nil, nil),
+				call("exit", stub.ExpectArgs{0}, nil, nil),
+			),
+
+			/* wait4 */
+			Tracks: []stub.Expect{{Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0), 0, nil}, 0xbad, nil),
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0xf), 0, nil, magicWait4Signal}, 0xcafe, nil),
+				call("wait4", stub.ExpectArgs{-1, nil, 0, nil}, 0, syscall.ECHILD),
+			}}},
+		}, nil},
+
+		{"critical", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: initSuperviseCalls(Params{
+				RetainSession:  true,
+				AdoptWaitDelay: time.Hour,
+				Termination: []TerminationStep{
+					{Signal: syscall.SIGTERM, Group: true, Wait: time.Hour},
+					{Signal: syscall.SIGKILL, Group: true},
+				},
+				Programs: []Program{{
+					Path:     pipewire,
+					Critical: true,
+				}},
+			},
+				call("isatty", stub.ExpectArgs{0}, true, nil),
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s", []any{pipewire}}, nil, nil),
+				call("start", pipewireArgs, &os.Process{Pid: 0xcafe}, nil),
+				call("verbosef", stub.ExpectArgs{"starting initial program %s", []any{check.MustAbs("/run/current-system/sw/bin/bash")}}, nil, nil),
+				call("start", withArg(bashArgs, &syscall.SysProcAttr{Foreground: true, Ctty: 0}), &os.Process{Pid: 0xbad}, nil),
+				call("New", stub.ExpectArgs{}, nil, nil),
+				call("notify", notifyArgs, nil, nil),
+				call("verbosef", stub.ExpectArgs{"supervised program %s terminated with status %#x", []any{pipewire, syscall.WaitStatus(0x300)}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"critical program %s terminated, terminating container", []any{pipewire}}, nil, nil),
+				call("verbose", stub.ExpectArgs{[]any{"applying termination sequence"}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"termination step %d: delivering %s", []any{0, syscall.SIGTERM}}, nil, nil),
+				call("signalGroup", stub.ExpectArgs{"/run/current-system/sw/bin/bash", []string{"bash", "-c", "false"}, syscall.SIGTERM}, magicWait4Signal, nil),
+				call("verbosef", stub.ExpectArgs{"initial process exited with signal %s", []any{syscall.SIGTERM}}, nil, nil),
+				call("beforeExit", stub.ExpectArgs{}, nil, nil),
+				call("exit", stub.ExpectArgs{3}, nil, nil),
+			),
+
+			/* wait4 */
+			Tracks: []stub.Expect{{Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0x300), 0, nil}, 0xcafe, nil),
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0xf), 0, nil, magicWait4Signal}, 0xbad, nil),
+				call("wait4", stub.ExpectArgs{-1, nil, 0, nil}, 0, syscall.ECHILD),
+			}}},
+		}, nil},
+
+		{"critical restart start", func(k *kstub) error { initEntrypoint(k, k); return nil }, stub.Expect{
+			Calls: initSuperviseCalls(Params{
+				AdoptWaitDelay: time.Hour,
+				Programs: []Program{{
+					Path:     pipewire,
+					Restart:  RestartOnFailure,
+					Backoff:  time.Nanosecond,
+					Critical: true,
+				}},
+			},
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s", []any{pipewire}}, nil, nil),
+				call("start", pipewireArgs, &os.Process{Pid: 0xcafe}, nil),
+				call("verbosef", stub.ExpectArgs{"starting initial program %s", []any{check.MustAbs("/run/current-system/sw/bin/bash")}}, nil, nil),
+				call("start", bashArgs, &os.Process{Pid: 0xbad}, nil),
+				call("New", stub.ExpectArgs{}, nil, nil),
+				call("notify", notifyArgs, nil, nil),
+				call("verbosef", stub.ExpectArgs{"supervised program %s terminated with status %#x", []any{pipewire, syscall.WaitStatus(0x100)}}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"starting supervised program %s again in %s", []any{pipewire, time.Nanosecond}}, nil, nil),
+				call("start", pipewireArgs, nil, stub.UniqueError(0xfe)),
+				call("printf", stub.ExpectArgs{"cannot start supervised program %s: %v", []any{pipewire, stub.UniqueError(0xfe)}}, nil, nil),
+				call("signal", withArg(bashArgs, syscall.SIGTERM), magicWait4Signal, nil),
+				call("verbosef", stub.ExpectArgs{"initial process exited with signal %s", []any{syscall.SIGTERM}}, nil, nil),
+				call("beforeExit", stub.ExpectArgs{}, nil, nil),
+				call("exit", stub.ExpectArgs{0xff}, nil, nil),
+			),
+
+			/* wait4 */
+			Tracks: []stub.Expect{{Calls: []stub.Call{
+				call("lockOSThread", stub.ExpectArgs{}, nil, nil),
+
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0x100), 0, nil}, 0xcafe, nil),
+				call("wait4", stub.ExpectArgs{-1, syscall.WaitStatus(0xf), 0, nil, magicWait4Signal}, 0xbad, nil),
+				call("wait4", stub.ExpectArgs{-1, nil, 0, nil}, 0, syscall.ECHILD),
+			}}},
+		}, nil},
+	})
+}
+
 func TestOpsGrow(t *testing.T) {
 	t.Parallel()
 	ops := new(Ops)
@@ -0,0 +1,168 @@
+package container
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/message"
+)
+
+const (
+	// fdPrefix is prepended to the number of a file descriptor to refer to it in the initial process.
+	fdPrefix = "/proc/self/fd/"
+	// execTrampoline is started in place of a verified initial program, see execfd.c.
+	execTrampoline = "/proc/self/exe"
+	// interpreterLineMax is the number of bytes considered by the kernel when parsing an interpreter line.
+	interpreterLineMax = 256
+)
+
+var (
+	// ErrInterpreterDigest is returned when the initial program is a script and [Params.InterpreterDigest] is nil.
+	ErrInterpreterDigest = errors.New("script interpreter has no digest")
+	// ErrInterpreterScript is returned when the interpreter of the initial program is itself a script.
+	ErrInterpreterScript = errors.New("nested script interpreters are not supported")
+)
+
+// A DigestError is returned when the initial program does not match [Params.PathDigest].
+type DigestError struct {
+	// Pathname of the initial program.
+	Path string
+	// Digest of the file opened at Path.
+	Got [sha256.Size]byte
+	// Expected digest.
+	Want [sha256.Size]byte
+}
+
+func (e *DigestError) Error() string {
+	return "digest mismatch for " + e.Path + ": got " + hex.EncodeToString(e.Got[:]) +
+		", want " + hex.EncodeToString(e.Want[:])
+}
+
+// checkDigest reads r until EOF and returns a [DigestError] if its SHA-256 digest differs from want.
+// The returned interpreter is non-empty if r holds a script, in which case the interpreter line is
+// covered by the digest. Like the kernel, the remainder of the interpreter line is returned as arg.
+func checkDigest(r io.Reader, pathname string, want *[sha256.Size]byte) (interpreter, arg string, err error) {
+	h := sha256.New()
+	head := make([]byte, interpreterLineMax)
+	var n int
+	if n, err = io.ReadFull(r, head); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return
+	}
+	head = head[:n]
+	h.Write(head)
+	if _, err = io.Copy(h, r); err != nil {
+		return
+	}
+
+	e := DigestError{Path: pathname, Want: *want}
+	h.Sum(e.Got[:0])
+	if e.Got != e.Want {
+		return "", "", &e
+	}
+
+	if line, ok := bytes.CutPrefix(head, []byte("#!")); ok {
+		if i := bytes.IndexByte(line, '\n'); i != -1 {
+			line = line[:i]
+		}
+		line = bytes.TrimSpace(line)
+		if i := bytes.IndexAny(line, " \t"); i != -1 {
+			interpreter, arg = string(line[:i]), string(bytes.TrimSpace(line[i:]))
+		} else {
+			interpreter = string(line)
+		}
+	}
+	return interpreter, arg, nil
+}
+
+// openVerified opens the file at pathname and verifies it against digest. The returned file is a
+// sealed memfd holding the bytes the digest was computed over, so writing to the file after it is
+// verified, including through another mount of the same inode, has no effect on what is executed.
+// It is close-on-exec, and is inherited by the initial process only by being passed as an extra file.
+func openVerified(k syscallDispatcher, pathname string, digest *[sha256.Size]byte) (f *os.File, interpreter, arg string, err error) {
+	var fd int
+	if fd, err = k.open(pathname, syscall.O_RDONLY|syscall.O_CLOEXEC, 0); err != nil {
+		return nil, "", "", &os.PathError{Op: "open", Path: pathname, Err: err}
+	}
+	src := k.newFile(uintptr(fd), pathname)
+	defer func() { _ = src.Close() }()
+
+	name := path.Base(pathname)
+	if fd, err = k.memfdCreate(name, MFD_CLOEXEC|MFD_ALLOW_SEALING|MFD_EXEC); errors.Is(err, syscall.EINVAL) {
+		// memfd is always executable prior to Linux 6.3, which does not recognise MFD_EXEC
+		fd, err = k.memfdCreate(name, MFD_CLOEXEC|MFD_ALLOW_SEALING)
+	}
+	if err != nil {
+		return nil, "", "", os.NewSyscallError("memfd_create", err)
+	}
+	f = k.newFile(uintptr(fd), pathname)
+
+	if interpreter, arg, err = checkDigest(io.TeeReader(src, f), pathname, digest); err == nil {
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			err = os.NewSyscallError("fcntl", k.addSeals(fd, F_SEAL_SEAL|F_SEAL_SHRINK|F_SEAL_GROW|F_SEAL_WRITE))
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, "", "", err
+	}
+	return
+}
+
+// openVerifiedProgram opens and verifies the initial program, and the interpreter of a script.
+// It returns files to be appended to extra files, the first of which is numbered next in the initial
+// process, along with argv and an additional environment variable to start [execTrampoline] with.
+//
+// The trampoline executes the verified copy via execveat, so replacing or writing to the file at its
+// pathname after verification has no effect. A script is not executed directly: its interpreter is, with the script
+// passed by its file descriptor, which would otherwise require the interpreter to be resolved again.
+func openVerifiedProgram(k syscallDispatcher, msg message.Msg, params *Params, next int) (files []*os.File, args []string, env string, err error) {
+	pathname := params.Path.String()
+	var (
+		f, interpreterFile       *os.File
+		interpreter, arg, nested string
+	)
+	if f, interpreter, arg, err = openVerified(k, pathname, params.PathDigest); err != nil {
+		return
+	}
+	if interpreter == "" {
+		msg.Verbosef("verified initial program %s", pathname)
+		return []*os.File{f}, params.Args, execFdEnv + "=" + strconv.Itoa(next), nil
+	}
+
+	if params.InterpreterDigest == nil {
+		_ = f.Close()
+		return nil, nil, "", ErrInterpreterDigest
+	}
+	if _, err = check.NewAbs(interpreter); err != nil {
+		_ = f.Close()
+		return
+	}
+	if interpreterFile, nested, _, err = openVerified(k, interpreter, params.InterpreterDigest); err != nil {
+		_ = f.Close()
+		return
+	}
+	if nested != "" {
+		_, _ = f.Close(), interpreterFile.Close()
+		return nil, nil, "", &os.PathError{Op: "execute", Path: interpreter, Err: ErrInterpreterScript}
+	}
+	msg.Verbosef("verified script %s interpreted by %s", pathname, interpreter)
+
+	// argv as constructed by the kernel for a script executed through its file descriptor
+	args = []string{interpreter}
+	if arg != "" {
+		args = append(args, arg)
+	}
+	args = append(args, fdPrefix+strconv.Itoa(next))
+	if len(params.Args) > 1 {
+		args = append(args, params.Args[1:]...)
+	}
+	return []*os.File{f, interpreterFile}, args, execFdEnv + "=" + strconv.Itoa(next+1), nil
+}
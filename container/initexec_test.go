@@ -0,0 +1,299 @@
+package container
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/stub"
+	"hakurei.app/message"
+)
+
+func TestCheckDigest(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleELF    = "\x7fELF\x02\x01\x01\x00"
+		sampleScript = "#!/bin/sh -e\nexec true\n"
+	)
+
+	testCases := []struct {
+		name        string
+		data        string
+		want        [sha256.Size]byte
+		interpreter string
+		arg         string
+		wantErr     error
+	}{
+		{"empty", "", sha256.Sum256(nil), "", "", nil},
+		{"binary", sampleELF, sha256.Sum256([]byte(sampleELF)), "", "", nil},
+		{"script", sampleScript, sha256.Sum256([]byte(sampleScript)), "/bin/sh", "-e", nil},
+		{"script arg spaces", "#! /usr/bin/env  python3 -u \n", sha256.Sum256([]byte("#! /usr/bin/env  python3 -u \n")), "/usr/bin/env", "python3 -u", nil},
+		{"script long", "#!/usr/bin/env" + strings.Repeat(" ", 1<<9), sha256.Sum256([]byte("#!/usr/bin/env" + strings.Repeat(" ", 1<<9))), "/usr/bin/env", "", nil},
+		{"script empty", "#!\n", sha256.Sum256([]byte("#!\n")), "", "", nil},
+
+		{"mismatch", sampleScript, sha256.Sum256([]byte(sampleELF)), "", "", &DigestError{
+			Path: "/bin/true",
+			Got:  sha256.Sum256([]byte(sampleScript)),
+			Want: sha256.Sum256([]byte(sampleELF)),
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			interpreter, arg, err := checkDigest(strings.NewReader(tc.data), "/bin/true", &tc.want)
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Fatalf("checkDigest: error = %v, want %v", err, tc.wantErr)
+			}
+			if interpreter != tc.interpreter || arg != tc.arg {
+				t.Errorf("checkDigest: %q, %q, want %q, %q", interpreter, arg, tc.interpreter, tc.arg)
+			}
+		})
+	}
+
+	t.Run("read", func(t *testing.T) {
+		t.Parallel()
+		if _, _, err := checkDigest(iotest.ErrReader(stub.UniqueError(0)), "/bin/true", new([sha256.Size]byte)); !errors.Is(err, stub.UniqueError(0)) {
+			t.Errorf("checkDigest: error = %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		const want = "digest mismatch for /bin/true: got " +
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855, want " +
+			"0000000000000000000000000000000000000000000000000000000000000000"
+		if got := (&DigestError{Path: "/bin/true", Got: sha256.Sum256(nil)}).Error(); got != want {
+			t.Errorf("Error: %q, want %q", got, want)
+		}
+	})
+}
+
+func TestOpenVerifiedProgram(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleELF    = "\x7fELF\x02\x01\x01\x00"
+		sampleScript = "#!/bin/sh -e\nexec true\n"
+	)
+	d := t.TempDir()
+	newFile := func(data string) *os.File {
+		name := filepath.Join(d, strconv.Itoa(int(sha256.Sum256([]byte(data))[0])))
+		if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+		return f
+	}
+	newMemfd := func() *os.File {
+		f, err := os.CreateTemp(d, "memfd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+		return f
+	}
+	sum := func(data string) *[sha256.Size]byte { v := sha256.Sum256([]byte(data)); return &v }
+	const (
+		flags      = syscall.O_RDONLY | syscall.O_CLOEXEC
+		memfdFlags = uintptr(MFD_CLOEXEC | MFD_ALLOW_SEALING | MFD_EXEC)
+		seals      = uintptr(F_SEAL_SEAL | F_SEAL_SHRINK | F_SEAL_GROW | F_SEAL_WRITE)
+	)
+
+	testCases := []struct {
+		name     string
+		params   *Params
+		calls    func() ([]stub.Call, []*os.File)
+		wantArgs []string
+		wantEnv  string
+		wantErr  error
+	}{
+		{"open", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleELF)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, -1, stub.UniqueError(0)),
+			}, nil
+		}, nil, "", &os.PathError{Op: "open", Path: "/bin/true", Err: stub.UniqueError(0)}},
+
+		{"memfdCreate", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleELF)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/true"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"true", memfdFlags}, -1, stub.UniqueError(1)),
+			}, nil
+		}, nil, "", os.NewSyscallError("memfd_create", stub.UniqueError(1))},
+
+		{"addSeals", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleELF)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/true"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"true", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/true"}, newMemfd(), nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, stub.UniqueError(2)),
+			}, nil
+		}, nil, "", os.NewSyscallError("fcntl", stub.UniqueError(2))},
+
+		{"binary", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleELF), Args: []string{"true", "-v"}}, func() ([]stub.Call, []*os.File) {
+			f := newMemfd()
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/true"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"true", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/true"}, f, nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"verified initial program %s", []any{"/bin/true"}}, nil, nil),
+			}, []*os.File{f}
+		}, []string{"true", "-v"}, "HAKUREI_EXECFD=5", nil},
+
+		{"binary no MFD_EXEC", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleELF), Args: []string{"true"}}, func() ([]stub.Call, []*os.File) {
+			f := newMemfd()
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/true"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"true", memfdFlags}, -1, syscall.EINVAL),
+				call("memfdCreate", stub.ExpectArgs{"true", uintptr(MFD_CLOEXEC | MFD_ALLOW_SEALING)}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/true"}, f, nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"verified initial program %s", []any{"/bin/true"}}, nil, nil),
+			}, []*os.File{f}
+		}, []string{"true"}, "HAKUREI_EXECFD=5", nil},
+
+		{"mismatch", &Params{Path: check.MustAbs("/bin/true"), PathDigest: sum(sampleScript)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/true", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/true"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"true", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/true"}, newMemfd(), nil),
+			}, nil
+		}, nil, "", &DigestError{Path: "/bin/true", Got: *sum(sampleELF), Want: *sum(sampleScript)}},
+
+		{"script no interpreter digest", &Params{Path: check.MustAbs("/bin/script"), PathDigest: sum(sampleScript)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/script", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/script"}, newFile(sampleScript), nil),
+				call("memfdCreate", stub.ExpectArgs{"script", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/script"}, newMemfd(), nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+			}, nil
+		}, nil, "", ErrInterpreterDigest},
+
+		{"script interpreter mismatch", &Params{Path: check.MustAbs("/bin/script"), PathDigest: sum(sampleScript), InterpreterDigest: sum(sampleELF)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/script", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/script"}, newFile(sampleScript), nil),
+				call("memfdCreate", stub.ExpectArgs{"script", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/script"}, newMemfd(), nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+				call("open", stub.ExpectArgs{"/bin/sh", flags, uint32(0)}, 0xcafe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xcafe), "/bin/sh"}, newFile(sampleELF+"\x00"), nil),
+				call("memfdCreate", stub.ExpectArgs{"sh", memfdFlags}, 0xfe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfe), "/bin/sh"}, newMemfd(), nil),
+			}, nil
+		}, nil, "", &DigestError{Path: "/bin/sh", Got: sha256.Sum256([]byte(sampleELF + "\x00")), Want: *sum(sampleELF)}},
+
+		{"script nested", &Params{Path: check.MustAbs("/bin/script"), PathDigest: sum(sampleScript), InterpreterDigest: sum(sampleScript)}, func() ([]stub.Call, []*os.File) {
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/script", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/script"}, newFile(sampleScript), nil),
+				call("memfdCreate", stub.ExpectArgs{"script", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/script"}, newMemfd(), nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+				call("open", stub.ExpectArgs{"/bin/sh", flags, uint32(0)}, 0xcafe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xcafe), "/bin/sh"}, newFile(sampleScript), nil),
+				call("memfdCreate", stub.ExpectArgs{"sh", memfdFlags}, 0xfe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfe), "/bin/sh"}, newMemfd(), nil),
+				call("addSeals", stub.ExpectArgs{0xfe, seals}, nil, nil),
+			}, nil
+		}, nil, "", &os.PathError{Op: "execute", Path: "/bin/sh", Err: ErrInterpreterScript}},
+
+		{"script", &Params{Path: check.MustAbs("/bin/script"), PathDigest: sum(sampleScript), InterpreterDigest: sum(sampleELF), Args: []string{"script", "a", "b"}}, func() ([]stub.Call, []*os.File) {
+			f, g := newMemfd(), newMemfd()
+			return []stub.Call{
+				call("open", stub.ExpectArgs{"/bin/script", flags, uint32(0)}, 0xbad, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xbad), "/bin/script"}, newFile(sampleScript), nil),
+				call("memfdCreate", stub.ExpectArgs{"script", memfdFlags}, 0xfd, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfd), "/bin/script"}, f, nil),
+				call("addSeals", stub.ExpectArgs{0xfd, seals}, nil, nil),
+				call("open", stub.ExpectArgs{"/bin/sh", flags, uint32(0)}, 0xcafe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xcafe), "/bin/sh"}, newFile(sampleELF), nil),
+				call("memfdCreate", stub.ExpectArgs{"sh", memfdFlags}, 0xfe, nil),
+				call("newFile", stub.ExpectArgs{uintptr(0xfe), "/bin/sh"}, g, nil),
+				call("addSeals", stub.ExpectArgs{0xfe, seals}, nil, nil),
+				call("verbosef", stub.ExpectArgs{"verified script %s interpreted by %s", []any{"/bin/script", "/bin/sh"}}, nil, nil),
+			}, []*os.File{f, g}
+		}, []string{"/bin/sh", "-e", "/proc/self/fd/5", "a", "b"}, "HAKUREI_EXECFD=6", nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls, wantFiles := tc.calls()
+			k := &kstub{nil, stub.New(t, func(s *stub.Stub[syscallDispatcher]) syscallDispatcher { return &kstub{nil, s} }, stub.Expect{Calls: calls})}
+			files, args, env, err := openVerifiedProgram(k, k, tc.params, 5)
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Fatalf("openVerifiedProgram: error = %v, want %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(files, wantFiles) || !reflect.DeepEqual(args, tc.wantArgs) || env != tc.wantEnv {
+				t.Errorf("openVerifiedProgram: %v, %q, %q, want %v, %q, %q", files, args, env, wantFiles, tc.wantArgs, tc.wantEnv)
+			}
+			k.VisitIncomplete(func(s *stub.Stub[syscallDispatcher]) {
+				t.Helper()
+				t.Errorf("openVerifiedProgram: %d calls, want %d", s.Pos(), s.Len())
+			})
+		})
+	}
+
+	t.Run("write after verification", func(t *testing.T) {
+		t.Parallel()
+
+		name := filepath.Join(t.TempDir(), "program")
+		if err := os.WriteFile(name, []byte(sampleELF), 0700); err != nil {
+			t.Fatal(err)
+		}
+		fd, err := syscall.Open(name, flags, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []*os.File
+		if files, _, _, err = openVerifiedProgram(direct{}, message.New(nil), &Params{
+			Path:       check.MustAbs(name),
+			PathDigest: sum(sampleELF),
+		}, 3); err != nil {
+			_ = syscall.Close(fd)
+			t.Fatalf("openVerifiedProgram: error = %v", err)
+		}
+		_ = syscall.Close(fd)
+		t.Cleanup(func() { _ = files[0].Close() })
+
+		// same inode, as written through a writable bind mount
+		if f, err := os.OpenFile(name, os.O_WRONLY, 0); err != nil {
+			t.Fatal(err)
+		} else if _, err = f.WriteAt([]byte("\x00\x00\x00\x00"), 0); err != nil {
+			_ = f.Close()
+			t.Fatal(err)
+		} else if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := io.ReadAll(files[0]); err != nil {
+			t.Fatalf("ReadAll: error = %v", err)
+		} else if string(got) != sampleELF {
+			t.Errorf("ReadAll: %q, want %q", got, sampleELF)
+		}
+		if _, err = files[0].WriteAt([]byte("\x00"), 0); !errors.Is(err, syscall.EPERM) {
+			t.Errorf("WriteAt: error = %v, want %v", err, syscall.EPERM)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+#define _GNU_SOURCE
+#include "join.h"
+#include <errno.h>
+#include <fcntl.h>
+#include <limits.h>
+#include <linux/capability.h>
+#include <sched.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/prctl.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#ifndef SYS_pidfd_open
+#define SYS_pidfd_open 434
+#endif
+#ifndef SYS_close_range
+#define SYS_close_range 436
+#endif
+
+static pid_t hakurei_join_init = -1;
+
+static void hakurei_join_fatal(const char *step) {
+    fprintf(stderr, "init: cannot %s: %s\n", step, strerror(errno));
+    _exit(EXIT_FAILURE);
+}
+
+/* Returns whether the current process was started as container init, with its arguments read
+ * from procfs as not every C library passes them to constructors. */
+static int hakurei_join_is_init(void) {
+    if (getenv(HAKUREI_JOIN_SETUP_ENV) == NULL)
+        return 0;
+
+    char buf[sizeof(HAKUREI_JOIN_INIT_NAME) + 1];
+    int fd = open("/proc/self/cmdline", O_RDONLY | O_CLOEXEC);
+    if (fd == -1)
+        hakurei_join_fatal("open cmdline");
+    ssize_t n = read(fd, buf, sizeof(buf));
+    close(fd);
+    /* argv[0] followed by its terminating null byte */
+    return n >= (ssize_t)sizeof(HAKUREI_JOIN_INIT_NAME) &&
+           memcmp(buf, HAKUREI_JOIN_INIT_NAME, sizeof(HAKUREI_JOIN_INIT_NAME)) == 0;
+}
+
+/* Returns the start time of process pid in clock ticks since boot, as reported in its stat file. */
+static unsigned long long hakurei_join_start_time(pid_t pid) {
+    char pathname[32], buf[1024];
+    snprintf(pathname, sizeof(pathname), "/proc/%d/stat", (int)pid);
+    int fd = open(pathname, O_RDONLY | O_CLOEXEC);
+    if (fd == -1)
+        hakurei_join_fatal("open pod process stat");
+    ssize_t n = read(fd, buf, sizeof(buf) - 1);
+    close(fd);
+    if (n == -1)
+        hakurei_join_fatal("read pod process stat");
+    buf[n] = '\0';
+
+    /* comm may contain spaces, starttime is the twentieth field following it */
+    char *p = strrchr(buf, ')');
+    for (int i = 0; p != NULL && i < 20; i++)
+        p = strchr(p + 1, ' ');
+    errno = EINVAL;
+    if (p == NULL)
+        hakurei_join_fatal("parse pod process stat");
+
+    char *end;
+    errno = 0;
+    unsigned long long v = strtoull(p + 1, &end, 10);
+    if (errno == 0 && (end == p + 1 || *end != ' '))
+        errno = EINVAL;
+    if (errno != 0)
+        hakurei_join_fatal("parse pod process stat");
+    return v;
+}
+
+static void hakurei_join_forward(int sig) {
+    int savedErrno = errno;
+    if (hakurei_join_init > 0)
+        kill(hakurei_join_init, sig);
+    errno = savedErrno;
+}
+
+/* Joins the user, network, IPC, UTS and pid namespaces of the process specified by HAKUREI_JOIN_ENV,
+ * and creates the remaining namespaces of the container. Joining a user namespace requires a
+ * single-threaded process, so this must run before the Go runtime starts. Only container init
+ * interprets HAKUREI_JOIN_ENV, every other program linking this package ignores it.
+ *
+ * HAKUREI_JOIN_ENV holds the pid and start time of the process separated by a colon. The start
+ * time is checked after the process and its namespaces are opened, so a reused pid is never joined.
+ * The pid namespace joined is the one the process creates children in, as the process is either init
+ * of that namespace, or the process remaining in the parent pid namespace of a previous join.
+ *
+ * Container init is forked into the joined pid namespace and returns from this function, while the
+ * calling process remains in the parent pid namespace, forwards signals to container init, and
+ * terminates the same way container init does. Container init is not pid 1 of the joined namespace,
+ * so it is made a child subreaper to remain the parent of every process it starts. */
+__attribute__((constructor)) static void hakurei_join(void) {
+    const char *s = getenv(HAKUREI_JOIN_ENV);
+    if (s == NULL || !hakurei_join_is_init())
+        return;
+
+    char *end;
+    errno = 0;
+    long pid = strtol(s, &end, 10);
+    if (errno == 0 && (*s == '\0' || *end != ':' || pid < 1 || pid > INT_MAX))
+        errno = EINVAL;
+    if (errno != 0)
+        hakurei_join_fatal("parse " HAKUREI_JOIN_ENV);
+    s = end + 1;
+    unsigned long long start = strtoull(s, &end, 10);
+    if (errno == 0 && (*s < '0' || *s > '9' || *end != '\0' || start == 0))
+        errno = EINVAL;
+    if (errno != 0)
+        hakurei_join_fatal("parse " HAKUREI_JOIN_ENV);
+    if (unsetenv(HAKUREI_JOIN_ENV) != 0)
+        hakurei_join_fatal("unset " HAKUREI_JOIN_ENV);
+
+    int fd = (int)syscall(SYS_pidfd_open, (pid_t)pid, 0);
+    if (fd == -1)
+        hakurei_join_fatal("open pod process");
+    char pathname[48];
+    snprintf(pathname, sizeof(pathname), "/proc/%d/ns/pid_for_children", (int)pid);
+    int pidns = open(pathname, O_RDONLY | O_CLOEXEC);
+    if (pidns == -1)
+        hakurei_join_fatal("open pod pid namespace");
+    /* both refer to the process with this start time if it still matches after opening */
+    if (hakurei_join_start_time((pid_t)pid) != start) {
+        errno = ESRCH;
+        hakurei_join_fatal("verify pod process");
+    }
+    if (setns(fd, CLONE_NEWUSER | CLONE_NEWNET | CLONE_NEWIPC | CLONE_NEWUTS) != 0)
+        hakurei_join_fatal("join pod namespaces");
+    close(fd);
+    /* requires privileges in the joined user namespace, which owns the pid namespace */
+    if (setns(pidns, CLONE_NEWPID) != 0)
+        hakurei_join_fatal("join pod pid namespace");
+    close(pidns);
+
+    /* joining a user namespace clears the inheritable set, which must hold every
+     * capability container init raises in the ambient set */
+    struct __user_cap_header_struct hdr = {_LINUX_CAPABILITY_VERSION_3, 0};
+    struct __user_cap_data_struct data[_LINUX_CAPABILITY_U32S_3];
+    if (syscall(SYS_capget, &hdr, data) != 0)
+        hakurei_join_fatal("capget");
+    for (int i = 0; i < _LINUX_CAPABILITY_U32S_3; i++)
+        data[i].inheritable = data[i].permitted;
+    if (syscall(SYS_capset, &hdr, data) != 0)
+        hakurei_join_fatal("capset");
+
+    if (unshare(CLONE_NEWNS | CLONE_NEWCGROUP) != 0)
+        hakurei_join_fatal("create container namespaces");
+
+    /* handlers are installed after fork, so signals are blocked until then */
+    sigset_t all, old;
+    sigfillset(&all);
+    if (sigprocmask(SIG_SETMASK, &all, &old) != 0)
+        hakurei_join_fatal("block signals");
+
+    hakurei_join_init = fork();
+    if (hakurei_join_init == -1)
+        hakurei_join_fatal("fork container init");
+    if (hakurei_join_init == 0) {
+        /* if this process is killed before this point, container init fails
+         * to receive its setup payload as the parent of this process is gone */
+        if (prctl(PR_SET_PDEATHSIG, SIGKILL) != 0)
+            hakurei_join_fatal("set parent death signal");
+        /* orphans are otherwise reparented to init of the joined pid namespace */
+        if (prctl(PR_SET_CHILD_SUBREAPER, 1) != 0)
+            hakurei_join_fatal("become child subreaper");
+        if (sigprocmask(SIG_SETMASK, &old, NULL) != 0)
+            hakurei_join_fatal("restore signal mask");
+        return;
+    }
+
+    /* every inherited file is only meant for container init */
+    syscall(SYS_close_range, 3, ~0U, 0);
+
+    struct sigaction sa = {0};
+    sa.sa_handler = hakurei_join_forward;
+    sa.sa_flags = SA_RESTART;
+    sigemptyset(&sa.sa_mask);
+    for (int sig = 1; sig < NSIG; sig++) {
+        if (sig == SIGKILL || sig == SIGSTOP || sig == SIGCHLD)
+            continue;
+        /* fails for signals reserved by the C library */
+        sigaction(sig, &sa, NULL);
+    }
+    if (sigprocmask(SIG_SETMASK, &old, NULL) != 0)
+        hakurei_join_fatal("restore signal mask");
+
+    int status;
+    while (waitpid(hakurei_join_init, &status, 0) == -1)
+        if (errno != EINTR)
+            hakurei_join_fatal("wait for container init");
+    if (WIFEXITED(status))
+        _exit(WEXITSTATUS(status));
+
+    int sig = WTERMSIG(status);
+    signal(sig, SIG_DFL);
+    sigset_t set;
+    sigemptyset(&set);
+    sigaddset(&set, sig);
+    sigprocmask(SIG_UNBLOCK, &set, NULL);
+    raise(sig);
+    _exit(128 + sig);
+}
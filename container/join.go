@@ -0,0 +1,8 @@
+package container
+
+//#include "join.h"
+import "C"
+
+// joinEnv is the environment variable holding the pid of the process whose namespaces are
+// joined by container init. It is interpreted before the Go runtime starts, see join.c.
+const joinEnv = C.HAKUREI_JOIN_ENV
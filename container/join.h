@@ -0,0 +1,5 @@
+/* see join.c for documentation */
+#define HAKUREI_JOIN_ENV "HAKUREI_JOIN"
+/* must match setupEnv and initName */
+#define HAKUREI_JOIN_SETUP_ENV "HAKUREI_SETUP"
+#define HAKUREI_JOIN_INIT_NAME "init"
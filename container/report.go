@@ -0,0 +1,121 @@
+package container
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"strconv"
+	. "syscall"
+	"time"
+
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+const (
+	// reportReapedMax is the maximum number of [hst.ProcessReport] held by [hst.ExitReport.Reaped].
+	reportReapedMax = 1 << 10
+	// reportLingeringMax is the maximum number of [hst.LingeringProcess] held by [hst.ExitReport.Lingering].
+	reportLingeringMax = 1 << 8
+	// reportSizeMax is the maximum size of an encoded [hst.ExitReport] accepted by [Container].
+	reportSizeMax = 1 << 20
+)
+
+// newProcessReport returns a [hst.ProcessReport] describing a process reaped by wait4.
+func newProcessReport(pid int, wstatus WaitStatus, rusage *Rusage) hst.ProcessReport {
+	p := hst.ProcessReport{PID: pid, CoreDump: wstatus.CoreDump()}
+	if rusage != nil {
+		p.UserTime = time.Duration(rusage.Utime.Nano())
+		p.SystemTime = time.Duration(rusage.Stime.Nano())
+		p.MaxRSS = rusage.Maxrss
+	}
+
+	switch {
+	case wstatus.Exited():
+		p.Code = wstatus.ExitStatus()
+	case wstatus.Signaled():
+		p.Code = 128 + int(wstatus.Signal())
+		p.Signal = wstatus.Signal().String()
+	default:
+		p.Code = 255
+	}
+	return p
+}
+
+// reportReap records a reaped process in r.
+func reportReap(r *hst.ExitReport, initial bool, pid int, wstatus WaitStatus, rusage *Rusage) {
+	p := newProcessReport(pid, wstatus, rusage)
+	if initial {
+		r.Initial = &p
+	} else if len(r.Reaped) < reportReapedMax {
+		r.Reaped = append(r.Reaped, p)
+	} else {
+		r.Dropped++
+	}
+}
+
+// reportCollectLingering populates the Lingering field of r from processes visible in procfs,
+// excluding the container init identified by initPid. If init is not pid 1 of its pid namespace,
+// only its children are collected, as other processes belong to the rest of a pod.
+func reportCollectLingering(r *hst.ExitReport, k syscallDispatcher, msg message.Msg, initPid int) {
+	var pids []int
+	if initPid != 1 {
+		var err error
+		if pids, err = childProcesses(k, initPid); err != nil {
+			msg.Verbosef("cannot enumerate lingering processes: %v", err)
+			return
+		}
+	} else {
+		entries, err := k.readdir(fhs.Proc)
+		if err != nil {
+			msg.Verbosef("cannot enumerate lingering processes: %v", err)
+			return
+		}
+		for _, ent := range entries {
+			if pid, err := strconv.Atoi(ent.Name()); err == nil && pid != initPid {
+				pids = append(pids, pid)
+			}
+		}
+	}
+
+	for _, pid := range pids {
+		if len(r.Lingering) == reportLingeringMax {
+			break
+		}
+
+		p := hst.LingeringProcess{PID: pid}
+		if f, err := k.openNew(fhs.Proc + strconv.Itoa(pid) + "/comm"); err != nil {
+			msg.Verbosef("cannot open comm of process %d: %v", pid, err)
+		} else {
+			var data []byte
+			if data, err = io.ReadAll(io.LimitReader(f, 1<<6)); err != nil {
+				msg.Verbosef("cannot read comm of process %d: %v", pid, err)
+			}
+			_ = f.Close()
+			p.Comm = string(bytes.TrimSuffix(data, []byte{'\n'}))
+		}
+		r.Lingering = append(r.Lingering, p)
+	}
+}
+
+// encodeReport encodes r to f and closes it.
+func encodeReport(r *hst.ExitReport, f *os.File) error {
+	err := gob.NewEncoder(f).Encode(r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// receiveReport decodes an [hst.ExitReport] from f and closes it.
+func receiveReport(f *os.File) (*hst.ExitReport, error) {
+	var r hst.ExitReport
+	err := gob.NewDecoder(io.LimitReader(f, reportSizeMax)).Decode(&r)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,146 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+)
+
+func TestReportReap(t *testing.T) {
+	t.Parallel()
+
+	var r hst.ExitReport
+	reportReap(&r, false, 2, 0, nil)
+	reportReap(&r, true, 3, syscall.WaitStatus(syscall.SIGKILL)|0x80, &syscall.Rusage{
+		Utime:  syscall.Timeval{Sec: 1},
+		Stime:  syscall.Timeval{Usec: 2},
+		Maxrss: 0xbad,
+	})
+	for i := range reportReapedMax {
+		reportReap(&r, false, 4+i, 1<<8, &syscall.Rusage{})
+	}
+
+	if want := (&hst.ProcessReport{
+		PID:        3,
+		Code:       137,
+		Signal:     "killed",
+		CoreDump:   true,
+		UserTime:   time.Second,
+		SystemTime: 2 * time.Microsecond,
+		MaxRSS:     0xbad,
+	}); !reflect.DeepEqual(r.Initial, want) {
+		t.Errorf("reportReap: Initial = %#v, want %#v", r.Initial, want)
+	}
+	if len(r.Reaped) != reportReapedMax {
+		t.Errorf("reportReap: len(Reaped) = %d, want %d", len(r.Reaped), reportReapedMax)
+	}
+	if want := (hst.ProcessReport{PID: 2}); r.Reaped[0] != want {
+		t.Errorf("reportReap: Reaped[0] = %#v, want %#v", r.Reaped[0], want)
+	}
+	if want := (hst.ProcessReport{PID: 4, Code: 1}); r.Reaped[1] != want {
+		t.Errorf("reportReap: Reaped[1] = %#v, want %#v", r.Reaped[1], want)
+	}
+	if r.Dropped != 1 {
+		t.Errorf("reportReap: Dropped = %d, want 1", r.Dropped)
+	}
+}
+
+func TestReportCollectLingering(t *testing.T) {
+	t.Parallel()
+
+	checkSimple(t, "reportCollectLingering", []simpleTestCase{
+		{"readdir", func(k *kstub) error {
+			var r hst.ExitReport
+			reportCollectLingering(&r, k, k, 1)
+			if r.Lingering != nil {
+				t.Errorf("reportCollectLingering: Lingering = %#v", r.Lingering)
+			}
+			return nil
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir(), stub.UniqueError(0)),
+			call("verbosef", stub.ExpectArgs{"cannot enumerate lingering processes: %v", []any{stub.UniqueError(0)}}, nil, nil),
+		}}, nil},
+
+		{"success", func(k *kstub) error {
+			var r hst.ExitReport
+			reportCollectLingering(&r, k, k, 1)
+			if want := []hst.LingeringProcess{
+				{PID: 2, Comm: "baloo_file"},
+				{PID: 0xbad},
+			}; !reflect.DeepEqual(r.Lingering, want) {
+				t.Errorf("reportCollectLingering: Lingering = %#v, want %#v", r.Lingering, want)
+			}
+			return nil
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("1", "2", "self", "2989", "acpi"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2/comm"}, newConstFile("baloo_file\n"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/comm"}, (*readerOsFile)(nil), stub.UniqueError(1)),
+			call("verbosef", stub.ExpectArgs{"cannot open comm of process %d: %v", []any{0xbad, stub.UniqueError(1)}}, nil, nil),
+		}}, nil},
+
+		{"joined readdir", func(k *kstub) error {
+			var r hst.ExitReport
+			reportCollectLingering(&r, k, k, 0xcafe)
+			if r.Lingering != nil {
+				t.Errorf("reportCollectLingering: Lingering = %#v", r.Lingering)
+			}
+			return nil
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir(), stub.UniqueError(2)),
+			call("verbosef", stub.ExpectArgs{"cannot enumerate lingering processes: %v", []any{stub.UniqueError(2)}}, nil, nil),
+		}}, nil},
+
+		{"joined", func(k *kstub) error {
+			var r hst.ExitReport
+			reportCollectLingering(&r, k, k, 0xcafe)
+			if want := []hst.LingeringProcess{
+				{PID: 0xbad, Comm: "clangd"},
+			}; !reflect.DeepEqual(r.Lingering, want) {
+				t.Errorf("reportCollectLingering: Lingering = %#v, want %#v", r.Lingering, want)
+			}
+			return nil
+		}, stub.Expect{Calls: []stub.Call{
+			call("readdir", stub.ExpectArgs{"/proc/"}, stubDir("1", "51966", "2989"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/1/stat"}, newConstFile("1 (init) S 0 1 1 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/stat"}, newConstFile("2989 (clangd) S 51966 2989 2989 0 -1"), nil),
+			call("openNew", stub.ExpectArgs{"/proc/2989/comm"}, newConstFile("clangd\n"), nil),
+		}}, nil},
+	})
+}
+
+func TestEncodeReport(t *testing.T) {
+	t.Parallel()
+
+	want := &hst.ExitReport{
+		Code:    137,
+		Initial: &hst.ProcessReport{PID: 2, Code: 137, Signal: "killed", MaxRSS: 1 << 20},
+		Reaped:  []hst.ProcessReport{{PID: 3}},
+		Timeout: true,
+		Lingering: []hst.LingeringProcess{
+			{PID: 4, Comm: "clangd"},
+		},
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- encodeReport(want, w) }()
+
+	got, err := receiveReport(r)
+	if err != nil {
+		t.Fatalf("receiveReport: error = %v", err)
+	}
+	if err = <-done; err != nil {
+		t.Fatalf("encodeReport: error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("receiveReport: %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	. "syscall"
+	"time"
+
+	"hakurei.app/container/check"
+)
+
+// RestartPolicy specifies whether a [Program] is started again after it terminates.
+type RestartPolicy uint8
+
+const (
+	// RestartNever never starts the program again.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure starts the program again if it exits with a non-zero status or is killed by a signal.
+	RestartOnFailure
+	// RestartAlways starts the program again regardless of how it terminated.
+	RestartAlways
+)
+
+// String returns the name of the [RestartPolicy].
+func (r RestartPolicy) String() string {
+	switch r {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+
+	default:
+		return "invalid"
+	}
+}
+
+const (
+	// ProgramBackoffDefault is used as the delay before the first restart of a [Program] if [Program.Backoff] is zero.
+	ProgramBackoffDefault = 100 * time.Millisecond
+	// ProgramBackoffMax is the upper bound of the delay between restarts of a [Program].
+	// A program running for longer than this duration has its delay reset on termination.
+	ProgramBackoffMax = 30 * time.Second
+)
+
+// Program describes a process started and supervised by the container init alongside the initial program.
+type Program struct {
+	// Pathname of the program in the container.
+	Path *check.Absolute
+	// Program argv. Defaults to the string representation of Path if zero-length.
+	Args []string
+	// Program environment. Inherited from [Params.Env] if nil.
+	Env []string
+	// Working directory of the program. Inherited from [Params.Dir] if nil.
+	Dir *check.Absolute
+
+	// Whether the program is started again after it terminates.
+	Restart RestartPolicy
+	// Delay before the first restart, doubled on every consecutive restart up to [ProgramBackoffMax].
+	// Defaults to [ProgramBackoffDefault] if zero.
+	Backoff time.Duration
+	// Whether termination of this program terminates the container.
+	// This only happens once the program is no longer going to be started again, and applies
+	// [Params.Termination] to the initial process, or delivers SIGTERM if it is empty.
+	Critical bool
+}
+
+// shouldRestart returns whether a program terminating with wstatus is started again.
+func (r RestartPolicy) shouldRestart(wstatus WaitStatus) bool {
+	switch r {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return !wstatus.Exited() || wstatus.ExitStatus() != 0
+
+	default:
+		return false
+	}
+}
+
+// exitCode returns the exit code of init for a process terminating with wstatus.
+func exitCode(wstatus WaitStatus) int {
+	switch {
+	case wstatus.Exited():
+		return wstatus.ExitStatus()
+	case wstatus.Signaled():
+		return 128 + int(wstatus.Signal())
+	default:
+		return 255
+	}
+}
+
+// supervisedProgram holds the state of a [Program] supervised by the container init.
+type supervisedProgram struct {
+	*Program
+
+	// Current process, nil if the program is not running.
+	cmd *exec.Cmd
+	// Time the current process was started.
+	started time.Time
+	// Delay before the next restart.
+	delay time.Duration
+}
+
+// newCmd returns a new [exec.Cmd] for the program, inheriting unset fields from params.
+func (p *supervisedProgram) newCmd(params *Params) *exec.Cmd {
+	cmd := exec.Command(p.Path.String())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Args = p.Args
+	if len(cmd.Args) == 0 {
+		cmd.Args = []string{p.Path.String()}
+	}
+	cmd.Env = p.Env
+	if cmd.Env == nil {
+		cmd.Env = params.Env
+	}
+	cmd.Dir = params.Dir.String()
+	if p.Dir != nil {
+		cmd.Dir = p.Dir.String()
+	}
+	return cmd
+}
+
+// nextDelay returns the delay before restarting a program which terminated at now,
+// and advances the backoff state.
+func (p *supervisedProgram) nextDelay(now time.Time) time.Duration {
+	initial := p.Backoff
+	if initial <= 0 {
+		initial = ProgramBackoffDefault
+	}
+	if p.delay == 0 || now.Sub(p.started) > ProgramBackoffMax {
+		p.delay = initial
+	}
+
+	d := p.delay
+	p.delay = min(p.delay*2, ProgramBackoffMax)
+	return d
+}
@@ -0,0 +1,148 @@
+package container
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	t.Parallel()
+
+	const (
+		exited0  = syscall.WaitStatus(0)
+		exited1  = syscall.WaitStatus(1 << 8)
+		signaled = syscall.WaitStatus(syscall.SIGKILL)
+	)
+
+	testCases := []struct {
+		policy RestartPolicy
+		want   string
+		status [3]bool
+	}{
+		{RestartNever, "never", [3]bool{false, false, false}},
+		{RestartOnFailure, "on-failure", [3]bool{false, true, true}},
+		{RestartAlways, "always", [3]bool{true, true, true}},
+		{0xff, "invalid", [3]bool{false, false, false}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.policy.String(); got != tc.want {
+				t.Errorf("String: %q, want %q", got, tc.want)
+			}
+
+			for i, wstatus := range []syscall.WaitStatus{exited0, exited1, signaled} {
+				if got := tc.policy.shouldRestart(wstatus); got != tc.status[i] {
+					t.Errorf("shouldRestart(%#x): %v, want %v", wstatus, got, tc.status[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		wstatus syscall.WaitStatus
+		want    int
+	}{
+		{"exited", 0xbe << 8, 0xbe},
+		{"signaled", syscall.WaitStatus(syscall.SIGTERM), 128 + int(syscall.SIGTERM)},
+		{"stopped", 0x7f | syscall.WaitStatus(syscall.SIGSTOP)<<8, 255},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := exitCode(tc.wstatus); got != tc.want {
+				t.Errorf("exitCode: %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupervisedProgramNewCmd(t *testing.T) {
+	t.Parallel()
+
+	params := &Params{
+		Dir: fhs.AbsTmp,
+		Env: []string{"HOME=/tmp"},
+	}
+
+	testCases := []struct {
+		name    string
+		program Program
+		args    []string
+		env     []string
+		dir     string
+	}{
+		{"inherit", Program{
+			Path: check.MustAbs("/run/current-system/sw/bin/baloo_file"),
+		}, []string{"/run/current-system/sw/bin/baloo_file"}, []string{"HOME=/tmp"}, "/tmp/"},
+
+		{"override", Program{
+			Path: check.MustAbs("/run/current-system/sw/bin/clangd"),
+			Args: []string{"clangd", "--log=error"},
+			Env:  []string{},
+			Dir:  fhs.AbsRoot,
+		}, []string{"clangd", "--log=error"}, []string{}, "/"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := (&supervisedProgram{Program: &tc.program}).newCmd(params)
+			if cmd.Path != tc.program.Path.String() {
+				t.Errorf("newCmd: Path = %q, want %q", cmd.Path, tc.program.Path.String())
+			}
+			if !reflect.DeepEqual(cmd.Args, tc.args) {
+				t.Errorf("newCmd: Args = %#v, want %#v", cmd.Args, tc.args)
+			}
+			if !reflect.DeepEqual(cmd.Env, tc.env) {
+				t.Errorf("newCmd: Env = %#v, want %#v", cmd.Env, tc.env)
+			}
+			if cmd.Dir != tc.dir {
+				t.Errorf("newCmd: Dir = %q, want %q", cmd.Dir, tc.dir)
+			}
+		})
+	}
+}
+
+func TestSupervisedProgramNextDelay(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(0, 0)
+
+	testCases := []struct {
+		name    string
+		backoff time.Duration
+		uptime  []time.Duration
+		want    []time.Duration
+	}{
+		{"default", 0, []time.Duration{0, 0, 0}, []time.Duration{
+			ProgramBackoffDefault, ProgramBackoffDefault * 2, ProgramBackoffDefault * 4}},
+		{"cap", 10 * time.Second, []time.Duration{0, 0, 0, 0}, []time.Duration{
+			10 * time.Second, 20 * time.Second, ProgramBackoffMax, ProgramBackoffMax}},
+		{"reset", time.Second, []time.Duration{0, 0, ProgramBackoffMax + 1, 0}, []time.Duration{
+			time.Second, 2 * time.Second, time.Second, 2 * time.Second}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &supervisedProgram{Program: &Program{Backoff: tc.backoff}, started: start}
+			for i, uptime := range tc.uptime {
+				if got := p.nextDelay(start.Add(uptime)); got != tc.want[i] {
+					t.Errorf("nextDelay: [%d] %s, want %s", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
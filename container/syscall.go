@@ -3,6 +3,8 @@ package container
 import (
 	. "syscall"
 	"unsafe"
+
+	"hakurei.app/container/std"
 )
 
 // Prctl manipulates various aspects of the behavior of the calling thread or process.
@@ -29,6 +31,44 @@ func SetDumpable(dumpable uintptr) error { return Prctl(PR_SET_DUMPABLE, dumpabl
 // SetNoNewPrivs sets the calling thread's no_new_privs attribute.
 func SetNoNewPrivs() error { return Prctl(PR_SET_NO_NEW_PRIVS, 1, 0) }
 
+// linux/memfd.h
+const (
+	MFD_CLOEXEC       = 0x1
+	MFD_ALLOW_SEALING = 0x2
+	MFD_EXEC          = 0x10
+)
+
+// MemfdCreate creates an anonymous file with the specified name, used for debugging purposes only.
+func MemfdCreate(name string, flags uintptr) (fd int, err error) {
+	var p *byte
+	if p, err = BytePtrFromString(name); err != nil {
+		return -1, err
+	}
+	r, _, errno := Syscall(std.SYS_MEMFD_CREATE, uintptr(unsafe.Pointer(p)), flags, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
+
+// linux/fcntl.h
+const (
+	F_ADD_SEALS = 1033
+
+	F_SEAL_SEAL   = 0x1
+	F_SEAL_SHRINK = 0x2
+	F_SEAL_GROW   = 0x4
+	F_SEAL_WRITE  = 0x8
+)
+
+// AddSeals adds seals to a file created by [MemfdCreate] with [MFD_ALLOW_SEALING].
+func AddSeals(fd int, seals uintptr) error {
+	if _, _, errno := Syscall(SYS_FCNTL, uintptr(fd), F_ADD_SEALS, seals); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
 // Isatty tests whether a file descriptor refers to a terminal.
 func Isatty(fd int) bool {
 	var buf [8]byte
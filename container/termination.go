@@ -0,0 +1,28 @@
+package container
+
+import (
+	"syscall"
+	"time"
+)
+
+// TerminationStep is a step of the sequence applied by the container init on cancellation.
+type TerminationStep struct {
+	// Signal delivered to the initial process, or zero to only wait.
+	Signal syscall.Signal
+	// Whether Signal is delivered to the process group of the initial process. The initial process
+	// leads its own process group if any step sets this, which is placed in the foreground of the
+	// terminal on standard input if RetainSession is true.
+	Group bool
+	// Duration to wait for before the next step.
+	Wait time.Duration
+}
+
+// hasGroupStep returns whether any step delivers its signal to a process group.
+func hasGroupStep(steps []TerminationStep) bool {
+	for i := range steps {
+		if steps[i].Group && steps[i].Signal != 0 {
+			return true
+		}
+	}
+	return false
+}
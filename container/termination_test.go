@@ -0,0 +1,34 @@
+package container
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHasGroupStep(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		steps []TerminationStep
+		want  bool
+	}{
+		{"nil", nil, false},
+		{"process", []TerminationStep{{Signal: syscall.SIGTERM, Wait: time.Second}}, false},
+		{"group wait", []TerminationStep{{Group: true, Wait: time.Second}}, false},
+		{"group", []TerminationStep{
+			{Signal: syscall.SIGTERM, Wait: 10 * time.Second},
+			{Signal: syscall.SIGHUP, Group: true, Wait: 2 * time.Second},
+			{Signal: syscall.SIGKILL},
+		}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := hasGroupStep(tc.steps); got != tc.want {
+				t.Errorf("hasGroupStep: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package container
+
+import (
+	"strconv"
+	"time"
+)
+
+// TimeOffsets holds clock offsets applied in a new time namespace.
+type TimeOffsets struct {
+	// Offset of CLOCK_MONOTONIC in the container.
+	Monotonic time.Duration
+	// Offset of CLOCK_BOOTTIME in the container.
+	Boottime time.Duration
+}
+
+// appendOffset appends a line of /proc/pid/timens_offsets describing offset of clock to buf.
+func appendOffset(buf []byte, clock string, offset time.Duration) []byte {
+	secs, nsecs := offset/time.Second, offset%time.Second
+	// nanoseconds must be within [0, 999999999]
+	if nsecs < 0 {
+		secs--
+		nsecs += time.Second
+	}
+
+	buf = append(buf, clock...)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(secs), 10)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(nsecs), 10)
+	return append(buf, '\n')
+}
+
+// data returns the contents to write to /proc/pid/timens_offsets.
+func (t *TimeOffsets) data() []byte {
+	buf := make([]byte, 0, 1<<6)
+	buf = appendOffset(buf, "monotonic", t.Monotonic)
+	return appendOffset(buf, "boottime", t.Boottime)
+}
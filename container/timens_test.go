@@ -0,0 +1,34 @@
+package container
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOffsetsData(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		offsets TimeOffsets
+		want    string
+	}{
+		{"zero", TimeOffsets{}, "monotonic 0 0\nboottime 0 0\n"},
+		{"positive", TimeOffsets{
+			Monotonic: 24 * time.Hour,
+			Boottime:  3*time.Second + 5,
+		}, "monotonic 86400 0\nboottime 3 5\n"},
+		{"negative", TimeOffsets{
+			Monotonic: -time.Second,
+			Boottime:  -1500 * time.Millisecond,
+		}, "monotonic -1 0\nboottime -2 500000000\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(tc.offsets.data()); got != tc.want {
+				t.Errorf("data: %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package hst
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"math"
 	"strconv"
 	"strings"
 
@@ -23,18 +26,48 @@ type Config struct {
 	// System D-Bus proxy configuration.
 	// If set to nil, system bus proxy is disabled.
 	SystemBus *BusConfig `json:"system_bus,omitempty"`
+	// Enforce bus configuration with the built-in D-Bus proxy instead of xdg-dbus-proxy.
+	BuiltinDBusProxy bool `json:"builtin_dbus_proxy,omitempty"`
 	// Direct access to wayland socket, no attempt is made to attach security-context-v1
 	// and the bare socket is made available to the container.
 	DirectWayland bool `json:"direct_wayland,omitempty"`
+	// Wayland protocol filtering proxy policy, applied with or without security-context-v1.
+	// If set to nil, the Wayland socket is made available to the container without filtering.
+	WaylandProxy *WaylandConfig `json:"wayland_proxy,omitempty"`
+	// PulseAudio protocol filtering proxy policy.
+	// If set to nil, the PulseAudio socket is made available to the container as is.
+	PulseProxy *PulseConfig `json:"pulse_proxy,omitempty"`
+	// Grant X11 access via a per-instance authorization written to an Xauthority file instead of
+	// a server-interpreted host entry for the target user, and only expose the socket of the display.
+	X11Cookie bool `json:"x11_cookie,omitempty"`
+	// Copy the master cookie from the host Xauthority file if the X server does not implement
+	// the SECURITY extension. The copied cookie grants trusted access to the display and is not
+	// revoked with the instance. Has no effect unless X11Cookie is set.
+	X11MasterCookie bool `json:"x11_master_cookie,omitempty"`
+	// Place a generated .flatpak-info at the container root and at the root of the xdg-dbus-proxy
+	// container, identifying the container to xdg-desktop-portal as the application named by ID.
+	// Not supported with BuiltinDBusProxy. The per-application view of the document portal is only
+	// bound if it is owned by the container uid, as it is otherwise inaccessible to the container.
+	FlatpakInfo bool `json:"flatpak_info,omitempty"`
 
 	// Extra acl updates to perform before setuid.
 	ExtraPerms []ExtraPermConfig `json:"extra_perms,omitempty"`
+	// Host pathname sockets made available to the container.
+	Sockets []SocketConfig `json:"sockets,omitempty"`
 
 	// Numerical application id, passed to hsu, used to derive init user namespace credentials.
 	Identity int `json:"identity"`
 	// Init user namespace supplementary groups inherited by all container processes.
+	// These are mapped to the same gid and name in the container user namespace unless the gid is
+	// already mapped to the emulated user or a subordinate id.
 	Groups []string `json:"groups"`
 
+	// Name of the pod this instance belongs to, empty for a standalone instance.
+	// The first instance of a pod creates its user, network, IPC, UTS and pid namespaces, and
+	// later instances of the same identity join them. The shared pid namespace does not outlive
+	// the init of the first instance, so every member is killed once the first instance exits.
+	Pod string `json:"pod,omitempty"`
+
 	// High level configuration applied to the underlying [container].
 	Container *ContainerConfig `json:"container"`
 }
@@ -47,8 +80,28 @@ var (
 	// ErrIdentityBounds is returned by [Config.Validate] for an out of bounds [Config.Identity] value.
 	ErrIdentityBounds = errors.New("identity out of bounds")
 
-	// ErrEnviron is returned by [Config.Validate] if an environment variable name contains '=' or NUL.
+	// ErrPathDigest is returned by [Config.Validate] if [ContainerConfig.PathDigest] or
+	// [ContainerConfig.InterpreterDigest] is not a SHA-256 digest, or if only the latter is set.
+	ErrPathDigest = errors.New("invalid initial program digest")
+
+	// ErrEnviron is returned by [Config.Validate] if an environment variable name of [ContainerConfig.Env]
+	// or [ProgramConfig.Env] contains '=' or NUL, or for an invalid [ContainerConfig.EnvPassthrough] pattern.
 	ErrEnviron = errors.New("invalid environment variable name")
+
+	// ErrRestartPolicy is returned by [Config.Validate] for an unknown [ProgramConfig.Restart] value.
+	ErrRestartPolicy = errors.New("invalid restart policy")
+	// ErrTerminationStep is returned by [Config.Validate] for an invalid [TerminationStep].
+	ErrTerminationStep = errors.New("invalid termination step")
+	// ErrSubordinate is returned by [Config.Validate] for an invalid [SubordinateConfig].
+	ErrSubordinate = errors.New("invalid subordinate id range")
+	// ErrEtc is returned by [Config.Validate] for an invalid [EtcConfig].
+	ErrEtc = errors.New("invalid generated etc configuration")
+	// ErrWaylandFilter is returned by [Config.Validate] for an invalid [WaylandConfig] pattern.
+	ErrWaylandFilter = errors.New("invalid wayland filter pattern")
+	// ErrPod is returned by [Config.Validate] for a pod member with a configuration that cannot be shared.
+	ErrPod = errors.New("invalid pod configuration")
+	// ErrSocket is returned by [Config.Validate] for an invalid [SocketConfig].
+	ErrSocket = errors.New("invalid socket configuration")
 )
 
 // Validate checks [Config] and returns [AppError] if an invalid value is encountered.
@@ -71,6 +124,16 @@ func (config *Config) Validate() error {
 		return err
 	}
 
+	if err := config.WaylandProxy.validate(); err != nil {
+		return err
+	}
+
+	for i := range config.Sockets {
+		if err := config.Sockets[i].validate(i); err != nil {
+			return err
+		}
+	}
+
 	if config.Container == nil {
 		return &AppError{Step: "validate configuration", Err: ErrConfigNull,
 			Msg: "configuration missing container state"}
@@ -88,6 +151,23 @@ func (config *Config) Validate() error {
 			Msg: "container configuration missing path to initial program"}
 	}
 
+	if d := config.Container.PathDigest; d != "" {
+		if b, err := hex.DecodeString(d); err != nil || len(b) != sha256.Size {
+			return &AppError{Step: "validate configuration", Err: ErrPathDigest,
+				Msg: "invalid SHA-256 digest " + strconv.Quote(d) + " for initial program"}
+		}
+	}
+	if d := config.Container.InterpreterDigest; d != "" {
+		if config.Container.PathDigest == "" {
+			return &AppError{Step: "validate configuration", Err: ErrPathDigest,
+				Msg: "interpreter digest set without initial program digest"}
+		}
+		if b, err := hex.DecodeString(d); err != nil || len(b) != sha256.Size {
+			return &AppError{Step: "validate configuration", Err: ErrPathDigest,
+				Msg: "invalid SHA-256 digest " + strconv.Quote(d) + " for interpreter"}
+		}
+	}
+
 	for key := range config.Container.Env {
 		if strings.IndexByte(key, '=') != -1 || strings.IndexByte(key, 0) != -1 {
 			return &AppError{Step: "validate configuration", Err: ErrEnviron,
@@ -95,6 +175,70 @@ func (config *Config) Validate() error {
 		}
 	}
 
+	for _, pattern := range config.Container.EnvPassthrough {
+		if !validPassthrough(pattern) {
+			return &AppError{Step: "validate configuration", Err: ErrEnviron,
+				Msg: "invalid environment passthrough pattern " + strconv.Quote(pattern)}
+		}
+	}
+
+	for i := range config.Container.Programs {
+		p := &config.Container.Programs[i]
+		if p.Path == nil {
+			return &AppError{Step: "validate configuration", Err: ErrConfigNull,
+				Msg: "supervised program " + strconv.Itoa(i) + " missing path"}
+		}
+		if !validRestart(p.Restart) {
+			return &AppError{Step: "validate configuration", Err: ErrRestartPolicy,
+				Msg: "invalid restart policy " + strconv.Quote(p.Restart)}
+		}
+		for key := range p.Env {
+			if strings.IndexByte(key, '=') != -1 || strings.IndexByte(key, 0) != -1 {
+				return &AppError{Step: "validate configuration", Err: ErrEnviron,
+					Msg: "invalid environment variable " + strconv.Quote(key) + " for supervised program " + strconv.Itoa(i)}
+			}
+		}
+	}
+
+	for i := range config.Container.Termination {
+		step := &config.Container.Termination[i]
+		if _, ok := step.SignalValue(); !ok {
+			return &AppError{Step: "validate configuration", Err: ErrTerminationStep,
+				Msg: "unsupported signal " + strconv.Quote(step.Signal) + " in termination step " + strconv.Itoa(i)}
+		}
+		if step.Wait < 0 {
+			return &AppError{Step: "validate configuration", Err: ErrTerminationStep,
+				Msg: "negative wait in termination step " + strconv.Itoa(i)}
+		}
+	}
+
+	if sub := config.Container.Subordinate; sub != nil {
+		// this is checked again in hsu
+		if sub.Count < 1 || sub.Count > SubordinateSize {
+			return &AppError{Step: "validate configuration", Err: ErrSubordinate,
+				Msg: "subordinate id count " + strconv.Itoa(sub.Count) + " out of range"}
+		}
+		if sub.Start < 0 || sub.Start > math.MaxUint32-1-sub.Count {
+			return &AppError{Step: "validate configuration", Err: ErrSubordinate,
+				Msg: "subordinate id start " + strconv.Itoa(sub.Start) + " out of range"}
+		}
+	}
+
+	if err := config.Container.Etc.validate(); err != nil {
+		return err
+	}
+
+	if config.Pod != "" {
+		if config.Container.Flags&FHostNet != 0 {
+			return &AppError{Step: "validate configuration", Err: ErrPod,
+				Msg: "pod member " + strconv.Quote(config.Pod) + " cannot share the host net namespace"}
+		}
+		if config.Container.Time != nil {
+			return &AppError{Step: "validate configuration", Err: ErrPod,
+				Msg: "pod member " + strconv.Quote(config.Pod) + " cannot have clock offsets"}
+		}
+	}
+
 	return nil
 }
 
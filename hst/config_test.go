@@ -26,6 +26,15 @@ func TestConfigValidate(t *testing.T) {
 			&hst.BadInterfaceError{Interface: "", Segment: "session"}},
 		{"dbus system", &hst.Config{SystemBus: &hst.BusConfig{See: []string{""}}},
 			&hst.BadInterfaceError{Interface: "", Segment: "system"}},
+		{"wayland filter", &hst.Config{WaylandProxy: &hst.WaylandConfig{Deny: []string{"zwlr_*", "["}}},
+			&hst.AppError{Step: "validate configuration", Err: hst.ErrWaylandFilter,
+				Msg: `invalid Wayland filter pattern "["`}},
+		{"socket source", &hst.Config{Sockets: []hst.SocketConfig{{Source: "$SSH_AUTH_SOCK"}, {}}},
+			&hst.AppError{Step: "validate configuration", Err: hst.ErrSocket,
+				Msg: "invalid source pathname for socket 1"}},
+		{"socket env", &hst.Config{Sockets: []hst.SocketConfig{{Source: "/run/cups/cups.sock", Env: "CUPS_SERVER="}}},
+			&hst.AppError{Step: "validate configuration", Err: hst.ErrEnviron,
+				Msg: `invalid environment variable "CUPS_SERVER="`}},
 		{"container", &hst.Config{}, &hst.AppError{Step: "validate configuration", Err: hst.ErrConfigNull,
 			Msg: "configuration missing container state"}},
 		{"home", &hst.Config{Container: &hst.ContainerConfig{}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrConfigNull,
@@ -39,6 +48,28 @@ func TestConfigValidate(t *testing.T) {
 			Shell: fhs.AbsTmp,
 		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrConfigNull,
 			Msg: "container configuration missing path to initial program"}},
+		{"path digest", &hst.Config{Container: &hst.ContainerConfig{
+			Home:       fhs.AbsTmp,
+			Shell:      fhs.AbsTmp,
+			Path:       fhs.AbsTmp,
+			PathDigest: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b8",
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrPathDigest,
+			Msg: `invalid SHA-256 digest "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b8" for initial program`}},
+		{"interpreter digest only", &hst.Config{Container: &hst.ContainerConfig{
+			Home:              fhs.AbsTmp,
+			Shell:             fhs.AbsTmp,
+			Path:              fhs.AbsTmp,
+			InterpreterDigest: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrPathDigest,
+			Msg: "interpreter digest set without initial program digest"}},
+		{"interpreter digest", &hst.Config{Container: &hst.ContainerConfig{
+			Home:              fhs.AbsTmp,
+			Shell:             fhs.AbsTmp,
+			Path:              fhs.AbsTmp,
+			PathDigest:        "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			InterpreterDigest: "e3b0",
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrPathDigest,
+			Msg: `invalid SHA-256 digest "e3b0" for interpreter`}},
 		{"env equals", &hst.Config{Container: &hst.ContainerConfig{
 			Home:  fhs.AbsTmp,
 			Shell: fhs.AbsTmp,
@@ -53,6 +84,90 @@ func TestConfigValidate(t *testing.T) {
 			Env:   map[string]string{"TERM\x00": ""},
 		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrEnviron,
 			Msg: `invalid environment variable "TERM\x00"`}},
+		{"env passthrough", &hst.Config{Container: &hst.ContainerConfig{
+			Home:           fhs.AbsTmp,
+			Shell:          fhs.AbsTmp,
+			Path:           fhs.AbsTmp,
+			EnvPassthrough: []string{"LC_*", "QT_[A-Z"},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrEnviron,
+			Msg: `invalid environment passthrough pattern "QT_[A-Z"`}},
+		{"program path", &hst.Config{Container: &hst.ContainerConfig{
+			Home:     fhs.AbsTmp,
+			Shell:    fhs.AbsTmp,
+			Path:     fhs.AbsTmp,
+			Programs: []hst.ProgramConfig{{Path: fhs.AbsTmp}, {}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrConfigNull,
+			Msg: "supervised program 1 missing path"}},
+		{"program restart", &hst.Config{Container: &hst.ContainerConfig{
+			Home:     fhs.AbsTmp,
+			Shell:    fhs.AbsTmp,
+			Path:     fhs.AbsTmp,
+			Programs: []hst.ProgramConfig{{Path: fhs.AbsTmp, Restart: "sometimes"}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrRestartPolicy,
+			Msg: `invalid restart policy "sometimes"`}},
+		{"program env", &hst.Config{Container: &hst.ContainerConfig{
+			Home:     fhs.AbsTmp,
+			Shell:    fhs.AbsTmp,
+			Path:     fhs.AbsTmp,
+			Programs: []hst.ProgramConfig{{Path: fhs.AbsTmp}, {Path: fhs.AbsTmp, Env: map[string]string{"TERM=": ""}}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrEnviron,
+			Msg: `invalid environment variable "TERM=" for supervised program 1`}},
+		{"termination signal", &hst.Config{Container: &hst.ContainerConfig{
+			Home:        fhs.AbsTmp,
+			Shell:       fhs.AbsTmp,
+			Path:        fhs.AbsTmp,
+			Termination: []hst.TerminationStep{{Signal: "SIGTERM"}, {Signal: "SIGSEGV"}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrTerminationStep,
+			Msg: `unsupported signal "SIGSEGV" in termination step 1`}},
+		{"termination wait", &hst.Config{Container: &hst.ContainerConfig{
+			Home:        fhs.AbsTmp,
+			Shell:       fhs.AbsTmp,
+			Path:        fhs.AbsTmp,
+			Termination: []hst.TerminationStep{{Wait: -1}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrTerminationStep,
+			Msg: "negative wait in termination step 0"}},
+		{"subordinate count", &hst.Config{Container: &hst.ContainerConfig{
+			Home:        fhs.AbsTmp,
+			Shell:       fhs.AbsTmp,
+			Path:        fhs.AbsTmp,
+			Subordinate: &hst.SubordinateConfig{Count: hst.SubordinateSize + 1},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrSubordinate,
+			Msg: "subordinate id count 33 out of range"}},
+		{"subordinate start", &hst.Config{Container: &hst.ContainerConfig{
+			Home:        fhs.AbsTmp,
+			Shell:       fhs.AbsTmp,
+			Path:        fhs.AbsTmp,
+			Subordinate: &hst.SubordinateConfig{Start: -1, Count: 1},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrSubordinate,
+			Msg: "subordinate id start -1 out of range"}},
+		{"etc machine id", &hst.Config{Container: &hst.ContainerConfig{
+			Home:  fhs.AbsTmp,
+			Shell: fhs.AbsTmp,
+			Path:  fhs.AbsTmp,
+			Etc:   &hst.EtcConfig{MachineID: "host"},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrEtc,
+			Msg: `invalid machine-id scope "host"`}},
+		{"etc nameserver", &hst.Config{Container: &hst.ContainerConfig{
+			Home:  fhs.AbsTmp,
+			Shell: fhs.AbsTmp,
+			Path:  fhs.AbsTmp,
+			Etc:   &hst.EtcConfig{Nameservers: []string{"9.9.9.9", "dns.quad9.net"}},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrEtc,
+			Msg: `invalid nameserver "dns.quad9.net"`}},
+		{"pod host net", &hst.Config{Pod: "browser", Container: &hst.ContainerConfig{
+			Home:  fhs.AbsTmp,
+			Shell: fhs.AbsTmp,
+			Path:  fhs.AbsTmp,
+			Flags: hst.FHostNet,
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrPod,
+			Msg: `pod member "browser" cannot share the host net namespace`}},
+		{"pod time", &hst.Config{Pod: "browser", Container: &hst.ContainerConfig{
+			Home:  fhs.AbsTmp,
+			Shell: fhs.AbsTmp,
+			Path:  fhs.AbsTmp,
+			Time:  &hst.TimeConfig{Boottime: -1},
+		}}, &hst.AppError{Step: "validate configuration", Err: hst.ErrPod,
+			Msg: `pod member "browser" cannot have clock offsets`}},
 		{"valid", &hst.Config{Container: &hst.ContainerConfig{
 			Home:  fhs.AbsTmp,
 			Shell: fhs.AbsTmp,
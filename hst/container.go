@@ -18,7 +18,8 @@ var AbsPrivateTmp = check.MustAbs(PrivateTmp)
 const (
 	// WaitDelayDefault is used when WaitDelay has its zero value.
 	WaitDelayDefault = 5 * time.Second
-	// WaitDelayMax is used if WaitDelay exceeds its value.
+	// WaitDelayMax is used if WaitDelay exceeds its value, and bounds the total duration of Termination.
+	// The value in effect can be overridden when building hakurei.
 	WaitDelayMax = 30 * time.Second
 )
 
@@ -67,6 +68,16 @@ const (
 	// FShareTmpdir shares TMPDIR between containers under the same identity.
 	FShareTmpdir
 
+	// FPty allocates a new pseudo-terminal for the container, relayed by the shim.
+	//	The container never has access to the terminal hakurei runs in, this takes precedence
+	//	over session retention by [FTty] but does not affect its syscall filter behaviour.
+	FPty
+
+	// FFakeRoot maps the target user uid and gid to 0 in the container user namespace, and retains
+	// the namespaced capabilities required to act as root on files, under the usual syscall filter.
+	//	The emulated user is named root. This has no effect if [FMapRealUID] is set.
+	FFakeRoot
+
 	fMax
 
 	// FAll is [ContainerConfig.Flags] with all currently defined bits set.
@@ -97,6 +108,10 @@ func (flags Flags) String() string {
 		return "runtime"
 	case FShareTmpdir:
 		return "tmpdir"
+	case FPty:
+		return "pty"
+	case FFakeRoot:
+		return "fakeroot"
 
 	default:
 		s := make([]string, 0, 1<<4)
@@ -116,14 +131,35 @@ func (flags Flags) String() string {
 type ContainerConfig struct {
 	// Container UTS namespace hostname.
 	Hostname string `json:"hostname,omitempty"`
+	// Clock offsets applied in a new time namespace. The time namespace is shared if nil.
+	Time *TimeConfig `json:"time,omitempty"`
+	// Range of subordinate ids mapped in the container user namespace in addition to the emulated user.
+	// The backing ids are allocated to each identity by cmd/hsu from the block of its user, which starts
+	// at the id configured in hsurc, or at the default returned by [ToSubordinate] if unset.
+	Subordinate *SubordinateConfig `json:"subordinate,omitempty"`
+	// Files generated in /etc in place of their host counterparts, nil to leave /etc as configured.
+	Etc *EtcConfig `json:"etc,omitempty"`
 
 	// Duration in nanoseconds to wait for after interrupting the initial process.
 	// Defaults to [WaitDelayDefault] if zero, or [WaitDelayMax] if greater than [WaitDelayMax].
 	// Values lesser than zero is equivalent to zero, bypassing [WaitDelayDefault].
 	WaitDelay time.Duration `json:"wait_delay,omitempty"`
+	// Duration in nanoseconds to wait for the initial program to notify readiness.
+	// If non-zero, an sd_notify compatible socket is made available via NOTIFY_SOCKET,
+	// and the instance identifier is only written once READY=1 is received.
+	// Values lesser than zero wait indefinitely.
+	ReadyTimeout time.Duration `json:"ready_timeout,omitempty"`
+	// Ordered sequence of steps applied to terminate the initial program on cancellation.
+	// If non-empty, this takes precedence over WaitDelay, and the container is killed once
+	// every step has elapsed. A sequence whose total duration exceeds [WaitDelayMax] is rejected.
+	Termination []TerminationStep `json:"termination,omitempty"`
 
 	// Initial process environment variables.
 	Env map[string]string `json:"env"`
+	// Patterns accepted by [path.Match] naming host environment variables passed through to the
+	// initial process. Variables matching [EnvDenylist] are only passed through if named exactly.
+	// Variables set in Env take precedence.
+	EnvPassthrough []string `json:"env_passthrough,omitempty"`
 
 	/* Container mount points.
 
@@ -140,8 +176,18 @@ type ContainerConfig struct {
 
 	// Pathname to executable file in the container filesystem.
 	Path *check.Absolute `json:"path,omitempty"`
+	// Lowercase hexadecimal SHA-256 digest of the file at Path. If set, the file is copied to memory as it
+	// is verified and the copy is executed, and the container refuses to start on a mismatch.
+	// This requires procfs to be mounted at /proc in the container.
+	PathDigest string `json:"path_digest,omitempty"`
+	// Lowercase hexadecimal SHA-256 digest of the interpreter named by the interpreter line of the file
+	// at Path. Required if PathDigest is set and the file is a script. The interpreter must not itself
+	// be a script, and programs it resolves on its own, for example via /usr/bin/env, are not verified.
+	InterpreterDigest string `json:"interpreter_digest,omitempty"`
 	// Final args passed to the initial program.
 	Args []string `json:"args"`
+	// Programs supervised alongside the initial program, started in order before it.
+	Programs []ProgramConfig `json:"programs,omitempty"`
 
 	// Flags holds boolean options of [ContainerConfig].
 	Flags Flags `json:"-"`
@@ -181,6 +227,10 @@ type containerConfigJSON = struct {
 	ShareRuntime bool `json:"share_runtime,omitempty"`
 	// Corresponds to [FShareTmpdir]
 	ShareTmpdir bool `json:"share_tmpdir,omitempty"`
+	// Corresponds to [FPty]
+	Pty bool `json:"pty,omitempty"`
+	// Corresponds to [FFakeRoot]
+	FakeRoot bool `json:"fake_root,omitempty"`
 }
 
 func (c *ContainerConfig) MarshalJSON() ([]byte, error) {
@@ -201,6 +251,8 @@ func (c *ContainerConfig) MarshalJSON() ([]byte, error) {
 		Device:        c.Flags&FDevice != 0,
 		ShareRuntime:  c.Flags&FShareRuntime != 0,
 		ShareTmpdir:   c.Flags&FShareTmpdir != 0,
+		Pty:           c.Flags&FPty != 0,
+		FakeRoot:      c.Flags&FFakeRoot != 0,
 	})
 }
 
@@ -248,5 +300,11 @@ func (c *ContainerConfig) UnmarshalJSON(data []byte) error {
 	if v.ShareTmpdir {
 		c.Flags |= FShareTmpdir
 	}
+	if v.Pty {
+		c.Flags |= FPty
+	}
+	if v.FakeRoot {
+		c.Flags |= FFakeRoot
+	}
 	return nil
 }
@@ -22,7 +22,7 @@ func (e *BadInterfaceError) Error() string {
 	return "bad interface string " + strconv.Quote(e.Interface) + " in " + e.Segment + " bus configuration"
 }
 
-// BusConfig configures the xdg-dbus-proxy process.
+// BusConfig configures the xdg-dbus-proxy process or the built-in message bus proxy.
 type BusConfig struct {
 	// See set 'see' policy for NAME (--see=NAME)
 	See []string `json:"see"`
@@ -36,7 +36,7 @@ type BusConfig struct {
 	// Broadcast set RULE for broadcasts from NAME (--broadcast=NAME=RULE)
 	Broadcast map[string]string `json:"broadcast"`
 
-	// Log turn on logging (--log)
+	// Log turn on logging (--log), logged messages are recorded for hakurei dbus-log
 	Log bool `json:"log,omitempty"`
 	// Filter enable filtering (--filter)
 	Filter bool `json:"filter"`
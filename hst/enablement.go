@@ -19,6 +19,10 @@ const (
 	EDBus
 	// EPulse copies the PulseAudio cookie to [hst.PrivateTmp] and exposes the PulseAudio socket.
 	EPulse
+	// EIntegration exposes host locale, timezone, font and theme resources and passes through related variables.
+	EIntegration
+	// EPipeWire exposes a PipeWire pathname socket via a PipeWire security context.
+	EPipeWire
 
 	// EM is a noop.
 	EM
@@ -37,6 +41,10 @@ func (e Enablement) String() string {
 		return "dbus"
 	case EPulse:
 		return "pulseaudio"
+	case EIntegration:
+		return "integration"
+	case EPipeWire:
+		return "pipewire"
 	default:
 		buf := new(strings.Builder)
 		buf.Grow(32)
@@ -62,10 +70,12 @@ type Enablements Enablement
 
 // enablementsJSON is the [json] representation of [Enablements].
 type enablementsJSON = struct {
-	Wayland bool `json:"wayland,omitempty"`
-	X11     bool `json:"x11,omitempty"`
-	DBus    bool `json:"dbus,omitempty"`
-	Pulse   bool `json:"pulse,omitempty"`
+	Wayland     bool `json:"wayland,omitempty"`
+	X11         bool `json:"x11,omitempty"`
+	DBus        bool `json:"dbus,omitempty"`
+	Pulse       bool `json:"pulse,omitempty"`
+	Integration bool `json:"integration,omitempty"`
+	PipeWire    bool `json:"pipewire,omitempty"`
 }
 
 // Unwrap returns the underlying [Enablement].
@@ -81,10 +91,12 @@ func (e *Enablements) MarshalJSON() ([]byte, error) {
 		return nil, syscall.EINVAL
 	}
 	return json.Marshal(&enablementsJSON{
-		Wayland: Enablement(*e)&EWayland != 0,
-		X11:     Enablement(*e)&EX11 != 0,
-		DBus:    Enablement(*e)&EDBus != 0,
-		Pulse:   Enablement(*e)&EPulse != 0,
+		Wayland:     Enablement(*e)&EWayland != 0,
+		X11:         Enablement(*e)&EX11 != 0,
+		DBus:        Enablement(*e)&EDBus != 0,
+		Pulse:       Enablement(*e)&EPulse != 0,
+		Integration: Enablement(*e)&EIntegration != 0,
+		PipeWire:    Enablement(*e)&EPipeWire != 0,
 	})
 }
 
@@ -111,6 +123,12 @@ func (e *Enablements) UnmarshalJSON(data []byte) error {
 	if v.Pulse {
 		ve |= EPulse
 	}
+	if v.Integration {
+		ve |= EIntegration
+	}
+	if v.PipeWire {
+		ve |= EPipeWire
+	}
 	*e = Enablements(ve)
 	return nil
 }
@@ -32,8 +32,11 @@ func TestEnablementString(t *testing.T) {
 		{hst.EWayland | hst.EDBus | hst.EPulse, "wayland, dbus, pulseaudio"},
 		{hst.EX11 | hst.EDBus | hst.EPulse, "x11, dbus, pulseaudio"},
 		{hst.EWayland | hst.EX11 | hst.EDBus | hst.EPulse, "wayland, x11, dbus, pulseaudio"},
+		{hst.EIntegration, "integration"},
+		{hst.EWayland | hst.EIntegration, "wayland, integration"},
+		{hst.EPipeWire, "pipewire"},
+		{hst.EPulse | hst.EPipeWire, "pulseaudio, pipewire"},
 
-		{1 << 5, "e20"},
 		{1 << 6, "e40"},
 		{1 << 7, "e80"},
 	}
@@ -63,7 +66,9 @@ func TestEnablements(t *testing.T) {
 		{"x11", hst.NewEnablements(hst.EX11), `{"x11":true}`, `{"value":{"x11":true},"magic":3236757504}`},
 		{"dbus", hst.NewEnablements(hst.EDBus), `{"dbus":true}`, `{"value":{"dbus":true},"magic":3236757504}`},
 		{"pulse", hst.NewEnablements(hst.EPulse), `{"pulse":true}`, `{"value":{"pulse":true},"magic":3236757504}`},
-		{"all", hst.NewEnablements(hst.EWayland | hst.EX11 | hst.EDBus | hst.EPulse), `{"wayland":true,"x11":true,"dbus":true,"pulse":true}`, `{"value":{"wayland":true,"x11":true,"dbus":true,"pulse":true},"magic":3236757504}`},
+		{"integration", hst.NewEnablements(hst.EIntegration), `{"integration":true}`, `{"value":{"integration":true},"magic":3236757504}`},
+		{"pipewire", hst.NewEnablements(hst.EPipeWire), `{"pipewire":true}`, `{"value":{"pipewire":true},"magic":3236757504}`},
+		{"all", hst.NewEnablements(hst.EWayland | hst.EX11 | hst.EDBus | hst.EPulse | hst.EIntegration | hst.EPipeWire), `{"wayland":true,"x11":true,"dbus":true,"pulse":true,"integration":true,"pipewire":true}`, `{"value":{"wayland":true,"x11":true,"dbus":true,"pulse":true,"integration":true,"pipewire":true},"magic":3236757504}`},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,62 @@
+package hst
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// EnvDenylist holds patterns naming host environment variables that are not passed through by
+// [ContainerConfig.EnvPassthrough] unless named exactly. These either grant access to host services
+// or credentials, alter the behaviour of the dynamic linker or C library, describe the host session,
+// or are managed by hakurei.
+var EnvDenylist = []string{
+	"LD_*",
+	"GCONV_PATH",
+	"LOCPATH",
+	"HOSTALIASES",
+	"MALLOC_*",
+	"GLIBC_TUNABLES",
+	"DBUS_*",
+	"SSH_*",
+	"GPG_*",
+	"GNUPGHOME",
+	"KRB5CCNAME",
+	"SUDO_*",
+	"XAUTHORITY",
+	"DISPLAY",
+	"WAYLAND_*",
+	"PULSE_*",
+	"PIPEWIRE_*",
+	"XDG_RUNTIME_DIR",
+	"XDG_SESSION_*",
+	"NOTIFY_SOCKET",
+	"HAKUREI_*",
+}
+
+// validPassthrough returns whether pattern is a valid [ContainerConfig.EnvPassthrough] entry.
+func validPassthrough(pattern string) bool {
+	if pattern == "" || strings.IndexByte(pattern, '=') != -1 || strings.IndexByte(pattern, 0) != -1 {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// MatchPassthrough returns whether the host environment variable name is passed through by patterns.
+func MatchPassthrough(patterns []string, name string) bool {
+	if slices.Contains(patterns, name) {
+		return true
+	}
+	for _, pattern := range EnvDenylist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package hst_test
+
+import (
+	"testing"
+
+	"hakurei.app/hst"
+)
+
+func TestMatchPassthrough(t *testing.T) {
+	t.Parallel()
+
+	patterns := []string{"LANG", "LC_*", "QT_*", "XDG_CURRENT_DESKTOP", "SSH_AUTH_SOCK", "DBUS_*"}
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{"LANG", true},
+		{"LANGUAGE", false},
+		{"LC_ALL", true},
+		{"QT_QPA_PLATFORM", true},
+		{"XDG_CURRENT_DESKTOP", true},
+		{"XDG_SESSION_TYPE", false},
+		{"SSH_AUTH_SOCK", true},
+		{"SSH_AGENT_PID", false},
+		{"DBUS_SESSION_BUS_ADDRESS", false},
+		{"LD_PRELOAD", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := hst.MatchPassthrough(patterns, tc.name); got != tc.want {
+				t.Errorf("MatchPassthrough: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnvDenylist(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		class string
+		names []string
+	}{
+		{"dynamic linker", []string{"LD_PRELOAD", "LD_LIBRARY_PATH", "LD_AUDIT"}},
+		{"c library", []string{"GCONV_PATH", "LOCPATH", "HOSTALIASES", "MALLOC_CHECK_", "MALLOC_ARENA_MAX", "GLIBC_TUNABLES"}},
+		{"credentials", []string{"SSH_AUTH_SOCK", "GPG_AGENT_INFO", "GNUPGHOME", "KRB5CCNAME", "SUDO_USER"}},
+		{"session", []string{"XDG_SESSION_ID", "XDG_SESSION_TYPE", "XDG_RUNTIME_DIR", "DBUS_SESSION_BUS_ADDRESS"}},
+		{"display", []string{"XAUTHORITY", "DISPLAY", "WAYLAND_DISPLAY"}},
+		{"audio", []string{"PULSE_SERVER", "PIPEWIRE_REMOTE", "PIPEWIRE_RUNTIME_DIR"}},
+		{"hakurei", []string{"NOTIFY_SOCKET", "HAKUREI_SHIM"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.class, func(t *testing.T) {
+			t.Parallel()
+			for _, name := range tc.names {
+				if hst.MatchPassthrough([]string{"*"}, name) {
+					t.Errorf("MatchPassthrough: %s passed through by wildcard", name)
+				}
+				if !hst.MatchPassthrough([]string{name}, name) {
+					t.Errorf("MatchPassthrough: %s not passed through when named exactly", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package hst
+
+import (
+	"net/netip"
+	"strconv"
+)
+
+const (
+	// MachineIDApp generates a machine-id derived from the host machine-id and the application identity.
+	// This is the default when [EtcConfig.MachineID] is empty.
+	MachineIDApp = "app"
+	// MachineIDInstance generates a machine-id derived from the instance identifier.
+	MachineIDInstance = "instance"
+)
+
+// EtcConfig describes files generated in /etc in place of their host counterparts.
+// These are never symlinked from the host by autoetc.
+type EtcConfig struct {
+	// Nameservers in the generated resolv.conf. If empty, resolv.conf is generated empty
+	// for a container in its own net namespace, and is otherwise left to the host.
+	Nameservers []string `json:"nameservers,omitempty"`
+	// Scope of the generated machine-id, one of [MachineIDApp] or [MachineIDInstance].
+	// The host machine-id is never made available.
+	MachineID string `json:"machine_id,omitempty"`
+}
+
+// validate returns [AppError] for an invalid value in [EtcConfig].
+func (c *EtcConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	switch c.MachineID {
+	case "", MachineIDApp, MachineIDInstance:
+	default:
+		return &AppError{Step: "validate configuration", Err: ErrEtc,
+			Msg: "invalid machine-id scope " + strconv.Quote(c.MachineID)}
+	}
+
+	for _, s := range c.Nameservers {
+		if _, err := netip.ParseAddr(s); err != nil {
+			return &AppError{Step: "validate configuration", Err: ErrEtc,
+				Msg: "invalid nameserver " + strconv.Quote(s)}
+		}
+	}
+	return nil
+}
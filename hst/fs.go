@@ -124,6 +124,12 @@ func (f *FilesystemConfigJSON) MarshalJSON() ([]byte, error) {
 			*FSLink
 		}{fsType{FilesystemLink}, cv}
 
+	case *FSMinimal:
+		v = &struct {
+			fsType
+			*FSMinimal
+		}{fsType{FilesystemMinimal}, cv}
+
 	default:
 		return nil, FSImplError{f.FilesystemConfig}
 	}
@@ -152,6 +158,9 @@ func (f *FilesystemConfigJSON) UnmarshalJSON(data []byte) error {
 	case FilesystemLink:
 		*f = FilesystemConfigJSON{new(FSLink)}
 
+	case FilesystemMinimal:
+		*f = FilesystemConfigJSON{new(FSMinimal)}
+
 	default:
 		return FSTypeError(t.Type)
 	}
@@ -84,6 +84,15 @@ func TestFilesystemConfigJSON(t *testing.T) {
 		}, nil,
 			`{"type":"link","dst":"/run/current-system","linkname":"/run/current-system","dereference":true}`,
 			`{"fs":{"type":"link","dst":"/run/current-system","linkname":"/run/current-system","dereference":true},"magic":3236757504}`},
+
+		{"minimal", hst.FilesystemConfigJSON{
+			FilesystemConfig: &hst.FSMinimal{
+				Programs: ms("/usr/bin/curl"),
+				Data:     ms("/etc/ssl"),
+			},
+		}, nil,
+			`{"type":"minimal","programs":["/usr/bin/curl"],"data":["/etc/ssl"]}`,
+			`{"fs":{"type":"minimal","programs":["/usr/bin/curl"],"data":["/etc/ssl"]},"magic":3236757504}`},
 	}
 
 	for _, tc := range testCases {
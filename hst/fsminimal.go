@@ -0,0 +1,79 @@
+package hst
+
+import (
+	"encoding/gob"
+	"slices"
+	"strings"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+)
+
+func init() { gob.Register(new(FSMinimal)) }
+
+// FilesystemMinimal is the type string of a minimal root filesystem.
+const FilesystemMinimal = "minimal"
+
+// FSMinimal represents a read-only root filesystem holding only the files required by a set of programs.
+// Every pathname is made available in the container at its host pathname.
+type FSMinimal struct {
+	// Executable files in the init mount namespace. Must not be empty.
+	Programs []*check.Absolute `json:"programs"`
+	// Directories holding data required by Programs.
+	Data []*check.Absolute `json:"data,omitempty"`
+
+	// Shared library dependencies and script interpreters of Programs, including the program started
+	// by an env(1) interpreter, looked up in PATH of the container. This is not serialised,
+	// and must be resolved in the init mount namespace before Apply is called.
+	Dependencies []*check.Absolute `json:"-"`
+}
+
+func (m *FSMinimal) Valid() bool {
+	if m == nil || len(m.Programs) == 0 {
+		return false
+	}
+	return !slices.Contains(m.Programs, nil) &&
+		!slices.Contains(m.Data, nil) &&
+		!slices.Contains(m.Dependencies, nil)
+}
+
+func (m *FSMinimal) Path() *check.Absolute {
+	if !m.Valid() {
+		return nil
+	}
+	return fhs.AbsRoot
+}
+
+func (m *FSMinimal) Host() []*check.Absolute {
+	if !m.Valid() {
+		return nil
+	}
+	p := make([]*check.Absolute, 0, len(m.Programs)+len(m.Dependencies)+len(m.Data))
+	p = append(p, m.Programs...)
+	p = append(p, m.Dependencies...)
+	p = append(p, m.Data...)
+	check.SortAbs(p)
+	return check.CompactAbs(p)
+}
+
+func (m *FSMinimal) Apply(z *ApplyState) {
+	if !m.Valid() {
+		return
+	}
+	// parent directories sort before their contents
+	for _, a := range m.Host() {
+		z.Bind(a, a, 0)
+	}
+}
+
+func (m *FSMinimal) String() string {
+	if !m.Valid() {
+		return "<invalid>"
+	}
+
+	programs := make([]string, len(m.Programs))
+	for i, a := range m.Programs {
+		programs[i] = a.String()
+	}
+	return FilesystemMinimal + ":" + strings.Join(programs, ",")
+}
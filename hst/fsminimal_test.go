@@ -0,0 +1,50 @@
+package hst_test
+
+import (
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/hst"
+)
+
+func TestFSMinimal(t *testing.T) {
+	t.Parallel()
+
+	checkFs(t, []fsTestCase{
+		{"nil", (*hst.FSMinimal)(nil), false, nil, nil, nil, "<invalid>"},
+		{"zero", new(hst.FSMinimal), false, nil, nil, nil, "<invalid>"},
+		{"nil program", &hst.FSMinimal{Programs: append(ms("/usr/bin/curl"), nil)},
+			false, nil, nil, nil, "<invalid>"},
+		{"nil data", &hst.FSMinimal{
+			Programs: ms("/usr/bin/curl"),
+			Data:     append(ms("/usr/share/ca-certificates"), nil),
+		}, false, nil, nil, nil, "<invalid>"},
+
+		{"resolved", &hst.FSMinimal{
+			Programs: ms("/usr/bin/jq", "/usr/bin/curl"),
+			Data:     ms("/etc/ssl", "/usr/share/ca-certificates"),
+			Dependencies: ms(
+				"/lib/ld-musl-x86_64.so.1",
+				"/usr/lib/libcurl.so.4",
+				"/lib/ld-musl-x86_64.so.1",
+				"/usr/lib/libjq.so.1",
+			),
+		}, true, container.Ops{
+			&container.BindMountOp{Source: m("/etc/ssl"), Target: m("/etc/ssl")},
+			&container.BindMountOp{Source: m("/lib/ld-musl-x86_64.so.1"), Target: m("/lib/ld-musl-x86_64.so.1")},
+			&container.BindMountOp{Source: m("/usr/bin/curl"), Target: m("/usr/bin/curl")},
+			&container.BindMountOp{Source: m("/usr/bin/jq"), Target: m("/usr/bin/jq")},
+			&container.BindMountOp{Source: m("/usr/lib/libcurl.so.4"), Target: m("/usr/lib/libcurl.so.4")},
+			&container.BindMountOp{Source: m("/usr/lib/libjq.so.1"), Target: m("/usr/lib/libjq.so.1")},
+			&container.BindMountOp{Source: m("/usr/share/ca-certificates"), Target: m("/usr/share/ca-certificates")},
+		}, m("/"), ms(
+			"/etc/ssl",
+			"/lib/ld-musl-x86_64.so.1",
+			"/usr/bin/curl",
+			"/usr/bin/jq",
+			"/usr/lib/libcurl.so.4",
+			"/usr/lib/libjq.so.1",
+			"/usr/share/ca-certificates",
+		), "minimal:/usr/bin/jq,/usr/bin/curl"},
+	})
+}
@@ -27,6 +27,20 @@ const (
 	IsolatedStart = RangeSize * 9
 	// IsolatedEnd is the end of UID and GID for fully isolated sandboxed processes.
 	IsolatedEnd = IsolatedStart + RangeSize - 1
+
+	// SubordinateStart is the first subordinate UID and GID of the default allocation, above every
+	// possible app user UID and GID and past the range systemd allocates to containers.
+	SubordinateStart = 0x70000000
+	// SubordinateEnd is the end of the default subordinate allocation, where the range systemd
+	// reserves for foreign UIDs and GIDs begins.
+	SubordinateEnd = 0x7ffe0000
+	// SubordinateSize is the number of subordinate UIDs and GIDs allocated to each identity of each user.
+	SubordinateSize = 1 << 5
+	// SubordinateBlock is the number of subordinate UIDs and GIDs allocated to each user.
+	SubordinateBlock = RangeSize * SubordinateSize
+	// SubordinateUsers is the number of users with a block in the default subordinate allocation.
+	// Blocks of other users must be configured explicitly.
+	SubordinateUsers = (SubordinateEnd - SubordinateStart) / SubordinateBlock
 )
 
 // A UID represents a kernel uid in the init namespace.
@@ -36,6 +50,13 @@ type UID uint32
 //
 // Not safe against untrusted input.
 func (uid UID) String() string {
+	if uid >= SubordinateStart && uid < SubordinateStart+SubordinateUsers*SubordinateBlock {
+		pair := (uid - SubordinateStart) / SubordinateSize
+		return fmt.Sprintf("u%d_a%d_s%d", pair/RangeSize, pair%RangeSize, (uid-SubordinateStart)%SubordinateSize)
+	} else if uid >= SubordinateStart {
+		return strconv.Itoa(int(uid))
+	}
+
 	appid := uid % UserOffset
 	userid := uid / UserOffset
 	if appid >= IsolatedStart && appid <= IsolatedEnd {
@@ -59,3 +80,11 @@ func (gid GID) String() string { return UID(gid).String() }
 //
 // Not safe against untrusted input.
 func ToUser[U int | uint32](userid, appid U) U { return userid*UserOffset + AppStart + appid }
+
+// ToSubordinate returns the first subordinate [hst.UID] value allocated to userid and appid
+// in the default allocation. Users with an explicitly configured block are not covered.
+//
+// Not safe against untrusted input.
+func ToSubordinate[U int | uint32](userid, appid U) U {
+	return SubordinateStart + (userid*RangeSize+appid)*SubordinateSize
+}
@@ -23,6 +23,12 @@ func TestUIDString(t *testing.T) {
 		{hst.ToUser[uint32](10, 127), "u10_a127"},
 		{hst.ToUser[uint32](11, 127), "u11_a127"},
 
+		{hst.SubordinateStart, "u0_a0_s0"}, // subordinateStart
+		{hst.ToSubordinate[uint32](hst.SubordinateUsers-1, hst.IdentityEnd) + hst.SubordinateSize - 1, "u837_a9999_s31"}, // subordinateEnd
+		{hst.ToSubordinate[uint32](hst.SubordinateUsers, 0), "2147208192"},
+		{hst.SubordinateEnd, "2147352576"},
+		{hst.ToSubordinate[uint32](10, 127) + 3, "u10_a127_s3"},
+
 		{0, "0"}, // out of bounds
 	}
 	for _, tc := range testCases {
@@ -246,7 +246,9 @@ func TestTemplate(t *testing.T) {
 		"map_real_uid": true,
 		"device": true,
 		"share_runtime": true,
-		"share_tmpdir": true
+		"share_tmpdir": true,
+		"pty": true,
+		"fake_root": true
 	}
 }`
 
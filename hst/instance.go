@@ -78,10 +78,21 @@ type State struct {
 	PID int `json:"pid"`
 	// Shim process pid. Runs as the target user.
 	ShimPID int `json:"shim_pid"`
+	// Pid of a process in the user, network, IPC and UTS namespaces of the container, which creates
+	// children in the pid namespace of the container. Recorded once the container starts if it is a
+	// pod member or has ids mapped externally, zero otherwise.
+	InitPID int `json:"init_pid,omitempty"`
+	// Start time of InitPID in clock ticks since boot, checked before its namespaces are joined.
+	InitStartTime uint64 `json:"init_start_time,omitempty"`
 
 	// Configuration used to start the container.
 	*Config
 
 	// Point in time the shim process was created.
 	Time time.Time `json:"time"`
+
+	// Whether the initial program notified readiness.
+	Ready bool `json:"ready,omitempty"`
+	// Most recent STATUS= message sent by the initial program.
+	Status string `json:"status,omitempty"`
 }
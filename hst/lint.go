@@ -0,0 +1,226 @@
+package hst
+
+import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"hakurei.app/container/check"
+)
+
+// Severity is the severity of a [Finding].
+type Severity int
+
+const (
+	// SeverityInfo describes a configuration that is usually intended but is worth a review.
+	SeverityInfo Severity = iota
+	// SeverityWarning describes a configuration that significantly weakens the sandbox.
+	SeverityWarning
+	// SeverityCritical describes a configuration that likely allows escaping the sandbox.
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "severity(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < SeverityInfo || s > SeverityCritical {
+		return nil, syscall.EINVAL
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(data []byte) error {
+	for v := SeverityInfo; v <= SeverityCritical; v++ {
+		if string(data) == v.String() {
+			*s = v
+			return nil
+		}
+	}
+	return syscall.EINVAL
+}
+
+// Finding describes a risky configuration reported by [Config.Lint].
+type Finding struct {
+	// How much the configuration weakens the sandbox.
+	Severity Severity `json:"severity"`
+	// Name of the offending field in the [json] representation of [Config].
+	Field string `json:"field"`
+	// Explanation of the risk.
+	Message string `json:"message"`
+}
+
+func (f *Finding) String() string { return f.Severity.String() + ": " + f.Field + ": " + f.Message }
+
+// busEscapeNames are well-known bus names of services that run arbitrary commands on behalf of their
+// callers, outside the sandbox.
+var busEscapeNames = []string{
+	"org.freedesktop.systemd1",
+	"org.freedesktop.Flatpak",
+}
+
+// Lint returns [Finding] for every risky configuration in [Config], in the order they appear.
+// Lint does not validate [Config], and reports nothing for fields holding invalid values.
+//
+// Home directories are identified by pathname only, as the user running the container is
+// not known ahead of time: these are /root, /home and its direct descendants.
+func (config *Config) Lint() (findings []Finding) {
+	if config == nil {
+		return
+	}
+	report := func(severity Severity, field, message string) {
+		findings = append(findings, Finding{severity, field, message})
+	}
+
+	if config.Enablements.Unwrap()&EDBus != 0 {
+		lintBus(report, "session_bus", config.SessionBus, config.ID)
+		lintBus(report, "system_bus", config.SystemBus, config.ID)
+	}
+
+	if config.DirectWayland && config.Enablements.Unwrap()&EWayland != 0 {
+		report(SeverityWarning, "direct_wayland",
+			"the bare Wayland socket exposes privileged protocols such as screen capture and input emulation")
+	}
+
+	if config.X11MasterCookie && config.X11Cookie && config.Enablements.Unwrap()&EX11 != 0 {
+		report(SeverityWarning, "x11_master_cookie",
+			"on an X server without the SECURITY extension, the master cookie grants trusted access to the display and outlives the instance")
+	}
+
+	for i, p := range config.ExtraPerms {
+		if p.Path == nil || !isHomeDir(p.Path) {
+			continue
+		}
+		severity := SeverityWarning
+		if p.Write {
+			severity = SeverityCritical
+		}
+		report(severity, "extra_perms["+strconv.Itoa(i)+"]",
+			"acl update on home directory "+p.Path.String()+" grants the container user access to another user's files")
+	}
+
+	if config.Container == nil {
+		return
+	}
+	c := config.Container
+
+	if c.Flags&FDevice != 0 {
+		report(SeverityCritical, "container.device",
+			"host /dev is mounted as is, exposing every device node accessible to the container user")
+	}
+	if c.Flags&FHostNet != 0 {
+		report(SeverityInfo, "container.host_net",
+			"the host network namespace is shared, exposing services listening on loopback")
+	}
+	if c.Flags&FHostAbstract != 0 {
+		report(SeverityWarning, "container.host_abstract",
+			"abstract unix sockets of the host, such as those of X11 and D-Bus, are reachable without filtering")
+	}
+	if c.Flags&FDevel != 0 && c.Flags&FUserns != 0 {
+		report(SeverityWarning, "container.devel",
+			"ptrace combined with user namespace creation greatly increases kernel attack surface")
+	}
+	if c.Flags&FUserns != 0 && len(config.Groups) > 0 {
+		report(SeverityWarning, "container.userns",
+			"nested user namespaces can drop supplementary groups, bypassing permissions denied to a group")
+	}
+	if c.Flags&FFakeRoot != 0 && c.Flags&FMapRealUID == 0 {
+		report(SeverityInfo, "container.fake_root",
+			"the container user appears as root and retains namespaced capabilities over its files")
+	}
+
+	for i, f := range c.Filesystem {
+		b, ok := f.FilesystemConfig.(*FSBind)
+		if !ok || !b.Valid() || !(b.Write || b.Device) {
+			continue
+		}
+		field := "container.filesystem[" + strconv.Itoa(i) + "]"
+		if b.Source.String() == "/" {
+			report(SeverityCritical, field, "the host root filesystem is mounted writable")
+		} else if isHomeDir(b.Source) {
+			report(SeverityCritical, field,
+				"home directory "+b.Source.String()+" is mounted writable, allowing persistence via shell and autostart files")
+		}
+	}
+
+	return
+}
+
+// lintBus reports risky rules in a [BusConfig] named by field.
+func lintBus(report func(severity Severity, field, message string), field string, c *BusConfig, id string) {
+	if c == nil {
+		return
+	}
+	if !c.Filter {
+		report(SeverityCritical, field+".filter",
+			"filtering is disabled, every name on the "+strings.TrimSuffix(field, "_bus")+" bus is reachable")
+		return
+	}
+
+	escape := func(policy string, pattern string) {
+		for _, name := range busEscapeNames {
+			if busNameMatch(pattern, name) {
+				report(SeverityCritical, field+"."+policy,
+					strconv.Quote(pattern)+" grants access to "+name+", which runs arbitrary commands outside the sandbox")
+			}
+		}
+	}
+	for _, name := range c.Talk {
+		escape("talk", name)
+	}
+	for _, name := range c.Own {
+		escape("own", name)
+
+		if prefix, ok := strings.CutSuffix(name, ".*"); ok && !busNameOwned(prefix, id) {
+			report(SeverityWarning, field+".own",
+				strconv.Quote(name)+" allows impersonating any service under "+prefix+", which is outside the application identifier")
+		}
+	}
+	names := make([]string, 0, len(c.Call))
+	for name := range c.Call {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		escape("call", name)
+	}
+}
+
+// busNameMatch returns whether a name pattern as accepted by xdg-dbus-proxy matches name.
+func busNameMatch(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, ".*"); ok {
+		return name == prefix || strings.HasPrefix(name, prefix+".")
+	}
+	return pattern == name
+}
+
+// busNameOwned returns whether prefix is id or the MPRIS name of id, or a name under either.
+func busNameOwned(prefix, id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, owned := range []string{id, "org.mpris.MediaPlayer2." + id} {
+		if prefix == owned || strings.HasPrefix(prefix, owned+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isHomeDir returns whether pathname likely refers to a home directory, or the directory holding them.
+func isHomeDir(pathname *check.Absolute) bool {
+	s := pathname.String()
+	return s == "/root" || s == "/home" || path.Dir(s) == "/home"
+}
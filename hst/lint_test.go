@@ -0,0 +1,192 @@
+package hst_test
+
+import (
+	"reflect"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+)
+
+func TestConfigLint(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		config *hst.Config
+		want   []hst.Finding
+	}{
+		{"nil", nil, nil},
+
+		{"dbus disabled", &hst.Config{
+			SessionBus: &hst.BusConfig{Talk: []string{"org.freedesktop.systemd1"}, Filter: true},
+		}, nil},
+		{"dbus unfiltered", &hst.Config{
+			Enablements: hst.NewEnablements(hst.EDBus),
+			SystemBus:   &hst.BusConfig{Talk: []string{"org.freedesktop.systemd1"}},
+		}, []hst.Finding{
+			{Severity: hst.SeverityCritical, Field: "system_bus.filter", Message: "filtering is disabled, every name on the system bus is reachable"},
+		}},
+		{"dbus escape", &hst.Config{
+			ID:          "org.example.App",
+			Enablements: hst.NewEnablements(hst.EDBus),
+			SessionBus: &hst.BusConfig{
+				Talk: []string{"org.freedesktop.portal.*", "org.freedesktop.Flatpak"},
+				Own:  []string{"org.example.App.*", "org.mpris.MediaPlayer2.org.example.App.*", "org.*"},
+				Call: map[string]string{
+					"org.freedesktop.systemd1":  "*",
+					"org.freedesktop.Flatpak.*": "*",
+				},
+				Filter: true,
+			},
+		}, []hst.Finding{
+			{Severity: hst.SeverityCritical, Field: "session_bus.talk",
+				Message: `"org.freedesktop.Flatpak" grants access to org.freedesktop.Flatpak, which runs arbitrary commands outside the sandbox`},
+			{Severity: hst.SeverityCritical, Field: "session_bus.own",
+				Message: `"org.*" grants access to org.freedesktop.systemd1, which runs arbitrary commands outside the sandbox`},
+			{Severity: hst.SeverityCritical, Field: "session_bus.own",
+				Message: `"org.*" grants access to org.freedesktop.Flatpak, which runs arbitrary commands outside the sandbox`},
+			{Severity: hst.SeverityWarning, Field: "session_bus.own",
+				Message: `"org.*" allows impersonating any service under org, which is outside the application identifier`},
+			{Severity: hst.SeverityCritical, Field: "session_bus.call",
+				Message: `"org.freedesktop.Flatpak.*" grants access to org.freedesktop.Flatpak, which runs arbitrary commands outside the sandbox`},
+			{Severity: hst.SeverityCritical, Field: "session_bus.call",
+				Message: `"org.freedesktop.systemd1" grants access to org.freedesktop.systemd1, which runs arbitrary commands outside the sandbox`},
+		}},
+		{"dbus own without id", &hst.Config{
+			Enablements: hst.NewEnablements(hst.EDBus),
+			SessionBus:  &hst.BusConfig{Own: []string{"org.example.App.*"}, Filter: true},
+		}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "session_bus.own",
+				Message: `"org.example.App.*" allows impersonating any service under org.example.App, which is outside the application identifier`},
+		}},
+
+		{"dbus own containing id", &hst.Config{
+			ID:          "org.example.App",
+			Enablements: hst.NewEnablements(hst.EDBus),
+			SessionBus: &hst.BusConfig{Own: []string{
+				"org.example.App.Helper.*",
+				"com.evil.org.example.App.*",
+				"org.mpris.MediaPlayer2.evil.org.example.App.*",
+			}, Filter: true},
+		}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "session_bus.own",
+				Message: `"com.evil.org.example.App.*" allows impersonating any service under com.evil.org.example.App, which is outside the application identifier`},
+			{Severity: hst.SeverityWarning, Field: "session_bus.own",
+				Message: `"org.mpris.MediaPlayer2.evil.org.example.App.*" allows impersonating any service under org.mpris.MediaPlayer2.evil.org.example.App, which is outside the application identifier`},
+		}},
+
+		{"direct wayland disabled", &hst.Config{DirectWayland: true}, nil},
+		{"direct wayland", &hst.Config{
+			Enablements:   hst.NewEnablements(hst.EWayland),
+			DirectWayland: true,
+		}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "direct_wayland",
+				Message: "the bare Wayland socket exposes privileged protocols such as screen capture and input emulation"},
+		}},
+
+		{"x11 master cookie disabled", &hst.Config{X11Cookie: true, X11MasterCookie: true}, nil},
+		{"x11 master cookie without cookie", &hst.Config{
+			Enablements:     hst.NewEnablements(hst.EX11),
+			X11MasterCookie: true,
+		}, nil},
+		{"x11 master cookie", &hst.Config{
+			Enablements:     hst.NewEnablements(hst.EX11),
+			X11Cookie:       true,
+			X11MasterCookie: true,
+		}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "x11_master_cookie",
+				Message: "on an X server without the SECURITY extension, the master cookie grants trusted access to the display and outlives the instance"},
+		}},
+
+		{"extra perms", &hst.Config{ExtraPerms: []hst.ExtraPermConfig{
+			{Path: nil},
+			{Path: check.MustAbs("/var/lib/hakurei/u0"), Read: true, Execute: true},
+			{Path: check.MustAbs("/home/user"), Execute: true},
+			{Path: check.MustAbs("/home/user/.local"), Write: true},
+			{Path: check.MustAbs("/root"), Read: true, Write: true},
+		}}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "extra_perms[2]",
+				Message: "acl update on home directory /home/user grants the container user access to another user's files"},
+			{Severity: hst.SeverityCritical, Field: "extra_perms[4]",
+				Message: "acl update on home directory /root grants the container user access to another user's files"},
+		}},
+
+		{"flags", &hst.Config{Container: &hst.ContainerConfig{
+			Flags: hst.FDevice | hst.FHostNet | hst.FHostAbstract | hst.FDevel | hst.FUserns,
+		}}, []hst.Finding{
+			{Severity: hst.SeverityCritical, Field: "container.device",
+				Message: "host /dev is mounted as is, exposing every device node accessible to the container user"},
+			{Severity: hst.SeverityInfo, Field: "container.host_net",
+				Message: "the host network namespace is shared, exposing services listening on loopback"},
+			{Severity: hst.SeverityWarning, Field: "container.host_abstract",
+				Message: "abstract unix sockets of the host, such as those of X11 and D-Bus, are reachable without filtering"},
+			{Severity: hst.SeverityWarning, Field: "container.devel",
+				Message: "ptrace combined with user namespace creation greatly increases kernel attack surface"},
+		}},
+		{"devel", &hst.Config{Container: &hst.ContainerConfig{Flags: hst.FDevel}}, nil},
+		{"userns without groups", &hst.Config{Container: &hst.ContainerConfig{Flags: hst.FUserns}}, nil},
+		{"userns", &hst.Config{Groups: []string{"video"}, Container: &hst.ContainerConfig{Flags: hst.FUserns}}, []hst.Finding{
+			{Severity: hst.SeverityWarning, Field: "container.userns",
+				Message: "nested user namespaces can drop supplementary groups, bypassing permissions denied to a group"},
+		}},
+		{"fake root real uid", &hst.Config{Container: &hst.ContainerConfig{Flags: hst.FFakeRoot | hst.FMapRealUID}}, nil},
+		{"fake root", &hst.Config{Container: &hst.ContainerConfig{Flags: hst.FFakeRoot}}, []hst.Finding{
+			{Severity: hst.SeverityInfo, Field: "container.fake_root",
+				Message: "the container user appears as root and retains namespaced capabilities over its files"},
+		}},
+
+		{"filesystem", &hst.Config{Container: &hst.ContainerConfig{Filesystem: []hst.FilesystemConfigJSON{
+			{FilesystemConfig: &hst.FSBind{Target: fhs.AbsRoot, Source: fhs.AbsRoot, Special: true}},
+			{FilesystemConfig: &hst.FSBind{Target: fhs.AbsRoot, Source: fhs.AbsRoot, Write: true, Special: true}},
+			{FilesystemConfig: &hst.FSBind{Source: check.MustAbs("/home/user")}},
+			{FilesystemConfig: &hst.FSBind{Source: check.MustAbs("/home/user"), Write: true}},
+			{FilesystemConfig: &hst.FSBind{Source: check.MustAbs("/home/user/Downloads"), Write: true}},
+			{FilesystemConfig: &hst.FSBind{Source: check.MustAbs("/home"), Device: true}},
+			{FilesystemConfig: &hst.FSEphemeral{Target: check.MustAbs("/root"), Write: true}},
+			{},
+		}}}, []hst.Finding{
+			{Severity: hst.SeverityCritical, Field: "container.filesystem[1]", Message: "the host root filesystem is mounted writable"},
+			{Severity: hst.SeverityCritical, Field: "container.filesystem[3]",
+				Message: "home directory /home/user is mounted writable, allowing persistence via shell and autostart files"},
+			{Severity: hst.SeverityCritical, Field: "container.filesystem[5]",
+				Message: "home directory /home is mounted writable, allowing persistence via shell and autostart files"},
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.config.Lint(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Lint:\n%#v\nwant\n%#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	t.Parallel()
+
+	for s := hst.SeverityInfo; s <= hst.SeverityCritical; s++ {
+		if data, err := s.MarshalText(); err != nil {
+			t.Fatalf("MarshalText: error = %v", err)
+		} else {
+			var got hst.Severity
+			if err = got.UnmarshalText(data); err != nil {
+				t.Fatalf("UnmarshalText: error = %v", err)
+			} else if got != s {
+				t.Errorf("UnmarshalText: %v, want %v", got, s)
+			}
+		}
+	}
+
+	if _, err := hst.Severity(0xbad).MarshalText(); err == nil {
+		t.Errorf("MarshalText: unexpected success")
+	}
+	if got := hst.Severity(0xbad).String(); got != "severity(2989)" {
+		t.Errorf("String: %q", got)
+	}
+	if err := new(hst.Severity).UnmarshalText([]byte("fatal")); err == nil {
+		t.Errorf("UnmarshalText: unexpected success")
+	}
+}
@@ -0,0 +1,47 @@
+package hst
+
+import (
+	"time"
+
+	"hakurei.app/container/check"
+)
+
+// Restart policies of [ProgramConfig].
+const (
+	// RestartNever never starts the program again. This is the default.
+	RestartNever = "never"
+	// RestartOnFailure starts the program again if it exits with a non-zero status or is killed by a signal.
+	RestartOnFailure = "on-failure"
+	// RestartAlways starts the program again regardless of how it terminated.
+	RestartAlways = "always"
+)
+
+// ProgramConfig describes a program supervised by the container init alongside the initial program.
+type ProgramConfig struct {
+	// Pathname to executable file in the container filesystem.
+	Path *check.Absolute `json:"path"`
+	// Final args passed to the program. Defaults to the string representation of Path.
+	Args []string `json:"args,omitempty"`
+	// Environment variables set for the program on top of the environment of the initial program.
+	Env map[string]string `json:"env,omitempty"`
+	// Working directory of the program in the container. Defaults to the working directory of the initial program.
+	Dir *check.Absolute `json:"dir,omitempty"`
+
+	// One of [RestartNever], [RestartOnFailure] or [RestartAlways]. Defaults to [RestartNever] if empty.
+	Restart string `json:"restart,omitempty"`
+	// Duration in nanoseconds to wait for before the first restart, doubled on every consecutive restart.
+	Backoff time.Duration `json:"backoff,omitempty"`
+	// Whether termination of the program terminates the container once it is no longer going to be started again.
+	// The initial program is terminated by the termination sequence if one is configured, or by SIGTERM otherwise.
+	Critical bool `json:"critical,omitempty"`
+}
+
+// validRestart returns whether s is a valid restart policy.
+func validRestart(s string) bool {
+	switch s {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+		return true
+	default:
+		return false
+	}
+}
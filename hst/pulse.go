@@ -0,0 +1,14 @@
+package hst
+
+// PulseConfig is the policy of the PulseAudio protocol filtering proxy. Every field is
+// a permission granted on top of playback and operations on streams owned by the connection.
+type PulseConfig struct {
+	// Allow record streams, granting access to capture devices and monitor sources.
+	Record bool `json:"record,omitempty"`
+	// Allow loading and unloading server modules, and calling module extensions.
+	Modules bool `json:"modules,omitempty"`
+	// Allow introspection of clients, streams, modules, cards and samples, and subscribing to events.
+	Introspect bool `json:"introspect,omitempty"`
+	// Allow changing the state of devices, server defaults and streams not owned by the connection.
+	Control bool `json:"control,omitempty"`
+}
@@ -0,0 +1,52 @@
+package hst
+
+import "time"
+
+// ProcessReport describes how a process in the container terminated.
+type ProcessReport struct {
+	// Process id in the container pid namespace.
+	PID int `json:"pid"`
+	// Exit code, or 128 plus the signal number if the process was killed by a signal.
+	Code int `json:"code"`
+	// Description of the signal killing the process.
+	Signal string `json:"signal,omitempty"`
+	// Whether the process dumped core.
+	CoreDump bool `json:"core_dump,omitempty"`
+
+	// User CPU time consumed.
+	UserTime time.Duration `json:"utime"`
+	// System CPU time consumed.
+	SystemTime time.Duration `json:"stime"`
+	// Maximum resident set size in kilobytes.
+	MaxRSS int64 `json:"maxrss"`
+}
+
+// LingeringProcess describes a process killed as the container terminates.
+type LingeringProcess struct {
+	// Process id in the container pid namespace.
+	PID int `json:"pid"`
+	// Command name of the process.
+	Comm string `json:"comm"`
+}
+
+// ExitReport describes how a container terminated, as observed by its init and the shim.
+type ExitReport struct {
+	// Exit code of the container init, or -1 if it was killed by a signal.
+	Code int `json:"code"`
+	// Description of the signal killing the container init.
+	Signal string `json:"signal,omitempty"`
+	// Whether the container init was killed after WaitDelay has elapsed following cancellation.
+	Killed bool `json:"killed,omitempty"`
+
+	// The initial process, nil if it was not reported by the container init.
+	Initial *ProcessReport `json:"initial,omitempty"`
+	// Other processes reaped by the container init.
+	Reaped []ProcessReport `json:"reaped,omitempty"`
+	// Number of reaped processes omitted from Reaped.
+	Dropped int `json:"dropped,omitempty"`
+
+	// Whether the container init stopped waiting for lingering processes after its timeout has elapsed.
+	Timeout bool `json:"timeout,omitempty"`
+	// Processes killed as the container init exits, only populated if Timeout is true.
+	Lingering []LingeringProcess `json:"lingering,omitempty"`
+}
@@ -0,0 +1,46 @@
+package hst
+
+import (
+	"strconv"
+	"strings"
+
+	"hakurei.app/container/check"
+)
+
+// SocketConfig describes a host pathname socket made available to the container.
+//
+// The target user is granted write access to the socket if it is not already writable by others.
+// Unless Link is set, it is also granted search access to every parent directory of a socket within
+// XDG_RUNTIME_DIR not already searchable by others, while a socket elsewhere is refused if any of its
+// parent directories is not searchable by others. These are reverted once no container of the identity remains.
+type SocketConfig struct {
+	// Pathname to the socket in the init mount namespace. Environment variables of the priv-side
+	// caller referenced as $NAME or ${NAME} are expanded, for example "$XDG_RUNTIME_DIR/ssh-agent".
+	Source string `json:"src"`
+	// Pathname to the socket in the container mount namespace.
+	// If nil, the socket is placed in XDG_RUNTIME_DIR under the name of Source.
+	Target *check.Absolute `json:"dst,omitempty"`
+	// Name of the environment variable set to the pathname of the socket in the container.
+	Env string `json:"env,omitempty"`
+	// String prepended to the pathname in the value of Env, for example "unix://" for DOCKER_HOST.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Hard link the socket to a directory private to the instance instead of updating the acl of
+	// its parent directories. Source must reside on the same filesystem as XDG_RUNTIME_DIR.
+	Link bool `json:"link,omitempty"`
+	// Silently skip this socket if Source does not exist or references an unset variable.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// validate returns [AppError] for an invalid value in [SocketConfig].
+func (s *SocketConfig) validate(i int) error {
+	if s.Source == "" || strings.IndexByte(s.Source, 0) != -1 {
+		return &AppError{Step: "validate configuration", Err: ErrSocket,
+			Msg: "invalid source pathname for socket " + strconv.Itoa(i)}
+	}
+	if s.Env != "" && (strings.IndexByte(s.Env, '=') != -1 || strings.IndexByte(s.Env, 0) != -1) {
+		return &AppError{Step: "validate configuration", Err: ErrEnviron,
+			Msg: "invalid environment variable " + strconv.Quote(s.Env)}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package hst
+
+// SubordinateConfig describes a range of subordinate ids mapped in the container user namespace.
+type SubordinateConfig struct {
+	// First uid and gid of the range in the container user namespace.
+	Start int `json:"start"`
+	// Number of ids in the range, must not exceed [SubordinateSize].
+	Count int `json:"count"`
+}
+
+// Contains returns whether id falls within the range described by [SubordinateConfig].
+func (c *SubordinateConfig) Contains(id int) bool {
+	return c != nil && id >= c.Start && id < c.Start+c.Count
+}
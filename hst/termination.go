@@ -0,0 +1,61 @@
+package hst
+
+import (
+	"math"
+	"syscall"
+	"time"
+)
+
+// TerminationStep describes a step of the sequence applied to terminate the initial program.
+type TerminationStep struct {
+	// Name of the signal to deliver, for example "SIGTERM". No signal is delivered if empty.
+	Signal string `json:"signal,omitempty"`
+	// Whether Signal is delivered to the process group of the initial program. The initial program
+	// leads its own process group if any step sets this, which is placed in the foreground of the
+	// terminal if the terminal session is retained via [FTty].
+	Group bool `json:"group,omitempty"`
+	// Duration in nanoseconds to wait for before the next step.
+	Wait time.Duration `json:"wait,omitempty"`
+}
+
+// terminationSignals holds signals accepted by [TerminationStep.Signal].
+var terminationSignals = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGPIPE":  syscall.SIGPIPE,
+	"SIGALRM":  syscall.SIGALRM,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGSTOP":  syscall.SIGSTOP,
+	"SIGWINCH": syscall.SIGWINCH,
+	"SIGPWR":   syscall.SIGPWR,
+}
+
+// SignalValue returns the signal named by Signal, or zero if Signal is empty.
+// The ok result is false if Signal does not name a supported signal.
+func (s *TerminationStep) SignalValue() (sig syscall.Signal, ok bool) {
+	if s.Signal == "" {
+		return 0, true
+	}
+	sig, ok = terminationSignals[s.Signal]
+	return
+}
+
+// TerminationDelay returns the total duration of steps, saturating instead of overflowing.
+// Negative waits do not contribute to the total.
+func TerminationDelay(steps []TerminationStep) time.Duration {
+	var d time.Duration
+	for i := range steps {
+		if w := steps[i].Wait; w > 0 {
+			if d > math.MaxInt64-w {
+				return math.MaxInt64
+			}
+			d += w
+		}
+	}
+	return d
+}
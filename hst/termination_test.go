@@ -0,0 +1,68 @@
+package hst_test
+
+import (
+	"math"
+	"syscall"
+	"testing"
+	"time"
+
+	"hakurei.app/hst"
+)
+
+func TestTerminationStepSignalValue(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		signal string
+		want   syscall.Signal
+		ok     bool
+	}{
+		{"", 0, true},
+		{"SIGTERM", syscall.SIGTERM, true},
+		{"SIGHUP", syscall.SIGHUP, true},
+		{"SIGKILL", syscall.SIGKILL, true},
+		{"SIGSEGV", 0, false},
+		{"TERM", 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.signal, func(t *testing.T) {
+			t.Parallel()
+			if got, ok := (&hst.TerminationStep{Signal: tc.signal}).SignalValue(); got != tc.want || ok != tc.ok {
+				t.Errorf("SignalValue: %v, %v, want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestTerminationDelay(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		steps []hst.TerminationStep
+		want  time.Duration
+	}{
+		{"nil", nil, 0},
+		{"sum", []hst.TerminationStep{
+			{Signal: "SIGTERM", Wait: 10 * time.Second},
+			{Signal: "SIGHUP", Group: true, Wait: 2 * time.Second},
+			{Signal: "SIGKILL"},
+		}, 12 * time.Second},
+		{"negative", []hst.TerminationStep{
+			{Signal: "SIGTERM", Wait: -time.Second},
+			{Signal: "SIGKILL", Wait: time.Second},
+		}, time.Second},
+		{"saturate", []hst.TerminationStep{
+			{Signal: "SIGTERM", Wait: math.MaxInt64 - 1},
+			{Signal: "SIGKILL", Wait: 2},
+		}, math.MaxInt64},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := hst.TerminationDelay(tc.steps); got != tc.want {
+				t.Errorf("TerminationDelay: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package hst
+
+import "time"
+
+// TimeConfig describes clock offsets applied in a new time namespace.
+type TimeConfig struct {
+	// Duration in nanoseconds added to CLOCK_MONOTONIC in the container.
+	Monotonic time.Duration `json:"monotonic,omitempty"`
+	// Duration in nanoseconds added to CLOCK_BOOTTIME in the container.
+	// Negative values hide the real boot time of the host.
+	Boottime time.Duration `json:"boottime,omitempty"`
+}
@@ -0,0 +1,53 @@
+package hst
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// WaylandConfig is the policy of the Wayland protocol filtering proxy. Globals are matched by
+// interface name against [path.Match] patterns, for example "zwlr_screencopy_*",
+// "zwp_input_method_*" or "wl_data_device_manager".
+type WaylandConfig struct {
+	// Patterns matching globals advertised to the container.
+	// If set to nil, every global not matched by Deny is advertised.
+	Allow []string `json:"allow,omitempty"`
+	// Patterns matching globals hidden from the container. Takes precedence over Allow.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// validate returns [AppError] wrapping [ErrWaylandFilter] for an invalid pattern.
+func (c *WaylandConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	for _, patterns := range [][]string{c.Allow, c.Deny} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" || strings.IndexByte(pattern, 0) != -1 {
+				return &AppError{Step: "validate configuration", Err: ErrWaylandFilter,
+					Msg: "invalid Wayland filter pattern " + strconv.Quote(pattern)}
+			}
+		}
+	}
+	return nil
+}
+
+// Advertise returns whether the global implementing the named interface is advertised to the container.
+func (c *WaylandConfig) Advertise(name string) bool {
+	for _, pattern := range c.Deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if c.Allow == nil {
+		return true
+	}
+	for _, pattern := range c.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
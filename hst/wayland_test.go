@@ -0,0 +1,37 @@
+package hst_test
+
+import (
+	"testing"
+
+	"hakurei.app/hst"
+)
+
+func TestWaylandConfigAdvertise(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		config hst.WaylandConfig
+		iface  string
+		want   bool
+	}{
+		{"zero", hst.WaylandConfig{}, "zwlr_screencopy_manager_v1", true},
+		{"deny", hst.WaylandConfig{Deny: []string{"zwlr_screencopy_*"}}, "zwlr_screencopy_manager_v1", false},
+		{"deny other", hst.WaylandConfig{Deny: []string{"zwlr_screencopy_*"}}, "wl_compositor", true},
+		{"allow", hst.WaylandConfig{Allow: []string{"wl_*", "xdg_wm_base"}}, "xdg_wm_base", true},
+		{"allow other", hst.WaylandConfig{Allow: []string{"wl_*", "xdg_wm_base"}}, "zwp_input_method_manager_v2", false},
+		{"allow empty", hst.WaylandConfig{Allow: []string{}}, "wl_compositor", false},
+		{"deny precedence", hst.WaylandConfig{
+			Allow: []string{"wl_*"},
+			Deny:  []string{"wl_data_device_manager"},
+		}, "wl_data_device_manager", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.config.Advertise(tc.iface); got != tc.want {
+				t.Errorf("Advertise: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -17,17 +17,29 @@ const (
 )
 
 // Update replaces ACL_USER entry with qualifier uid.
-func Update(name string, uid int, perms ...Perm) error {
+func Update(name string, uid int, perms ...Perm) error { return update(name, uid, false, perms) }
+
+// Merge adds perms to the ACL_USER entry with qualifier uid, creating it if it does not exist.
+// Permissions already granted by the entry are kept.
+func Merge(name string, uid int, perms ...Perm) error { return update(name, uid, true, perms) }
+
+// update implements Update and Merge.
+func update(name string, uid int, merge bool, perms []Perm) error {
 	var p *Perm
 	if len(perms) > 0 {
 		p = &perms[0]
 	}
+	var m C.int
+	if merge {
+		m = 1
+	}
 
 	r, err := C.hakurei_acl_update_file_by_uid(
 		C.CString(name),
 		C.uid_t(uid),
 		(*C.acl_perm_t)(p),
 		C.size_t(len(perms)),
+		m,
 	)
 	return newAclPathError(name, int(r), err)
 }
@@ -77,6 +77,38 @@ func TestUpdate(t *testing.T) {
 	testUpdate(t, testFilePath, "r-x", cur, fAclPermRead|fAclPermExecute, acl.Read, acl.Execute)
 	testUpdate(t, testFilePath, "rw-", cur, fAclPermRead|fAclPermWrite, acl.Read, acl.Write)
 	testUpdate(t, testFilePath, "rwx", cur, fAclPermRead|fAclPermWrite|fAclPermExecute, acl.Read, acl.Write, acl.Execute)
+
+	t.Run("merge", func(t *testing.T) {
+		t.Cleanup(func() {
+			if err := acl.Update(testFilePath, uid); err != nil {
+				t.Fatalf("Update: error = %v", err)
+			}
+			if v := getfacl(t, testFilePath); !reflect.DeepEqual(v, cur) {
+				t.Fatalf("Update: %v, want %v", v, cur)
+			}
+		})
+
+		if err := acl.Merge(testFilePath, uid, acl.Execute); err != nil {
+			t.Fatalf("Merge: error = %v", err)
+		}
+		if r := respByCred(getfacl(t, testFilePath), fAclTypeUser, cred); r == nil || !r.equals(fAclTypeUser, cred, fAclPermExecute) {
+			t.Fatalf("Merge(--x) = %s", r)
+		}
+
+		if err := acl.Merge(testFilePath, uid, acl.Read, acl.Write); err != nil {
+			t.Fatalf("Merge: error = %v", err)
+		}
+		if r := respByCred(getfacl(t, testFilePath), fAclTypeUser, cred); r == nil || !r.equals(fAclTypeUser, cred, fAclPermRead|fAclPermWrite|fAclPermExecute) {
+			t.Fatalf("Merge(rw-) = %s", r)
+		}
+
+		if err := acl.Merge(testFilePath, uid, acl.Execute); err != nil {
+			t.Fatalf("Merge: error = %v", err)
+		}
+		if r := respByCred(getfacl(t, testFilePath), fAclTypeUser, cred); r == nil || !r.equals(fAclTypeUser, cred, fAclPermRead|fAclPermWrite|fAclPermExecute) {
+			t.Fatalf("Merge(--x) = %s", r)
+		}
+	})
 }
 
 func testUpdate(t *testing.T, testFilePath, name string, cur []*getFAclResp, val fAclPerm, perms ...acl.Perm) {
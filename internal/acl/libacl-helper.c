@@ -5,7 +5,7 @@
 #include <sys/acl.h>
 
 int hakurei_acl_update_file_by_uid(const char *path_p, uid_t uid,
-                                   acl_perm_t *perms, size_t plen) {
+                                   acl_perm_t *perms, size_t plen, int merge) {
     int ret;
     bool v;
     int i;
@@ -14,13 +14,16 @@ int hakurei_acl_update_file_by_uid(const char *path_p, uid_t uid,
     acl_tag_t tag_type;
     void *qualifier_p;
     acl_permset_t permset;
+    acl_permset_t prev;
+    bool found;
 
     ret = -1; /* acl_get_file */
     acl = acl_get_file(path_p, ACL_TYPE_ACCESS);
     if (acl == NULL)
         goto out;
 
-    /* prune entries by uid */
+    /* prune entries by uid, carrying their perms over if merging */
+    found = false;
     for (i = acl_get_entry(acl, ACL_FIRST_ENTRY, &entry); i == 1;
          i = acl_get_entry(acl, ACL_NEXT_ENTRY, &entry)) {
         ret = -2; /* acl_get_tag_type */
@@ -39,6 +42,14 @@ int hakurei_acl_update_file_by_uid(const char *path_p, uid_t uid,
         if (!v)
             continue;
 
+        if (merge && !found) {
+            ret = -6; /* acl_get_permset */
+            if (acl_get_permset(entry, &prev) != 0)
+                goto out;
+            found = true;
+            continue;
+        }
+
         ret = -4; /* acl_delete_entry */
         if (acl_delete_entry(acl, entry) != 0)
             goto out;
@@ -47,6 +58,15 @@ int hakurei_acl_update_file_by_uid(const char *path_p, uid_t uid,
     if (plen == 0)
         goto set;
 
+    if (found) {
+        ret = -7; /* acl_add_perm */
+        for (i = 0; i < plen; i++) {
+            if (acl_add_perm(prev, perms[i]) != 0)
+                goto out;
+        }
+        goto set;
+    }
+
     ret = -5; /* acl_create_entry */
     if (acl_create_entry(&acl, &entry) != 0)
         goto out;
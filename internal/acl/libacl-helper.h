@@ -1,4 +1,4 @@
 #include <sys/acl.h>
 
 int hakurei_acl_update_file_by_uid(const char *path_p, uid_t uid,
-                                   acl_perm_t *perms, size_t plen);
+                                   acl_perm_t *perms, size_t plen, int merge);
@@ -0,0 +1,107 @@
+package dbus
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrAuth is returned when the authentication protocol fails on either end of the proxy.
+var ErrAuth = errors.New("D-Bus authentication failed")
+
+// authenticate authenticates to the bus as the current user via the EXTERNAL mechanism
+// and returns the server GUID and whether file descriptor passing was negotiated.
+func authenticate(conn *net.UnixConn, r *reader) (guid string, unixFD bool, err error) {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err = conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return
+	}
+
+	var line string
+	if line, err = r.line(); err != nil {
+		return
+	}
+	var ok bool
+	if guid, ok = strings.CutPrefix(line, "OK "); !ok {
+		err = ErrAuth
+		return
+	}
+
+	if _, err = conn.Write([]byte("NEGOTIATE_UNIX_FD\r\n")); err != nil {
+		return
+	}
+	if line, err = r.line(); err != nil {
+		return
+	}
+	unixFD = line == "AGREE_UNIX_FD"
+
+	_, err = conn.Write([]byte("BEGIN\r\n"))
+	return
+}
+
+// accept runs the server side of the authentication protocol with a client connected to the proxy.
+// Any identity claimed via the EXTERNAL mechanism is accepted, as access to the proxy socket is
+// restricted by its permissions, and the proxy authenticates to the bus with its own credentials.
+func accept(conn *net.UnixConn, r *reader, guid string, unixFD bool) error {
+	if err := r.fill(1); err != nil {
+		return err
+	}
+	if r.buf[0] != 0 {
+		return ErrAuth
+	}
+	r.consume(1)
+
+	reply := func(s string) error { _, err := conn.Write([]byte(s + "\r\n")); return err }
+	authenticated, waitData := false, false
+	for {
+		line, err := r.line()
+		if err != nil {
+			return err
+		}
+		command, _, _ := strings.Cut(line, " ")
+
+		switch {
+		case command == "AUTH" && !authenticated:
+			mechanism, initial, hasInitial := strings.Cut(strings.TrimPrefix(line, "AUTH "), " ")
+			if line == "AUTH" || mechanism != "EXTERNAL" {
+				err = reply("REJECTED EXTERNAL")
+			} else if !hasInitial {
+				waitData = true
+				err = reply("DATA")
+			} else if _, decodeErr := hex.DecodeString(initial); decodeErr != nil {
+				err = reply("REJECTED EXTERNAL")
+			} else {
+				authenticated = true
+				err = reply("OK " + guid)
+			}
+
+		case command == "DATA" && waitData:
+			waitData = false
+			authenticated = true
+			err = reply("OK " + guid)
+
+		case command == "NEGOTIATE_UNIX_FD" && authenticated:
+			if unixFD {
+				err = reply("AGREE_UNIX_FD")
+			} else {
+				err = reply("ERROR file descriptor passing not supported by the bus")
+			}
+
+		case command == "BEGIN" && authenticated:
+			return nil
+
+		case command == "CANCEL" || command == "ERROR":
+			authenticated, waitData = false, false
+			err = reply("REJECTED EXTERNAL")
+
+		default:
+			err = reply("ERROR unexpected command")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
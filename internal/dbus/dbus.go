@@ -1,4 +1,5 @@
-// Package dbus wraps xdg-dbus-proxy and implements configuration and sandboxing of the underlying helper process.
+// Package dbus wraps xdg-dbus-proxy and implements configuration and sandboxing of the underlying helper process,
+// and implements [Filter], an in-process proxy enforcing the same configuration.
 package dbus
 
 import (
@@ -0,0 +1,339 @@
+package dbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction is the direction of a message relayed by a message bus proxy.
+type Direction string
+
+const (
+	// Outgoing messages are sent by the client to the message bus.
+	Outgoing Direction = "outgoing"
+	// Incoming messages are sent by the message bus to the client.
+	Incoming Direction = "incoming"
+)
+
+const (
+	// SessionBus identifies [Event] recorded for the session bus.
+	SessionBus = "session"
+	// SystemBus identifies [Event] recorded for the system bus.
+	SystemBus = "system"
+)
+
+// MessageType is the type of message described by [Event].
+type MessageType string
+
+const (
+	MessageCall   MessageType = "call"
+	MessageReturn MessageType = "return"
+	MessageError  MessageType = "error"
+	MessageSignal MessageType = "signal"
+)
+
+// Event describes a message relayed or denied by a message bus proxy.
+type Event struct {
+	// Time the message was processed by the proxy.
+	Time time.Time `json:"time"`
+	// Identifies the message bus, one of [SessionBus] or [SystemBus], empty if unknown.
+	Bus string `json:"bus,omitempty"`
+	// Identifies the client connection, zero if not reported by the proxy.
+	Conn int `json:"conn,omitempty"`
+
+	Direction Direction   `json:"direction"`
+	Type      MessageType `json:"type"`
+	// Serial of the message, in the namespace of its sender.
+	Serial uint32 `json:"serial"`
+	// Serial of the message replied to, for [MessageReturn] and [MessageError].
+	ReplySerial uint32 `json:"reply_serial,omitempty"`
+
+	Sender      string `json:"sender,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Interface   string `json:"interface,omitempty"`
+	Member      string `json:"member,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ErrorName   string `json:"error_name,omitempty"`
+	// First argument of the message if it is a string, empty if not reported by the proxy.
+	Arg0 string `json:"arg0,omitempty"`
+
+	// Whether the message was denied by the proxy.
+	Denied bool `json:"denied,omitempty"`
+	// Reason reported by the proxy for denying the message.
+	Reason string `json:"reason,omitempty"`
+}
+
+// String returns a single line representation of e in the log format of [Filter].
+func (e *Event) String() string {
+	var s string
+	switch e.Type {
+	case MessageCall:
+		s = "call " + e.Interface + "." + e.Member + " at " + e.Path
+	case MessageReturn:
+		s = "return for #" + strconv.FormatUint(uint64(e.ReplySerial), 10)
+	case MessageError:
+		s = "error " + e.ErrorName + " for #" + strconv.FormatUint(uint64(e.ReplySerial), 10)
+	case MessageSignal:
+		s = "signal " + e.Interface + "." + e.Member + " at " + e.Path
+	default:
+		s = string(e.Type)
+	}
+
+	serial := " #" + strconv.FormatUint(uint64(e.Serial), 10) + " "
+	if e.Direction == Incoming {
+		s = "<- " + e.Sender + serial + s
+	} else {
+		s = "-> " + e.Destination + serial + s
+	}
+	if e.Conn != 0 {
+		s = "C" + strconv.Itoa(e.Conn) + ": " + s
+	}
+	if e.Denied {
+		s += " denied"
+		if e.Reason != "" {
+			s += ": " + e.Reason
+		}
+	}
+	return s
+}
+
+// EventLogSizeMax is the number of bytes an [EventLog] appends before dropping further [Event].
+const EventLogSizeMax = 16 << 20
+
+// ErrEventLogFull is returned by [EventLog.Append] for the first [Event] dropped
+// once [EventLogSizeMax] is reached.
+var ErrEventLogFull = errors.New("event log size limit reached")
+
+// EventLog appends [Event] to an [io.Writer] as newline-delimited JSON, up to [EventLogSizeMax] bytes.
+// EventLog is safe for concurrent use.
+type EventLog struct {
+	w   io.Writer
+	bus string
+
+	// Number of bytes written to w.
+	n int
+	// Maximum value of n, for tests.
+	max int
+	// Whether an [Event] was dropped.
+	full bool
+
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+// NewEventLog returns the address of a new [EventLog] appending to w.
+// If bus is not empty, it is recorded as the Bus field of every [Event].
+func NewEventLog(w io.Writer, bus string) *EventLog {
+	return &EventLog{w: w, bus: bus, max: EventLogSizeMax}
+}
+
+// Append writes e to the underlying [io.Writer] in a single call to its Write method.
+// Events which would grow the log past its size limit are dropped.
+func (l *EventLog) Append(e *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.bus != "" {
+		e.Bus = l.bus
+	}
+
+	l.buf.Reset()
+	if err := json.NewEncoder(&l.buf).Encode(e); err != nil {
+		return err
+	}
+	if l.n+l.buf.Len() > l.max {
+		if l.full {
+			return nil
+		}
+		l.full = true
+		return ErrEventLogFull
+	}
+	n, err := l.w.Write(l.buf.Bytes())
+	l.n += n
+	return err
+}
+
+// ReadEvents returns every [Event] written to r by [EventLog]. A truncated final entry
+// is skipped, since the log may be read while it is being written.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	d := json.NewDecoder(r)
+	for {
+		var e Event
+		if err := d.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return events, nil
+			}
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// LogParser converts log output of xdg-dbus-proxy to [Event] appended to an [EventLog].
+//
+// Each message is logged by xdg-dbus-proxy as a header line, optionally followed by a
+// line reporting the action taken if the message was not relayed as is, so an [Event]
+// is only appended once the following line is seen or on a call to [LogParser.Flush].
+// LogParser is not safe for concurrent use.
+type LogParser struct {
+	events *EventLog
+	// Pending event awaiting its status line.
+	last *Event
+	// Returns the current time, for tests.
+	now func() time.Time
+}
+
+// NewLogParser returns the address of a new [LogParser] appending to events.
+func NewLogParser(events *EventLog) *LogParser { return &LogParser{events: events, now: time.Now} }
+
+// Line handles a line of xdg-dbus-proxy output, and returns false if it is not part of the message log.
+func (p *LogParser) Line(s string) bool {
+	if e, ok := parseLogHeader(s); ok {
+		_ = p.Flush()
+		e.Time = p.now()
+		p.last = e
+		return true
+	}
+
+	if p.last == nil || !strings.HasPrefix(s, "*") {
+		return false
+	}
+	word, reason, ok := strings.Cut(s[1:], "*")
+	if !ok {
+		return false
+	}
+	switch word {
+	case "HIDDEN", "DENIED", "SKIPPED":
+		p.last.Denied = true
+		reason = strings.TrimSpace(reason)
+		reason = strings.TrimSuffix(strings.TrimPrefix(reason, "("), ")")
+		if reason == "" {
+			reason = strings.ToLower(word)
+		}
+		p.last.Reason = reason
+	}
+	_ = p.Flush()
+	return true
+}
+
+// Flush appends the pending [Event], if any.
+func (p *LogParser) Flush() error {
+	if p.last == nil {
+		return nil
+	}
+	e := p.last
+	p.last = nil
+	return p.events.Append(e)
+}
+
+// parseLogHeader parses a message header logged by xdg-dbus-proxy, of the form
+// "C1: -> org.example call org.example.Interface.Method at /org/example" for outgoing
+// messages, and "B1: <- :1.1 return from C1" for incoming messages.
+func parseLogHeader(s string) (*Event, bool) {
+	var e Event
+	switch {
+	case strings.HasPrefix(s, "C"):
+		e.Direction = Outgoing
+	case strings.HasPrefix(s, "B"):
+		e.Direction = Incoming
+	default:
+		return nil, false
+	}
+
+	serial, s, ok := strings.Cut(s[1:], ": ")
+	if !ok {
+		return nil, false
+	}
+	if v, err := strconv.ParseUint(serial, 10, 32); err != nil {
+		return nil, false
+	} else {
+		e.Serial = uint32(v)
+	}
+
+	arrow := "-> "
+	if e.Direction == Incoming {
+		arrow = "<- "
+	}
+	if s, ok = strings.CutPrefix(s, arrow); !ok {
+		return nil, false
+	}
+	// peer is omitted as "(no dest)" or "(no sender)"
+	for _, omitted := range []string{"(no dest) ", "(no sender) "} {
+		if v, omit := strings.CutPrefix(s, omitted); omit {
+			s = "- " + v
+		}
+	}
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return nil, false
+	}
+	peer := fields[0]
+	if peer == "-" {
+		peer = ""
+	}
+
+	replySerial := func(s string) bool {
+		other := "B"
+		if e.Direction == Incoming {
+			other = "C"
+		}
+		if s, ok = strings.CutPrefix(s, other); !ok {
+			return false
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		e.ReplySerial = uint32(v)
+		return err == nil
+	}
+
+	switch fields[1] {
+	case "call", "signal":
+		if len(fields) != 5 || fields[3] != "at" {
+			return nil, false
+		}
+		if fields[1] == "call" {
+			e.Type = MessageCall
+		} else {
+			e.Type = MessageSignal
+			if e.Direction == Outgoing && peer == "all" {
+				peer = ""
+			}
+		}
+		i := strings.LastIndexByte(fields[2], '.')
+		if i == -1 {
+			return nil, false
+		}
+		e.Interface, e.Member, e.Path = fields[2][:i], fields[2][i+1:], fields[4]
+
+	case "return":
+		switch {
+		case len(fields) == 4 && fields[2] == "from":
+			e.Type = MessageReturn
+			ok = replySerial(fields[3])
+		case len(fields) == 6 && fields[2] == "error" && fields[4] == "from":
+			e.Type = MessageError
+			e.ErrorName = fields[3]
+			ok = replySerial(fields[5])
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+
+	default:
+		return nil, false
+	}
+
+	if e.Direction == Incoming {
+		e.Sender = peer
+	} else {
+		e.Destination = peer
+	}
+	return &e, true
+}
@@ -0,0 +1,135 @@
+package dbus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogParser(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0xdeadbeef).UTC()
+	lines := []struct {
+		line string
+		ok   bool
+	}{
+		{"C1: -> org.freedesktop.DBus call org.freedesktop.DBus.Hello at /org/freedesktop/DBus", true},
+		{"B1: <- org.freedesktop.DBus return from C1", true},
+		{"C2: -> org.freedesktop.portal.Desktop call org.freedesktop.portal.FileChooser.OpenFile at /org/freedesktop/portal/desktop", true},
+		{"*HIDDEN* (ping)", true},
+		{"C3: -> org.example.App call org.freedesktop.DBus.Peer.Ping at /", true},
+		{"*SKIPPED*", true},
+		{"B2: <- :1.4 signal org.example.Interface.Changed at /org/example", true},
+		{"warning: not a message", false},
+		{"*DENIED*", true},
+		{"C4: -> (no dest) signal org.example.Interface.Changed at /", true},
+		{"B3: <- (no sender) return error org.freedesktop.DBus.Error.AccessDenied from C4", true},
+		{"C5: -> org.example call Invalid at /", false},
+		{"C5: -> org.example return from C1", false},
+		{"C: -> org.example return from B1", false},
+		{"*HIDDEN*", true},
+		{"*HIDDEN*", false},
+	}
+	want := []Event{
+		{Time: now, Direction: Outgoing, Type: MessageCall, Serial: 1,
+			Destination: busName, Interface: busName, Member: "Hello", Path: busPath},
+		{Time: now, Direction: Incoming, Type: MessageReturn, Serial: 1, ReplySerial: 1, Sender: busName},
+		{Time: now, Direction: Outgoing, Type: MessageCall, Serial: 2,
+			Destination: "org.freedesktop.portal.Desktop", Interface: "org.freedesktop.portal.FileChooser",
+			Member: "OpenFile", Path: "/org/freedesktop/portal/desktop", Denied: true, Reason: "ping"},
+		{Time: now, Direction: Outgoing, Type: MessageCall, Serial: 3,
+			Destination: "org.example.App", Interface: "org.freedesktop.DBus.Peer",
+			Member: "Ping", Path: "/", Denied: true, Reason: "skipped"},
+		{Time: now, Direction: Incoming, Type: MessageSignal, Serial: 2,
+			Sender: ":1.4", Interface: "org.example.Interface", Member: "Changed", Path: "/org/example",
+			Denied: true, Reason: "denied"},
+		{Time: now, Direction: Outgoing, Type: MessageSignal, Serial: 4,
+			Interface: "org.example.Interface", Member: "Changed", Path: "/"},
+		{Time: now, Direction: Incoming, Type: MessageError, Serial: 3, ReplySerial: 4,
+			ErrorName: errAccessDenied, Denied: true, Reason: "hidden"},
+	}
+
+	var buf bytes.Buffer
+	p := NewLogParser(NewEventLog(&buf, ""))
+	p.now = func() time.Time { return now }
+	for _, l := range lines {
+		if ok := p.Line(l.line); ok != l.ok {
+			t.Errorf("Line(%q): %v, want %v", l.line, ok, l.ok)
+		}
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush: error = %v", err)
+	}
+
+	// truncated entry written concurrently
+	buf.WriteString(`{"time":`)
+	if got, err := ReadEvents(&buf); err != nil {
+		t.Fatalf("ReadEvents: error = %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadEvents:\n%#v\nwant\n%#v", got, want)
+	}
+
+	if _, err := ReadEvents(strings.NewReader("{}\n[]\n")); err == nil {
+		t.Errorf("ReadEvents: unexpected success")
+	}
+}
+
+func TestEventString(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"call", Event{Conn: 1, Direction: Outgoing, Type: MessageCall, Serial: 2,
+			Destination: "org.example", Interface: "org.example.Interface", Member: "Method", Path: "/"},
+			"C1: -> org.example #2 call org.example.Interface.Method at /"},
+		{"return", Event{Direction: Incoming, Type: MessageReturn, Serial: 3, ReplySerial: 2, Sender: ":1.1"},
+			"<- :1.1 #3 return for #2"},
+		{"error denied", Event{Conn: 1, Direction: Incoming, Type: MessageError, Serial: 3, ReplySerial: 2,
+			Sender: ":1.1", ErrorName: errAccessDenied, Denied: true},
+			"C1: <- :1.1 #3 error org.freedesktop.DBus.Error.AccessDenied for #2 denied"},
+		{"signal denied", Event{Conn: 1, Direction: Incoming, Type: MessageSignal, Serial: 4, Sender: ":1.1",
+			Interface: "org.example.Interface", Member: "Changed", Path: "/", Denied: true, Reason: "broadcast not permitted"},
+			"C1: <- :1.1 #4 signal org.example.Interface.Changed at / denied: broadcast not permitted"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.event.String(); got != tc.want {
+				t.Errorf("String: %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEventLogFull(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := NewEventLog(&buf, SessionBus)
+	e := Event{Time: time.Unix(0, 0).UTC(), Direction: Outgoing, Type: MessageCall, Serial: 1}
+	if err := l.Append(&e); err != nil {
+		t.Fatalf("Append: error = %v", err)
+	}
+	l.max = buf.Len() * 2
+
+	want := []error{nil, ErrEventLogFull, nil}
+	for i, wantErr := range want {
+		e.Serial = uint32(i + 2)
+		if err := l.Append(&e); !errors.Is(err, wantErr) {
+			t.Errorf("Append: error = %v, want %v", err, wantErr)
+		}
+	}
+
+	if got, err := ReadEvents(&buf); err != nil {
+		t.Fatalf("ReadEvents: error = %v", err)
+	} else if len(got) != 2 || got[1].Serial != 2 || got[1].Bus != SessionBus {
+		t.Errorf("ReadEvents: %#v", got)
+	}
+}
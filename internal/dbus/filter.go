@@ -0,0 +1,824 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"hakurei.app/hst"
+	"hakurei.app/internal/proxy"
+	"hakurei.app/message"
+)
+
+const (
+	// busName is the name of the message bus itself.
+	busName = "org.freedesktop.DBus"
+	// busPath is the object path of the message bus itself.
+	busPath = "/org/freedesktop/DBus"
+
+	errAccessDenied   = "org.freedesktop.DBus.Error.AccessDenied"
+	errNameHasNoOwner = "org.freedesktop.DBus.Error.NameHasNoOwner"
+)
+
+// ErrNoAddress is returned by [NewFilter] for an upstream address without a supported unix socket.
+var ErrNoAddress = errors.New("no supported unix socket in bus address")
+
+// Filter accepts connections on a pathname socket and relays them to a message bus,
+// enforcing [hst.BusConfig] in process without spawning xdg-dbus-proxy.
+type Filter struct {
+	msg      message.Msg
+	upstream []AddrEntry
+	policy   *policy
+	log      bool
+	events   *EventLog
+
+	l *proxy.Listener
+
+	// Identifies connections in log messages.
+	count int
+	mu    sync.Mutex
+}
+
+// NewFilter binds a pathname socket to bus[1] and relays connections accepted on it to the
+// message bus at address bus[0]. An invalid config is reported as [PolicyError].
+// If logging is enabled in config and events is not nil, relayed messages are appended
+// to events instead of the log.
+//
+// NewFilter does not attach a finalizer to the resulting [Filter] struct.
+// The caller is responsible for calling [Filter.Close].
+func NewFilter(msg message.Msg, bus ProxyPair, config *hst.BusConfig, events *EventLog) (*Filter, error) {
+	if config == nil {
+		return nil, os.ErrInvalid
+	}
+
+	f := Filter{
+		msg:    msg,
+		log:    config.Log,
+		events: events,
+	}
+	var err error
+	if f.policy, err = newPolicy(config); err != nil {
+		return nil, err
+	}
+	if f.upstream, err = Parse([]byte(bus[0])); err != nil {
+		return nil, err
+	}
+	if !hasUnixSocket(f.upstream) {
+		return nil, ErrNoAddress
+	}
+
+	if f.l, err = proxy.Listen(bus[1]); err != nil {
+		return nil, err
+	}
+
+	f.l.Go(f.serve)
+	return &f, nil
+}
+
+// Close stops accepting connections, closes every active connection and waits for all
+// goroutines started by [Filter] to return.
+func (f *Filter) Close() error {
+	if f == nil {
+		return os.ErrInvalid
+	}
+
+	return f.l.Close()
+}
+
+// hasUnixSocket returns whether entries contains a unix socket supported by dial.
+func hasUnixSocket(entries []AddrEntry) bool {
+	for _, ent := range entries {
+		if ent.Method != "unix" {
+			continue
+		}
+		for _, pair := range ent.Values {
+			if pair[0] == "path" || pair[0] == "abstract" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dial connects to the first reachable unix socket in entries.
+func dial(entries []AddrEntry) (*net.UnixConn, error) {
+	var errs []error
+	for _, ent := range entries {
+		if ent.Method != "unix" {
+			continue
+		}
+		for _, pair := range ent.Values {
+			var name string
+			switch pair[0] {
+			case "path":
+				name = pair[1]
+			case "abstract":
+				name = "@" + pair[1]
+			default:
+				continue
+			}
+
+			if conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: name, Net: "unix"}); err != nil {
+				errs = append(errs, err)
+			} else {
+				return conn, nil
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil, ErrNoAddress
+	}
+	return nil, errors.Join(errs...)
+}
+
+// serve accepts connections until the listener is closed.
+func (f *Filter) serve() {
+	for {
+		client, err := f.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				f.msg.Verbosef("cannot accept D-Bus client: %v", err)
+			}
+			return
+		}
+		if !f.l.Track(client) {
+			return
+		}
+
+		f.mu.Lock()
+		f.count++
+		c := conn{f: f, id: f.count, client: client,
+			pending:  make(map[uint32]pendingCall),
+			incoming: make(map[incomingCall]struct{}),
+			known:    make(map[string]struct{}),
+			owners:   make(map[string]string),
+		}
+		f.mu.Unlock()
+
+		f.l.Go(func() {
+			if err := c.run(); !isClosed(err) {
+				f.msg.Verbosef("D-Bus client %d: %v", c.id, err)
+			}
+			f.l.Untrack(c.client, c.bus, c.watch)
+		})
+	}
+}
+
+// isClosed returns whether err is nil or the result of either end closing the connection.
+func isClosed(err error) bool {
+	return err == nil || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
+
+// pendingCall describes a method call made by the client awaiting a reply.
+type pendingCall struct {
+	// Member of the message bus interface called, empty for other destinations.
+	member string
+	// Name passed to GetNameOwner.
+	name string
+}
+
+// incomingCall identifies a method call delivered to the client awaiting a reply.
+type incomingCall struct {
+	sender string
+	serial uint32
+}
+
+// conn holds the state of a proxied connection.
+type conn struct {
+	f  *Filter
+	id int
+
+	client, bus *net.UnixConn
+	// Connection used to track name ownership, nil if messages are not filtered.
+	watch *net.UnixConn
+	// Serialises writes to client.
+	clientMu sync.Mutex
+
+	// Unique name of the client, assigned in the reply to Hello.
+	unique string
+	// Serial of the last message originating from the proxy.
+	serial uint32
+	// Method calls made by the client awaiting a reply.
+	pending map[uint32]pendingCall
+	// Method calls delivered to the client awaiting a reply.
+	incoming map[incomingCall]struct{}
+	// Unique names revealed to the client.
+	known map[string]struct{}
+	// Owners of well-known names subject to policy.
+	owners map[string]string
+	mu     sync.Mutex
+}
+
+// run connects to the bus, authenticates both ends and relays messages until either end
+// closes the connection.
+func (c *conn) run() (err error) {
+	if c.bus, err = dial(c.f.upstream); err != nil {
+		return
+	}
+	if !c.f.l.Track(c.bus) {
+		return net.ErrClosed
+	}
+	busReader := reader{conn: c.bus}
+	defer busReader.close()
+	var (
+		guid   string
+		unixFD bool
+	)
+	if guid, unixFD, err = authenticate(c.bus, &busReader); err != nil {
+		return
+	}
+
+	var watchReader reader
+	defer watchReader.close()
+	if c.f.policy.filter {
+		if c.watch, err = dial(c.f.upstream); err != nil {
+			return
+		}
+		if !c.f.l.Track(c.watch) {
+			return net.ErrClosed
+		}
+		watchReader.conn = c.watch
+		if err = c.watchOwners(&watchReader); err != nil {
+			return
+		}
+	}
+
+	clientReader := reader{conn: c.client}
+	defer clientReader.close()
+	if err = accept(c.client, &clientReader, guid, unixFD); err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	relay := func(i int, f func() error) {
+		defer wg.Done()
+		if errs[i] = f(); isClosed(errs[i]) {
+			errs[i] = nil
+		}
+		// terminate the remaining goroutines
+		c.f.l.Untrack(c.client, c.bus, c.watch)
+	}
+	wg.Add(2)
+	go relay(0, func() error { return c.upstream(&clientReader) })
+	go relay(1, func() error { return c.downstream(&busReader) })
+	if c.watch != nil {
+		wg.Add(1)
+		go relay(2, func() error { return c.updateOwners(&watchReader) })
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// reply sends a message originating from the proxy to the client.
+func (c *conn) reply(m *wireMessage) error {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	return writeMessage(c.client, m)
+}
+
+// newBusCall returns a method call to the message bus.
+func newBusCall(serial uint32, member, signature string, body []byte) *wireMessage {
+	return &wireMessage{order: binary.LittleEndian, kind: typeMethodCall, serial: serial,
+		path: busPath, iface: busName, member: member, destination: busName,
+		signature: signature, body: body}
+}
+
+// newReply returns a reply to m originating from the message bus. If errorName is not empty,
+// the reply is an error. The caller must hold mu.
+func (c *conn) newReply(m *wireMessage, errorName, signature string, body []byte) *wireMessage {
+	c.serial++
+	r := wireMessage{order: m.order, kind: typeMethodReturn, serial: c.serial,
+		replySerial: m.serial, destination: c.unique, sender: busName,
+		signature: signature, body: body}
+	if errorName != "" {
+		r.kind = typeError
+		r.errorName = errorName
+	}
+	return &r
+}
+
+// watchOwners starts tracking owners of names subject to policy on the watch connection,
+// and returns once the initial owner of every such name is known.
+func (c *conn) watchOwners(r *reader) error {
+	if _, _, err := authenticate(c.watch, r); err != nil {
+		return err
+	}
+
+	call := func(serial uint32, member, signature string, body []byte) (*wireMessage, error) {
+		if err := writeMessage(c.watch, newBusCall(serial, member, signature, body)); err != nil {
+			return nil, err
+		}
+		for {
+			m, err := r.next()
+			if err != nil {
+				return nil, err
+			}
+			closeFDs(m.fds)
+			if m.kind == typeSignal {
+				if err = c.nameOwnerChanged(m); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if m.replySerial != serial {
+				continue
+			}
+			if m.kind == typeError {
+				return m, fmt.Errorf("%s failed: %s", member, m.errorName)
+			}
+			return m, nil
+		}
+	}
+
+	if _, err := call(1, "Hello", "", nil); err != nil {
+		return err
+	}
+	if _, err := call(2, "AddMatch", "s", encodeString(binary.LittleEndian,
+		"type='signal',sender='"+busName+"',interface='"+busName+"',member='NameOwnerChanged'")); err != nil {
+		return err
+	}
+
+	m, err := call(3, "ListNames", "", nil)
+	if err != nil {
+		return err
+	}
+	var d *decoder
+	if d, err = m.args("as"); err != nil {
+		return err
+	}
+	var names []string
+	if names, err = d.strings(); err != nil {
+		return err
+	}
+
+	serial := uint32(3)
+	for _, name := range names {
+		if strings.HasPrefix(name, ":") || c.f.policy.level(name) == levelNone {
+			continue
+		}
+		serial++
+		if m, err = call(serial, "GetNameOwner", "s", encodeString(binary.LittleEndian, name)); err != nil {
+			if m != nil && m.errorName == errNameHasNoOwner {
+				// lost ownership since ListNames
+				continue
+			}
+			return err
+		}
+		if d, err = m.args("s"); err != nil {
+			return err
+		}
+		var owner string
+		if owner, err = d.string(); err != nil {
+			return err
+		}
+		c.setOwner(name, owner)
+	}
+	return nil
+}
+
+// updateOwners tracks owners of names subject to policy until the watch connection is closed.
+func (c *conn) updateOwners(r *reader) error {
+	for {
+		m, err := r.next()
+		if err != nil {
+			return err
+		}
+		closeFDs(m.fds)
+		if m.kind == typeSignal {
+			if err = c.nameOwnerChanged(m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nameOwnerChanged updates name ownership if m is a NameOwnerChanged signal from the message bus.
+func (c *conn) nameOwnerChanged(m *wireMessage) error {
+	if m.sender != busName || m.iface != busName || m.member != "NameOwnerChanged" {
+		return nil
+	}
+	d, err := m.args("sss")
+	if err != nil {
+		return err
+	}
+	var name, owner string
+	if name, err = d.string(); err != nil {
+		return err
+	}
+	if _, err = d.string(); err != nil {
+		return err
+	}
+	if owner, err = d.string(); err != nil {
+		return err
+	}
+	c.setOwner(name, owner)
+	return nil
+}
+
+// setOwner records owner as the owner of name if name is subject to policy. An empty owner
+// releases name.
+func (c *conn) setOwner(name, owner string) {
+	if strings.HasPrefix(name, ":") || c.f.policy.level(name) == levelNone {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if owner == "" {
+		delete(c.owners, name)
+	} else {
+		c.owners[name] = owner
+	}
+}
+
+// names returns the names subject to policy owned by the connection with unique name owner,
+// or name itself if it is a well-known name. The caller must hold mu.
+func (c *conn) names(name string) []string {
+	if !strings.HasPrefix(name, ":") {
+		return []string{name}
+	}
+	var names []string
+	for n, owner := range c.owners {
+		if owner == name {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// visible returns whether name is visible to the client. The caller must hold mu.
+func (c *conn) visible(name string) bool {
+	if name == busName || name == c.unique {
+		return true
+	}
+	if _, ok := c.known[name]; ok {
+		return true
+	}
+	return c.f.policy.level(c.names(name)...) >= levelSee
+}
+
+// upstream relays messages sent by the client to the bus.
+func (c *conn) upstream(r *reader) error {
+	for {
+		m, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !c.f.policy.filter {
+			c.logMessage(m, false, "")
+			if err = writeMessage(c.bus, m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		reply, reason := c.request(m)
+		c.logMessage(m, false, reason)
+		if reason == "" {
+			if err = writeMessage(c.bus, m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		closeFDs(m.fds)
+		if reply == nil && m.kind == typeMethodCall && m.flags&flagNoReplyExpected == 0 {
+			c.mu.Lock()
+			reply = c.newReply(m, errAccessDenied, "s", encodeString(m.order, reason))
+			c.mu.Unlock()
+		}
+		if reply != nil && m.flags&flagNoReplyExpected == 0 {
+			if err = c.reply(reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// request checks a message sent by the client, and returns why it is denied if it is.
+// A reply to send in place of the default error is returned alongside a denial.
+func (c *conn) request(m *wireMessage) (reply *wireMessage, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch m.kind {
+	case typeMethodCall:
+		switch {
+		case m.destination == busName:
+			var member string
+			if reply, reason, member = c.busCall(m); reason != "" {
+				return
+			}
+			if m.flags&flagNoReplyExpected == 0 {
+				c.pending[m.serial] = pendingCall{member: member, name: c.callName(m)}
+			}
+			return
+
+		case m.destination == "":
+			return nil, "method call without destination"
+
+		case m.destination != c.unique:
+			names := c.names(m.destination)
+			if c.f.policy.level(names...) < levelTalk {
+				return nil, fmt.Sprintf("talk access to %q not granted", m.destination)
+			}
+			if !c.f.policy.call(names, m.path, m.iface, m.member) {
+				return nil, fmt.Sprintf("no call rule for %q permits %s.%s at %s",
+					m.destination, m.iface, m.member, m.path)
+			}
+		}
+		if m.flags&flagNoReplyExpected == 0 {
+			c.pending[m.serial] = pendingCall{}
+		}
+
+	case typeMethodReturn, typeError:
+		call := incomingCall{m.destination, m.replySerial}
+		if _, ok := c.incoming[call]; !ok {
+			return nil, fmt.Sprintf("unexpected reply to %q", m.destination)
+		}
+		delete(c.incoming, call)
+
+	case typeSignal:
+		if m.destination != "" && m.destination != c.unique &&
+			c.f.policy.level(c.names(m.destination)...) < levelTalk {
+			return nil, fmt.Sprintf("talk access to %q not granted", m.destination)
+		}
+	}
+	return
+}
+
+// callName returns the first argument of m if it is a name, or the empty string.
+func (c *conn) callName(m *wireMessage) string {
+	d, err := m.args("s")
+	if err != nil {
+		return ""
+	}
+	name, _ := d.string()
+	return name
+}
+
+// busCall checks a method call to the message bus, and returns the member to expect a reply
+// to if it is permitted.
+func (c *conn) busCall(m *wireMessage) (reply *wireMessage, reason, member string) {
+	switch m.iface {
+	case "org.freedesktop.DBus.Introspectable", "org.freedesktop.DBus.Peer":
+		return
+
+	case "org.freedesktop.DBus.Properties":
+		if m.member != "Get" && m.member != "GetAll" {
+			reason = "setting message bus properties is not permitted"
+		}
+		return
+
+	case "", busName:
+		break
+
+	default:
+		reason = fmt.Sprintf("interface %s of the message bus is not permitted", m.iface)
+		return
+	}
+
+	member = m.member
+	name := c.callName(m)
+	switch m.member {
+	case "Hello", "GetId", "ListNames", "ListActivatableNames", "RemoveMatch":
+
+	case "AddMatch":
+		// rejected regardless of value or quoting
+		if _, ok := parseMatchRule(name)["eavesdrop"]; ok {
+			reason = "eavesdropping is not permitted"
+		}
+
+	case "RequestName", "ReleaseName":
+		if c.f.policy.level(name) < levelOwn {
+			reason = fmt.Sprintf("own access to %q not granted", name)
+		}
+
+	case "StartServiceByName":
+		if c.f.policy.level(name) < levelTalk {
+			reason = fmt.Sprintf("talk access to %q not granted", name)
+		}
+
+	case "NameHasOwner":
+		if !c.visible(name) {
+			reason = fmt.Sprintf("%q is not visible", name)
+			var e encoder
+			e.order = m.order
+			e.uint32(0)
+			reply = c.newReply(m, "", "b", e.buf)
+		}
+
+	case "GetNameOwner", "ListQueuedOwners",
+		"GetConnectionUnixUser", "GetConnectionUnixProcessID", "GetConnectionCredentials",
+		"GetAdtAuditSessionData", "GetConnectionSELinuxSecurityContext":
+		if !c.visible(name) {
+			reason = fmt.Sprintf("%q is not visible", name)
+			reply = c.newReply(m, errNameHasNoOwner, "s",
+				encodeString(m.order, "Could not get owner of name '"+name+"': no such name"))
+		}
+
+	default:
+		reason = fmt.Sprintf("method %s of the message bus is not permitted", m.member)
+	}
+	return
+}
+
+// downstream relays messages sent by the bus to the client.
+func (c *conn) downstream(r *reader) error {
+	for {
+		m, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		var reason string
+		if c.f.policy.filter {
+			if reason, err = c.event(m); err != nil {
+				closeFDs(m.fds)
+				return err
+			}
+		}
+		c.logMessage(m, true, reason)
+		if reason != "" {
+			closeFDs(m.fds)
+			continue
+		}
+		if err = c.reply(m); err != nil {
+			return err
+		}
+	}
+}
+
+// event checks and rewrites a message sent by the bus, and returns why it is withheld
+// from the client if it is.
+func (c *conn) event(m *wireMessage) (string, error) {
+	if m.kind == typeSignal {
+		if err := c.nameOwnerChanged(m); err != nil {
+			return "", err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the unique name is not yet known while the reply to Hello is in flight
+	if m.destination != "" && c.unique != "" && m.destination != c.unique {
+		return fmt.Sprintf("message destined for %q", m.destination), nil
+	}
+
+	switch m.kind {
+	case typeMethodReturn, typeError:
+		call, ok := c.pending[m.replySerial]
+		if !ok {
+			return "unexpected reply", nil
+		}
+		delete(c.pending, m.replySerial)
+		if m.kind == typeError || m.sender != busName {
+			if m.sender != busName {
+				c.known[m.sender] = struct{}{}
+			}
+			break
+		}
+
+		switch call.member {
+		case "Hello":
+			d, err := m.args("s")
+			if err != nil {
+				return "", err
+			}
+			if c.unique, err = d.string(); err != nil {
+				return "", err
+			}
+
+		case "GetNameOwner":
+			d, err := m.args("s")
+			if err != nil {
+				return "", err
+			}
+			var owner string
+			if owner, err = d.string(); err != nil {
+				return "", err
+			}
+			c.known[owner] = struct{}{}
+			if !strings.HasPrefix(call.name, ":") && c.f.policy.level(call.name) != levelNone {
+				c.owners[call.name] = owner
+			}
+
+		case "ListNames", "ListActivatableNames":
+			d, err := m.args("as")
+			if err != nil {
+				return "", err
+			}
+			var names []string
+			if names, err = d.strings(); err != nil {
+				return "", err
+			}
+			visible := make([]string, 0, len(names))
+			for _, name := range names {
+				if c.visible(name) {
+					visible = append(visible, name)
+				}
+			}
+			m.body = encodeStrings(m.order, visible)
+			m.raw = nil
+		}
+
+	case typeMethodCall:
+		if m.flags&flagNoReplyExpected == 0 {
+			c.incoming[incomingCall{m.sender, m.serial}] = struct{}{}
+		}
+		c.known[m.sender] = struct{}{}
+
+	case typeSignal:
+		switch m.sender {
+		case busName:
+			if m.iface != busName || m.member != "NameOwnerChanged" {
+				break
+			}
+			d, err := m.args("sss")
+			if err != nil {
+				return "", err
+			}
+			var name string
+			if name, err = d.string(); err != nil {
+				return "", err
+			}
+			if !c.visible(name) {
+				return fmt.Sprintf("%q is not visible", name), nil
+			}
+
+		case c.unique:
+
+		default:
+			if !c.f.policy.broadcast(c.names(m.sender), m.path, m.iface, m.member) {
+				return fmt.Sprintf("no broadcast rule for %q permits %s.%s at %s",
+					m.sender, m.iface, m.member, m.path), nil
+			}
+			c.known[m.sender] = struct{}{}
+		}
+	}
+	return "", nil
+}
+
+// logMessage logs a message relayed in either direction if logging is enabled,
+// and logs denied messages verbosely otherwise. If an [EventLog] is passed to
+// [NewFilter], messages are appended to it instead while logging is enabled.
+func (c *conn) logMessage(m *wireMessage, fromBus bool, reason string) {
+	if !c.f.log && reason == "" {
+		return
+	}
+
+	e := Event{
+		Time:        time.Now(),
+		Conn:        c.id,
+		Direction:   Outgoing,
+		Serial:      m.serial,
+		ReplySerial: m.replySerial,
+		Sender:      m.sender,
+		Destination: m.destination,
+		Interface:   m.iface,
+		Member:      m.member,
+		Path:        m.path,
+		ErrorName:   m.errorName,
+		Denied:      reason != "",
+		Reason:      reason,
+	}
+	if fromBus {
+		e.Direction = Incoming
+	}
+	switch m.kind {
+	case typeMethodCall:
+		e.Type = MessageCall
+	case typeMethodReturn:
+		e.Type = MessageReturn
+	case typeError:
+		e.Type = MessageError
+	case typeSignal:
+		e.Type = MessageSignal
+	}
+	if d, err := m.args("s"); err == nil {
+		e.Arg0, _ = d.string()
+	}
+
+	switch {
+	case !c.f.log:
+		c.f.msg.Verbose(e.String())
+
+	case c.f.events == nil:
+		c.f.msg.GetLogger().Println(e.String())
+
+	default:
+		if e.Denied {
+			c.f.msg.Verbose(e.String())
+		}
+		if err := c.f.events.Append(&e); err != nil {
+			c.f.msg.Verbosef("cannot record D-Bus event: %v", err)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+package dbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+// stubBus implements the parts of a message bus exercised by the proxy.
+// Every connection receives every broadcast, and every name request succeeds.
+type stubBus struct {
+	l *net.UnixListener
+
+	// Connections by unique name.
+	conns map[string]*stubConn
+	// Owners of well-known names.
+	owners map[string]string
+	count  int
+	mu     sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+// stubConn is a connection to stubBus.
+type stubConn struct {
+	conn   *net.UnixConn
+	unique string
+	serial uint32
+	mu     sync.Mutex
+}
+
+// send sends m to the connection.
+func (c *stubConn) send(m *wireMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m.serial == 0 {
+		c.serial++
+		m.serial = c.serial
+	}
+	m.raw = nil
+	return writeMessage(c.conn, m)
+}
+
+// newStubBus starts a stubBus listening on name.
+func newStubBus(t *testing.T, name string) *stubBus {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix: error = %v", err)
+	}
+	b := stubBus{l: l, conns: make(map[string]*stubConn), owners: make(map[string]string)}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			conn, acceptErr := l.AcceptUnix()
+			if acceptErr != nil {
+				return
+			}
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				defer func() { _ = conn.Close() }()
+				if serveErr := b.serve(&stubConn{conn: conn}); !isClosed(serveErr) && !errors.Is(serveErr, syscall.ECONNRESET) {
+					t.Errorf("serve: error = %v", serveErr)
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		_ = l.Close()
+		b.mu.Lock()
+		for _, c := range b.conns {
+			_ = c.conn.Close()
+		}
+		b.mu.Unlock()
+		b.wg.Wait()
+	})
+	return &b
+}
+
+// serve handles messages sent by c until it is closed.
+func (b *stubBus) serve(c *stubConn) error {
+	r := reader{conn: c.conn}
+	defer r.close()
+	if err := accept(c.conn, &r, "stub", true); err != nil {
+		return err
+	}
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c.unique)
+		b.mu.Unlock()
+	}()
+
+	for {
+		m, err := r.next()
+		if err != nil {
+			return err
+		}
+		m.sender = c.unique
+		if m.destination != busName {
+			b.route(m)
+			continue
+		}
+
+		reply := wireMessage{order: m.order, kind: typeMethodReturn, replySerial: m.serial,
+			destination: c.unique, sender: busName}
+		var name string
+		if d, argsErr := m.args("s"); argsErr == nil {
+			name, _ = d.string()
+		}
+		var (
+			e      = encoder{order: m.order}
+			signal *wireMessage
+		)
+
+		b.mu.Lock()
+		owner, ok := b.owners[name]
+		if _, unique := b.conns[name]; unique {
+			owner, ok = name, true
+		}
+		switch m.member {
+		case "Hello":
+			b.count++
+			c.unique = ":1." + strconv.Itoa(b.count)
+			b.conns[c.unique] = c
+			reply.destination = c.unique
+			reply.signature, reply.body = "s", encodeString(m.order, c.unique)
+
+		case "RequestName":
+			b.owners[name] = c.unique
+			e.uint32(1)
+			reply.signature, reply.body = "u", e.buf
+			args := encoder{order: m.order}
+			args.string(name)
+			args.string("")
+			args.string(c.unique)
+			signal = &wireMessage{order: m.order, kind: typeSignal, sender: busName,
+				path: busPath, iface: busName, member: "NameOwnerChanged",
+				signature: "sss", body: args.buf}
+
+		case "NameHasOwner":
+			if ok {
+				e.uint32(1)
+			} else {
+				e.uint32(0)
+			}
+			reply.signature, reply.body = "b", e.buf
+
+		case "GetNameOwner":
+			if ok {
+				reply.signature, reply.body = "s", encodeString(m.order, owner)
+			} else {
+				reply.kind, reply.errorName = typeError, errNameHasNoOwner
+			}
+
+		case "ListNames":
+			names := []string{busName}
+			for n := range b.conns {
+				names = append(names, n)
+			}
+			for n := range b.owners {
+				names = append(names, n)
+			}
+			slices.Sort(names)
+			reply.signature, reply.body = "as", encodeStrings(m.order, names)
+
+		case "GetId", "AddMatch":
+
+		default:
+			reply.kind, reply.errorName = typeError, "org.freedesktop.DBus.Error.UnknownMethod"
+		}
+		b.mu.Unlock()
+
+		if err = c.send(&reply); err != nil {
+			return err
+		}
+		if signal != nil {
+			b.route(signal)
+		}
+	}
+}
+
+// route delivers m to its destination, or to every other connection if it is a broadcast.
+func (b *stubBus) route(m *wireMessage) {
+	b.mu.Lock()
+	var targets []*stubConn
+	if m.destination == "" {
+		for unique, c := range b.conns {
+			if unique != m.sender {
+				targets = append(targets, c)
+			}
+		}
+	} else if owner, ok := b.owners[m.destination]; ok {
+		targets = append(targets, b.conns[owner])
+	} else if c, ok := b.conns[m.destination]; ok {
+		targets = append(targets, c)
+	}
+	b.mu.Unlock()
+
+	for _, c := range targets {
+		dup := *m
+		dup.fds = nil
+		if err := c.send(&dup); err != nil {
+			return
+		}
+	}
+	closeFDs(m.fds)
+}
+
+// testConn is a client connection used by tests.
+type testConn struct {
+	t      *testing.T
+	conn   *net.UnixConn
+	r      reader
+	serial uint32
+	unique string
+
+	// Signals received while waiting for a reply.
+	signals []*wireMessage
+}
+
+// dialTest connects and authenticates to the bus at name, and calls Hello.
+func dialTest(t *testing.T, name string) *testConn {
+	t.Helper()
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		t.Fatalf("DialUnix: error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	c := testConn{t: t, conn: conn, r: reader{conn: conn}}
+	if _, _, err = authenticate(conn, &c.r); err != nil {
+		t.Fatalf("authenticate: error = %v", err)
+	}
+
+	reply := c.call(busName, busPath, busName, "Hello", "", nil)
+	if d, argsErr := reply.args("s"); argsErr != nil {
+		t.Fatalf("Hello: error = %v", argsErr)
+	} else if c.unique, err = d.string(); err != nil {
+		t.Fatalf("Hello: error = %v", err)
+	}
+	return &c
+}
+
+// send sends m with the next serial and returns the serial.
+func (c *testConn) send(m *wireMessage) uint32 {
+	c.t.Helper()
+	c.serial++
+	m.order, m.serial = binary.LittleEndian, c.serial
+	if err := writeMessage(c.conn, m); err != nil {
+		c.t.Fatalf("writeMessage: error = %v", err)
+	}
+	return m.serial
+}
+
+// recv returns the next message other than a signal, and stores signals received before it.
+func (c *testConn) recv() *wireMessage {
+	c.t.Helper()
+	for {
+		m, err := c.r.next()
+		if err != nil {
+			c.t.Fatalf("next: error = %v", err)
+		}
+		if m.kind != typeSignal {
+			return m
+		}
+		c.signals = append(c.signals, m)
+	}
+}
+
+// call makes a method call and returns its reply.
+func (c *testConn) call(destination, path, iface, member, signature string, body []byte) *wireMessage {
+	c.t.Helper()
+	serial := c.send(&wireMessage{kind: typeMethodCall, destination: destination,
+		path: path, iface: iface, member: member, signature: signature, body: body})
+	m := c.recv()
+	if m.replySerial != serial {
+		c.t.Fatalf("call: reply to %d, want %d", m.replySerial, serial)
+	}
+	return m
+}
+
+// callBus calls a method of the message bus with a single name argument.
+func (c *testConn) callBus(member, name string) *wireMessage {
+	c.t.Helper()
+	return c.call(busName, busPath, busName, member, "s", encodeString(binary.LittleEndian, name))
+}
+
+// wantError checks that m is an error with the specified name and message.
+func wantError(t *testing.T, m *wireMessage, name, msg string) {
+	t.Helper()
+	if m.kind != typeError || m.errorName != name {
+		t.Fatalf("reply: kind %d, error %q, want %q", m.kind, m.errorName, name)
+	}
+	if d, err := m.args("s"); err != nil {
+		t.Fatalf("args: error = %v", err)
+	} else if v, _ := d.string(); msg != "" && v != msg {
+		t.Errorf("error message: %q, want %q", v, msg)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewFilter(nil, ProxyPair{}, nil, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("NewFilter: error = %v", err)
+	}
+	if _, err := NewFilter(nil, ProxyPair{}, &hst.BusConfig{Talk: []string{"org"}}, nil); !errors.Is(err, ErrBadName) {
+		t.Fatalf("NewFilter: error = %v", err)
+	}
+	if _, err := NewFilter(nil, ProxyPair{"tcp:host=localhost,port=1"}, new(hst.BusConfig), nil); !errors.Is(err, ErrNoAddress) {
+		t.Fatalf("NewFilter: error = %v", err)
+	}
+	if err := (*Filter)(nil).Close(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	d := check.MustAbs(t.TempDir())
+	busPathname, bindPath := d.Append("bus").String(), d.Append("proxy").String()
+	bus := newStubBus(t, busPathname)
+
+	services := make(map[string]*testConn)
+	for _, name := range []string{"org.example.Talk", "org.example.See", "org.example.Restricted", "org.example.Hidden"} {
+		services[name] = dialTest(t, busPathname)
+		if reply := services[name].callBus("RequestName", name); reply.kind != typeMethodReturn {
+			t.Fatalf("RequestName: error = %s", reply.errorName)
+		}
+	}
+
+	f, err := NewFilter(message.New(nil), ProxyPair{"unix:path=" + busPathname, bindPath}, &hst.BusConfig{
+		See:       []string{"org.example.See"},
+		Talk:      []string{"org.example.Talk"},
+		Own:       []string{"org.example.App.*"},
+		Call:      map[string]string{"org.example.Restricted": "org.example.Restricted.Get@/org/example"},
+		Broadcast: map[string]string{"org.example.Restricted": "org.example.Restricted.*@/org/example/*"},
+		Filter:    true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewFilter: error = %v", err)
+	}
+	c := dialTest(t, bindPath)
+
+	t.Run("names", func(t *testing.T) {
+		reply := c.call(busName, busPath, busName, "ListNames", "", nil)
+		var names []string
+		if a, argsErr := reply.args("as"); argsErr != nil {
+			t.Fatalf("ListNames: error = %v", argsErr)
+		} else if names, err = a.strings(); err != nil {
+			t.Fatalf("ListNames: error = %v", err)
+		}
+		// owners of visible names are visible
+		want := []string{
+			c.unique,
+			services["org.example.Talk"].unique,
+			services["org.example.See"].unique,
+			services["org.example.Restricted"].unique,
+			"org.example.Restricted", "org.example.See", "org.example.Talk", busName,
+		}
+		slices.Sort(want)
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("ListNames: %q, want %q", names, want)
+		}
+
+		if reply = c.callBus("NameHasOwner", "org.example.Hidden"); reply.kind != typeMethodReturn ||
+			reply.signature != "b" || !reflect.DeepEqual(reply.body, []byte{0, 0, 0, 0}) {
+			t.Errorf("NameHasOwner: %#v", reply)
+		}
+		wantError(t, c.callBus("GetNameOwner", "org.example.Hidden"), errNameHasNoOwner,
+			"Could not get owner of name 'org.example.Hidden': no such name")
+		wantError(t, c.callBus("GetNameOwner", services["org.example.Hidden"].unique), errNameHasNoOwner, "")
+
+		if reply = c.callBus("GetNameOwner", "org.example.See"); reply.kind != typeMethodReturn {
+			t.Errorf("GetNameOwner: error = %s", reply.errorName)
+		}
+	})
+
+	t.Run("own", func(t *testing.T) {
+		if reply := c.callBus("RequestName", "org.example.App.Player"); reply.kind != typeMethodReturn {
+			t.Errorf("RequestName: error = %s", reply.errorName)
+		}
+		wantError(t, c.callBus("RequestName", "org.example.Other"), errAccessDenied,
+			`own access to "org.example.Other" not granted`)
+		wantError(t, c.call(busName, busPath, "org.freedesktop.DBus.Monitoring", "BecomeMonitor", "", nil),
+			errAccessDenied, `interface org.freedesktop.DBus.Monitoring of the message bus is not permitted`)
+		wantError(t, c.callBus("AddMatch", "eavesdrop=true"), errAccessDenied,
+			`eavesdropping is not permitted`)
+		wantError(t, c.callBus("AddMatch", "type='signal', eavesdrop='true'"), errAccessDenied,
+			`eavesdropping is not permitted`)
+	})
+
+	t.Run("call", func(t *testing.T) {
+		service := services["org.example.Talk"]
+		serial := c.send(&wireMessage{kind: typeMethodCall, destination: "org.example.Talk",
+			path: "/", iface: "org.example.Any", member: "Any"})
+		if m := service.recv(); m.kind != typeMethodCall || m.member != "Any" || m.sender != c.unique {
+			t.Fatalf("recv: %#v", m)
+		} else {
+			service.send(&wireMessage{kind: typeMethodReturn, replySerial: m.serial, destination: m.sender})
+		}
+		if m := c.recv(); m.kind != typeMethodReturn || m.replySerial != serial {
+			t.Fatalf("recv: %#v", m)
+		}
+
+		wantError(t, c.call("org.example.See", "/", "org.example.Any", "Any", "", nil),
+			errAccessDenied, `talk access to "org.example.See" not granted`)
+		wantError(t, c.call("org.example.Hidden", "/", "org.example.Any", "Any", "", nil),
+			errAccessDenied, `talk access to "org.example.Hidden" not granted`)
+
+		restricted := services["org.example.Restricted"]
+		owner := c.callBus("GetNameOwner", "org.example.Restricted")
+		if a, argsErr := owner.args("s"); argsErr != nil {
+			t.Fatalf("GetNameOwner: error = %v", argsErr)
+		} else if v, _ := a.string(); v != restricted.unique {
+			t.Fatalf("GetNameOwner: %q, want %q", v, restricted.unique)
+		}
+		wantError(t, c.call(restricted.unique, "/org/example", "org.example.Restricted", "Set", "", nil),
+			errAccessDenied, `no call rule for "`+restricted.unique+`" permits org.example.Restricted.Set at /org/example`)
+		serial = c.send(&wireMessage{kind: typeMethodCall, destination: restricted.unique,
+			path: "/org/example", iface: "org.example.Restricted", member: "Get"})
+		if m := restricted.recv(); m.member != "Get" {
+			t.Fatalf("recv: %#v", m)
+		} else {
+			restricted.send(&wireMessage{kind: typeMethodReturn, replySerial: m.serial, destination: m.sender})
+		}
+		if m := c.recv(); m.kind != typeMethodReturn || m.replySerial != serial {
+			t.Fatalf("recv: %#v", m)
+		}
+	})
+
+	t.Run("incoming", func(t *testing.T) {
+		service := services["org.example.Hidden"]
+		serial := service.send(&wireMessage{kind: typeMethodCall, destination: c.unique,
+			path: "/", iface: "org.example.Client", member: "Activate"})
+		m := c.recv()
+		if m.kind != typeMethodCall || m.sender != service.unique {
+			t.Fatalf("recv: %#v", m)
+		}
+		c.send(&wireMessage{kind: typeMethodReturn, replySerial: 0xbad, destination: service.unique})
+		c.send(&wireMessage{kind: typeMethodReturn, replySerial: m.serial, destination: service.unique})
+		c.send(&wireMessage{kind: typeMethodReturn, replySerial: m.serial, destination: service.unique})
+		if m = service.recv(); m.kind != typeMethodReturn || m.replySerial != serial {
+			t.Fatalf("recv: %#v", m)
+		}
+		// the reply following a dropped reply is received next
+		c.call(busName, busPath, busName, "GetId", "", nil)
+		service.call(busName, busPath, busName, "GetId", "", nil)
+	})
+
+	t.Run("misdirected", func(t *testing.T) {
+		// delivered to the proxy regardless of destination, as if by a misbehaving bus
+		bus.mu.Lock()
+		upstream := bus.conns[c.unique]
+		bus.mu.Unlock()
+		sender, destination := services["org.example.Talk"].unique, services["org.example.See"].unique
+		for _, m := range []*wireMessage{
+			{kind: typeMethodCall, path: "/", iface: "org.example.Client", member: "Activate"},
+			{kind: typeSignal, path: "/", iface: "org.example.Any", member: "Changed"},
+		} {
+			m.order, m.sender, m.destination = binary.LittleEndian, sender, destination
+			if err = upstream.send(m); err != nil {
+				t.Fatalf("send: error = %v", err)
+			}
+		}
+		c.signals = nil
+		c.call(busName, busPath, busName, "GetId", "", nil)
+		if len(c.signals) != 0 {
+			t.Errorf("signals: %#v", c.signals)
+		}
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		c.signals = nil
+		signal := func(service, path, iface string) {
+			services[service].send(&wireMessage{kind: typeSignal, path: path, iface: iface, member: "Changed"})
+			services[service].call(busName, busPath, busName, "GetId", "", nil)
+		}
+		signal("org.example.Restricted", "/org", "org.example.Restricted")
+		signal("org.example.Restricted", "/org/example/child", "org.example.Other")
+		signal("org.example.Hidden", "/org/example", "org.example.Restricted")
+		signal("org.example.See", "/org/example", "org.example.Restricted")
+		signal("org.example.Restricted", "/org/example/child", "org.example.Restricted")
+		signal("org.example.Talk", "/", "org.example.Any")
+		c.call(busName, busPath, busName, "GetId", "", nil)
+
+		var got [][2]string
+		for _, m := range c.signals {
+			got = append(got, [2]string{m.sender, m.path})
+		}
+		if want := [][2]string{
+			{services["org.example.Restricted"].unique, "/org/example/child"},
+			{services["org.example.Talk"].unique, "/"},
+		}; !reflect.DeepEqual(got, want) {
+			t.Errorf("signals: %q, want %q", got, want)
+		}
+	})
+
+	if err = f.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+	if _, err = c.r.next(); err == nil {
+		t.Errorf("next: unexpected message after Close")
+	}
+	if _, err = net.Dial("unix", bindPath); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Dial: error = %v", err)
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	t.Parallel()
+
+	d := check.MustAbs(t.TempDir())
+	busPathname, bindPath := d.Append("bus").String(), d.Append("proxy").String()
+	newStubBus(t, busPathname)
+
+	var buf bytes.Buffer
+	f, err := NewFilter(message.New(log.New(io.Discard, "", 0)), ProxyPair{"unix:path=" + busPathname, bindPath},
+		&hst.BusConfig{Talk: []string{"org.example.Talk"}, Filter: true, Log: true}, NewEventLog(&buf, SessionBus))
+	if err != nil {
+		t.Fatalf("NewFilter: error = %v", err)
+	}
+	c := dialTest(t, bindPath)
+	c.call(busName, busPath, busName, "GetId", "", nil)
+	wantError(t, c.callBus("RequestName", "org.example.Talk"), errAccessDenied, "")
+	if err = f.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	events, err := ReadEvents(&buf)
+	if err != nil {
+		t.Fatalf("ReadEvents: error = %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		if e.Conn != 1 || e.Bus != SessionBus || e.Time.IsZero() {
+			t.Errorf("ReadEvents: %#v", e)
+		}
+		if e.Member == "RequestName" && e.Arg0 != "org.example.Talk" {
+			t.Errorf("ReadEvents: arg0 = %q", e.Arg0)
+		}
+		e.Conn, e.Time, e.Serial = 0, time.Time{}, 0
+		if e.ReplySerial != 0 {
+			e.ReplySerial = 1
+		}
+		got = append(got, e.String())
+	}
+	want := []string{
+		"-> org.freedesktop.DBus #0 call org.freedesktop.DBus.Hello at /org/freedesktop/DBus",
+		"<- org.freedesktop.DBus #0 return for #1",
+		"-> org.freedesktop.DBus #0 call org.freedesktop.DBus.GetId at /org/freedesktop/DBus",
+		"<- org.freedesktop.DBus #0 return for #1",
+		"-> org.freedesktop.DBus #0 call org.freedesktop.DBus.RequestName at /org/freedesktop/DBus" +
+			` denied: own access to "org.example.Talk" not granted`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadEvents: %q, want %q", got, want)
+	}
+}
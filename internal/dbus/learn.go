@@ -0,0 +1,207 @@
+package dbus
+
+import (
+	"slices"
+	"strings"
+
+	"hakurei.app/hst"
+)
+
+// Learn returns a minimal [hst.BusConfig] permitting every message sent by clients on the
+// message bus identified by bus in events, or nil if no such message was recorded.
+//
+// Names passed to bus methods as arguments are learnt from the Arg0 field, so only events
+// recorded by [Filter] are useful here. Since [hst.BusConfig] holds a single call and
+// broadcast rule for each name, a rule covers every method call to or subscribed broadcast
+// from its name by leaving out fields that differ between them. Talk access is granted
+// instead of a call rule matching every method call.
+func Learn(events []Event, bus string) *hst.BusConfig {
+	var (
+		recorded  bool
+		see, talk = make(map[string]struct{}), make(map[string]struct{})
+		own       = make(map[string]struct{})
+		call      = make(map[string]*learntRule)
+		broadcast = make(map[string]*learntRule)
+	)
+
+	for i := range events {
+		e := &events[i]
+		if e.Bus != bus || e.Direction != Outgoing {
+			continue
+		}
+		recorded = true
+		if e.Type != MessageCall || !validName(e.Destination, true) {
+			continue
+		}
+
+		if e.Destination != busName {
+			learnRule(call, e.Destination, e.Interface, e.Member, e.Path, false)
+			continue
+		}
+		if e.Arg0 == "" {
+			continue
+		}
+
+		switch e.Member {
+		case "RequestName":
+			if validName(e.Arg0, true) {
+				own[e.Arg0] = struct{}{}
+			}
+
+		case "StartServiceByName":
+			if validName(e.Arg0, true) {
+				talk[e.Arg0] = struct{}{}
+			}
+
+		case "NameHasOwner", "GetNameOwner",
+			"GetConnectionUnixUser", "GetConnectionUnixProcessID", "GetConnectionCredentials":
+			if validName(e.Arg0, true) {
+				see[e.Arg0] = struct{}{}
+			}
+
+		case "AddMatch":
+			m := parseMatchRule(e.Arg0)
+			if m["type"] != "signal" || m["sender"] == busName || !validName(m["sender"], true) {
+				continue
+			}
+			path, subtree := m["path"], false
+			if namespace, ok := m["path_namespace"]; ok {
+				path, subtree = namespace, true
+			}
+			learnRule(broadcast, m["sender"], m["interface"], m["member"], path, subtree)
+		}
+	}
+
+	if !recorded {
+		return nil
+	}
+
+	config := hst.BusConfig{Filter: true}
+	for name, r := range call {
+		if s, ok := r.String(); ok {
+			if config.Call == nil {
+				config.Call = make(map[string]string)
+			}
+			config.Call[name] = s
+		} else {
+			talk[name] = struct{}{}
+		}
+	}
+	for name := range own {
+		delete(talk, name)
+		delete(config.Call, name)
+	}
+	for name := range see {
+		if _, ok := config.Call[name]; !ok {
+			config.See = append(config.See, name)
+		}
+	}
+	for name, r := range broadcast {
+		if config.Broadcast == nil {
+			config.Broadcast = make(map[string]string)
+		}
+		// broadcast rules grant see access only, so matching every broadcast is fine here
+		config.Broadcast[name], _ = r.String()
+	}
+	for name := range own {
+		config.Own = append(config.Own, name)
+		delete(config.Broadcast, name)
+	}
+	for name := range talk {
+		config.Talk = append(config.Talk, name)
+		delete(config.Call, name)
+		delete(config.Broadcast, name)
+	}
+	config.See = slices.DeleteFunc(config.See, func(name string) bool {
+		_, isTalk := talk[name]
+		_, isOwn := own[name]
+		_, isBroadcast := config.Broadcast[name]
+		return isTalk || isOwn || isBroadcast
+	})
+
+	if len(config.Call) == 0 {
+		config.Call = nil
+	}
+	if len(config.Broadcast) == 0 {
+		config.Broadcast = nil
+	}
+	slices.Sort(config.See)
+	slices.Sort(config.Talk)
+	slices.Sort(config.Own)
+	return &config
+}
+
+// A learntRule is the narrowest rule matching every message seen so far.
+type learntRule struct {
+	iface, member, path string
+	subtree             bool
+	// Whether the corresponding field differs between messages.
+	anyIface, anyMember, anyPath bool
+}
+
+// learnRule widens the rule for name in rules to match a message with the specified fields.
+func learnRule(rules map[string]*learntRule, name, iface, member, path string, subtree bool) {
+	r, ok := rules[name]
+	if !ok {
+		rules[name] = &learntRule{iface, member, path, subtree, iface == "", member == "", path == ""}
+		return
+	}
+	r.anyIface = r.anyIface || iface == "" || iface != r.iface
+	r.anyMember = r.anyMember || member == "" || member != r.member
+	r.anyPath = r.anyPath || path == "" || path != r.path || subtree != r.subtree
+}
+
+// String returns the rule in the form accepted by parseRule, and false if it matches every message.
+func (r *learntRule) String() (string, bool) {
+	var s string
+	switch {
+	case r.anyIface:
+		s = "*"
+	case r.anyMember:
+		s = r.iface + ".*"
+	default:
+		s = r.iface + "." + r.member
+	}
+	if !r.anyPath {
+		if r.subtree {
+			s += "@" + strings.TrimSuffix(r.path, "/") + "/*"
+		} else {
+			s += "@" + r.path
+		}
+	}
+	return s, s != "*"
+}
+
+// parseMatchRule returns the keys and values of a match rule passed to the AddMatch bus method.
+// Malformed rules are parsed up to the first error.
+func parseMatchRule(s string) map[string]string {
+	m := make(map[string]string)
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		var value strings.Builder
+		for rest != "" && rest[0] != ',' {
+			if rest[0] == '\'' {
+				quoted, after, closed := strings.Cut(rest[1:], "'")
+				if !closed {
+					return m
+				}
+				value.WriteString(quoted)
+				rest = after
+			} else if rest[0] == '\\' && len(rest) > 1 && rest[1] == '\'' {
+				value.WriteByte('\'')
+				rest = rest[2:]
+			} else {
+				value.WriteByte(rest[0])
+				rest = rest[1:]
+			}
+		}
+		m[key] = value.String()
+		s = strings.TrimPrefix(rest, ",")
+	}
+	return m
+}
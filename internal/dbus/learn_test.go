@@ -0,0 +1,113 @@
+package dbus
+
+import (
+	"reflect"
+	"testing"
+
+	"hakurei.app/hst"
+)
+
+func TestLearn(t *testing.T) {
+	t.Parallel()
+
+	busCall := func(member, arg0 string) Event {
+		return Event{Bus: SessionBus, Direction: Outgoing, Type: MessageCall,
+			Destination: busName, Interface: busName, Member: member, Path: busPath, Arg0: arg0}
+	}
+	call := func(destination, iface, member, path string) Event {
+		return Event{Bus: SessionBus, Direction: Outgoing, Type: MessageCall,
+			Destination: destination, Interface: iface, Member: member, Path: path}
+	}
+
+	events := []Event{
+		busCall("Hello", ""),
+		busCall("RequestName", "org.chromium.Chromium"),
+		busCall("RequestName", ":1.1"),
+		busCall("NameHasOwner", "org.freedesktop.secrets"),
+		busCall("GetNameOwner", "org.freedesktop.Notifications"),
+		busCall("GetNameOwner", "org.freedesktop.portal.Documents"),
+		busCall("StartServiceByName", "org.kde.kwalletd6"),
+		busCall("AddMatch", "type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged'"),
+		busCall("AddMatch", "type='signal',sender='org.freedesktop.portal.Desktop',interface='org.freedesktop.portal.Request',member='Response',path_namespace='/org/freedesktop/portal/desktop/request'"),
+		busCall("AddMatch", "type='signal',sender='org.freedesktop.portal.Desktop',interface='org.freedesktop.portal.Settings',member='SettingChanged',path='/org/freedesktop/portal/desktop'"),
+		busCall("AddMatch", "type='signal',sender='org.freedesktop.Notifications',interface='org.freedesktop.Notifications'"),
+		busCall("AddMatch", "type='signal',sender='org.kde.kwalletd6'"),
+		busCall("AddMatch", "type='signal',sender=':1.4',interface='org.example'"),
+		busCall("AddMatch", "type='method_call',sender='org.example.Method'"),
+
+		call("org.freedesktop.portal.Desktop", "org.freedesktop.portal.Settings", "Read", "/org/freedesktop/portal/desktop"),
+		call("org.freedesktop.portal.Desktop", "org.freedesktop.portal.FileChooser", "OpenFile", "/org/freedesktop/portal/desktop"),
+		call("org.freedesktop.Notifications", "org.freedesktop.Notifications", "Notify", "/org/freedesktop/Notifications"),
+		call("org.freedesktop.Notifications", "org.freedesktop.Notifications", "Notify", "/org/freedesktop/Notifications"),
+		call("org.freedesktop.ScreenSaver", "org.freedesktop.ScreenSaver", "Inhibit", "/org/freedesktop/ScreenSaver"),
+		call("org.freedesktop.ScreenSaver", "org.freedesktop.ScreenSaver", "UnInhibit", "/ScreenSaver"),
+		call("org.freedesktop.Accounts", "org.freedesktop.Accounts", "FindUserById", "/org/freedesktop/Accounts"),
+		call("org.freedesktop.Accounts", "org.freedesktop.DBus.Properties", "Get", "/org/freedesktop/Accounts/User1000"),
+		call("org.freedesktop.FileManager1", "org.freedesktop.FileManager1", "ShowItems", "/org/freedesktop/FileManager1"),
+		call("org.freedesktop.FileManager1", "org.freedesktop.DBus.Properties", "Get", "/org/freedesktop/FileManager1"),
+		call("org.chromium.Chromium", "org.chromium.Chromium", "Activate", "/"),
+		call("org.kde.kwalletd6", "org.kde.KWallet", "open", "/modules/kwalletd6"),
+		call(":1.7", "org.example", "Method", "/"),
+		{Bus: SessionBus, Direction: Outgoing, Type: MessageSignal, Interface: "org.example", Member: "Changed", Path: "/"},
+		{Bus: SessionBus, Direction: Incoming, Type: MessageCall, Sender: "org.example.Incoming", Interface: "org.example", Member: "Method", Path: "/"},
+
+		{Bus: SystemBus, Direction: Incoming, Type: MessageSignal, Sender: busName, Interface: busName, Member: "NameAcquired", Path: busPath},
+	}
+
+	want := &hst.BusConfig{
+		See:  []string{"org.freedesktop.portal.Documents", "org.freedesktop.secrets"},
+		Talk: []string{"org.freedesktop.Accounts", "org.kde.kwalletd6"},
+		Own:  []string{"org.chromium.Chromium"},
+		Call: map[string]string{
+			"org.freedesktop.FileManager1":   "*@/org/freedesktop/FileManager1",
+			"org.freedesktop.portal.Desktop": "*@/org/freedesktop/portal/desktop",
+			"org.freedesktop.Notifications":  "org.freedesktop.Notifications.Notify@/org/freedesktop/Notifications",
+			"org.freedesktop.ScreenSaver":    "org.freedesktop.ScreenSaver.*",
+		},
+		Broadcast: map[string]string{
+			"org.freedesktop.portal.Desktop": "*",
+			"org.freedesktop.Notifications":  "org.freedesktop.Notifications.*",
+		},
+		Filter: true,
+	}
+
+	got := Learn(events, SessionBus)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Learn: %#v, want %#v", got, want)
+	}
+	if _, err := newPolicy(got); err != nil {
+		t.Errorf("newPolicy: error = %v", err)
+	}
+
+	if got = Learn(events, SystemBus); got != nil {
+		t.Errorf("Learn: %#v", got)
+	}
+	if got = Learn(append(events, Event{Bus: SystemBus, Direction: Outgoing, Type: MessageCall,
+		Destination: busName, Interface: busName, Member: "Hello", Path: busPath}), SystemBus); !reflect.DeepEqual(got, &hst.BusConfig{Filter: true}) {
+		t.Errorf("Learn: %#v", got)
+	}
+}
+
+func TestParseMatchRule(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		rule string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{"type='signal',sender='org.example',path_namespace='/org/example'",
+			map[string]string{"type": "signal", "sender": "org.example", "path_namespace": "/org/example"}},
+		{"type=signal, arg0='it'\\''s'", map[string]string{"type": "signal", "arg0": "it's"}},
+		{"type='signal',sender='org.example", map[string]string{"type": "signal"}},
+		{"type='signal',sender", map[string]string{"type": "signal"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			t.Parallel()
+			if got := parseMatchRule(tc.rule); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseMatchRule: %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package dbus
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"hakurei.app/hst"
+)
+
+var (
+	// ErrBadName is wrapped by [PolicyError] for a malformed bus name.
+	ErrBadName = errors.New("invalid bus name")
+	// ErrBadRule is wrapped by [PolicyError] for a malformed call or broadcast rule.
+	ErrBadRule = errors.New("invalid rule")
+)
+
+// PolicyError is returned by [NewFilter] for an [hst.BusConfig] entry the proxy cannot enforce.
+type PolicyError struct {
+	// Segment names the offending [hst.BusConfig] field.
+	Segment string
+	// Name is the offending bus name, or the bus name of the offending rule.
+	Name string
+	// Rule is the offending rule, empty if Name is malformed.
+	Rule string
+	// Err is [ErrBadName] or [ErrBadRule].
+	Err error
+}
+
+func (e *PolicyError) Unwrap() error   { return e.Err }
+func (e *PolicyError) Message() string { return e.Error() }
+func (e *PolicyError) Error() string {
+	if e.Rule == "" {
+		return "invalid bus name " + strconv.Quote(e.Name) + " in " + e.Segment + " policy"
+	}
+	return "invalid " + e.Segment + " rule " + strconv.Quote(e.Rule) + " for " + strconv.Quote(e.Name)
+}
+
+// level is the access granted to a bus name, in increasing order of access.
+type level byte
+
+const (
+	// levelNone hides the name from the client.
+	levelNone level = iota
+	// levelSee makes the name visible to the client.
+	levelSee
+	// levelTalk permits method calls to the name subject to call rules.
+	levelTalk
+	// levelOwn permits the client to own the name.
+	levelOwn
+)
+
+// A rule matches messages by interface, member and object path. Empty fields match any value.
+type rule struct {
+	iface, member string
+	path          string
+	// Whether path also matches every object below it.
+	subtree bool
+}
+
+// parseRule parses a rule of the form [METHOD][@PATH] used by xdg-dbus-proxy, where METHOD is
+// "*", "org.the.interface.*" or "org.the.interface.method", and PATH is an object path with an
+// optional "/*" suffix matching every object below it.
+func parseRule(s string) (r rule, ok bool) {
+	method, path, hasPath := strings.Cut(s, "@")
+	if method != "" && method != "*" {
+		if iface, wildcard := strings.CutSuffix(method, ".*"); wildcard {
+			r.iface = iface
+		} else if i := strings.LastIndexByte(method, '.'); i != -1 {
+			r.iface, r.member = method[:i], method[i+1:]
+			if !validMember(r.member) {
+				return
+			}
+		}
+		if !validName(r.iface, false) {
+			return
+		}
+	}
+
+	if hasPath {
+		if path == "/*" {
+			r.path, r.subtree = "/", true
+		} else {
+			r.path, r.subtree = strings.CutSuffix(path, "/*")
+		}
+		if !validPath(r.path) {
+			return
+		}
+	}
+	return r, true
+}
+
+// match returns whether a message with the specified header fields matches r.
+func (r *rule) match(path, iface, member string) bool {
+	if r.iface != "" && r.iface != iface {
+		return false
+	}
+	if r.member != "" && r.member != member {
+		return false
+	}
+	if r.path == "" || r.path == path {
+		return true
+	}
+	return r.subtree && (r.path == "/" || strings.HasPrefix(path, r.path+"/"))
+}
+
+// A nameEntry holds access granted to a bus name, or to a name and every name below it.
+type nameEntry struct {
+	name     string
+	wildcard bool
+
+	level level
+	// Whether messages are permitted without matching a rule.
+	unrestricted bool
+	// Rules for method calls to and broadcasts from the name.
+	call, broadcast []rule
+}
+
+// matchName returns whether e applies to name.
+func (e *nameEntry) matchName(name string) bool {
+	return e.name == name || (e.wildcard && strings.HasPrefix(name, e.name+"."))
+}
+
+// policy is the enforced form of [hst.BusConfig].
+type policy struct {
+	// Whether messages are filtered at all.
+	filter  bool
+	entries []nameEntry
+}
+
+// newPolicy checks and converts [hst.BusConfig] to policy, returning [PolicyError] for an
+// invalid entry. A name with a ".*" suffix applies to the name and every name below it.
+// See, talk and own grant the corresponding access to a name. A call rule grants talk access
+// to its name, restricted to matching method calls, and a broadcast rule grants see access
+// and delivery of matching broadcasts from the name. Broadcasts from names with talk access
+// granted via talk or own are always delivered.
+func newPolicy(c *hst.BusConfig) (*policy, error) {
+	p := policy{filter: c.Filter}
+
+	entry := func(segment, name string) (*nameEntry, error) {
+		e := nameEntry{}
+		e.name, e.wildcard = strings.CutSuffix(name, ".*")
+		if !validName(e.name, true) {
+			return nil, &PolicyError{segment, name, "", ErrBadName}
+		}
+		for i := range p.entries {
+			if p.entries[i].name == e.name && p.entries[i].wildcard == e.wildcard {
+				return &p.entries[i], nil
+			}
+		}
+		p.entries = append(p.entries, e)
+		return &p.entries[len(p.entries)-1], nil
+	}
+	grant := func(segment string, names []string, l level) error {
+		for _, name := range names {
+			if e, err := entry(segment, name); err != nil {
+				return err
+			} else {
+				e.level = max(e.level, l)
+				// see access alongside a call rule must not lift the restriction
+				if l >= levelTalk {
+					e.unrestricted = true
+				}
+			}
+		}
+		return nil
+	}
+	rules := func(segment string, m map[string]string, l level) error {
+		for name, s := range m {
+			e, err := entry(segment, name)
+			if err != nil {
+				return err
+			}
+			r, ok := parseRule(s)
+			if !ok {
+				return &PolicyError{segment, name, s, ErrBadRule}
+			}
+			e.level = max(e.level, l)
+			if l == levelTalk {
+				e.call = append(e.call, r)
+			} else {
+				e.broadcast = append(e.broadcast, r)
+			}
+		}
+		return nil
+	}
+
+	if err := grant("see", c.See, levelSee); err != nil {
+		return nil, err
+	}
+	if err := grant("talk", c.Talk, levelTalk); err != nil {
+		return nil, err
+	}
+	if err := grant("own", c.Own, levelOwn); err != nil {
+		return nil, err
+	}
+	if err := rules("call", c.Call, levelTalk); err != nil {
+		return nil, err
+	}
+	if err := rules("broadcast", c.Broadcast, levelSee); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// level returns the highest access granted to any of names.
+func (p *policy) level(names ...string) (l level) {
+	for i := range p.entries {
+		for _, name := range names {
+			if p.entries[i].matchName(name) {
+				l = max(l, p.entries[i].level)
+			}
+		}
+	}
+	return
+}
+
+// call returns whether a method call to a connection owning names is permitted.
+func (p *policy) call(names []string, path, iface, member string) bool {
+	return p.match(names, func(e *nameEntry) []rule { return e.call }, path, iface, member)
+}
+
+// broadcast returns whether a signal from a connection owning names is delivered.
+func (p *policy) broadcast(names []string, path, iface, member string) bool {
+	return p.match(names, func(e *nameEntry) []rule { return e.broadcast }, path, iface, member)
+}
+
+// match returns whether an entry applying to any of names is unrestricted or has a matching rule.
+func (p *policy) match(names []string, rules func(e *nameEntry) []rule, path, iface, member string) bool {
+	for i := range p.entries {
+		e := &p.entries[i]
+		for _, name := range names {
+			if !e.matchName(name) {
+				continue
+			}
+			if e.unrestricted && e.level >= levelTalk {
+				return true
+			}
+			for j := range rules(e) {
+				if rules(e)[j].match(path, iface, member) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validName returns whether s is a well-known bus name, or an interface name if bus is false.
+func validName(s string, bus bool) bool {
+	if len(s) > 255 {
+		return false
+	}
+	elements := strings.Split(s, ".")
+	if len(elements) < 2 {
+		return false
+	}
+	for _, element := range elements {
+		if element == "" || (element[0] >= '0' && element[0] <= '9') {
+			return false
+		}
+		for _, c := range []byte(element) {
+			if !validNameByte(c) && (!bus || c != '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validMember returns whether s is a member name.
+func validMember(s string) bool {
+	if s == "" || len(s) > 255 || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if !validNameByte(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// validPath returns whether s is an object path.
+func validPath(s string) bool {
+	if s == "/" {
+		return true
+	}
+	if !strings.HasPrefix(s, "/") {
+		return false
+	}
+	for _, element := range strings.Split(s[1:], "/") {
+		if element == "" {
+			return false
+		}
+		for _, c := range []byte(element) {
+			if !validNameByte(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validNameByte returns whether c is permitted in an element of a name or object path.
+func validNameByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
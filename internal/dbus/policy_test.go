@@ -0,0 +1,202 @@
+package dbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hakurei.app/hst"
+)
+
+func TestParseRule(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		rule string
+		want rule
+		ok   bool
+	}{
+		{"", rule{}, true},
+		{"*", rule{}, true},
+		{"org.example.Interface.*", rule{iface: "org.example.Interface"}, true},
+		{"org.example.Interface.Method", rule{iface: "org.example.Interface", member: "Method"}, true},
+		{"@/org/example", rule{path: "/org/example"}, true},
+		{"*@/org/example/*", rule{path: "/org/example", subtree: true}, true},
+		{"@/*", rule{path: "/", subtree: true}, true},
+		{"org.example.Method@/", rule{iface: "org.example", member: "Method", path: "/"}, true},
+
+		{"Method", rule{}, false},
+		{"org.example.1Method", rule{}, false},
+		{"org.example-interface.Method", rule{}, false},
+		{"@org/example", rule{}, false},
+		{"@/org//example", rule{}, false},
+		{"@/org/example/", rule{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			t.Parallel()
+			if got, ok := parseRule(tc.rule); ok != tc.ok || (ok && got != tc.want) {
+				t.Errorf("parseRule: %#v, %v, want %#v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                string
+		rule                string
+		path, iface, member string
+		want                bool
+	}{
+		{"any", "*", "/", "org.example", "Method", true},
+		{"interface", "org.example.*", "/", "org.example", "Method", true},
+		{"interface mismatch", "org.example.*", "/", "org.example.Other", "Method", false},
+		{"method", "org.example.Method", "/", "org.example", "Method", true},
+		{"method mismatch", "org.example.Method", "/", "org.example", "Other", false},
+		{"path", "@/org/example", "/org/example", "org.example", "Method", true},
+		{"path mismatch", "@/org/example", "/org/example/child", "org.example", "Method", false},
+		{"subtree", "@/org/example/*", "/org/example", "org.example", "Method", true},
+		{"subtree child", "@/org/example/*", "/org/example/child", "org.example", "Method", true},
+		{"subtree prefix", "@/org/example/*", "/org/examples", "org.example", "Method", false},
+		{"root subtree", "@/*", "/org", "org.example", "Method", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			r, ok := parseRule(tc.rule)
+			if !ok {
+				t.Fatalf("parseRule: %q rejected", tc.rule)
+			}
+			if got := r.match(tc.path, tc.iface, tc.member); got != tc.want {
+				t.Errorf("match: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name   string
+			config hst.BusConfig
+			want   *PolicyError
+			msg    string
+		}{
+			{"see", hst.BusConfig{See: []string{"org"}},
+				&PolicyError{"see", "org", "", ErrBadName},
+				`invalid bus name "org" in see policy`},
+			{"own wildcard", hst.BusConfig{Own: []string{"org.*"}},
+				&PolicyError{"own", "org.*", "", ErrBadName},
+				`invalid bus name "org.*" in own policy`},
+			{"call name", hst.BusConfig{Call: map[string]string{"org.example.": "*"}},
+				&PolicyError{"call", "org.example.", "", ErrBadName},
+				`invalid bus name "org.example." in call policy`},
+			{"broadcast rule", hst.BusConfig{Broadcast: map[string]string{"org.example": "@org"}},
+				&PolicyError{"broadcast", "org.example", "@org", ErrBadRule},
+				`invalid broadcast rule "@org" for "org.example"`},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				_, err := newPolicy(&tc.config)
+				if !reflect.DeepEqual(err, tc.want) {
+					t.Fatalf("newPolicy: error = %#v, want %#v", err, tc.want)
+				}
+				if !errors.Is(err, tc.want.Err) {
+					t.Errorf("newPolicy: error = %v", err)
+				}
+				if err.Error() != tc.msg {
+					t.Errorf("Error: %q, want %q", err.Error(), tc.msg)
+				}
+			})
+		}
+	})
+
+	p, err := newPolicy(&hst.BusConfig{
+		See:  []string{"org.example.See", "org.example.Limited"},
+		Talk: []string{"org.example.Talk", "org.example.See"},
+		Own:  []string{"org.example.App.*"},
+		Call: map[string]string{
+			"org.example.Restricted.*": "org.example.Restricted.Get@/org/example",
+			"org.example.Limited":      "org.example.Limited.Get",
+		},
+		Broadcast: map[string]string{
+			"org.example.Restricted.*": "org.example.Restricted.*@/org/example/*",
+		},
+		Filter: true,
+	})
+	if err != nil {
+		t.Fatalf("newPolicy: error = %v", err)
+	}
+	if !p.filter {
+		t.Errorf("newPolicy: filter = %v", p.filter)
+	}
+
+	levels := map[string]level{
+		"org.example.See":              levelTalk,
+		"org.example.Talk":             levelTalk,
+		"org.example.Talk.Child":       levelNone,
+		"org.example.App":              levelOwn,
+		"org.example.App.Child":        levelOwn,
+		"org.example.Application":      levelNone,
+		"org.example.Restricted":       levelTalk,
+		"org.example.Restricted.Child": levelTalk,
+		"org.example.Limited":          levelTalk,
+		"org.example.Hidden":           levelNone,
+	}
+	for name, want := range levels {
+		if got := p.level(name); got != want {
+			t.Errorf("level(%q): %d, want %d", name, got, want)
+		}
+	}
+	if got := p.level("org.example.Hidden", "org.example.App"); got != levelOwn {
+		t.Errorf("level: %d, want %d", got, levelOwn)
+	}
+
+	callCases := []struct {
+		names               []string
+		path, iface, member string
+		want                bool
+	}{
+		{[]string{"org.example.Talk"}, "/", "org.example.Any", "Any", true},
+		{[]string{"org.example.App.Child"}, "/", "org.example.Any", "Any", true},
+		{[]string{"org.example.Hidden"}, "/", "org.example.Any", "Any", false},
+		{nil, "/", "org.example.Any", "Any", false},
+		{[]string{"org.example.Restricted"}, "/org/example", "org.example.Restricted", "Get", true},
+		{[]string{"org.example.Restricted"}, "/org/example", "org.example.Restricted", "Set", false},
+		{[]string{"org.example.Restricted"}, "/", "org.example.Restricted", "Get", false},
+		{[]string{"org.example.Hidden", "org.example.Restricted"}, "/org/example", "org.example.Restricted", "Get", true},
+		{[]string{"org.example.Limited"}, "/", "org.example.Limited", "Get", true},
+		{[]string{"org.example.Limited"}, "/", "org.example.Limited", "Set", false},
+	}
+	for _, tc := range callCases {
+		if got := p.call(tc.names, tc.path, tc.iface, tc.member); got != tc.want {
+			t.Errorf("call(%q, %q, %q, %q): %v, want %v", tc.names, tc.path, tc.iface, tc.member, got, tc.want)
+		}
+	}
+
+	broadcastCases := []struct {
+		names               []string
+		path, iface, member string
+		want                bool
+	}{
+		{[]string{"org.example.Talk"}, "/", "org.example.Any", "Any", true},
+		{[]string{"org.example.Restricted"}, "/org/example/child", "org.example.Restricted", "Changed", true},
+		{[]string{"org.example.Restricted"}, "/org", "org.example.Restricted", "Changed", false},
+		{[]string{"org.example.Restricted"}, "/org/example", "org.example.Other", "Changed", false},
+		{[]string{"org.example.Hidden"}, "/org/example", "org.example.Restricted", "Changed", false},
+		{[]string{"org.example.Limited"}, "/", "org.example.Limited", "Changed", false},
+	}
+	for _, tc := range broadcastCases {
+		if got := p.broadcast(tc.names, tc.path, tc.iface, tc.member); got != tc.want {
+			t.Errorf("broadcast(%q, %q, %q, %q): %v, want %v", tc.names, tc.path, tc.iface, tc.member, got, tc.want)
+		}
+	}
+}
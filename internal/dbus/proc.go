@@ -10,6 +10,7 @@ import (
 
 	"hakurei.app/container"
 	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
 	"hakurei.app/container/seccomp"
 	"hakurei.app/container/std"
 	"hakurei.app/internal/helper"
@@ -120,6 +121,10 @@ func (p *Proxy) Start() error {
 				// xdg-dbus-proxy bin path
 				binPath := toolPath.Dir()
 				z.Bind(binPath, binPath, 0)
+
+				if p.final.FlatpakInfo != nil {
+					z.Place(fhs.AbsRoot.Append(".flatpak-info"), p.final.FlatpakInfo)
+				}
 			}, nil)
 	}
 
@@ -53,6 +53,9 @@ type Final struct {
 	Session, System ProxyPair
 	// parsed upstream address
 	SessionUpstream, SystemUpstream []AddrEntry
+	// Contents of .flatpak-info placed at the container root, nil to omit. Clients are identified to
+	// xdg-desktop-portal by their peer process on the bus, which is the proxy.
+	FlatpakInfo []byte
 	io.WriterTo
 }
 
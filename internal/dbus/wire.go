@@ -0,0 +1,572 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Message types
+// (https://dbus.freedesktop.org/doc/dbus-specification.html#message-protocol-messages).
+const (
+	typeMethodCall   = 1
+	typeMethodReturn = 2
+	typeError        = 3
+	typeSignal       = 4
+)
+
+// flagNoReplyExpected is set in the header flags of a method call that does not expect a reply.
+const flagNoReplyExpected = 0x1
+
+// Header field codes
+// (https://dbus.freedesktop.org/doc/dbus-specification.html#message-protocol-header-fields).
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+	fieldUnixFDs     = 9
+)
+
+const (
+	// fixedHeaderSize is the size of the header preceding the header field array.
+	fixedHeaderSize = 16
+	// messageSizeMax is the largest message permitted by the specification.
+	messageSizeMax = 1 << 27
+	// fdsMax is the largest number of file descriptors received in a single read.
+	fdsMax = 253
+)
+
+// ErrMessage is returned when decoding a malformed or unsupported D-Bus message.
+var ErrMessage = errors.New("malformed D-Bus message")
+
+// A wireMessage is a decoded D-Bus message. Header fields not set in the message are zero.
+type wireMessage struct {
+	order  binary.ByteOrder
+	kind   byte
+	flags  byte
+	serial uint32
+
+	path, iface, member string
+	errorName           string
+	replySerial         uint32
+	destination, sender string
+	signature           string
+	unixFDs             uint32
+
+	body []byte
+	// File descriptors received alongside the message.
+	fds []int
+	// Wire representation the message was decoded from, nil if the message was modified.
+	raw []byte
+}
+
+// encode returns the wire representation of m.
+func (m *wireMessage) encode() []byte {
+	e := encoder{order: m.order}
+	if m.order == binary.BigEndian {
+		e.byte('B')
+	} else {
+		e.byte('l')
+	}
+	e.byte(m.kind)
+	e.byte(m.flags)
+	e.byte(1)
+	e.uint32(uint32(len(m.body)))
+	e.uint32(m.serial)
+
+	fields := e.arrayStart(8)
+	field := func(code byte, sig, s string) {
+		if s == "" {
+			return
+		}
+		e.align(8)
+		e.byte(code)
+		e.signature(sig)
+		if sig == "g" {
+			e.signature(s)
+		} else {
+			e.string(s)
+		}
+	}
+	field(fieldPath, "o", m.path)
+	field(fieldInterface, "s", m.iface)
+	field(fieldMember, "s", m.member)
+	field(fieldErrorName, "s", m.errorName)
+	if m.replySerial != 0 {
+		e.align(8)
+		e.byte(fieldReplySerial)
+		e.signature("u")
+		e.uint32(m.replySerial)
+	}
+	field(fieldDestination, "s", m.destination)
+	field(fieldSender, "s", m.sender)
+	field(fieldSignature, "g", m.signature)
+	if m.unixFDs != 0 {
+		e.align(8)
+		e.byte(fieldUnixFDs)
+		e.signature("u")
+		e.uint32(m.unixFDs)
+	}
+	e.arrayEnd(fields)
+
+	e.align(8)
+	return append(e.buf, m.body...)
+}
+
+// decodeMessage decodes a complete message held in data.
+func decodeMessage(data []byte) (*wireMessage, error) {
+	size, err := messageSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if size != len(data) {
+		return nil, ErrMessage
+	}
+
+	m := wireMessage{order: byteOrder(data[0]), kind: data[1], flags: data[2]}
+	if m.kind < typeMethodCall || m.kind > typeSignal || data[3] != 1 {
+		return nil, ErrMessage
+	}
+	m.serial = m.order.Uint32(data[8:])
+	if m.serial == 0 {
+		return nil, ErrMessage
+	}
+
+	fieldsEnd := fixedHeaderSize + int(m.order.Uint32(data[12:]))
+	d := decoder{order: m.order, data: data[:fieldsEnd], pos: fixedHeaderSize}
+	for d.pos < fieldsEnd {
+		if err = d.align(8); err != nil {
+			return nil, err
+		}
+		var code byte
+		if code, err = d.byte(); err != nil {
+			return nil, err
+		}
+		var sig string
+		if sig, err = d.signature(); err != nil {
+			return nil, err
+		}
+
+		var want string
+		var s *string
+		switch code {
+		case fieldPath:
+			want, s = "o", &m.path
+		case fieldInterface:
+			want, s = "s", &m.iface
+		case fieldMember:
+			want, s = "s", &m.member
+		case fieldErrorName:
+			want, s = "s", &m.errorName
+		case fieldDestination:
+			want, s = "s", &m.destination
+		case fieldSender:
+			want, s = "s", &m.sender
+		case fieldSignature:
+			want, s = "g", &m.signature
+
+		case fieldReplySerial, fieldUnixFDs:
+			if sig != "u" {
+				return nil, ErrMessage
+			}
+			var v uint32
+			if v, err = d.uint32(); err != nil {
+				return nil, err
+			}
+			if code == fieldReplySerial {
+				m.replySerial = v
+			} else {
+				m.unixFDs = v
+			}
+			continue
+
+		default:
+			// unknown header fields must be ignored
+			if err = d.skip(sig); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if sig != want {
+			return nil, ErrMessage
+		}
+		if want == "g" {
+			*s, err = d.signature()
+		} else {
+			*s, err = d.string()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.body = data[size-int(m.order.Uint32(data[4:])):]
+	m.raw = data
+	return &m, nil
+}
+
+// byteOrder returns the byte order corresponding to an endianness flag.
+func byteOrder(flag byte) binary.ByteOrder {
+	if flag == 'B' {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// messageSize returns the size of the message at the start of data, which must hold at
+// least the fixed header.
+func messageSize(data []byte) (int, error) {
+	if len(data) < fixedHeaderSize || (data[0] != 'l' && data[0] != 'B') {
+		return 0, ErrMessage
+	}
+	order := byteOrder(data[0])
+	fieldsLen, bodyLen := uint64(order.Uint32(data[12:])), uint64(order.Uint32(data[4:]))
+	size := (fixedHeaderSize+fieldsLen+7)&^7 + bodyLen
+	if size > messageSizeMax {
+		return 0, ErrMessage
+	}
+	return int(size), nil
+}
+
+// decoder decodes values from data, with alignment relative to the start of data.
+type decoder struct {
+	order binary.ByteOrder
+	data  []byte
+	pos   int
+}
+
+// align skips padding up to the next multiple of n.
+func (d *decoder) align(n int) error {
+	next := (d.pos + n - 1) &^ (n - 1)
+	if next > len(d.data) {
+		return ErrMessage
+	}
+	d.pos = next
+	return nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, ErrMessage
+	}
+	d.pos++
+	return d.data[d.pos-1], nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	if d.pos+4 > len(d.data) {
+		return 0, ErrMessage
+	}
+	d.pos += 4
+	return d.order.Uint32(d.data[d.pos-4:]), nil
+}
+
+// bytes returns n bytes followed by a NUL byte.
+func (d *decoder) bytes(n uint64) (string, error) {
+	if n >= uint64(len(d.data)-d.pos) || d.data[d.pos+int(n)] != 0 {
+		return "", ErrMessage
+	}
+	v := string(d.data[d.pos : d.pos+int(n)])
+	d.pos += int(n) + 1
+	return v, nil
+}
+
+// string decodes a value of type STRING or OBJECT_PATH.
+func (d *decoder) string() (string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	return d.bytes(uint64(n))
+}
+
+// signature decodes a value of type SIGNATURE.
+func (d *decoder) signature() (string, error) {
+	n, err := d.byte()
+	if err != nil {
+		return "", err
+	}
+	return d.bytes(uint64(n))
+}
+
+// skip skips a value of the basic type sig.
+func (d *decoder) skip(sig string) (err error) {
+	if len(sig) != 1 {
+		return ErrMessage
+	}
+	switch sig[0] {
+	case 'y':
+		_, err = d.byte()
+	case 'n', 'q':
+		if err = d.align(2); err == nil {
+			if d.pos+2 > len(d.data) {
+				return ErrMessage
+			}
+			d.pos += 2
+		}
+	case 'b', 'i', 'u', 'h':
+		_, err = d.uint32()
+	case 'x', 't', 'd':
+		if err = d.align(8); err == nil {
+			if d.pos+8 > len(d.data) {
+				return ErrMessage
+			}
+			d.pos += 8
+		}
+	case 's', 'o':
+		_, err = d.string()
+	case 'g':
+		_, err = d.signature()
+	default:
+		return ErrMessage
+	}
+	return
+}
+
+// strings decodes a value of type ARRAY of STRING.
+func (d *decoder) strings() ([]string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	end := uint64(d.pos) + uint64(n)
+	if end > uint64(len(d.data)) {
+		return nil, ErrMessage
+	}
+	var v []string
+	for uint64(d.pos) < end {
+		var s string
+		if s, err = d.string(); err != nil {
+			return nil, err
+		}
+		v = append(v, s)
+	}
+	if uint64(d.pos) != end {
+		return nil, ErrMessage
+	}
+	return v, nil
+}
+
+// args returns a decoder over the body of m, or an error if its signature does not begin with sig.
+func (m *wireMessage) args(sig string) (*decoder, error) {
+	if len(m.signature) < len(sig) || m.signature[:len(sig)] != sig {
+		return nil, ErrMessage
+	}
+	return &decoder{order: m.order, data: m.body}, nil
+}
+
+// encoder encodes values to buf, with alignment relative to the start of buf.
+type encoder struct {
+	order binary.ByteOrder
+	buf   []byte
+}
+
+// align appends padding up to the next multiple of n.
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) byte(v byte) { e.buf = append(e.buf, v) }
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	e.order.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+func (e *encoder) string(v string) {
+	e.uint32(uint32(len(v)))
+	e.buf = append(append(e.buf, v...), 0)
+}
+
+func (e *encoder) signature(v string) {
+	e.buf = append(append(append(e.buf, byte(len(v))), v...), 0)
+}
+
+// arrayStart appends a placeholder array length, and padding for elements aligned to n.
+// It returns the offsets required by arrayEnd.
+func (e *encoder) arrayStart(n int) [2]int {
+	e.uint32(0)
+	lenOff := len(e.buf) - 4
+	e.align(n)
+	return [2]int{lenOff, len(e.buf)}
+}
+
+// arrayEnd sets the length of an array started by arrayStart.
+func (e *encoder) arrayEnd(off [2]int) {
+	e.order.PutUint32(e.buf[off[0]:], uint32(len(e.buf)-off[1]))
+}
+
+// encodeStrings returns the encoding of a message body holding an ARRAY of STRING.
+func encodeStrings(order binary.ByteOrder, v []string) []byte {
+	e := encoder{order: order}
+	a := e.arrayStart(4)
+	for _, s := range v {
+		e.string(s)
+	}
+	e.arrayEnd(a)
+	return e.buf
+}
+
+// encodeString returns the encoding of a message body holding a STRING.
+func encodeString(order binary.ByteOrder, v string) []byte {
+	e := encoder{order: order}
+	e.string(v)
+	return e.buf
+}
+
+// reader reads messages and file descriptors from a connection.
+type reader struct {
+	conn *net.UnixConn
+	// Data read from conn and not yet consumed.
+	buf []byte
+	// File descriptors received and not yet attached to a message.
+	fds []int
+}
+
+// fill reads from conn until buf holds at least n bytes.
+func (r *reader) fill(n int) error {
+	if cap(r.buf) < n {
+		buf := make([]byte, len(r.buf), max(n, 1<<12))
+		copy(buf, r.buf)
+		r.buf = buf
+	}
+	oob := make([]byte, syscall.CmsgSpace(fdsMax*4))
+	for len(r.buf) < n {
+		m, oobn, flags, _, err := r.conn.ReadMsgUnix(r.buf[len(r.buf):cap(r.buf)], oob)
+		if oobn > 0 {
+			rights, parseErr := parseRights(oob[:oobn])
+			r.fds = append(r.fds, rights...)
+			if parseErr != nil {
+				return parseErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if flags&syscall.MSG_CTRUNC != 0 {
+			return syscall.EMFILE
+		}
+		if m == 0 {
+			return io.EOF
+		}
+		r.buf = r.buf[:len(r.buf)+m]
+	}
+	return nil
+}
+
+// consume removes n bytes from the start of buf.
+func (r *reader) consume(n int) {
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+}
+
+// line reads a line of the authentication protocol, without its terminating CRLF.
+func (r *reader) line() (string, error) {
+	for i := 0; ; i++ {
+		if i+1 < len(r.buf) && r.buf[i] == '\r' && r.buf[i+1] == '\n' {
+			v := string(r.buf[:i])
+			r.consume(i + 2)
+			return v, nil
+		}
+		if i >= 1<<14 {
+			return "", ErrAuth
+		}
+		if i+1 >= len(r.buf) {
+			if err := r.fill(i + 2); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// next reads the next message, and attaches the file descriptors it refers to.
+func (r *reader) next() (*wireMessage, error) {
+	if err := r.fill(fixedHeaderSize); err != nil {
+		return nil, err
+	}
+	size, err := messageSize(r.buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.fill(size); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	var m *wireMessage
+	// the message is copied as the buffer is reused
+	if m, err = decodeMessage(append([]byte(nil), r.buf[:size]...)); err != nil {
+		return nil, err
+	}
+	r.consume(size)
+
+	if uint64(m.unixFDs) > uint64(len(r.fds)) {
+		return nil, ErrMessage
+	}
+	m.fds = r.fds[:m.unixFDs:m.unixFDs]
+	r.fds = r.fds[m.unixFDs:]
+	return m, nil
+}
+
+// close closes file descriptors not attached to a message.
+func (r *reader) close() {
+	closeFDs(r.fds)
+	r.fds = nil
+}
+
+// closeFDs closes every file descriptor in fds.
+func closeFDs(fds []int) {
+	for _, fd := range fds {
+		_ = syscall.Close(fd)
+	}
+}
+
+// parseRights returns file descriptors held in SCM_RIGHTS control messages.
+func parseRights(oob []byte) ([]int, error) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for i := range messages {
+		if messages[i].Header.Level != syscall.SOL_SOCKET || messages[i].Header.Type != syscall.SCM_RIGHTS {
+			continue
+		}
+		var rights []int
+		if rights, err = syscall.ParseUnixRights(&messages[i]); err != nil {
+			return fds, err
+		}
+		fds = append(fds, rights...)
+	}
+	return fds, nil
+}
+
+// writeMessage writes m and the file descriptors attached to it to conn, then closes them.
+func writeMessage(conn *net.UnixConn, m *wireMessage) error {
+	defer closeFDs(m.fds)
+	data := m.raw
+	if data == nil {
+		data = m.encode()
+	}
+	var rights []byte
+	if len(m.fds) > 0 {
+		rights = syscall.UnixRights(m.fds...)
+	}
+	_, _, err := conn.WriteMsgUnix(data, rights, nil)
+	return err
+}
@@ -0,0 +1,120 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWireMessage(t *testing.T) {
+	t.Parallel()
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			t.Parallel()
+
+			m := wireMessage{
+				order:       order,
+				kind:        typeError,
+				flags:       flagNoReplyExpected,
+				serial:      0xcafe,
+				path:        "/org/example",
+				iface:       "org.example.Interface",
+				member:      "Member",
+				errorName:   errAccessDenied,
+				replySerial: 0xbabe,
+				destination: ":1.1",
+				sender:      busName,
+				signature:   "as",
+				unixFDs:     2,
+				body:        encodeStrings(order, []string{"org.example", "", ":1.2"}),
+			}
+			data := m.encode()
+			got, err := decodeMessage(data)
+			if err != nil {
+				t.Fatalf("decodeMessage: error = %v", err)
+			}
+			m.raw = data
+			if !reflect.DeepEqual(got, &m) {
+				t.Fatalf("decodeMessage: %#v, want %#v", got, &m)
+			}
+
+			d, err := got.args("as")
+			if err != nil {
+				t.Fatalf("args: error = %v", err)
+			}
+			if v, err := d.strings(); err != nil || !reflect.DeepEqual(v, []string{"org.example", "", ":1.2"}) {
+				t.Errorf("strings: %q, error = %v", v, err)
+			}
+			if _, err = got.args("s"); !errors.Is(err, ErrMessage) {
+				t.Errorf("args: error = %v", err)
+			}
+
+			if _, err = decodeMessage(data[:len(data)-1]); !errors.Is(err, ErrMessage) {
+				t.Errorf("decodeMessage: error = %v", err)
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		t.Parallel()
+
+		valid := (&wireMessage{order: binary.LittleEndian, kind: typeSignal, serial: 1,
+			path: "/", iface: "org.example.Interface", member: "Member"}).encode()
+		testCases := []struct {
+			name   string
+			mangle func(data []byte)
+		}{
+			{"endianness", func(data []byte) { data[0] = 'x' }},
+			{"type", func(data []byte) { data[1] = 5 }},
+			{"version", func(data []byte) { data[3] = 2 }},
+			{"serial", func(data []byte) { binary.LittleEndian.PutUint32(data[8:], 0) }},
+			{"field signature", func(data []byte) { data[fixedHeaderSize+2] = 'u' }},
+			{"string terminator", func(data []byte) { data[fixedHeaderSize+8+1] = 'x' }},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				data := append([]byte(nil), valid...)
+				tc.mangle(data)
+				if _, err := decodeMessage(data); !errors.Is(err, ErrMessage) {
+					t.Errorf("decodeMessage: error = %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		t.Parallel()
+
+		e := encoder{order: binary.LittleEndian}
+		e.buf = []byte{'l', typeSignal, 0, 1}
+		e.uint32(0)
+		e.uint32(1)
+		fields := e.arrayStart(8)
+		e.byte(fieldMember)
+		e.signature("s")
+		e.string("Member")
+		e.align(8)
+		e.byte(0xfe)
+		e.signature("t")
+		e.align(8)
+		e.buf = append(e.buf, make([]byte, 8)...)
+		e.arrayEnd(fields)
+		e.align(8)
+
+		if m, err := decodeMessage(e.buf); err != nil {
+			t.Fatalf("decodeMessage: error = %v", err)
+		} else if m.member != "Member" {
+			t.Errorf("decodeMessage: member = %q", m.member)
+		}
+	})
+
+	if _, err := messageSize(make([]byte, fixedHeaderSize-1)); !errors.Is(err, ErrMessage) {
+		t.Errorf("messageSize: error = %v", err)
+	}
+	if _, err := messageSize([]byte{'l', 1, 0, 1, 0xff, 0xff, 0xff, 0xff, 1, 0, 0, 0, 0, 0, 0, 0}); !errors.Is(err, ErrMessage) {
+		t.Errorf("messageSize: error = %v", err)
+	}
+}
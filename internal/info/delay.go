@@ -0,0 +1,34 @@
+package info
+
+import (
+	"log"
+	"time"
+
+	"hakurei.app/hst"
+)
+
+// waitDelayMax is the upper bound of the container termination delay,
+// in a format accepted by [time.ParseDuration].
+//
+// This is set by the linker.
+var waitDelayMax string
+
+// WaitDelayMax returns the upper bound of the container termination delay.
+// It is either the value of the constant [hst.WaitDelayMax] or, when set by the linker, an admin-configured value.
+func WaitDelayMax() time.Duration { return mustParseDelay(log.Fatal, waitDelayMax, hst.WaitDelayMax) }
+
+// mustParseDelay parses a non-negative duration string, returning fallback if s is empty and calling fatal if parsing fails.
+func mustParseDelay(fatal func(v ...any), s string, fallback time.Duration) time.Duration {
+	if s == "" {
+		return fallback
+	}
+	if d, err := time.ParseDuration(s); err != nil {
+		fatal("invalid termination delay bound: " + err.Error() + ", this program is compiled incorrectly")
+		return fallback // unreachable
+	} else if d < 0 {
+		fatal("negative termination delay bound, this program is compiled incorrectly")
+		return fallback // unreachable
+	} else {
+		return d
+	}
+}
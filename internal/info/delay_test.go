@@ -0,0 +1,45 @@
+package info
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMustParseDelay(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		s         string
+		want      time.Duration
+		wantFatal string
+	}{
+		{"zero", "", 0xbad, ""},
+		{"invalid", "forever", 0xbad, `invalid termination delay bound: time: invalid duration "forever", this program is compiled incorrectly`},
+		{"negative", "-1s", 0xbad, "negative termination delay bound, this program is compiled incorrectly"},
+		{"success", "2m", 2 * time.Minute, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			fatal := func(v ...any) { t.Fatal(append([]any{"invalid call to fatal:"}, v...)...) }
+			if tc.wantFatal != "" {
+				fatal = func(v ...any) {
+					if len(v) != 1 {
+						t.Errorf("mustParseDelay: fatal %#v", v)
+					} else if gotFatal, ok := v[0].(string); !ok {
+						t.Errorf("mustParseDelay: fatal = %#v", v[0])
+					} else if gotFatal != tc.wantFatal {
+						t.Errorf("mustParseDelay: fatal = %q, want %q", gotFatal, tc.wantFatal)
+					}
+
+					// do not simulate exit
+				}
+			}
+
+			if got := mustParseDelay(fatal, tc.s, 0xbad); got != tc.want {
+				t.Errorf("mustParseDelay: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"hakurei.app/container/check"
 	"hakurei.app/container/seccomp"
 	"hakurei.app/container/std"
+	"hakurei.app/hst"
 	"hakurei.app/internal/dbus"
 	"hakurei.app/internal/info"
+	"hakurei.app/ldd"
 	"hakurei.app/message"
 )
 
@@ -43,6 +45,8 @@ type syscallDispatcher interface {
 	getgid() int
 	// lookupEnv provides [os.LookupEnv].
 	lookupEnv(key string) (string, bool)
+	// environ provides [os.Environ].
+	environ() []string
 	// pipe provides os.Pipe.
 	pipe() (r, w *os.File, err error)
 	// stat provides [os.Stat].
@@ -65,6 +69,9 @@ type syscallDispatcher interface {
 	// cmdOutput provides the Output method of [exec.Cmd].
 	cmdOutput(cmd *exec.Cmd) ([]byte, error)
 
+	// lddResolve provides [ldd.Resolve].
+	lddResolve(msg message.Msg, pathname *check.Absolute) ([]*ldd.Entry, error)
+
 	// notifyContext provides [signal.NotifyContext].
 	notifyContext(parent context.Context, signals ...os.Signal) (ctx context.Context, stop context.CancelFunc)
 
@@ -97,6 +104,12 @@ type syscallDispatcher interface {
 
 	// setupContSignal provides setupContSignal.
 	setupContSignal(pid int) (io.ReadCloser, func(), error)
+	// setupPty provides setupPty.
+	setupPty(z *container.Container) (func(), error)
+	// sendReport provides sendReport.
+	sendReport(fd uintptr, report *hst.ExitReport) error
+	// requestIDMap provides requestIDMap.
+	requestIDMap(fd uintptr, pid int) error
 
 	// getMsg returns the [message.Msg] held by syscallDispatcher.
 	getMsg() message.Msg
@@ -116,6 +129,7 @@ func (direct) getpid() int                                { return os.Getpid() }
 func (direct) getuid() int                                { return os.Getuid() }
 func (direct) getgid() int                                { return os.Getgid() }
 func (direct) lookupEnv(key string) (string, bool)        { return os.LookupEnv(key) }
+func (direct) environ() []string                          { return os.Environ() }
 func (direct) pipe() (r, w *os.File, err error)           { return os.Pipe() }
 func (direct) stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
 func (direct) open(name string) (osFile, error)           { return os.Open(name) }
@@ -136,6 +150,10 @@ func (direct) lookupGroupId(name string) (gid string, err error) {
 
 func (direct) cmdOutput(cmd *exec.Cmd) ([]byte, error) { return cmd.Output() }
 
+func (direct) lddResolve(msg message.Msg, pathname *check.Absolute) ([]*ldd.Entry, error) {
+	return ldd.Resolve(context.Background(), msg, pathname)
+}
+
 func (direct) notifyContext(parent context.Context, signals ...os.Signal) (ctx context.Context, stop context.CancelFunc) {
 	return signal.NotifyContext(parent, signals...)
 }
@@ -161,6 +179,9 @@ func (direct) mustHsuPath() *check.Absolute { return info.MustHsuPath() }
 func (direct) dbusAddress() (session, system string) { return dbus.Address() }
 
 func (direct) setupContSignal(pid int) (io.ReadCloser, func(), error) { return setupContSignal(pid) }
+func (k direct) setupPty(z *container.Container) (func(), error)      { return setupPty(k.msg, z) }
+func (direct) sendReport(fd uintptr, report *hst.ExitReport) error    { return sendReport(fd, report) }
+func (direct) requestIDMap(fd uintptr, pid int) error                 { return requestIDMap(fd, pid) }
 
 func (k direct) getMsg() message.Msg            { return k.msg }
 func (k direct) fatal(v ...any)                 { k.msg.GetLogger().Fatal(v...) }
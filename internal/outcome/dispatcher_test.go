@@ -14,6 +14,7 @@ import (
 	"reflect"
 	"slices"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 	"unsafe"
@@ -25,6 +26,7 @@ import (
 	"hakurei.app/container/stub"
 	"hakurei.app/hst"
 	"hakurei.app/internal/system"
+	"hakurei.app/ldd"
 	"hakurei.app/message"
 )
 
@@ -336,6 +338,11 @@ func (k *kstub) getppid() int { k.Helper(); return k.Expects("getppid").Ret.(int
 func (k *kstub) getpid() int  { k.Helper(); return k.Expects("getpid").Ret.(int) }
 func (k *kstub) getuid() int  { k.Helper(); return k.Expects("getuid").Ret.(int) }
 func (k *kstub) getgid() int  { k.Helper(); return k.Expects("getgid").Ret.(int) }
+func (k *kstub) environ() []string {
+	k.Helper()
+	return k.Expects("environ").Ret.([]string)
+}
+
 func (k *kstub) lookupEnv(key string) (string, bool) {
 	k.Helper()
 	expect := k.Expects("lookupEnv")
@@ -387,6 +394,13 @@ func (k *kstub) evalSymlinks(path string) (string, error) {
 		stub.CheckArg(k.Stub, "path", path, 0))
 }
 
+func (k *kstub) lddResolve(_ message.Msg, pathname *check.Absolute) ([]*ldd.Entry, error) {
+	k.Helper()
+	expect := k.Expects("lddResolve")
+	return expect.Ret.([]*ldd.Entry), expect.Error(
+		stub.CheckArgReflect(k.Stub, "pathname", pathname, 0))
+}
+
 func (k *kstub) prctl(op, arg2, arg3 uintptr) error {
 	k.Helper()
 	return k.Expects("prctl").Error(
@@ -420,6 +434,28 @@ func (k *kstub) expectCheckContainer(expect *stub.Call, z *container.Container)
 	return expect.Err
 }
 
+func (k *kstub) setupPty(z *container.Container) (func(), error) {
+	k.Helper()
+	return func() {
+		k.Helper()
+		k.Expects("restoreTerminal")
+	}, k.expectCheckContainer(k.Expects("setupPty"), z)
+}
+
+func (k *kstub) sendReport(fd uintptr, report *hst.ExitReport) error {
+	k.Helper()
+	return k.Expects("sendReport").Error(
+		stub.CheckArg(k.Stub, "fd", fd, 0),
+		stub.CheckArgReflect(k.Stub, "report", report, 1))
+}
+
+func (k *kstub) requestIDMap(fd uintptr, pid int) error {
+	k.Helper()
+	return k.Expects("requestIDMap").Error(
+		stub.CheckArg(k.Stub, "fd", fd, 0),
+		stub.CheckArg(k.Stub, "pid", pid, 1))
+}
+
 func (k *kstub) containerStart(z *container.Container) error {
 	k.Helper()
 	if k.unblockShimReader != nil {
@@ -620,14 +656,20 @@ type stubFi struct {
 	size  int64
 	mode  os.FileMode
 	isDir bool
+	sys   *syscall.Stat_t
 }
 
 func (fi *stubFi) Name() string       { panic("unreachable") }
 func (fi *stubFi) ModTime() time.Time { panic("unreachable") }
-func (fi *stubFi) Sys() any           { panic("unreachable") }
-func (fi *stubFi) Size() int64        { return fi.size }
-func (fi *stubFi) Mode() os.FileMode  { return fi.mode }
-func (fi *stubFi) IsDir() bool        { return fi.isDir }
+func (fi *stubFi) Sys() any {
+	if fi.sys == nil {
+		panic("unreachable")
+	}
+	return fi.sys
+}
+func (fi *stubFi) Size() int64       { return fi.size }
+func (fi *stubFi) Mode() os.FileMode { return fi.mode }
+func (fi *stubFi) IsDir() bool       { return fi.isDir }
 
 // stubDir returns a slice of [os.DirEntry] with only their Name method implemented.
 func stubDir(names ...string) []os.DirEntry {
@@ -663,6 +705,15 @@ func mustMarshal(v any) string {
 // m is a shortcut for [check.MustAbs].
 func m(pathname string) *check.Absolute { return check.MustAbs(pathname) }
 
+// ms calls [check.MustAbs] for every pathname.
+func ms(pathnames ...string) []*check.Absolute {
+	as := make([]*check.Absolute, len(pathnames))
+	for i, pathname := range pathnames {
+		as[i] = check.MustAbs(pathname)
+	}
+	return as
+}
+
 // f returns [hst.FilesystemConfig] wrapped in its [json] adapter.
 func f(c hst.FilesystemConfig) hst.FilesystemConfigJSON {
 	return hst.FilesystemConfigJSON{FilesystemConfig: c}
@@ -690,26 +741,33 @@ func (panicMsgContext) Value(any) any               { panic("unreachable") }
 // This type is meant to be embedded in partial syscallDispatcher implementations.
 type panicDispatcher struct{}
 
-func (panicDispatcher) new(func(k syscallDispatcher, msg message.Msg))      { panic("unreachable") }
-func (panicDispatcher) getppid() int                                        { panic("unreachable") }
-func (panicDispatcher) getpid() int                                         { panic("unreachable") }
-func (panicDispatcher) getuid() int                                         { panic("unreachable") }
-func (panicDispatcher) getgid() int                                         { panic("unreachable") }
-func (panicDispatcher) lookupEnv(string) (string, bool)                     { panic("unreachable") }
-func (panicDispatcher) pipe() (*os.File, *os.File, error)                   { panic("unreachable") }
-func (panicDispatcher) stat(string) (os.FileInfo, error)                    { panic("unreachable") }
-func (panicDispatcher) open(string) (osFile, error)                         { panic("unreachable") }
-func (panicDispatcher) readdir(string) ([]os.DirEntry, error)               { panic("unreachable") }
-func (panicDispatcher) tempdir() string                                     { panic("unreachable") }
-func (panicDispatcher) exit(int)                                            { panic("unreachable") }
-func (panicDispatcher) evalSymlinks(string) (string, error)                 { panic("unreachable") }
-func (panicDispatcher) prctl(uintptr, uintptr, uintptr) error               { panic("unreachable") }
-func (panicDispatcher) lookupGroupId(string) (string, error)                { panic("unreachable") }
-func (panicDispatcher) cmdOutput(*exec.Cmd) ([]byte, error)                 { panic("unreachable") }
+func (panicDispatcher) new(func(k syscallDispatcher, msg message.Msg)) { panic("unreachable") }
+func (panicDispatcher) getppid() int                                   { panic("unreachable") }
+func (panicDispatcher) getpid() int                                    { panic("unreachable") }
+func (panicDispatcher) getuid() int                                    { panic("unreachable") }
+func (panicDispatcher) getgid() int                                    { panic("unreachable") }
+func (panicDispatcher) lookupEnv(string) (string, bool)                { panic("unreachable") }
+func (panicDispatcher) environ() []string                              { panic("unreachable") }
+func (panicDispatcher) pipe() (*os.File, *os.File, error)              { panic("unreachable") }
+func (panicDispatcher) stat(string) (os.FileInfo, error)               { panic("unreachable") }
+func (panicDispatcher) open(string) (osFile, error)                    { panic("unreachable") }
+func (panicDispatcher) readdir(string) ([]os.DirEntry, error)          { panic("unreachable") }
+func (panicDispatcher) tempdir() string                                { panic("unreachable") }
+func (panicDispatcher) exit(int)                                       { panic("unreachable") }
+func (panicDispatcher) evalSymlinks(string) (string, error)            { panic("unreachable") }
+func (panicDispatcher) prctl(uintptr, uintptr, uintptr) error          { panic("unreachable") }
+func (panicDispatcher) lookupGroupId(string) (string, error)           { panic("unreachable") }
+func (panicDispatcher) cmdOutput(*exec.Cmd) ([]byte, error)            { panic("unreachable") }
+func (panicDispatcher) lddResolve(message.Msg, *check.Absolute) ([]*ldd.Entry, error) {
+	panic("unreachable")
+}
 func (panicDispatcher) overflowUid(message.Msg) int                         { panic("unreachable") }
 func (panicDispatcher) overflowGid(message.Msg) int                         { panic("unreachable") }
 func (panicDispatcher) setDumpable(uintptr) error                           { panic("unreachable") }
 func (panicDispatcher) receive(string, any, *uintptr) (func() error, error) { panic("unreachable") }
+func (panicDispatcher) setupPty(*container.Container) (func(), error)       { panic("unreachable") }
+func (panicDispatcher) sendReport(uintptr, *hst.ExitReport) error           { panic("unreachable") }
+func (panicDispatcher) requestIDMap(uintptr, int) error                     { panic("unreachable") }
 func (panicDispatcher) containerStart(*container.Container) error           { panic("unreachable") }
 func (panicDispatcher) containerServe(*container.Container) error           { panic("unreachable") }
 func (panicDispatcher) containerWait(*container.Container) error            { panic("unreachable") }
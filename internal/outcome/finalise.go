@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 
@@ -27,6 +28,8 @@ type outcome struct {
 	state *outcomeState
 	// Retained for registering current instance.
 	config *hst.Config
+	// Exit report is written here in JSON if not nil.
+	reportOutput io.Writer
 
 	ctx context.Context
 	syscallDispatcher
@@ -67,6 +70,9 @@ func (k *outcome) finalise(ctx context.Context, msg message.Msg, id *hst.ID, con
 	if err := s.populateLocal(k.syscallDispatcher, msg); err != nil {
 		return err
 	}
+	if err := s.mapGroups(msg, config.Groups, supp); err != nil {
+		return err
+	}
 
 	sys := system.New(k.ctx, msg, s.uid.unwrap())
 	if err := s.newSys(config, sys).toSystem(); err != nil {
@@ -0,0 +1,119 @@
+package outcome
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+// idMapTimeout is the time to wait for the shim to request id mapping after receiving its payload.
+const idMapTimeout = 5 * time.Second
+
+// newIDMapSocket returns both ends of the socket the shim uses to request id mapping.
+// The priv side end is non-blocking and the shim end is inherited by the shim through hsu.
+func newIDMapSocket() (priv, shim *os.File, err error) {
+	var fds [2]int
+	if fds, err = syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0); err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+	if err = syscall.SetNonblock(fds[0], true); err != nil {
+		_, _ = syscall.Close(fds[0]), syscall.Close(fds[1])
+		return nil, nil, os.NewSyscallError("setnonblock", err)
+	}
+	return os.NewFile(uintptr(fds[0]), "idmap"), os.NewFile(uintptr(fds[1]), "idmap"), nil
+}
+
+// requestIDMap sends the pid of container init through the inherited id map socket, and waits for the
+// priv side to write its uid and gid maps. The socket is closed before requestIDMap returns.
+func requestIDMap(fd uintptr, pid int) error {
+	f := os.NewFile(fd, "idmap")
+	if f == nil {
+		return syscall.EBADF
+	}
+	defer func() { _ = f.Close() }()
+
+	if pid < 1 {
+		return syscall.EINVAL
+	}
+	if err := binary.Write(f, binary.NativeEndian, uint32(pid)); err != nil {
+		return err
+	}
+
+	var ack [1]byte
+	if _, err := io.ReadFull(f, ack[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("request refused by priv side")
+		}
+		return err
+	}
+	return nil
+}
+
+// serveIDMap receives the pid of container init from the shim through the id map socket, and
+// calls hsu to write its uid and gid maps, mapping the subordinate id range described by sub if
+// not nil, and groups to their own gid. If neither is requested, hsu is not called and the maps
+// are left to container init. The socket is closed before serveIDMap returns, and only
+// acknowledged if the maps are written. The pid of container init is returned on success.
+// A non-nil error returned by serveIDMap is of type [hst.AppError].
+func serveIDMap(ctx context.Context, msg message.Msg,
+	hsuPath *check.Absolute,
+	conn *os.File,
+	identity, mapuid, mapgid int,
+	sub *hst.SubordinateConfig,
+	groups []mappedGroup,
+) (int, error) {
+	defer func() { _ = conn.Close() }()
+	if err := conn.SetDeadline(time.Now().Add(idMapTimeout)); err != nil {
+		msg.Verbose(err.Error())
+	}
+
+	var pid uint32
+	if err := binary.Read(conn, binary.NativeEndian, &pid); err != nil {
+		return 0, &hst.AppError{Step: "receive id map request", Err: err}
+	}
+
+	if sub == nil && len(groups) == 0 {
+		if _, err := conn.Write([]byte{0}); err != nil {
+			return 0, &hst.AppError{Step: "acknowledge id map request", Err: err}
+		}
+		return int(pid), nil
+	}
+
+	// interpreted by hsu
+	request := []string{strconv.Itoa(int(pid)), strconv.Itoa(mapuid), strconv.Itoa(mapgid), "0", "0"}
+	if sub != nil {
+		msg.Verbosef("mapping %d subordinate ids starting at %d for process %d", sub.Count, sub.Start, pid)
+		request[3], request[4] = strconv.Itoa(sub.Start), strconv.Itoa(sub.Count)
+	}
+	for _, g := range groups {
+		msg.Verbosef("mapping group %q for process %d", g.Name, pid)
+		request = append(request, strconv.Itoa(g.Gid))
+	}
+
+	cmd := exec.CommandContext(ctx, hsuPath.String())
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	cmd.Env = []string{
+		// interpreted by hsu
+		"HAKUREI_IDENTITY=" + strconv.Itoa(identity),
+		"HAKUREI_IDMAP=" + strings.Join(request, " "),
+	}
+	if err := cmd.Run(); err != nil {
+		return 0, &hst.AppError{Step: "write id map", Err: err}
+	}
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return 0, &hst.AppError{Step: "acknowledge id map request", Err: err}
+	}
+	return int(pid), nil
+}
@@ -0,0 +1,135 @@
+package outcome
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+func TestRequestIDMap(t *testing.T) {
+	t.Parallel()
+
+	newTestSocket := func(t *testing.T) (*os.File, uintptr) {
+		priv, shim, err := newIDMapSocket()
+		if err != nil {
+			t.Fatalf("newIDMapSocket: error = %v", err)
+		}
+		t.Cleanup(func() { _ = priv.Close() })
+
+		// requestIDMap takes ownership of the file descriptor
+		var fd int
+		if fd, err = syscall.Dup(int(shim.Fd())); err != nil {
+			t.Fatalf("Dup: error = %v", err)
+		}
+		if err = shim.Close(); err != nil {
+			t.Fatalf("Close: error = %v", err)
+		}
+		return priv, uintptr(fd)
+	}
+
+	receivePid := func(t *testing.T, priv *os.File) {
+		var pid uint32
+		if err := binary.Read(priv, binary.NativeEndian, &pid); err != nil {
+			t.Fatalf("Read: error = %v", err)
+		}
+		if pid != 0xbad {
+			t.Errorf("Read: pid = %d, want %d", pid, 0xbad)
+		}
+	}
+
+	t.Run("invalid pid", func(t *testing.T) {
+		t.Parallel()
+		_, fd := newTestSocket(t)
+		if err := requestIDMap(fd, -1); !reflect.DeepEqual(err, syscall.EINVAL) {
+			t.Errorf("requestIDMap: error = %v", err)
+		}
+	})
+
+	t.Run("acknowledged", func(t *testing.T) {
+		t.Parallel()
+		priv, fd := newTestSocket(t)
+
+		done := make(chan error, 1)
+		go func() { done <- requestIDMap(fd, 0xbad) }()
+		receivePid(t, priv)
+		if _, err := priv.Write([]byte{0}); err != nil {
+			t.Fatalf("Write: error = %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("requestIDMap: error = %v", err)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		t.Parallel()
+		priv, fd := newTestSocket(t)
+
+		done := make(chan error, 1)
+		go func() { done <- requestIDMap(fd, 0xbad) }()
+		receivePid(t, priv)
+		if err := priv.Close(); err != nil {
+			t.Fatalf("Close: error = %v", err)
+		}
+		if err := <-done; err == nil || err.Error() != "request refused by priv side" {
+			t.Errorf("requestIDMap: error = %v", err)
+		}
+	})
+}
+
+func TestServeIDMap(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unmapped", func(t *testing.T) {
+		t.Parallel()
+		priv, shim, err := newIDMapSocket()
+		if err != nil {
+			t.Fatalf("newIDMapSocket: error = %v", err)
+		}
+		defer func() { _ = shim.Close() }()
+
+		done := make(chan error, 1)
+		go func() {
+			if err := binary.Write(shim, binary.NativeEndian, uint32(0xbad)); err != nil {
+				done <- err
+				return
+			}
+			var ack [1]byte
+			_, err := shim.Read(ack[:])
+			done <- err
+		}()
+
+		if pid, err := serveIDMap(context.Background(), message.New(nil), nil, priv,
+			0, 1000, 1000, nil, nil); err != nil {
+			t.Fatalf("serveIDMap: error = %v", err)
+		} else if pid != 0xbad {
+			t.Errorf("serveIDMap: pid = %d, want %d", pid, 0xbad)
+		}
+		if err = <-done; err != nil {
+			t.Errorf("Read: error = %v", err)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+		priv, shim, err := newIDMapSocket()
+		if err != nil {
+			t.Fatalf("newIDMapSocket: error = %v", err)
+		}
+		if err = shim.Close(); err != nil {
+			t.Fatalf("Close: error = %v", err)
+		}
+
+		var appError *hst.AppError
+		if _, err = serveIDMap(context.Background(), message.New(nil), nil, priv,
+			0, 1000, 1000, nil, nil); !errors.As(err, &appError) || appError.Step != "receive id map request" {
+			t.Errorf("serveIDMap: error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package outcome
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+const (
+	// interpreterLineMax is the number of bytes considered by the kernel when parsing an interpreter line.
+	interpreterLineMax = 256
+	// interpreterDepthMax is the number of nested interpreters the kernel follows before giving up.
+	interpreterDepthMax = 5
+
+	// minimalDefaultPath is the search path used by the C library if PATH is unset in the container.
+	minimalDefaultPath = "/bin:/usr/bin"
+)
+
+// readInterpreter returns the interpreter named by the interpreter line of the file at pathname
+// and its optional argument, or nil if it does not start with one.
+func readInterpreter(k syscallDispatcher, pathname *check.Absolute) (interpreter *check.Absolute, arg string, err error) {
+	var f osFile
+	if f, err = k.open(pathname.String()); err != nil {
+		return
+	}
+	buf := make([]byte, interpreterLineMax)
+	n, err := io.ReadFull(f, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+
+	line, ok := bytes.CutPrefix(buf[:n], []byte("#!"))
+	if !ok {
+		return
+	}
+	if i := bytes.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	// the remainder of the line is passed as a single argument
+	fields := bytes.SplitN(bytes.TrimSpace(line), []byte{' '}, 2)
+	if len(fields[0]) == 0 {
+		return nil, "", syscall.ENOEXEC
+	}
+	if len(fields) == 2 {
+		arg = string(bytes.TrimSpace(fields[1]))
+	}
+	interpreter, err = check.NewAbs(string(fields[0]))
+	return
+}
+
+// envProgram returns the program started by env(1) given arg as its only argument.
+// Options other than a leading split string are not supported.
+func envProgram(arg string) (string, error) {
+	fields := strings.Fields(arg)
+	if len(fields) > 0 {
+		if fields[0] == "-S" || fields[0] == "--split-string" {
+			fields = fields[1:]
+		} else if s, ok := strings.CutPrefix(fields[0], "-S"); ok {
+			fields[0] = s
+		}
+	}
+	// variable assignments precede the program
+	for len(fields) > 0 && strings.IndexByte(fields[0], '=') > 0 {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "-") {
+		return "", syscall.ENOEXEC
+	}
+	return fields[0], nil
+}
+
+// lookPathMinimal returns the pathname of the executable file name in the colon-separated
+// directories of pathList. Relative directories are not searched.
+func lookPathMinimal(k syscallDispatcher, name, pathList string) (*check.Absolute, error) {
+	if strings.IndexByte(name, '/') != -1 {
+		return check.NewAbs(name)
+	}
+	for _, dir := range strings.Split(pathList, ":") {
+		a, err := check.NewAbs(dir)
+		if err != nil {
+			continue
+		}
+		a = a.Append(name)
+		if fi, err := k.stat(a.String()); err == nil && fi.Mode().IsRegular() && fi.Mode()&0o111 != 0 {
+			return a, nil
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// resolveMinimal returns the deduplicated shared library dependencies and interpreters of every program of m.
+// A program started by env(1) as an interpreter is looked up in pathList, the value of PATH in the container.
+// A non-nil error returned by resolveMinimal is of type [hst.AppError].
+func resolveMinimal(msg message.Msg, k syscallDispatcher, m *hst.FSMinimal, pathList string) ([]*check.Absolute, error) {
+	var deps []*check.Absolute
+
+	var resolve func(pathname *check.Absolute, depth int) error
+	resolve = func(pathname *check.Absolute, depth int) error {
+		if interpreter, arg, err := readInterpreter(k, pathname); err != nil {
+			return &hst.AppError{Step: "read interpreter line of " + strconv.Quote(pathname.String()), Err: err}
+		} else if interpreter != nil {
+			if depth >= interpreterDepthMax {
+				return &hst.AppError{Step: "resolve interpreter of " + strconv.Quote(pathname.String()), Err: syscall.ELOOP}
+			}
+			msg.Verbosef("program %q is interpreted by %q", pathname, interpreter)
+			deps = append(deps, interpreter)
+
+			if path.Base(interpreter.String()) == "env" && arg != "" {
+				var program *check.Absolute
+				if name, err := envProgram(arg); err != nil {
+					return &hst.AppError{Step: "parse interpreter line of " + strconv.Quote(pathname.String()), Err: err}
+				} else if program, err = lookPathMinimal(k, name, pathList); err != nil {
+					return &hst.AppError{Step: "look up " + strconv.Quote(name) + " for " + strconv.Quote(pathname.String()), Err: err}
+				}
+				msg.Verbosef("program %q is interpreted by %q", pathname, program)
+				deps = append(deps, program)
+				if err = resolve(program, depth+1); err != nil {
+					return err
+				}
+			}
+			return resolve(interpreter, depth+1)
+		}
+
+		entries, err := k.lddResolve(msg, pathname)
+		if err != nil {
+			return &hst.AppError{Step: "resolve dependencies of " + strconv.Quote(pathname.String()), Err: err}
+		}
+		for _, entry := range entries {
+			if entry.Path != nil {
+				deps = append(deps, entry.Path)
+			}
+			// the dynamic linker is named by its absolute pathname
+			if a, err := check.NewAbs(entry.Name); err == nil {
+				deps = append(deps, a)
+			}
+		}
+		return nil
+	}
+
+	for _, pathname := range m.Programs {
+		if err := resolve(pathname, 0); err != nil {
+			return nil, err
+		}
+	}
+	check.SortAbs(deps)
+	return check.CompactAbs(deps), nil
+}
@@ -0,0 +1,135 @@
+package outcome
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/ldd"
+)
+
+func TestResolveMinimal(t *testing.T) {
+	t.Parallel()
+
+	newResolve := func(programs ...string) func(k *kstub) error {
+		return func(k *kstub) error {
+			deps, err := resolveMinimal(k, k, &hst.FSMinimal{Programs: ms(programs...)}, "relative:/usr/bin:/bin")
+			k.Verbose(deps)
+			return err
+		}
+	}
+	elf := func() osFile { return &stubOsFile{Reader: strings.NewReader("\x7fELF\x02\x01\x01\x00")} }
+	script := func(line string) osFile { return &stubOsFile{Reader: strings.NewReader(line + "\nexec true\n")} }
+	musl := []*ldd.Entry{
+		{Name: "/lib/ld-musl-x86_64.so.1", Location: 0x7ff71c0a4000},
+		{Name: "libc.musl-x86_64.so.1", Path: m("/lib/ld-musl-x86_64.so.1"), Location: 0x7ff71c0a4000},
+	}
+
+	checkSimple(t, "resolveMinimal", []simpleTestCase{
+		{"open", newResolve("/usr/bin/curl"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/curl"}, (*stubOsFile)(nil), stub.UniqueError(3)),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `read interpreter line of "/usr/bin/curl"`, Err: stub.UniqueError(3)}},
+
+		{"read", newResolve("/usr/bin/curl"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/curl"}, &stubOsFile{Reader: errorReader{stub.UniqueError(2)}}, nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `read interpreter line of "/usr/bin/curl"`, Err: stub.UniqueError(2)}},
+
+		{"close", newResolve("/usr/bin/curl"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/curl"}, &stubOsFile{closeErr: stub.UniqueError(1), Reader: bytes.NewReader(nil)}, nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `read interpreter line of "/usr/bin/curl"`, Err: stub.UniqueError(1)}},
+
+		{"interpreter empty", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!  "), nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `read interpreter line of "/usr/bin/wrapper"`, Err: syscall.ENOEXEC}},
+
+		{"interpreter relative", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!sh"), nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `read interpreter line of "/usr/bin/wrapper"`, Err: &check.AbsoluteError{Pathname: "sh"}}},
+
+		{"interpreter loop", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/wrapper")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/wrapper")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/wrapper")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/wrapper")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/wrapper")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/wrapper"), nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `resolve interpreter of "/usr/bin/wrapper"`, Err: syscall.ELOOP}},
+
+		{"env option", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/env -i python3"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/env")}}, nil, nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `parse interpreter line of "/usr/bin/wrapper"`, Err: syscall.ENOEXEC}},
+
+		{"env not found", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/env python3"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/env")}}, nil, nil),
+			call("stat", stub.ExpectArgs{"/usr/bin/python3"}, (*stubFi)(nil), syscall.ENOENT),
+			call("stat", stub.ExpectArgs{"/bin/python3"}, &stubFi{mode: fs.ModeDir | 0755, isDir: true}, nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `look up "python3" for "/usr/bin/wrapper"`, Err: syscall.ENOENT}},
+
+		{"env relative", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/env bin/python3"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/env")}}, nil, nil),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `look up "bin/python3" for "/usr/bin/wrapper"`, Err: &check.AbsoluteError{Pathname: "bin/python3"}}},
+
+		{"env", newResolve("/usr/bin/wrapper"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/usr/bin/env -S PYTHONUNBUFFERED=1 python3 -u"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/usr/bin/env")}}, nil, nil),
+			call("stat", stub.ExpectArgs{"/usr/bin/python3"}, &stubFi{mode: 0644}, nil),
+			call("stat", stub.ExpectArgs{"/bin/python3"}, &stubFi{mode: 0755}, nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/bin/python3")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/bin/python3"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/bin/python3")}, musl, nil),
+			call("open", stub.ExpectArgs{"/usr/bin/env"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/usr/bin/env")}, musl, nil),
+			call("verbose", stub.ExpectArgs{[]any{ms(
+				"/bin/python3",
+				"/lib/ld-musl-x86_64.so.1",
+				"/usr/bin/env",
+			)}}, nil, nil),
+		}}, nil},
+
+		{"ldd", newResolve("/usr/bin/curl"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/curl"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/usr/bin/curl")}, ([]*ldd.Entry)(nil), stub.UniqueError(0)),
+			call("verbose", stub.ExpectArgs{[]any{[]*check.Absolute(nil)}}, nil, nil),
+		}}, &hst.AppError{Step: `resolve dependencies of "/usr/bin/curl"`, Err: stub.UniqueError(0)}},
+
+		{"success", newResolve("/usr/bin/curl", "/usr/bin/wrapper", "/bin/busybox"), stub.Expect{Calls: []stub.Call{
+			call("open", stub.ExpectArgs{"/usr/bin/curl"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/usr/bin/curl")}, append([]*ldd.Entry{
+				{Name: "libcurl.so.4", Path: m("/usr/lib/libcurl.so.4"), Location: 0x7ff71bfd2000},
+			}, musl...), nil),
+			call("open", stub.ExpectArgs{"/usr/bin/wrapper"}, script("#!/bin/sh -e"), nil),
+			call("verbosef", stub.ExpectArgs{"program %q is interpreted by %q", []any{m("/usr/bin/wrapper"), m("/bin/sh")}}, nil, nil),
+			call("open", stub.ExpectArgs{"/bin/sh"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/bin/sh")}, musl, nil),
+			call("open", stub.ExpectArgs{"/bin/busybox"}, elf(), nil),
+			call("lddResolve", stub.ExpectArgs{m("/bin/busybox")}, ([]*ldd.Entry)(nil), nil),
+			call("verbose", stub.ExpectArgs{[]any{ms(
+				"/bin/sh",
+				"/lib/ld-musl-x86_64.so.1",
+				"/usr/lib/libcurl.so.4",
+			)}}, nil, nil),
+		}}, nil},
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"hakurei.app/hst"
 	"hakurei.app/internal/acl"
 	"hakurei.app/internal/env"
+	"hakurei.app/internal/info"
 	"hakurei.app/internal/system"
 	"hakurei.app/message"
 )
@@ -55,6 +56,8 @@ type outcomeState struct {
 
 	// Mapped credentials within container user namespace.
 	Mapuid, Mapgid int
+	// Supplementary groups mapped in the container user namespace. Populated during finalise.
+	Groups []mappedGroup
 	// Copied from their respective exported values.
 	mapuid, mapgid *stringPair[int]
 
@@ -89,18 +92,24 @@ func newOutcomeState(k syscallDispatcher, msg message.Msg, id *hst.ID, config *h
 	}
 
 	// enforce bounds and default early
-	if s.Container.WaitDelay < 0 {
+	waitDelayMax := info.WaitDelayMax()
+	if len(s.Container.Termination) > 0 {
+		// sequences exceeding the bound are rejected by spParamsOp
+		s.Shim.WaitDelay = min(hst.TerminationDelay(s.Container.Termination), waitDelayMax)
+	} else if s.Container.WaitDelay < 0 {
 		s.Shim.WaitDelay = 0
 	} else if s.Container.WaitDelay == 0 {
-		s.Shim.WaitDelay = hst.WaitDelayDefault
-	} else if s.Container.WaitDelay > hst.WaitDelayMax {
-		s.Shim.WaitDelay = hst.WaitDelayMax
+		s.Shim.WaitDelay = min(hst.WaitDelayDefault, waitDelayMax)
+	} else if s.Container.WaitDelay > waitDelayMax {
+		s.Shim.WaitDelay = waitDelayMax
 	} else {
 		s.Shim.WaitDelay = s.Container.WaitDelay
 	}
 
 	if s.Container.Flags&hst.FMapRealUID != 0 {
 		s.Mapuid, s.Mapgid = k.getuid(), k.getgid()
+	} else if s.Container.Flags&hst.FFakeRoot != 0 {
+		s.Mapuid, s.Mapgid = 0, 0
 	} else {
 		s.Mapuid, s.Mapgid = k.overflowUid(msg), k.overflowGid(msg)
 	}
@@ -108,6 +117,24 @@ func newOutcomeState(k syscallDispatcher, msg message.Msg, id *hst.ID, config *h
 	return &s
 }
 
+// mapGroups populates Groups from the names and corresponding numerical group ids of supplementary groups.
+// Groups conflicting with other ids mapped in the container user namespace are not mapped.
+// These are mapped as-is by hsu alongside the emulated user.
+func (s *outcomeState) mapGroups(msg message.Msg, names, gids []string) error {
+	for i, name := range names {
+		gid, err := strconv.Atoi(gids[i])
+		if err != nil {
+			return &hst.AppError{Step: "look up group by name", Err: err, Msg: err.Error()}
+		}
+		if gid == s.Mapgid || s.Container.Subordinate.Contains(gid) {
+			msg.Verbosef("not mapping group %q with conflicting gid %d", name, gid)
+			continue
+		}
+		s.Groups = append(s.Groups, mappedGroup{name, gid})
+	}
+	return nil
+}
+
 // populateLocal populates unexported fields from transmitted exported fields.
 // These fields are cheaper to recompute per-process.
 func (s *outcomeState) populateLocal(k syscallDispatcher, msg message.Msg) error {
@@ -160,10 +187,27 @@ type outcomeStateSys struct {
 
 	// Copied from [hst.Config]. Safe for read by spWaylandOp.toSystem only.
 	directWayland bool
+	// Copied from [hst.Config]. Safe for read by spWaylandOp.toSystem only.
+	waylandProxy *hst.WaylandConfig
+	// Copied from [hst.Config]. Safe for read by spPulseOp.toSystem only.
+	pulseProxy *hst.PulseConfig
+	// Copied from [hst.Config]. Safe for read by spX11Op.toSystem only.
+	x11Cookie bool
+	// Copied from [hst.Config]. Safe for read by spX11Op.toSystem only.
+	x11MasterCookie bool
 	// Copied header from [hst.Config]. Safe for read by spFilesystemOp.toSystem only.
 	extraPerms []hst.ExtraPermConfig
-	// Copied address from [hst.Config]. Safe for read by spDBusOp.toSystem only.
+	// Copied header from [hst.Config]. Safe for read by spSocketOp.toSystem only.
+	sockets []hst.SocketConfig
+	// Copied address from [hst.Config]. Safe for read by spFlatpakOp.toSystem and spDBusOp.toSystem only.
 	sessionBus, systemBus *hst.BusConfig
+	// Copied from [hst.Config]. Safe for read by spFlatpakOp.toSystem and spDBusOp.toSystem only.
+	builtinDBusProxy bool
+	// Copied from [hst.Config]. Safe for read by spFlatpakOp.toSystem only.
+	flatpakInfo bool
+	// Generated .flatpak-info contents, nil if not enabled. Populated by spFlatpakOp.toSystem.
+	// Safe for read by spDBusOp.toSystem only.
+	flatpakInfoData []byte
 
 	sys *system.I
 	*outcomeState
@@ -173,9 +217,11 @@ type outcomeStateSys struct {
 func (s *outcomeState) newSys(config *hst.Config, sys *system.I) *outcomeStateSys {
 	return &outcomeStateSys{
 		appId: config.ID, et: config.Enablements.Unwrap(),
-		directWayland: config.DirectWayland, extraPerms: config.ExtraPerms,
-		sessionBus: config.SessionBus, systemBus: config.SystemBus,
-		sys: sys, outcomeState: s,
+		directWayland: config.DirectWayland, waylandProxy: config.WaylandProxy,
+		pulseProxy: config.PulseProxy, x11Cookie: config.X11Cookie,
+		x11MasterCookie: config.X11MasterCookie, extraPerms: config.ExtraPerms,
+		sessionBus: config.SessionBus, systemBus: config.SystemBus, builtinDBusProxy: config.BuiltinDBusProxy,
+		flatpakInfo: config.FlatpakInfo, sockets: config.Sockets, sys: sys, outcomeState: s,
 	}
 }
 
@@ -276,12 +322,18 @@ func (state *outcomeStateSys) toSystem() error {
 		&spRuntimeOp{},
 		spTmpdirOp{},
 		spAccountOp{},
+		&spEtcOp{},
 
 		// optional via enablements
 		&spWaylandOp{},
 		&spX11Op{},
 		&spPulseOp{},
+		spPipeWireOp{},
+		&spFlatpakOp{},
 		&spDBusOp{},
+		&spIntegrationOp{},
+		spNotifyOp{},
+		&spSocketOp{},
 
 		// must run last
 		&spFilesystemOp{},
@@ -0,0 +1,73 @@
+package outcome
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"syscall"
+
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+	"hakurei.app/internal/store"
+	"hakurei.app/message"
+)
+
+// podUserFlags are the [hst.Flags] affecting the user namespace, which is shared by every pod member.
+const podUserFlags = hst.FMapRealUID | hst.FFakeRoot
+
+// findPod returns the pid and start time of a process holding the namespaces of the pod config
+// is a member of, or zero if the pod has no running members. Must be called while holding
+// [store.Handle.Lock]. A non-nil error returned by findPod is of type [hst.AppError].
+func findPod(msg message.Msg, handle *store.Handle, config *hst.Config) (pid int, start uint64, err error) {
+	var members []*hst.State
+	if members, err = handle.Pod(config.Pod); err != nil {
+		return 0, 0, err
+	}
+	if len(members) == 0 {
+		msg.Verbosef("creating namespaces of pod %q", config.Pod)
+		return 0, 0, nil
+	}
+
+	for _, state := range members {
+		// not yet started, or failed to start
+		if state.InitPID == 0 || state.InitStartTime == 0 {
+			continue
+		}
+
+		if state.Container.Flags&podUserFlags != config.Container.Flags&podUserFlags ||
+			!reflect.DeepEqual(state.Container.Subordinate, config.Container.Subordinate) ||
+			!slices.Equal(state.Groups, config.Groups) {
+			return 0, 0, &hst.AppError{Step: "join pod", Err: syscall.EINVAL,
+				Msg: "pod " + strconv.Quote(config.Pod) + " has a different user namespace configuration"}
+		}
+		msg.Verbosef("joining pod %q via process %d", config.Pod, state.InitPID)
+		return state.InitPID, state.InitStartTime, nil
+	}
+	return 0, 0, &hst.AppError{Step: "join pod", Err: syscall.EAGAIN,
+		Msg: "pod " + strconv.Quote(config.Pod) + " is still starting"}
+}
+
+// processStartTime returns the start time of process pid in clock ticks since boot.
+func processStartTime(pid int) (uint64, error) {
+	stat, err := os.ReadFile(fhs.Proc + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	return parseStartTime(stat)
+}
+
+// parseStartTime returns the start time held by the contents of a proc_pid_stat(5) file.
+func parseStartTime(stat []byte) (uint64, error) {
+	// comm may contain spaces, starttime is the twentieth field following it
+	i := bytes.LastIndexByte(stat, ')')
+	if i == -1 {
+		return 0, syscall.EINVAL
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return 0, syscall.EINVAL
+	}
+	return strconv.ParseUint(string(fields[19]), 10, 64)
+}
@@ -0,0 +1,118 @@
+package outcome
+
+import (
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/store"
+	"hakurei.app/message"
+)
+
+func TestFindPod(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func() *hst.Config {
+		c := hst.Template()
+		c.Pod = "browser"
+		c.Container.Flags &^= hst.FHostNet
+		c.Container.Time = nil
+		return c
+	}
+
+	testCases := []struct {
+		name    string
+		members []hst.State
+		config  func() *hst.Config
+		want    int
+		start   uint64
+		wantErr error
+	}{
+		{"create", []hst.State{
+			{InitPID: 0xbeef, InitStartTime: 0xcafe, Config: hst.Template()},
+		}, newConfig, 0, 0, nil},
+
+		{"join", []hst.State{
+			{Config: newConfig()},
+			{InitPID: 0xbeef, InitStartTime: 0xcafe, Config: newConfig()},
+		}, newConfig, 0xbeef, 0xcafe, nil},
+
+		{"unknown start time", []hst.State{
+			{InitPID: 0xbeef, Config: newConfig()},
+		}, newConfig, 0, 0, &hst.AppError{Step: "join pod", Err: syscall.EAGAIN,
+			Msg: `pod "browser" is still starting`}},
+
+		{"starting", []hst.State{
+			{Config: newConfig()},
+		}, newConfig, 0, 0, &hst.AppError{Step: "join pod", Err: syscall.EAGAIN,
+			Msg: `pod "browser" is still starting`}},
+
+		{"user namespace", []hst.State{
+			{InitPID: 0xbeef, InitStartTime: 0xcafe, Config: newConfig()},
+		}, func() *hst.Config {
+			c := newConfig()
+			c.Groups = nil
+			return c
+		}, 0, 0, &hst.AppError{Step: "join pod", Err: syscall.EINVAL,
+			Msg: `pod "browser" has a different user namespace configuration`}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handle, err := store.New(check.MustAbs(t.TempDir())).Handle(9)
+			if err != nil {
+				t.Fatalf("Handle: error = %v", err)
+			}
+			for i := range tc.members {
+				state := &tc.members[i]
+				state.ID[0] = byte(i)
+				if _, err = handle.Save(state); err != nil {
+					t.Fatalf("Save: error = %v", err)
+				}
+			}
+
+			got, start, err := findPod(message.New(nil), handle, tc.config())
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Errorf("findPod: error = %#v, want %#v", err, tc.wantErr)
+			}
+			if got != tc.want || start != tc.start {
+				t.Errorf("findPod: %d %d, want %d %d", got, start, tc.want, tc.start)
+			}
+		})
+	}
+}
+
+func TestParseStartTime(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		stat    string
+		want    uint64
+		wantErr error
+	}{
+		{"no comm", "1 init S 0", 0, syscall.EINVAL},
+		{"short", "1 (init) S 0 1 1 0 -1 4194560 81985 6062577 165 3150 77 187 14004 5271 20 0 1 0\n", 0, syscall.EINVAL},
+		{"invalid", "1 (init) S 0 1 1 0 -1 4194560 81985 6062577 165 3150 77 187 14004 5271 20 0 1 0 x 23691264\n", 0,
+			&strconv.NumError{Func: "ParseUint", Num: "x", Err: strconv.ErrSyntax}},
+		{"success", "1 (init) S 0 1 1 0 -1 4194560 81985 6062577 165 3150 77 187 14004 5271 20 0 1 0 14 23691264 3296\n", 14, nil},
+		{"comm", "4242 (a) b) (c) R 1 4242 4242 34816 4242 4194304 84 0 0 0 0 0 0 0 20 0 1 0 94521 8704000 150\n", 94521, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseStartTime([]byte(tc.stat))
+			if !reflect.DeepEqual(err, tc.wantErr) {
+				t.Errorf("parseStartTime: error = %#v, want %#v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseStartTime: %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -32,6 +32,17 @@ const (
 // NewStore returns the address of a new instance of [store.Store].
 func NewStore(sc *hst.Paths) *store.Store { return store.New(sc.SharePath.Append("state")) }
 
+// DBusLogDir returns the pathname to the directory holding D-Bus events recorded for each instance,
+// in a file named after the [hst.ID] of the instance. Entries outlive their instance, and only those of
+// the 64 most recently created instances are retained: older entries are removed as an instance exits.
+// Each bus appends at most 16 MiB of events to an entry, further events are dropped.
+func DBusLogDir(sc *hst.Paths) *check.Absolute { return sc.SharePath.Append("dbus") }
+
+// ExitReportDir returns the pathname to the directory holding the [hst.ExitReport] of each instance in JSON,
+// in a file named after the [hst.ID] of the instance. Entries outlive their instance, and only those of
+// the 64 most recently created instances are retained: older entries are removed as a report is saved.
+func ExitReportDir(sc *hst.Paths) *check.Absolute { return sc.SharePath.Append("report") }
+
 // main carries out outcome and terminates. main does not return.
 func (k *outcome) main(msg message.Msg, identifierFd int) {
 	if k.ctx == nil || k.sys == nil || k.state == nil {
@@ -88,6 +99,22 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 		// write end of shim setup pipe,
 		// populated in processStart, accessed by processServe
 		shimPipe *os.File
+		// read end of exit report pipe,
+		// populated in processStart, accessed by processLifecycle
+		reportPipe *os.File
+		// priv side end of the id map socket, nil if disabled,
+		// populated in processStart, closed in processServe
+		idMapConn *os.File
+
+		// saved instance state,
+		// populated in processCommit, updated by the readiness notification goroutine
+		instanceState *hst.State
+		// readiness notification socket, nil if disabled,
+		// populated in processCommit, closed in processLifecycle
+		notify *notifier
+		// result of the readiness notification goroutine,
+		// populated in processServe, received from in processLifecycle
+		notifyDone chan error
 
 		// perror cancels ctx and prints an error message
 		perror = func(err error, message string) {
@@ -129,12 +156,12 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 				handle = h
 			}
 
-			cmd, f, err := k.start(ctx, msg, hsuPath, &startTime)
+			cmd, f, r, c, err := k.start(ctx, msg, hsuPath, &startTime)
 			if err != nil {
 				perrorFatal(err, "start shim", processFinal)
 				continue
 			} else {
-				shimCmd, shimPipe = cmd, f
+				shimCmd, shimPipe, reportPipe, idMapConn = cmd, f, r, c
 			}
 
 			processState = processCommit
@@ -150,32 +177,39 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 				perrorFatal(err, "acquire lock on store segment", processLifecycle)
 				continue
 			}
-			if entryHandle, err = handle.Save(&hst.State{
+			if k.config.Pod != "" {
+				if pid, start, err := findPod(msg, handle, k.config); err != nil {
+					unlock()
+					// transition here to avoid the commit/revert cycle on the doomed instance
+					perrorFatal(err, "look up pod", processLifecycle)
+					continue
+				} else {
+					k.state.Shim.PodPID, k.state.Shim.PodStartTime = pid, start
+				}
+			}
+
+			instanceState = &hst.State{
 				ID:      k.state.id.unwrap(),
 				PID:     os.Getpid(),
 				ShimPID: shimCmd.Process.Pid,
 				Config:  k.config,
 				Time:    startTime,
-			}); err != nil {
+			}
+			if entryHandle, err = handle.Save(instanceState); err != nil {
 				unlock()
 				// transition here to avoid the commit/revert cycle on the doomed instance
 				perrorFatal(err, "save instance state", processLifecycle)
 				continue
 			}
 
-			if f := os.NewFile(uintptr(identifierFd), "identifier"); f != nil {
-				_, err = f.Write(k.state.id.v[:])
-				if err != nil {
+			// identifier is written by the readiness notification goroutine if enabled
+			if k.state.Container.ReadyTimeout == 0 {
+				if err = writeIdentifier(msg, identifierFd, k.state.id.unwrap()); err != nil {
 					unlock()
 					// transition here to avoid the commit/revert cycle on the doomed instance
-					perrorFatal(&hst.AppError{Step: "write instance identifier", Err: err},
-						"write instance identifier", processLifecycle)
+					perrorFatal(err, "write instance identifier", processLifecycle)
 					continue
 				}
-				msg.Verbosef("wrote identifier to %d", identifierFd)
-				if err = f.Close(); err != nil {
-					msg.Verbose(err.Error())
-				}
 			}
 
 			err = k.sys.Commit()
@@ -186,6 +220,17 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 			}
 			isBeforeRevert = true
 
+			// the instance directory is created during commit
+			if k.state.Container.ReadyTimeout != 0 {
+				if notify, err = newNotifier(
+					k.state.instancePath().Append(notifySocketName),
+					k.state.uid.unwrap(),
+				); err != nil {
+					perrorFatal(err, "set up readiness notification", processLifecycle)
+					continue
+				}
+			}
+
 			processState = processServe
 
 		case processServe:
@@ -200,6 +245,60 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 				shimPipe = nil // this is already closed by serveShim
 			}
 
+			// updateState must not be called after processLifecycle
+			updateState := func(f func(state *hst.State)) {
+				unlock, err := handle.Lock()
+				if err != nil {
+					printMessageError(msg.GetLogger().Println, "cannot acquire lock on store segment:", err)
+					return
+				}
+				f(instanceState)
+				if err = entryHandle.Update(instanceState); err != nil {
+					printMessageError(msg.GetLogger().Println, "cannot update instance state:", err)
+				}
+				unlock()
+			}
+
+			if idMapConn != nil {
+				sub, groups := k.state.Container.Subordinate, k.state.Groups
+				if k.state.Shim.PodPID != 0 {
+					// the joined user namespace already has its id maps
+					sub, groups = nil, nil
+				}
+
+				pid, err := serveIDMap(ctx, msg, hsuPath, idMapConn,
+					k.state.identity.unwrap(), k.state.Mapuid, k.state.Mapgid,
+					sub, groups)
+				idMapConn = nil // this is already closed by serveIDMap
+				if err != nil {
+					perror(err, "set up container user namespace")
+					continue
+				}
+				// recorded for joining the namespaces of this instance
+				if start, err := processStartTime(pid); err != nil {
+					printMessageError(msg.GetLogger().Println, "cannot record container init:", err)
+				} else {
+					updateState(func(state *hst.State) { state.InitPID, state.InitStartTime = pid, start })
+				}
+			}
+
+			if notify != nil {
+				notifyDone = make(chan error, 1)
+				go func() {
+					err := notify.serve(msg, k.state.Container.ReadyTimeout, func() error {
+						updateState(func(state *hst.State) { state.Ready = true })
+						return writeIdentifier(msg, identifierFd, k.state.id.unwrap())
+					}, func(s string) {
+						updateState(func(state *hst.State) { state.Status = s })
+					})
+					if err != nil {
+						// terminates the container
+						cancel()
+					}
+					notifyDone <- err
+				}()
+			}
+
 		case processLifecycle:
 			// this state transition to processCleanup only
 			processState = processCleanup
@@ -250,6 +349,32 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 			}
 			msg.Resume()
 
+			if notify != nil {
+				if err := notify.close(); err != nil {
+					msg.Verbose(err.Error())
+				}
+				if notifyDone != nil {
+					if err := <-notifyDone; err != nil {
+						perror(err, "wait for readiness notification")
+					}
+				}
+				notify = nil
+			}
+
+			if report, err := receiveReport(reportPipe); err != nil {
+				msg.Verbosef("cannot receive exit report: %v", err)
+			} else if report != nil {
+				if err = saveExitReport(ExitReportDir(&k.state.sc), k.state.ID, report); err != nil {
+					printMessageError(msg.GetLogger().Println, "cannot save exit report:", err)
+				} else if err = pruneInstanceFiles(ExitReportDir(&k.state.sc), instanceFilesMax); err != nil {
+					printMessageError(msg.GetLogger().Println, "cannot remove old exit reports:", err)
+				}
+				printExitReport(msg, k.reportOutput, report)
+			}
+			if err := pruneInstanceFiles(DBusLogDir(&k.state.sc), instanceFilesMax); err != nil && !errors.Is(err, os.ErrNotExist) {
+				printMessageError(msg.GetLogger().Println, "cannot remove old D-Bus logs:", err)
+			}
+
 		case processCleanup:
 			// this state transition to processFinal only
 			processState = processFinal
@@ -333,14 +458,31 @@ func (k *outcome) main(msg message.Msg, identifierFd int) {
 	}
 }
 
+// writeIdentifier writes the instance identifier to fd and closes it.
+// Nothing is written if fd is not a valid file descriptor.
+// A non-nil error returned by writeIdentifier is of type [hst.AppError].
+func writeIdentifier(msg message.Msg, fd int, id hst.ID) error {
+	if f := os.NewFile(uintptr(fd), "identifier"); f != nil {
+		if _, err := f.Write(id[:]); err != nil {
+			return &hst.AppError{Step: "write instance identifier", Err: err}
+		}
+		msg.Verbosef("wrote identifier to %d", fd)
+		if err := f.Close(); err != nil {
+			msg.Verbose(err.Error())
+		}
+	}
+	return nil
+}
+
 // start starts the shim via cmd/hsu.
 //
 // If successful, a [time.Time] value for [hst.State] is stored in the value pointed to by startTime.
-// The resulting [exec.Cmd] and write end of the shim setup pipe is returned.
+// The resulting [exec.Cmd], write end of the shim setup pipe, read end of the exit report pipe
+// and priv side end of the id map socket is returned.
 func (k *outcome) start(ctx context.Context, msg message.Msg,
 	hsuPath *check.Absolute,
 	startTime *time.Time,
-) (*exec.Cmd, *os.File, error) {
+) (*exec.Cmd, *os.File, *os.File, *os.File, error) {
 	cmd := exec.CommandContext(ctx, hsuPath.String())
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
 	cmd.Dir = fhs.Root // container init enters final working directory
@@ -349,7 +491,7 @@ func (k *outcome) start(ctx context.Context, msg message.Msg,
 
 	var shimPipe *os.File
 	if fd, w, err := container.Setup(&cmd.ExtraFiles); err != nil {
-		return cmd, nil, &hst.AppError{Step: "create shim setup pipe", Err: err}
+		return cmd, nil, nil, nil, &hst.AppError{Step: "create shim setup pipe", Err: err}
 	} else {
 		shimPipe = w
 		cmd.Env = []string{
@@ -360,6 +502,30 @@ func (k *outcome) start(ctx context.Context, msg message.Msg,
 		}
 	}
 
+	// inherited by shim through hsu, the descriptor number is transmitted in shimParams
+	var reportPipe, reportW *os.File
+	if r, w, err := os.Pipe(); err != nil {
+		_ = shimPipe.Close()
+		return cmd, nil, nil, nil, &hst.AppError{Step: "create exit report pipe", Err: err}
+	} else {
+		reportPipe, reportW = r, w
+		k.state.Shim.ReportFd = uintptr(3 + len(cmd.ExtraFiles))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	}
+
+	// inherited by shim through hsu, the descriptor number is transmitted in shimParams
+	var idMapConn, idMapShim *os.File
+	if k.state.Container.Subordinate != nil || len(k.state.Groups) > 0 || k.config.Pod != "" {
+		if c, s, err := newIDMapSocket(); err != nil {
+			_, _, _ = shimPipe.Close(), reportPipe.Close(), reportW.Close()
+			return cmd, nil, nil, nil, &hst.AppError{Step: "create id map socket", Err: err}
+		} else {
+			idMapConn, idMapShim = c, s
+			k.state.Shim.IDMapFd = uintptr(3 + len(cmd.ExtraFiles))
+			cmd.ExtraFiles = append(cmd.ExtraFiles, s)
+		}
+	}
+
 	if len(k.supp) > 0 {
 		msg.Verbosef("attaching supplementary group ids %s", k.supp)
 		// interpreted by hsu
@@ -367,13 +533,27 @@ func (k *outcome) start(ctx context.Context, msg message.Msg,
 	}
 
 	msg.Verbosef("setuid helper at %s", hsuPath)
-	if err := cmd.Start(); err != nil {
+	err := cmd.Start()
+	// the only remaining write end is held by the shim
+	if closeErr := reportW.Close(); closeErr != nil {
+		msg.Verbose(closeErr.Error())
+	}
+	if idMapShim != nil {
+		if closeErr := idMapShim.Close(); closeErr != nil {
+			msg.Verbose(closeErr.Error())
+		}
+	}
+	if err != nil {
 		msg.Resume()
-		return cmd, shimPipe, &hst.AppError{Step: "start setuid wrapper", Err: err}
+		_ = reportPipe.Close()
+		if idMapConn != nil {
+			_ = idMapConn.Close()
+		}
+		return cmd, shimPipe, nil, nil, &hst.AppError{Step: "start setuid wrapper", Err: err}
 	}
 
 	*startTime = time.Now().UTC()
-	return cmd, shimPipe, nil
+	return cmd, shimPipe, reportPipe, idMapConn, nil
 }
 
 // serveShim serves outcomeState through the shim setup pipe.
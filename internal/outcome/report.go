@@ -0,0 +1,189 @@
+package outcome
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+const (
+	// reportSizeMax is the maximum size of an encoded [hst.ExitReport] accepted from the shim.
+	reportSizeMax = 1 << 20
+	// reportReadTimeout is the maximum duration to wait for the exit report after the shim terminates.
+	reportReadTimeout = time.Second
+
+	// instanceFilesMax is the number of per-instance files retained in [ExitReportDir] and [DBusLogDir].
+	instanceFilesMax = 64
+)
+
+// newExitReport returns the address of a new [hst.ExitReport] describing how the container terminated.
+// Report is the value sent by the container init and may be nil, state is the [os.ProcessState] of
+// the container init, and canceled is whether the container was canceled by the shim.
+func newExitReport(report *hst.ExitReport, state *os.ProcessState, canceled bool) *hst.ExitReport {
+	r := new(hst.ExitReport)
+	if report != nil {
+		*r = *report
+	}
+	if state != nil {
+		r.Code = state.ExitCode()
+		if wstatus, ok := state.Sys().(syscall.WaitStatus); ok && wstatus.Signaled() {
+			r.Signal = wstatus.Signal().String()
+			r.Killed = canceled && wstatus.Signal() == syscall.SIGKILL
+		}
+	}
+	return r
+}
+
+// sendReport encodes report to the exit report pipe inherited by the shim.
+func sendReport(fd uintptr, report *hst.ExitReport) error {
+	f := os.NewFile(fd, "report")
+	if f == nil {
+		return syscall.EBADF
+	}
+	err := gob.NewEncoder(f).Encode(report)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// receiveReport decodes an [hst.ExitReport] sent by the shim from r and closes it.
+// A nil report is returned without error if the shim did not send one.
+func receiveReport(r *os.File) (*hst.ExitReport, error) {
+	defer func() { _ = r.Close() }()
+	if err := r.SetReadDeadline(time.Now().Add(reportReadTimeout)); err != nil {
+		return nil, err
+	}
+
+	var report hst.ExitReport
+	if err := gob.NewDecoder(io.LimitReader(r, reportSizeMax)).Decode(&report); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// saveExitReport writes report in JSON to a file named after id in dir, creating dir if it does not exist.
+func saveExitReport(dir *check.Absolute, id *hst.ID, report *hst.ExitReport) error {
+	if err := os.Mkdir(dir.String(), 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dir.Append(id.String()).String(), data, 0600)
+}
+
+// pruneInstanceFiles removes files named after an [hst.ID] in dir, oldest instance first,
+// until at most n of them remain. Other entries are left alone.
+func pruneInstanceFiles(dir *check.Absolute, n int) error {
+	entries, err := os.ReadDir(dir.String())
+	if err != nil {
+		return err
+	}
+
+	ids := make([]hst.ID, 0, len(entries))
+	for _, ent := range entries {
+		var id hst.ID
+		if !ent.Type().IsRegular() || id.UnmarshalText([]byte(ent.Name())) != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) <= n {
+		return nil
+	}
+	slices.SortFunc(ids, func(a, b hst.ID) int { return a.CreationTime().Compare(b.CreationTime()) })
+
+	var errs []error
+	for i := range ids[:len(ids)-n] {
+		if err = os.Remove(dir.Append(ids[i].String()).String()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// describeProcess returns a human-readable description of how a process terminated.
+func describeProcess(p *hst.ProcessReport) string {
+	var buf strings.Builder
+	buf.WriteString("process " + strconv.Itoa(p.PID))
+	if p.Signal != "" {
+		buf.WriteString(" got " + p.Signal)
+	} else {
+		buf.WriteString(" exited with code " + strconv.Itoa(p.Code))
+	}
+	if p.CoreDump {
+		buf.WriteString(" and dumped core")
+	}
+	buf.WriteString(" (user " + p.UserTime.String() +
+		", system " + p.SystemTime.String() +
+		", max rss " + strconv.FormatInt(p.MaxRSS, 10) + " KiB)")
+	return buf.String()
+}
+
+// DescribeExitReport returns human-readable lines describing report.
+func DescribeExitReport(report *hst.ExitReport) []string {
+	var lines []string
+	if report.Killed {
+		lines = append(lines, "container init killed after wait delay")
+	} else if report.Signal != "" {
+		lines = append(lines, "container init got "+report.Signal)
+	} else {
+		lines = append(lines, "container init exited with code "+strconv.Itoa(report.Code))
+	}
+
+	if report.Initial != nil {
+		lines = append(lines, "initial "+describeProcess(report.Initial))
+	} else {
+		lines = append(lines, "initial process was not reaped")
+	}
+	for i := range report.Reaped {
+		lines = append(lines, "reaped "+describeProcess(&report.Reaped[i]))
+	}
+	if report.Dropped > 0 {
+		lines = append(lines, strconv.Itoa(report.Dropped)+" reaped processes omitted")
+	}
+
+	if report.Timeout {
+		lingering := make([]string, len(report.Lingering))
+		for i, p := range report.Lingering {
+			lingering[i] = strconv.Itoa(p.PID) + " (" + p.Comm + ")"
+		}
+		lines = append(lines, "killed "+strconv.Itoa(len(report.Lingering))+
+			" lingering processes after timeout: "+strings.Join(lingering, ", "))
+	}
+	return lines
+}
+
+// printExitReport prints report as verbose messages, and to w in JSON if w is not nil.
+func printExitReport(msg message.Msg, w io.Writer, report *hst.ExitReport) {
+	if w != nil {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			msg.Verbosef("cannot encode exit report: %v", err)
+		}
+	}
+
+	if !msg.IsVerbose() {
+		return
+	}
+	for _, line := range DescribeExitReport(report) {
+		msg.Verbose(line)
+	}
+}
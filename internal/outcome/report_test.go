@@ -0,0 +1,97 @@
+package outcome
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+)
+
+func TestSaveExitReport(t *testing.T) {
+	t.Parallel()
+
+	dir := check.MustAbs(t.TempDir()).Append("report")
+	id := hst.ID{0xfe, 0xed}
+	want := &hst.ExitReport{
+		Code:    137,
+		Signal:  "killed",
+		Killed:  true,
+		Initial: &hst.ProcessReport{PID: 2, Code: 143, Signal: "terminated", UserTime: time.Second},
+		Timeout: true,
+		Lingering: []hst.LingeringProcess{
+			{PID: 3, Comm: "clangd"},
+		},
+	}
+
+	for range 2 {
+		if err := saveExitReport(dir, &id, want); err != nil {
+			t.Fatalf("saveExitReport: error = %v", err)
+		}
+	}
+
+	var got hst.ExitReport
+	if data, err := os.ReadFile(dir.Append(id.String()).String()); err != nil {
+		t.Fatalf("ReadFile: error = %v", err)
+	} else if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("saveExitReport: %#v, want %#v", &got, want)
+	}
+
+	wantLines := []string{
+		"container init killed after wait delay",
+		"initial process 2 got terminated (user 1s, system 0s, max rss 0 KiB)",
+		"killed 1 lingering processes after timeout: 3 (clangd)",
+	}
+	if lines := DescribeExitReport(&got); !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("DescribeExitReport: %#v, want %#v", lines, wantLines)
+	}
+}
+
+func TestPruneInstanceFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := check.MustAbs(t.TempDir())
+	var ids [5]hst.ID
+	for i := range ids {
+		// creation time is stored in the leading bytes, encode them out of order
+		ids[i] = hst.ID{7: byte(len(ids) - i), 8: 0xfd}
+		if err := os.WriteFile(dir.Append(ids[i].String()).String(), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(dir.Append("nonexistent").String(), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pruneInstanceFiles(dir, 2); err != nil {
+		t.Fatalf("pruneInstanceFiles: error = %v", err)
+	}
+	if err := pruneInstanceFiles(dir, 2); err != nil {
+		t.Fatalf("pruneInstanceFiles: error = %v", err)
+	}
+
+	var got []string
+	if entries, err := os.ReadDir(dir.String()); err != nil {
+		t.Fatalf("ReadDir: error = %v", err)
+	} else {
+		for _, ent := range entries {
+			got = append(got, ent.Name())
+		}
+	}
+	want := []string{ids[1].String(), ids[0].String(), "nonexistent"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pruneInstanceFiles: %q, want %q", got, want)
+	}
+
+	if err := pruneInstanceFiles(dir.Append("nonexistent", "report"), 2); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("pruneInstanceFiles: error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package outcome
 import (
 	"context"
 	"log"
+	"os"
 	"time"
 	_ "unsafe" // for go:linkname
 
@@ -18,7 +19,8 @@ import (
 func IsPollDescriptor(fd uintptr) bool
 
 // Main runs an app according to [hst.Config] and terminates. Main does not return.
-func Main(ctx context.Context, msg message.Msg, config *hst.Config, fd int) {
+// If reportJSON is true, the [hst.ExitReport] of the container is written to stderr in JSON.
+func Main(ctx context.Context, msg message.Msg, config *hst.Config, fd int, reportJSON bool) {
 	// avoids runtime internals or standard streams
 	if fd >= 0 {
 		if IsPollDescriptor(uintptr(fd)) || fd < 3 {
@@ -32,6 +34,9 @@ func Main(ctx context.Context, msg message.Msg, config *hst.Config, fd int) {
 	}
 
 	k := outcome{syscallDispatcher: direct{msg}}
+	if reportJSON {
+		k.reportOutput = os.Stderr
+	}
 
 	finaliseTime := time.Now()
 	if err := k.finalise(ctx, msg, &id, config); err != nil {
@@ -89,7 +89,7 @@ func TestOutcomeMain(t *testing.T) {
 				}, dbus.ProxyPair{
 					"unix:path=/var/run/dbus/system_bus_socket",
 					"/tmp/hakurei.0/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/system_bus_socket",
-				},
+				}, nil, nil,
 			).UpdatePerm(m("/tmp/hakurei.0/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/bus"), acl.Read, acl.Write).
 			UpdatePerm(m("/tmp/hakurei.0/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/system_bus_socket"), acl.Read, acl.Write).
 
@@ -119,7 +119,8 @@ func TestOutcomeMain(t *testing.T) {
 
 			// spParamsOp
 			Hostname:      "localhost",
-			RetainSession: true,
+			RetainSession: false,
+			Ctty:          true,
 			HostNet:       true,
 			HostAbstract:  true,
 			Path:          m("/run/current-system/sw/bin/chromium"),
@@ -133,6 +134,7 @@ func TestOutcomeMain(t *testing.T) {
 			SeccompFlags: seccomp.AllowMultiarch,
 			Uid:          1971,
 			Gid:          100,
+			MapExternal:  true,
 
 			Ops: new(container.Ops).
 				// resolveRoot
@@ -152,7 +154,7 @@ func TestOutcomeMain(t *testing.T) {
 
 				// spAccountOp
 				Place(m("/etc/passwd"), []byte("chronos:x:1971:100:Hakurei:/data/data/org.chromium.Chromium:/run/current-system/sw/bin/zsh\n")).
-				Place(m("/etc/group"), []byte("hakurei:x:100:\n")).
+				Place(m("/etc/group"), []byte("hakurei:x:100:\nvideo:x:26:chronos\ndialout:x:27:chronos\nplugdev:x:46:chronos\n")).
 
 				// spWaylandOp
 				Bind(m("/tmp/hakurei.0/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/wayland"), m("/run/user/1971/wayland-0"), 0).
@@ -386,7 +388,7 @@ func TestOutcomeMain(t *testing.T) {
 			}, dbus.ProxyPair{
 				"unix:path=/var/run/dbus/system_bus_socket",
 				"/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/system_bus_socket",
-			}).
+			}, nil, nil).
 			UpdatePerm(m("/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/bus"), acl.Read, acl.Write).
 			UpdatePerm(m("/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/system_bus_socket"), acl.Read, acl.Write), &container.Params{
 
@@ -417,7 +419,7 @@ func TestOutcomeMain(t *testing.T) {
 				Bind(m("/tmp/hakurei.0/runtime/9"), m("/run/user/65534"), std.BindWritable).
 				Bind(m("/tmp/hakurei.0/tmpdir/9"), m("/tmp/"), std.BindWritable).
 				Place(m("/etc/passwd"), []byte("chronos:x:65534:65534:Hakurei:/home/chronos:/run/current-system/sw/bin/zsh\n")).
-				Place(m("/etc/group"), []byte("hakurei:x:65534:\n")).
+				Place(m("/etc/group"), []byte("hakurei:x:65534:\nvideo:x:26:chronos\n")).
 				Bind(m("/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/wayland"), m("/run/user/65534/wayland-0"), 0).
 				Bind(m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"), m("/run/user/65534/pulse/native"), 0).
 				Place(m(hst.PrivateTmp+"/pulse-cookie"), bytes.Repeat([]byte{0}, pulseCookieSizeMax)).
@@ -436,6 +438,7 @@ func TestOutcomeMain(t *testing.T) {
 			HostAbstract:   true,
 			RetainSession:  true,
 			ForwardCancel:  true,
+			MapExternal:    true,
 		}},
 
 		{"nixos chromium direct wayland", new(stubNixOS), &hst.Config{
@@ -531,7 +534,7 @@ func TestOutcomeMain(t *testing.T) {
 			}, dbus.ProxyPair{
 				"unix:path=/var/run/dbus/system_bus_socket",
 				"/tmp/hakurei.0/8e2c76b066dabe574cf073bdb46eb5c1/system_bus_socket",
-			}).
+			}, nil, nil).
 			UpdatePerm(m("/tmp/hakurei.0/8e2c76b066dabe574cf073bdb46eb5c1/bus"), acl.Read, acl.Write).
 			UpdatePerm(m("/tmp/hakurei.0/8e2c76b066dabe574cf073bdb46eb5c1/system_bus_socket"), acl.Read, acl.Write), &container.Params{
 
@@ -602,6 +605,18 @@ func TestOutcomeMain(t *testing.T) {
 					t.Fatalf("populateLocal: error = %#v", err)
 				}
 
+				supp := make([]string, len(tc.config.Groups))
+				for i, name := range tc.config.Groups {
+					if gid, err := tc.k.lookupGroupId(name); err != nil {
+						t.Fatalf("lookupGroupId: error = %v", err)
+					} else {
+						supp[i] = gid
+					}
+				}
+				if err := sPriv.mapGroups(msg, tc.config.Groups, supp); err != nil {
+					t.Fatalf("mapGroups: error = %#v", err)
+				}
+
 				gotSys = system.New(t.Context(), msg, sPriv.uid.unwrap())
 				if err := sPriv.newSys(tc.config, gotSys).toSystem(); err != nil {
 					t.Fatalf("toSystem: error = %#v", err)
@@ -894,6 +909,10 @@ func (k *stubNixOS) lookupGroupId(name string) (string, error) {
 	switch name {
 	case "video":
 		return "26", nil
+	case "dialout":
+		return "27", nil
+	case "plugdev":
+		return "46", nil
 	default:
 		return "", user.UnknownGroupError(name)
 	}
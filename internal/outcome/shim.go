@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -64,6 +65,17 @@ type shimParams struct {
 	// Verbosity pass through from [message.Msg].
 	Verbose bool
 
+	// Inherited write end of the exit report pipe, zero if unavailable.
+	ReportFd uintptr
+	// Inherited end of the id map socket, zero if ids are not mapped externally and the
+	// container is not a pod member.
+	IDMapFd uintptr
+	// Pid of a process holding the namespaces of the pod joined by the container,
+	// zero to create new namespaces.
+	PodPID int
+	// Start time of PodPID, checked by container init before joining its namespaces.
+	PodStartTime uint64
+
 	// Outcome setup ops, contains setup state. Populated by outcome.finalise.
 	Ops []outcomeOp
 }
@@ -165,12 +177,21 @@ func shimEntrypoint(k syscallDispatcher) {
 		k.fatal("invalid container state")
 	}
 
+	// restores the terminal if a pseudo-terminal is relayed, called on every exit path once set up
+	var restoreTerminal atomic.Pointer[func()]
+	restore := func() {
+		if f := restoreTerminal.Load(); f != nil {
+			(*f)()
+		}
+	}
+
 	// shim exit outcomes
 	var cancelContainer atomic.Pointer[context.CancelFunc]
 	k.new(func(k syscallDispatcher, msg message.Msg) {
 		buf := make([]byte, 1)
 		for {
 			if _, err := signalPipe.Read(buf); err != nil {
+				restore()
 				k.fatalf("cannot read from signal pipe: %v", err)
 			}
 
@@ -183,9 +204,11 @@ func shimEntrypoint(k syscallDispatcher) {
 				}
 
 				// setup has not completed, terminate immediately
+				restore()
 				k.exit(hst.ExitRequest)
 
 			case shimMsgOrphaned: // got SIGCONT after orphaned: hakurei died before delivering signal
+				restore()
 				k.exit(hst.ExitOrphan)
 
 			case shimMsgInvalid: // unreachable
@@ -195,6 +218,7 @@ func shimEntrypoint(k syscallDispatcher) {
 				msg.Verbose("got SIGCONT from unexpected process")
 
 			default: // unreachable
+				restore()
 				k.fatalf("got invalid message %d from signal handler", buf[0])
 			}
 		}
@@ -211,11 +235,24 @@ func shimEntrypoint(k syscallDispatcher) {
 	z := container.New(ctx, msg)
 	z.Params = *stateParams.params
 	z.Stdin, z.Stdout, z.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// only useful if it can be passed on to the priv side
+	z.Report = state.Shim.ReportFd != 0
+	z.Join, z.JoinStartTime = state.Shim.PodPID, state.Shim.PodStartTime
+
+	if z.Ctty {
+		if f, err := k.setupPty(z); err != nil {
+			k.fatalf("cannot set up pseudo-terminal: %v", err)
+		} else {
+			f = sync.OnceFunc(f)
+			restoreTerminal.Store(&f)
+		}
+	}
 
 	// bounds and default enforced in finalise.go
 	z.WaitDelay = state.Shim.WaitDelay
 
 	if err := k.containerStart(z); err != nil {
+		restore()
 		var f func(v ...any)
 		if logger := msg.GetLogger(); logger != nil {
 			f = logger.Println
@@ -227,7 +264,16 @@ func shimEntrypoint(k syscallDispatcher) {
 		printMessageError(f, "cannot start container:", err)
 		k.exit(hst.ExitFailure)
 	}
+	if state.Shim.IDMapFd != 0 {
+		// container init blocks on its setup payload until the priv side writes its id maps
+		// and records its pid
+		if err := k.requestIDMap(state.Shim.IDMapFd, z.Pid()); err != nil {
+			restore()
+			k.fatalf("cannot set up container user namespace: %v", err)
+		}
+	}
 	if err := k.containerServe(z); err != nil {
+		restore()
 		printMessageError(func(v ...any) { k.fatal(fmt.Sprintln(v...)) },
 			"cannot configure container:", err)
 	}
@@ -236,10 +282,20 @@ func shimEntrypoint(k syscallDispatcher) {
 		seccomp.Preset(std.PresetStrict, seccomp.AllowMultiarch),
 		seccomp.AllowMultiarch,
 	); err != nil {
+		restore()
 		k.fatalf("cannot load syscall filter: %v", err)
 	}
 
-	if err := k.containerWait(z); err != nil {
+	err := k.containerWait(z)
+	restore()
+	if state.Shim.ReportFd != 0 {
+		if reportErr := k.sendReport(state.Shim.ReportFd, newExitReport(
+			z.ExitReport(), z.ProcessState(), errors.Is(err, context.Canceled),
+		)); reportErr != nil {
+			msg.Verbosef("cannot send exit report: %v", reportErr)
+		}
+	}
+	if err != nil {
 		var exitError *exec.ExitError
 		if !errors.As(err, &exitError) {
 			if errors.Is(err, context.Canceled) {
@@ -43,7 +43,8 @@ func TestShimEntrypoint(t *testing.T) {
 
 		// spParamsOp
 		Hostname:      "localhost",
-		RetainSession: true,
+		RetainSession: false,
+		Ctty:          true,
 		HostNet:       true,
 		HostAbstract:  true,
 		ForwardCancel: true,
@@ -109,7 +110,7 @@ func TestShimEntrypoint(t *testing.T) {
 
 	newShimParams := func() *shimParams {
 		return &shimParams{PrivPID: 0xbad, WaitDelay: 0xf, Verbose: true, Ops: []outcomeOp{
-			&spParamsOp{"xterm-256color", true},
+			&spParamsOp{Term: "xterm-256color", TermSet: true},
 			&spRuntimeOp{sessionTypeWayland},
 			spTmpdirOp{},
 			spAccountOp{},
@@ -131,6 +132,16 @@ func TestShimEntrypoint(t *testing.T) {
 		Paths:     &env.Paths{TempDir: fhs.AbsTmp, RuntimePath: fhs.AbsRunUser.Append("1000")},
 	}
 
+	reportState := templateState
+	reportState.Shim = newShimParams()
+	reportState.Shim.ReportFd = 4
+	reportParams := *templateParams
+	reportParams.Report = true
+
+	idMapState := templateState
+	idMapState.Shim = newShimParams()
+	idMapState.Shim.IDMapFd = 5
+
 	checkSimple(t, "shimEntrypoint", []simpleTestCase{
 		{"dumpable", func(k *kstub) error { shimEntrypoint(k); return nil }, stub.Expect{Calls: []stub.Call{
 			call("getMsg", stub.ExpectArgs{}, nil, nil),
@@ -328,7 +339,9 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, stub.UniqueError(5)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("getLogger", stub.ExpectArgs{}, (*log.Logger)(nil), nil),
 			call("verbose", stub.ExpectArgs{[]any{"cannot start container: unique error 5 injected by the test suite\n"}}, nil, nil),
 			call("exit", stub.ExpectArgs{hst.ExitFailure}, stub.PanicExit, nil),
@@ -352,7 +365,9 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, stub.UniqueError(5)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("getLogger", stub.ExpectArgs{}, log.Default(), nil),
 			call("exit", stub.ExpectArgs{hst.ExitFailure}, stub.PanicExit, nil),
 
@@ -376,8 +391,10 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, stub.UniqueError(3)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("fatal", stub.ExpectArgs{[]any{"cannot configure container: unique error 3 injected by the test suite\n"}}, nil, nil),
 
 			// deferred
@@ -399,9 +416,11 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, stub.UniqueError(2)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("fatalf", stub.ExpectArgs{"cannot load syscall filter: %v", []any{stub.UniqueError(2)}}, nil, nil),
 
 			// deferred
@@ -424,10 +443,12 @@ func TestShimEntrypoint(t *testing.T) {
 			call("closeReceive", stub.ExpectArgs{}, nil, stub.UniqueError(1)),
 			call("verbosef", stub.ExpectArgs{"cannot close setup pipe: %v", []any{stub.UniqueError(1)}}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, 0, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
 			call("containerWait", stub.ExpectArgs{templateParams}, nil, makeExitError(1<<8)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("exit", stub.ExpectArgs{1}, stub.PanicExit, nil),
 
 			// deferred
@@ -450,10 +471,12 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, 0, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
 			call("containerWait", stub.ExpectArgs{templateParams}, nil, makeExitError(1<<8)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("exit", stub.ExpectArgs{1}, stub.PanicExit, nil),
 
 			// deferred
@@ -477,10 +500,12 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
 			call("containerWait", stub.ExpectArgs{templateParams}, nil, context.Canceled),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("exit", stub.ExpectArgs{hst.ExitCancel}, stub.PanicExit, nil),
 
 			// deferred
@@ -503,10 +528,12 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
 			call("containerWait", stub.ExpectArgs{templateParams}, nil, stub.UniqueError(0)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 			call("verbosef", stub.ExpectArgs{"cannot wait: %v", []any{stub.UniqueError(0)}}, nil, nil),
 			call("exit", stub.ExpectArgs{127}, stub.PanicExit, nil),
 
@@ -517,6 +544,63 @@ func TestShimEntrypoint(t *testing.T) {
 			call("fatalf", stub.ExpectArgs{"got invalid message %d from signal handler", []any{byte(0xff)}}, nil, nil),
 		}}}}, nil},
 
+		{"report", func(k *kstub) error { shimEntrypoint(k); return nil }, stub.Expect{Calls: []stub.Call{
+			call("getMsg", stub.ExpectArgs{}, nil, nil),
+			call("getLogger", stub.ExpectArgs{}, (*log.Logger)(nil), nil),
+			call("setDumpable", stub.ExpectArgs{uintptr(container.SUID_DUMP_DISABLE)}, nil, nil),
+			call("getppid", stub.ExpectArgs{}, 0xbad, nil),
+			call("setupContSignal", stub.ExpectArgs{0xbad}, 0, nil),
+			call("receive", stub.ExpectArgs{"HAKUREI_SHIM", reportState, nil}, nil, nil),
+			call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+			call("verbosef", stub.ExpectArgs{"process share directory at %q, runtime directory at %q", []any{m("/tmp/hakurei.10"), m("/run/user/1000/hakurei")}}, nil, nil),
+			call("prctl", stub.ExpectArgs{uintptr(syscall.PR_SET_PDEATHSIG), uintptr(syscall.SIGCONT), uintptr(0)}, nil, nil),
+			call("New", stub.ExpectArgs{}, nil, nil),
+			call("closeReceive", stub.ExpectArgs{}, nil, nil),
+			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{&reportParams}, nil, nil),
+			call("containerStart", stub.ExpectArgs{&reportParams}, nil, nil),
+			call("containerServe", stub.ExpectArgs{&reportParams}, nil, nil),
+			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
+			call("containerWait", stub.ExpectArgs{&reportParams}, nil, nil),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
+			call("sendReport", stub.ExpectArgs{uintptr(4), &hst.ExitReport{}}, nil, stub.UniqueError(0xcafe)),
+			call("verbosef", stub.ExpectArgs{"cannot send exit report: %v", []any{stub.UniqueError(0xcafe)}}, nil, nil),
+
+			// deferred
+			call("wKeepAlive", stub.ExpectArgs{}, nil, nil),
+		}, Tracks: []stub.Expect{{Calls: []stub.Call{
+			call("rcRead", stub.ExpectArgs{}, []byte{shimMsgInvalid}, nil),
+			call("verbose", stub.ExpectArgs{[]any{"sa_sigaction got invalid siginfo"}}, nil, nil),
+			call("rcRead", stub.ExpectArgs{}, []byte{shimMsgBadPID}, nil),
+			call("verbose", stub.ExpectArgs{[]any{"got SIGCONT from unexpected process"}}, nil, nil),
+			call("rcRead", stub.ExpectArgs{}, nil, nil), // stub terminates this goroutine
+		}}}}, nil},
+
+		{"idmap", func(k *kstub) error { shimEntrypoint(k); return nil }, stub.Expect{Calls: []stub.Call{
+			call("getMsg", stub.ExpectArgs{}, nil, nil),
+			call("getLogger", stub.ExpectArgs{}, (*log.Logger)(nil), nil),
+			call("setDumpable", stub.ExpectArgs{uintptr(container.SUID_DUMP_DISABLE)}, nil, nil),
+			call("getppid", stub.ExpectArgs{}, 0xbad, nil),
+			call("setupContSignal", stub.ExpectArgs{0xbad}, 0, nil),
+			call("receive", stub.ExpectArgs{"HAKUREI_SHIM", idMapState, nil}, nil, nil),
+			call("swapVerbose", stub.ExpectArgs{true}, false, nil),
+			call("verbosef", stub.ExpectArgs{"process share directory at %q, runtime directory at %q", []any{m("/tmp/hakurei.10"), m("/run/user/1000/hakurei")}}, nil, nil),
+			call("prctl", stub.ExpectArgs{uintptr(syscall.PR_SET_PDEATHSIG), uintptr(syscall.SIGCONT), uintptr(0)}, nil, nil),
+			call("New", stub.ExpectArgs{}, nil, nil),
+			call("closeReceive", stub.ExpectArgs{}, nil, nil),
+			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
+			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
+			call("requestIDMap", stub.ExpectArgs{uintptr(5), -1}, nil, stub.UniqueError(0xbeef)),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
+			call("fatalf", stub.ExpectArgs{"cannot set up container user namespace: %v", []any{stub.UniqueError(0xbeef)}}, nil, nil),
+
+			// deferred
+			call("wKeepAlive", stub.ExpectArgs{}, nil, nil),
+		}, Tracks: []stub.Expect{{Calls: []stub.Call{
+			call("rcRead", stub.ExpectArgs{}, nil, nil), // stub terminates this goroutine
+		}}}}, nil},
+
 		{"success", func(k *kstub) error { shimEntrypoint(k); return nil }, stub.Expect{Calls: []stub.Call{
 			call("getMsg", stub.ExpectArgs{}, nil, nil),
 			call("getLogger", stub.ExpectArgs{}, (*log.Logger)(nil), nil),
@@ -530,10 +614,12 @@ func TestShimEntrypoint(t *testing.T) {
 			call("New", stub.ExpectArgs{}, nil, nil),
 			call("closeReceive", stub.ExpectArgs{}, nil, nil),
 			call("notifyContext", stub.ExpectArgs{context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM}}, -1, nil),
+			call("setupPty", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerStart", stub.ExpectArgs{templateParams}, nil, nil),
 			call("containerServe", stub.ExpectArgs{templateParams}, nil, nil),
 			call("seccompLoad", stub.ExpectArgs{shimPreset, seccomp.AllowMultiarch}, nil, nil),
 			call("containerWait", stub.ExpectArgs{templateParams}, nil, nil),
+			call("restoreTerminal", stub.ExpectArgs{}, nil, nil),
 
 			// deferred
 			call("wKeepAlive", stub.ExpectArgs{}, nil, nil),
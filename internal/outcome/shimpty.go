@@ -0,0 +1,255 @@
+package outcome
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"hakurei.app/container"
+	"hakurei.app/message"
+)
+
+// asm-generic/ioctls.h, identical on all supported targets
+const (
+	_TCGETS = 0x5401
+	_TCSETS = 0x5402
+)
+
+// ptyDrainTimeout is the maximum duration to wait for output left in the master after the container terminates.
+const ptyDrainTimeout = 500 * time.Millisecond
+
+// ioctlPtr performs an ioctl request with a pointer argument.
+func ioctlPtr(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openPty allocates a new pseudo-terminal pair via /dev/ptmx.
+func openPty() (master, slave *os.File, err error) {
+	if master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0); err != nil {
+		return
+	}
+
+	var (
+		unlock int32
+		n      uint32
+	)
+	if err = ioctlPtr(master.Fd(), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		_ = master.Close()
+		return nil, nil, os.NewSyscallError("ioctl(TIOCSPTLCK)", err)
+	}
+	if err = ioctlPtr(master.Fd(), syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		_ = master.Close()
+		return nil, nil, os.NewSyscallError("ioctl(TIOCGPTN)", err)
+	}
+
+	if slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0); err != nil {
+		_ = master.Close()
+		return nil, nil, err
+	}
+	return
+}
+
+// makeRaw puts the terminal referred to by fd into raw mode, returning its previous state.
+func makeRaw(fd uintptr) (*syscall.Termios, error) {
+	var t syscall.Termios
+	if err := ioctlPtr(fd, _TCGETS, unsafe.Pointer(&t)); err != nil {
+		return nil, os.NewSyscallError("ioctl(TCGETS)", err)
+	}
+	prev := t
+
+	// equivalent to cfmakeraw(3)
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	if err := ioctlPtr(fd, _TCSETS, unsafe.Pointer(&t)); err != nil {
+		return nil, os.NewSyscallError("ioctl(TCSETS)", err)
+	}
+	return &prev, nil
+}
+
+// pollFd is struct pollfd.
+type pollFd struct {
+	fd      int32
+	events  int16
+	revents int16
+}
+
+// _POLLIN is identical on all supported targets.
+const _POLLIN = 0x1
+
+// relayInput copies data from fd to w until stop becomes readable or either side fails.
+// Reading is deferred until fd is readable, so no input is consumed once stop is readable.
+func relayInput(w io.Writer, fd, stop int) {
+	fds := [2]pollFd{{fd: int32(fd), events: _POLLIN}, {fd: int32(stop), events: _POLLIN}}
+	buf := make([]byte, 1<<12)
+	for {
+		fds[0].revents, fds[1].revents = 0, 0
+		if _, _, errno := syscall.Syscall6(syscall.SYS_PPOLL,
+			uintptr(unsafe.Pointer(&fds[0])), uintptr(len(fds)), 0, 0, 0, 0); errno != 0 {
+			if errno == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		if fds[1].revents != 0 {
+			return
+		}
+
+		n, err := syscall.Read(fd, buf)
+		if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+			continue
+		}
+		if n <= 0 {
+			return
+		}
+		if _, err = w.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// copyWinsize copies the window size of the terminal referred to by src to dst.
+func copyWinsize(dst, src uintptr) error {
+	var ws [4]uint16 // struct winsize
+	if err := ioctlPtr(src, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return os.NewSyscallError("ioctl(TIOCGWINSZ)", err)
+	}
+	if err := ioctlPtr(dst, syscall.TIOCSWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return os.NewSyscallError("ioctl(TIOCSWINSZ)", err)
+	}
+	return nil
+}
+
+// setupPty allocates a new pseudo-terminal pair and attaches its slave to the standard streams of the container.
+//
+// I/O is relayed between the master and the standard streams of the shim. If standard input of the shim is a
+// terminal, it is placed in raw mode, its window size is propagated on SIGWINCH, and job control signals
+// delivered to the shim are forwarded to the foreground process group of the new terminal.
+//
+// The returned function must be called once the container terminates or before the shim exits. It stops
+// relaying input, drains remaining output, restores the original terminal state and releases the pseudo-terminal.
+func setupPty(msg message.Msg, z *container.Container) (func(), error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return nil, err
+	}
+	// closing the write end stops the input relay
+	var stopInput, stopInputW *os.File
+	if stopInput, stopInputW, err = os.Pipe(); err != nil {
+		_, _ = master.Close(), slave.Close()
+		return nil, err
+	}
+	z.Stdin, z.Stdout, z.Stderr = slave, slave, slave
+
+	var prev *syscall.Termios
+	if container.Isatty(int(os.Stdin.Fd())) {
+		// the new terminal starts out with settings of the outer terminal
+		var t syscall.Termios
+		if err = ioctlPtr(os.Stdin.Fd(), _TCGETS, unsafe.Pointer(&t)); err == nil {
+			err = ioctlPtr(slave.Fd(), _TCSETS, unsafe.Pointer(&t))
+		}
+		if err != nil {
+			msg.Verbosef("cannot copy terminal attributes: %v", err)
+		}
+		if err = copyWinsize(slave.Fd(), os.Stdin.Fd()); err != nil {
+			msg.Verbose(err.Error())
+		}
+
+		if prev, err = makeRaw(os.Stdin.Fd()); err != nil {
+			_, _, _, _ = master.Close(), slave.Close(), stopInput.Close(), stopInputW.Close()
+			return nil, err
+		}
+	} else {
+		msg.Verbose("standard input is not a terminal, relaying without raw mode")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH, syscall.SIGHUP, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	go func() {
+		for s := range sig {
+			if s == syscall.SIGWINCH {
+				if prev != nil {
+					if err := copyWinsize(master.Fd(), os.Stdin.Fd()); err != nil {
+						msg.Verbose(err.Error())
+					}
+				}
+				continue
+			}
+
+			var pgrp int32
+			if err := ioctlPtr(master.Fd(), syscall.TIOCGPGRP, unsafe.Pointer(&pgrp)); err != nil {
+				msg.Verbosef("cannot forward %s: %v", s, os.NewSyscallError("ioctl(TIOCGPGRP)", err))
+				continue
+			}
+			msg.Verbosef("forwarding %s to process group %d", s, pgrp)
+			if err := syscall.Kill(-int(pgrp), s.(syscall.Signal)); err != nil {
+				msg.Verbosef("cannot forward %s: %v", s, err)
+			}
+		}
+	}()
+
+	// input read after the relay stops would be lost to the outer shell
+	inputDone := make(chan struct{})
+	go func() {
+		relayInput(master, int(os.Stdin.Fd()), int(stopInput.Fd()))
+		close(inputDone)
+	}()
+	done := make(chan struct{})
+	go func() {
+		// EIO is returned once all slave descriptors are closed
+		if _, err := io.Copy(os.Stdout, master); err != nil && !errors.Is(err, syscall.EIO) {
+			msg.Verbosef("cannot relay terminal output: %v", err)
+		}
+		close(done)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sig)
+			close(sig)
+
+			if err := stopInputW.Close(); err != nil {
+				msg.Verbose(err.Error())
+			}
+			<-inputDone
+			if err := stopInput.Close(); err != nil {
+				msg.Verbose(err.Error())
+			}
+
+			// all container processes are gone by now, closing the last slave descriptor ends the output relay
+			if err := slave.Close(); err != nil {
+				msg.Verbose(err.Error())
+			}
+			select {
+			case <-done:
+			case <-time.After(ptyDrainTimeout):
+				msg.Verbose("timed out draining terminal output")
+			}
+
+			if prev != nil {
+				if err := ioctlPtr(os.Stdin.Fd(), _TCSETS, unsafe.Pointer(prev)); err != nil {
+					msg.Verbosef("cannot restore terminal attributes: %v", err)
+				}
+			}
+			if err := master.Close(); err != nil {
+				msg.Verbose(err.Error())
+			}
+		})
+	}, nil
+}
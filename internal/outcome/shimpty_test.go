@@ -0,0 +1,53 @@
+package outcome
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRelayInput(t *testing.T) {
+	t.Parallel()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: error = %v", err)
+	}
+	t.Cleanup(func() { _, _ = r.Close(), w.Close() })
+	stopR, stopW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: error = %v", err)
+	}
+	t.Cleanup(func() { _ = stopR.Close() })
+
+	relayed := make(chanWriter, 1)
+	done := make(chan struct{})
+	go func() { relayInput(relayed, int(r.Fd()), int(stopR.Fd())); close(done) }()
+
+	if _, err = w.Write([]byte("relayed")); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	if got := <-relayed; got != "relayed" {
+		t.Errorf("relayInput: %q, want %q", got, "relayed")
+	}
+
+	if err = stopW.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+	<-done
+
+	// input arriving after the relay stops is left for the next reader
+	if _, err = w.Write([]byte("retained")); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	p := make([]byte, 1<<4)
+	if n, readErr := r.Read(p); readErr != nil {
+		t.Fatalf("Read: error = %v", readErr)
+	} else if string(p[:n]) != "retained" {
+		t.Errorf("Read: %q, want %q", string(p[:n]), "retained")
+	}
+}
+
+// chanWriter sends every write to the channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) { w <- string(p); return len(p), nil }
@@ -3,12 +3,41 @@ package outcome
 import (
 	"encoding/gob"
 	"fmt"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
 	"hakurei.app/internal/validate"
 )
 
+const (
+	// fallbackUsername is the name of the emulated user if [hst.ContainerConfig.Username] is not set.
+	fallbackUsername = "chronos"
+	// fakeRootName is the name of the emulated user and group under [hst.FFakeRoot].
+	fakeRootName = "root"
+)
+
+// isFakeRoot returns whether the emulated user is mapped to root according to flags.
+func isFakeRoot(flags hst.Flags) bool { return flags&hst.FFakeRoot != 0 && flags&hst.FMapRealUID == 0 }
+
+// subordinateName returns the name of the emulated account of a subordinate id.
+func subordinateName(id int) string {
+	if id == 0 {
+		return "root"
+	}
+	return "sub" + strconv.Itoa(id)
+}
+
+// mappedGroup is a supplementary group mapped to the same gid in the container user namespace.
+type mappedGroup struct {
+	// Group name in the init namespace, also used in the container.
+	Name string
+	// Group id in both the init namespace and the container user namespace.
+	Gid int
+}
+
 func init() { gob.Register(spAccountOp{}) }
 
 // spAccountOp sets up user account emulation inside the container.
@@ -25,14 +54,32 @@ func (s spAccountOp) toSystem(state *outcomeStateSys) error {
 	if state.Container.Username != "" && !validate.IsValidUsername(state.Container.Username) {
 		return newWithMessage(fmt.Sprintf("invalid user name %q", state.Container.Username))
 	}
+
+	if sub := state.Container.Subordinate; sub != nil {
+		if sub.Contains(state.Mapuid) || sub.Contains(state.Mapgid) {
+			return newWithMessage("subordinate ids overlap the emulated user")
+		}
+
+		username := state.Container.Username
+		if isFakeRoot(state.Container.Flags) {
+			username = fakeRootName
+		} else if username == "" {
+			username = fallbackUsername
+		}
+		for id := sub.Start; id < sub.Start+sub.Count; id++ {
+			if subordinateName(id) == username {
+				return newWithMessage(fmt.Sprintf("user name %q is reserved for subordinate id %d", username, id))
+			}
+		}
+	}
 	return nil
 }
 
 func (s spAccountOp) toContainer(state *outcomeStateParams) error {
-	const fallbackUsername = "chronos"
-
-	username := state.Container.Username
-	if username == "" {
+	username, groupname := state.Container.Username, "hakurei"
+	if isFakeRoot(state.Container.Flags) {
+		username, groupname = fakeRootName, fakeRootName
+	} else if username == "" {
 		username = fallbackUsername
 	}
 
@@ -41,16 +88,29 @@ func (s spAccountOp) toContainer(state *outcomeStateParams) error {
 	state.env["USER"] = username
 	state.env["SHELL"] = state.Container.Shell.String()
 
+	var passwd, group strings.Builder
+	passwd.WriteString(username + ":x:" +
+		state.mapuid.String() + ":" +
+		state.mapgid.String() +
+		":Hakurei:" +
+		state.Container.Home.String() + ":" +
+		state.Container.Shell.String() + "\n")
+	group.WriteString(groupname + ":x:" + state.mapgid.String() + ":\n")
+
+	if sub := state.Container.Subordinate; sub != nil {
+		for id := sub.Start; id < sub.Start+sub.Count; id++ {
+			name, ids := subordinateName(id), strconv.Itoa(id)
+			passwd.WriteString(name + ":x:" + ids + ":" + ids + ":Hakurei:" + fhs.Root + ":" + state.Container.Shell.String() + "\n")
+			group.WriteString(name + ":x:" + ids + ":\n")
+		}
+	}
+	for _, g := range state.Groups {
+		group.WriteString(g.Name + ":x:" + strconv.Itoa(g.Gid) + ":" + username + "\n")
+	}
+
 	state.params.
-		Place(fhs.AbsEtc.Append("passwd"),
-			[]byte(username+":x:"+
-				state.mapuid.String()+":"+
-				state.mapgid.String()+
-				":Hakurei:"+
-				state.Container.Home.String()+":"+
-				state.Container.Shell.String()+"\n")).
-		Place(fhs.AbsEtc.Append("group"),
-			[]byte("hakurei:x:"+state.mapgid.String()+":\n"))
+		Place(fhs.AbsEtc.Append("passwd"), []byte(passwd.String())).
+		Place(fhs.AbsEtc.Append("group"), []byte(group.String()))
 
 	return nil
 }
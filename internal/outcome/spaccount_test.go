@@ -35,6 +35,53 @@ func TestSpAccountOp(t *testing.T) {
 			Msg:  `invalid user name "9"`,
 		}, nil, nil, nil, nil, nil},
 
+		{"subordinate overlap", func(bool, bool) outcomeOp { return spAccountOp{} }, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Subordinate = &hst.SubordinateConfig{Start: 99, Count: 2}
+			return c
+		}, nil, []stub.Call{
+			// this op performs basic validation and does not make calls during toSystem
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  "subordinate ids overlap the emulated user",
+		}, nil, nil, nil, nil, nil},
+
+		{"subordinate reserved", func(bool, bool) outcomeOp { return spAccountOp{} }, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Username = "root"
+			c.Container.Subordinate = &hst.SubordinateConfig{Count: 1}
+			return c
+		}, nil, []stub.Call{
+			// this op performs basic validation and does not make calls during toSystem
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  `user name "root" is reserved for subordinate id 0`,
+		}, nil, nil, nil, nil, nil},
+
+		{"success subordinate", func(bool, bool) outcomeOp { return spAccountOp{} }, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Subordinate = &hst.SubordinateConfig{Count: 3}
+			return c
+		}, nil, []stub.Call{
+			// this op performs basic validation and does not make calls during toSystem
+		}, newI(), nil, nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Dir: config.Container.Home,
+			Ops: new(container.Ops).
+				Place(m("/etc/passwd"), []byte("chronos:x:1000:100:Hakurei:/data/data/org.chromium.Chromium:/run/current-system/sw/bin/zsh\n"+
+					"root:x:0:0:Hakurei:/:/run/current-system/sw/bin/zsh\n"+
+					"sub1:x:1:1:Hakurei:/:/run/current-system/sw/bin/zsh\n"+
+					"sub2:x:2:2:Hakurei:/:/run/current-system/sw/bin/zsh\n")).
+				Place(m("/etc/group"), []byte("hakurei:x:100:\nroot:x:0:\nsub1:x:1:\nsub2:x:2:\n")),
+		}, paramsWantEnv(config, map[string]string{
+			"HOME":  config.Container.Home.String(),
+			"USER":  config.Container.Username,
+			"SHELL": config.Container.Shell.String(),
+		}, nil), nil},
+
 		{"success fallback username", func(bool, bool) outcomeOp { return spAccountOp{} }, func() *hst.Config {
 			c := hst.Template()
 			c.Container.Username = ""
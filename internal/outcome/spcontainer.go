@@ -1,13 +1,17 @@
 package outcome
 
 import (
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"errors"
 	"io/fs"
+	"maps"
 	"os"
 	"path"
 	"slices"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"hakurei.app/container"
@@ -34,15 +38,55 @@ type spParamsOp struct {
 	Term string
 	// Whether $TERM is set, stored during toSystem.
 	TermSet bool
+	// Dependencies of a minimal root filesystem, stored during toSystem.
+	MinimalDependencies []*check.Absolute
+	// Host environment variables matching [hst.ContainerConfig.EnvPassthrough], stored during toSystem.
+	Passthrough map[string]string
 }
 
 func (s *spParamsOp) toSystem(state *outcomeStateSys) error {
+	if d := hst.TerminationDelay(state.Container.Termination); d > state.Shim.WaitDelay {
+		return newWithMessage("termination sequence of " + d.String() + " exceeds the maximum of " + state.Shim.WaitDelay.String())
+	}
+
 	s.Term, s.TermSet = state.k.lookupEnv("TERM")
 	state.sys.Ensure(state.sc.SharePath, 0711)
+
+	if len(state.Container.EnvPassthrough) > 0 {
+		s.Passthrough = make(map[string]string)
+		for _, kv := range state.k.environ() {
+			if key, value, ok := strings.Cut(kv, "="); ok && hst.MatchPassthrough(state.Container.EnvPassthrough, key) {
+				s.Passthrough[key] = value
+			}
+		}
+	}
+
+	if rootfs, _, _ := resolveRoot(state.Container); rootfs != nil {
+		if minimal, ok := rootfs.(*hst.FSMinimal); ok {
+			pathList, ok := state.Container.Env["PATH"]
+			if !ok {
+				if pathList, ok = s.Passthrough["PATH"]; !ok {
+					pathList = minimalDefaultPath
+				}
+			}
+			if deps, err := resolveMinimal(state.msg, state.k, minimal, pathList); err != nil {
+				return err
+			} else {
+				s.MinimalDependencies = deps
+			}
+		}
+	}
 	return nil
 }
 
 func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
+	for key, value := range s.Passthrough {
+		// explicitly configured variables take precedence
+		if _, ok := state.Container.Env[key]; !ok {
+			state.env[key] = value
+		}
+	}
+
 	// pass $TERM for proper terminal I/O in initial process
 	if s.TermSet {
 		state.env["TERM"] = s.Term
@@ -52,7 +96,9 @@ func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
 	const preallocateOpsCount = 1 << 5
 
 	state.params.Hostname = state.Container.Hostname
-	state.params.RetainSession = state.Container.Flags&hst.FTty != 0
+	// a terminal private to the container requires a new session
+	state.params.Ctty = state.Container.Flags&hst.FPty != 0
+	state.params.RetainSession = state.Container.Flags&hst.FTty != 0 && !state.params.Ctty
 	state.params.HostNet = state.Container.Flags&hst.FHostNet != 0
 	state.params.HostAbstract = state.Container.Flags&hst.FHostAbstract != 0
 
@@ -60,6 +106,20 @@ func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
 		return newWithMessage("invalid program path")
 	}
 	state.params.Path = state.Container.Path
+	if state.Container.PathDigest != "" {
+		if digest, err := hex.DecodeString(state.Container.PathDigest); err != nil || len(digest) != sha256.Size {
+			return newWithMessage("invalid program digest")
+		} else {
+			state.params.PathDigest = (*[sha256.Size]byte)(digest)
+		}
+	}
+	if state.Container.InterpreterDigest != "" {
+		if digest, err := hex.DecodeString(state.Container.InterpreterDigest); err != nil || len(digest) != sha256.Size {
+			return newWithMessage("invalid interpreter digest")
+		} else {
+			state.params.InterpreterDigest = (*[sha256.Size]byte)(digest)
+		}
+	}
 
 	if len(state.Container.Args) == 0 {
 		state.params.Args = []string{state.Container.Path.String()}
@@ -67,9 +127,49 @@ func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
 		state.params.Args = state.Container.Args
 	}
 
+	if len(state.Container.Programs) > 0 {
+		state.params.Programs = make([]container.Program, len(state.Container.Programs))
+		for i, p := range state.Container.Programs {
+			program := &state.params.Programs[i]
+			program.Path, program.Args, program.Dir = p.Path, p.Args, p.Dir
+			program.Backoff, program.Critical = p.Backoff, p.Critical
+
+			switch p.Restart {
+			case "", hst.RestartNever:
+				program.Restart = container.RestartNever
+			case hst.RestartOnFailure:
+				program.Restart = container.RestartOnFailure
+			case hst.RestartAlways:
+				program.Restart = container.RestartAlways
+
+			default:
+				return newWithMessage("invalid restart policy " + strconv.Quote(p.Restart))
+			}
+		}
+	}
+
 	// the container is canceled when shim is requested to exit or receives an interrupt or termination signal;
-	// this behaviour is implemented in the shim
-	state.params.ForwardCancel = state.Shim.WaitDelay > 0
+	// this behaviour is implemented in the shim, and is required for termination steps regardless of their waits
+	state.params.ForwardCancel = state.Shim.WaitDelay > 0 || len(state.Container.Termination) > 0
+
+	if state.Container.Time != nil {
+		state.params.Time = &container.TimeOffsets{
+			Monotonic: state.Container.Time.Monotonic,
+			Boottime:  state.Container.Time.Boottime,
+		}
+	}
+
+	if len(state.Container.Termination) > 0 {
+		state.params.Termination = make([]container.TerminationStep, len(state.Container.Termination))
+		for i := range state.Container.Termination {
+			step := &state.Container.Termination[i]
+			sig, ok := step.SignalValue()
+			if !ok {
+				return newWithMessage("unsupported termination signal " + strconv.Quote(step.Signal))
+			}
+			state.params.Termination[i] = container.TerminationStep{Signal: sig, Group: step.Group, Wait: step.Wait}
+		}
+	}
 
 	if state.Container.Flags&hst.FMultiarch != 0 {
 		state.params.SeccompFlags |= seccomp.AllowMultiarch
@@ -91,7 +191,11 @@ func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
 	if state.Container.Flags&hst.FMapRealUID != 0 {
 		state.params.Uid = state.Mapuid
 		state.params.Gid = state.Mapgid
+	} else if state.Container.Flags&hst.FFakeRoot != 0 {
+		state.params.FakeRoot = true
 	}
+	// written via hsu on behalf of the shim
+	state.params.MapExternal = state.Container.Subordinate != nil || len(state.Groups) > 0
 
 	{
 		state.as.AutoEtcPrefix = state.id.String()
@@ -103,6 +207,12 @@ func (s *spParamsOp) toContainer(state *outcomeStateParams) error {
 	rootfs, filesystem, _ := resolveRoot(state.Container)
 	state.filesystem = filesystem
 	if rootfs != nil {
+		if minimal, ok := rootfs.(*hst.FSMinimal); ok {
+			// dependencies are resolved in the init mount namespace
+			resolved := *minimal
+			resolved.Dependencies = s.MinimalDependencies
+			rootfs = &resolved
+		}
 		rootfs.Apply(&state.as)
 	}
 
@@ -287,16 +397,30 @@ func (s *spFilesystemOp) toContainer(state *outcomeStateParams) error {
 	}
 	state.params.Remount(fhs.AbsRoot, syscall.MS_RDONLY)
 
-	state.params.Env = make([]string, 0, len(state.env))
-	for key, value := range state.env {
-		// key validated early via hst
-		state.params.Env = append(state.params.Env, key+"="+value)
+	state.params.Env = environ(state.env)
+	for i := range state.params.Programs {
+		// supervised programs inherit params.Env unless they set any variables
+		if override := state.Container.Programs[i].Env; len(override) > 0 {
+			env := maps.Clone(state.env)
+			maps.Copy(env, override)
+			state.params.Programs[i].Env = environ(env)
+		}
 	}
-	slices.Sort(state.params.Env)
 
 	return nil
 }
 
+// environ returns the sorted environment described by env.
+func environ(env map[string]string) []string {
+	s := make([]string, 0, len(env))
+	for key, value := range env {
+		// key validated early via hst
+		s = append(s, key+"="+value)
+	}
+	slices.Sort(s)
+	return s
+}
+
 // resolveRoot handles the root filesystem special case for [hst.FilesystemConfig] and additionally resolves autoroot
 // as it requires special handling during path hiding.
 func resolveRoot(c *hst.ContainerConfig) (rootfs hst.FilesystemConfig, filesystem []hst.FilesystemConfigJSON, autoroot *hst.FSBind) {
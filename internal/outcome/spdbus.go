@@ -3,6 +3,7 @@ package outcome
 import (
 	"encoding/gob"
 
+	"hakurei.app/container/check"
 	"hakurei.app/container/fhs"
 	"hakurei.app/hst"
 	"hakurei.app/internal/acl"
@@ -11,7 +12,7 @@ import (
 
 func init() { gob.Register(new(spDBusOp)) }
 
-// spDBusOp maintains an xdg-dbus-proxy instance for the container.
+// spDBusOp maintains an xdg-dbus-proxy instance or built-in message bus proxies for the container.
 // Runs after spRuntimeOp.
 type spDBusOp struct {
 	// Whether to bind the system bus socket. Populated during toSystem.
@@ -23,9 +24,7 @@ func (s *spDBusOp) toSystem(state *outcomeStateSys) error {
 		return errNotEnabled
 	}
 
-	if state.sessionBus == nil {
-		state.sessionBus = dbus.NewConfig(state.appId, true, true)
-	}
+	state.populateSessionBus()
 
 	// downstream socket paths
 	sessionPath, systemPath := state.instance().Append("bus"), state.instance().Append("system_bus_socket")
@@ -33,7 +32,20 @@ func (s *spDBusOp) toSystem(state *outcomeStateSys) error {
 	var sessionBus, systemBus dbus.ProxyPair
 	sessionBus[0], systemBus[0] = state.k.dbusAddress()
 	sessionBus[1], systemBus[1] = sessionPath.String(), systemPath.String()
-	if err := state.sys.ProxyDBus(state.sessionBus, state.systemBus, sessionBus, systemBus); err != nil {
+
+	// messages logged by the proxy are recorded for hakurei dbus-log
+	var events *check.Absolute
+	if state.sessionBus.Log || (state.systemBus != nil && state.systemBus.Log) {
+		state.sys.Ensure(DBusLogDir(&state.sc), 0700)
+		events = DBusLogDir(&state.sc).Append(state.id.String())
+	}
+
+	if state.builtinDBusProxy {
+		state.sys.FilterDBus(dbus.SessionBus, state.sessionBus, sessionBus, logEvents(state.sessionBus, events))
+		if state.systemBus != nil {
+			state.sys.FilterDBus(dbus.SystemBus, state.systemBus, systemBus, logEvents(state.systemBus, events))
+		}
+	} else if err := state.sys.ProxyDBus(state.sessionBus, state.systemBus, sessionBus, systemBus, events, state.flatpakInfoData); err != nil {
 		return err
 	}
 
@@ -56,3 +68,18 @@ func (s *spDBusOp) toContainer(state *outcomeStateParams) error {
 	}
 	return nil
 }
+
+// populateSessionBus populates the session bus configuration with defaults if it is not set.
+func (state *outcomeStateSys) populateSessionBus() {
+	if state.sessionBus == nil {
+		state.sessionBus = dbus.NewConfig(state.appId, true, true)
+	}
+}
+
+// logEvents returns events if logging is enabled in config, and nil otherwise.
+func logEvents(config *hst.BusConfig, events *check.Absolute) *check.Absolute {
+	if !config.Log {
+		return nil
+	}
+	return events
+}
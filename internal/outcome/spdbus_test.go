@@ -16,6 +16,8 @@ import (
 
 func TestSpDBusOp(t *testing.T) {
 	config := hst.Template()
+	configLog := hst.Template()
+	configLog.SessionBus.Log = true
 
 	checkOpBehaviour(t, []opBehaviourTestCase{
 		{"not enabled", func(bool, bool) outcomeOp {
@@ -86,6 +88,7 @@ func TestSpDBusOp(t *testing.T) {
 				dbus.NewConfig(config.ID, true, true), nil,
 				dbus.ProxyPair{"unix:path=/run/user/1000/bus", wantInstancePrefix + "/bus"},
 				dbus.ProxyPair{"unix:path=/var/run/dbus/system_bus_socket", wantInstancePrefix + "/system_bus_socket"},
+				nil, nil,
 			); err != nil {
 				t.Fatalf("cannot prepare sys: %v", err)
 			}
@@ -168,6 +171,7 @@ func TestSpDBusOp(t *testing.T) {
 				config.SessionBus, config.SystemBus,
 				dbus.ProxyPair{"unix:path=/run/user/1000/bus", wantInstancePrefix + "/bus"},
 				dbus.ProxyPair{"unix:path=/var/run/dbus/system_bus_socket", wantInstancePrefix + "/system_bus_socket"},
+				nil, nil,
 			); err != nil {
 				t.Fatalf("cannot prepare sys: %v", err)
 			}
@@ -186,5 +190,73 @@ func TestSpDBusOp(t *testing.T) {
 			"DBUS_SESSION_BUS_ADDRESS": "unix:path=/run/user/1000/bus",
 			"DBUS_SYSTEM_BUS_ADDRESS":  "unix:path=/var/run/dbus/system_bus_socket",
 		}, nil), nil},
+
+		{"success builtin", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spDBusOp)
+			}
+			return &spDBusOp{ProxySystem: true}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.BuiltinDBusProxy = true
+			return c
+		}, nil, []stub.Call{
+			call("dbusAddress", stub.ExpectArgs{}, [2]string{
+				"unix:path=/run/user/1000/bus",
+				"unix:path=/var/run/dbus/system_bus_socket",
+			}, nil),
+		}, newI().
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			FilterDBus(dbus.SessionBus, config.SessionBus, dbus.ProxyPair{"unix:path=/run/user/1000/bus", wantInstancePrefix + "/bus"}, nil).
+			FilterDBus(dbus.SystemBus, config.SystemBus, dbus.ProxyPair{"unix:path=/var/run/dbus/system_bus_socket", wantInstancePrefix + "/system_bus_socket"}, nil).
+			UpdatePerm(m(wantInstancePrefix+"/bus"), acl.Read, acl.Write).
+			UpdatePerm(m(wantInstancePrefix+"/system_bus_socket"), acl.Read, acl.Write),
+			sysUsesInstance(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, &container.Params{
+				Ops: new(container.Ops).
+					Bind(m(wantInstancePrefix+"/bus"),
+						m("/run/user/1000/bus"), 0).
+					Bind(m(wantInstancePrefix+"/system_bus_socket"),
+						m("/var/run/dbus/system_bus_socket"), 0),
+			}, paramsWantEnv(config, map[string]string{
+				"DBUS_SESSION_BUS_ADDRESS": "unix:path=/run/user/1000/bus",
+				"DBUS_SYSTEM_BUS_ADDRESS":  "unix:path=/var/run/dbus/system_bus_socket",
+			}, nil), nil},
+		{"success builtin log", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spDBusOp)
+			}
+			return &spDBusOp{ProxySystem: true}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.BuiltinDBusProxy = true
+			c.SessionBus.Log = true
+			return c
+		}, nil, []stub.Call{
+			call("dbusAddress", stub.ExpectArgs{}, [2]string{
+				"unix:path=/run/user/1000/bus",
+				"unix:path=/var/run/dbus/system_bus_socket",
+			}, nil),
+		}, newI().
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			Ensure(m(container.Nonexistent+"/tmp/hakurei.0/dbus"), 0700).
+			FilterDBus(dbus.SessionBus, configLog.SessionBus, dbus.ProxyPair{"unix:path=/run/user/1000/bus", wantInstancePrefix + "/bus"},
+				m(container.Nonexistent+"/tmp/hakurei.0/dbus/"+wantAutoEtcPrefix)).
+			FilterDBus(dbus.SystemBus, config.SystemBus, dbus.ProxyPair{"unix:path=/var/run/dbus/system_bus_socket", wantInstancePrefix + "/system_bus_socket"}, nil).
+			UpdatePerm(m(wantInstancePrefix+"/bus"), acl.Read, acl.Write).
+			UpdatePerm(m(wantInstancePrefix+"/system_bus_socket"), acl.Read, acl.Write),
+			sysUsesInstance(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, &container.Params{
+				Ops: new(container.Ops).
+					Bind(m(wantInstancePrefix+"/bus"),
+						m("/run/user/1000/bus"), 0).
+					Bind(m(wantInstancePrefix+"/system_bus_socket"),
+						m("/var/run/dbus/system_bus_socket"), 0),
+			}, paramsWantEnv(config, map[string]string{
+				"DBUS_SESSION_BUS_ADDRESS": "unix:path=/run/user/1000/bus",
+				"DBUS_SYSTEM_BUS_ADDRESS":  "unix:path=/var/run/dbus/system_bus_socket",
+			}, nil), nil},
 	})
 }
@@ -0,0 +1,82 @@
+package outcome
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"strings"
+
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+)
+
+const (
+	// hostMachineID is the pathname to the host machine-id, keying the per-application machine-id.
+	hostMachineID = fhs.Etc + "machine-id"
+	// machineIDContext is prepended to data deriving a machine-id.
+	machineIDContext = "hakurei machine-id "
+)
+
+func init() { gob.Register(new(spEtcOp)) }
+
+// spEtcOp places files generated in /etc in place of their host counterparts.
+type spEtcOp struct {
+	// Generated machine-id as a lowercase hex string. Populated during toSystem.
+	MachineID string
+}
+
+func (s *spEtcOp) toSystem(state *outcomeStateSys) error {
+	if state.Container.Etc == nil {
+		return errNotEnabled
+	}
+
+	var id [16]byte
+	switch state.Container.Etc.MachineID {
+	case hst.MachineIDInstance:
+		instanceID := state.id.unwrap()
+		sum := sha256.Sum256(append([]byte(machineIDContext), instanceID[:]...))
+		copy(id[:], sum[:])
+
+	default: // hst.MachineIDApp, checked by Validate
+		// 32 hexadecimal characters and a trailing newline
+		var key [33]byte
+		n, err := loadFile(state.msg, state.k, "host machine-id", hostMachineID, key[:])
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, bytes.TrimSpace(key[:n]))
+		mac.Write([]byte(machineIDContext + state.identity.String()))
+		copy(id[:], mac.Sum(nil))
+	}
+
+	// formatted as a version 4 variant 1 UUID, as is done by systemd
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	s.MachineID = hex.EncodeToString(id[:])
+	return nil
+}
+
+func (s *spEtcOp) toContainer(state *outcomeStateParams) error {
+	hostname := state.Container.Hostname
+
+	hosts := "127.0.0.1\tlocalhost\n::1\tlocalhost\n"
+	if hostname != "" {
+		hosts += "127.0.1.1\t" + hostname + "\n"
+		state.params.Place(fhs.AbsEtc.Append("hostname"), []byte(hostname+"\n"))
+	}
+	state.params.Place(fhs.AbsEtc.Append("hosts"), []byte(hosts))
+
+	// nameservers on the host are unreachable from a new net namespace
+	if nameservers := state.Container.Etc.Nameservers; len(nameservers) > 0 || state.Container.Flags&hst.FHostNet == 0 {
+		var resolv strings.Builder
+		for _, ns := range nameservers {
+			resolv.WriteString("nameserver " + ns + "\n")
+		}
+		state.params.Place(fhs.AbsEtc.Append("resolv.conf"), []byte(resolv.String()))
+	}
+
+	state.params.Place(fhs.AbsEtc.Append("machine-id"), []byte(s.MachineID+"\n"))
+	return nil
+}
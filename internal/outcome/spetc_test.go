@@ -0,0 +1,106 @@
+package outcome
+
+import (
+	"bytes"
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/container/fhs"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+)
+
+func TestSpEtcOp(t *testing.T) {
+	t.Parallel()
+
+	const sampleMachineID = "b08dfa6083e7567a1921a715000001fb\n"
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return new(spEtcOp)
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"loadFile", func(bool, bool) outcomeOp {
+			return new(spEtcOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Etc = new(hst.EtcConfig)
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/etc/machine-id"}, &stubFi{isDir: false, size: 33}, nil),
+			call("verbosef", stub.ExpectArgs{"loading %d bytes from %q", []any{33, "/etc/machine-id"}}, nil, nil),
+			call("open", stub.ExpectArgs{"/etc/machine-id"}, (*stubOsFile)(nil), stub.UniqueError(0)),
+		}, nil, nil, &hst.AppError{
+			Step: "open host machine-id",
+			Err:  stub.UniqueError(0),
+		}, nil, nil, nil, nil, nil},
+
+		{"app", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spEtcOp)
+			}
+			return &spEtcOp{MachineID: "e365ba9c6573446c9004739222bcb826"}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Etc = &hst.EtcConfig{MachineID: hst.MachineIDApp}
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/etc/machine-id"}, &stubFi{isDir: false, size: 33}, nil),
+			call("verbosef", stub.ExpectArgs{"loading %d bytes from %q", []any{33, "/etc/machine-id"}}, nil, nil),
+			call("open", stub.ExpectArgs{"/etc/machine-id"}, &stubOsFile{Reader: bytes.NewReader([]byte(sampleMachineID))}, nil),
+		}, newI(), nil, nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsEtc.Append("hostname"), []byte("localhost\n")).
+				Place(fhs.AbsEtc.Append("hosts"), []byte("127.0.0.1\tlocalhost\n::1\tlocalhost\n127.0.1.1\tlocalhost\n")).
+				Place(fhs.AbsEtc.Append("machine-id"), []byte("e365ba9c6573446c9004739222bcb826\n")),
+		}, nil, nil},
+
+		{"instance", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spEtcOp)
+			}
+			return &spEtcOp{MachineID: "2af12a4513af484295057fc951503f32"}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Hostname = ""
+			c.Container.Etc = &hst.EtcConfig{
+				Nameservers: []string{"9.9.9.9", "2620:fe::fe"},
+				MachineID:   hst.MachineIDInstance,
+			}
+			return c
+		}, nil, []stub.Call{
+			// the instance machine-id does not depend on the host
+		}, newI(), nil, nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsEtc.Append("hosts"), []byte("127.0.0.1\tlocalhost\n::1\tlocalhost\n")).
+				Place(fhs.AbsEtc.Append("resolv.conf"), []byte("nameserver 9.9.9.9\nnameserver 2620:fe::fe\n")).
+				Place(fhs.AbsEtc.Append("machine-id"), []byte("2af12a4513af484295057fc951503f32\n")),
+		}, nil, nil},
+
+		{"network-less", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spEtcOp)
+			}
+			return &spEtcOp{MachineID: "2af12a4513af484295057fc951503f32"}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Container.Flags &^= hst.FHostNet
+			c.Container.Etc = &hst.EtcConfig{MachineID: hst.MachineIDInstance}
+			return c
+		}, nil, []stub.Call{
+			// the instance machine-id does not depend on the host
+		}, newI(), nil, nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsEtc.Append("hostname"), []byte("localhost\n")).
+				Place(fhs.AbsEtc.Append("hosts"), []byte("127.0.0.1\tlocalhost\n::1\tlocalhost\n127.0.1.1\tlocalhost\n")).
+				Place(fhs.AbsEtc.Append("resolv.conf"), []byte{}).
+				Place(fhs.AbsEtc.Append("machine-id"), []byte("2af12a4513af484295057fc951503f32\n")),
+		}, nil, nil},
+	})
+}
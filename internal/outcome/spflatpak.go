@@ -0,0 +1,142 @@
+package outcome
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+	"hakurei.app/container/std"
+	"hakurei.app/hst"
+	"hakurei.app/internal/info"
+)
+
+// flatpakInfoName is the name of the file identifying a flatpak sandbox at the container root.
+const flatpakInfoName = ".flatpak-info"
+
+func init() { gob.Register(new(spFlatpakOp)) }
+
+// spFlatpakOp places a generated .flatpak-info at the container root, indicating a sandbox to its
+// programs, and hands the same contents to spDBusOp to place in the container of xdg-dbus-proxy.
+// Clients are identified to xdg-desktop-portal by their peer process on the bus, which is the proxy.
+// The per-application view of the document portal is bound if it is accessible to the container.
+// Runs after spRuntimeOp and before spDBusOp.
+type spFlatpakOp struct {
+	// Generated .flatpak-info contents. Populated during toSystem.
+	Info string
+	// Per-application view of the document portal, nil if not accessible. Populated during toSystem.
+	Documents *check.Absolute
+}
+
+func (s *spFlatpakOp) toSystem(state *outcomeStateSys) error {
+	if !state.flatpakInfo {
+		return errNotEnabled
+	}
+
+	var sessionBus, systemBus *hst.BusConfig
+	if state.et&hst.EDBus != 0 {
+		if state.builtinDBusProxy {
+			// the built-in proxy runs in the priv-side process, which cannot carry this identity
+			return newWithMessage("flatpak_info is not supported with the built-in D-Bus proxy")
+		}
+		state.populateSessionBus()
+		sessionBus, systemBus = state.sessionBus, state.systemBus
+	}
+
+	appId := state.appId
+	if appId == "" {
+		// use instance ID in case app id is not set
+		appId = "app.hakurei." + state.id.String()
+	}
+
+	var buf strings.Builder
+	buf.WriteString("[Application]\n")
+	buf.WriteString("name=" + appId + "\n")
+	buf.WriteString("runtime=runtime/app.hakurei/" + flatpakArch() + "/" + info.Version() + "\n")
+	buf.WriteString("\n[Instance]\n")
+	buf.WriteString("instance-id=" + state.id.String() + "\n")
+	buf.WriteString("session-bus-proxy=" + strconv.FormatBool(sessionBus != nil && sessionBus.Filter) + "\n")
+	buf.WriteString("system-bus-proxy=" + strconv.FormatBool(systemBus != nil && systemBus.Filter) + "\n")
+	writeBusPolicy(&buf, "Session Bus Policy", sessionBus)
+	writeBusPolicy(&buf, "System Bus Policy", systemBus)
+	s.Info = buf.String()
+
+	if sessionBus != nil {
+		state.flatpakInfoData = []byte(s.Info)
+	}
+
+	// the document portal creates views for any application on lookup, which are only
+	// accessible to the user running the portal as they are not mounted with allow_other
+	documents := state.sc.RuntimePath.Append("doc", "by-app", appId)
+	if fi, err := state.k.stat(documents.String()); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			state.msg.Verbosef("cannot access document portal: %v", err)
+		}
+	} else if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != state.uid.unwrap() {
+		state.msg.Verbosef("document portal at %s is not accessible to the container", documents)
+	} else {
+		s.Documents = documents
+	}
+	return nil
+}
+
+func (s *spFlatpakOp) toContainer(state *outcomeStateParams) error {
+	state.params.Place(fhs.AbsRoot.Append(flatpakInfoName), []byte(s.Info))
+	if s.Documents != nil {
+		state.params.Bind(s.Documents, state.runtimeDir.Append("doc"), std.BindWritable|std.BindOptional)
+	}
+	return nil
+}
+
+// writeBusPolicy writes a bus policy group to buf in the format of .flatpak-info.
+// Call and broadcast rules have no representation in this format and are omitted.
+func writeBusPolicy(buf *strings.Builder, group string, c *hst.BusConfig) {
+	if c == nil || !c.Filter {
+		return
+	}
+
+	policy := make(map[string]string, len(c.See)+len(c.Talk)+len(c.Own))
+	for _, name := range c.See {
+		policy[name] = "see"
+	}
+	for _, name := range c.Talk {
+		policy[name] = "talk"
+	}
+	for _, name := range c.Own {
+		policy[name] = "own"
+	}
+	if len(policy) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(policy))
+	for name := range policy {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	buf.WriteString("\n[" + group + "]\n")
+	for _, name := range names {
+		buf.WriteString(name + "=" + policy[name] + "\n")
+	}
+}
+
+// flatpakArch returns the name flatpak uses for [runtime.GOARCH].
+func flatpakArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i386"
+	default:
+		return runtime.GOARCH
+	}
+}
@@ -0,0 +1,176 @@
+package outcome
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/container/fhs"
+	"hakurei.app/container/std"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/info"
+)
+
+func TestSpFlatpakOp(t *testing.T) {
+	t.Parallel()
+
+	sampleInfo := `[Application]
+name=org.chromium.Chromium
+runtime=runtime/app.hakurei/` + flatpakArch() + "/" + info.Version() + `
+
+[Instance]
+instance-id=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+session-bus-proxy=true
+system-bus-proxy=true
+
+[Session Bus Policy]
+org.chromium.Chromium.*=own
+org.freedesktop.FileManager1=talk
+org.freedesktop.Notifications=talk
+org.freedesktop.ScreenSaver=talk
+org.freedesktop.secrets=talk
+org.gnome.SessionManager=talk
+org.kde.kwalletd5=talk
+org.kde.kwalletd6=talk
+org.mpris.MediaPlayer2.chromium.*=own
+org.mpris.MediaPlayer2.org.chromium.Chromium.*=own
+
+[System Bus Policy]
+org.bluez=talk
+org.freedesktop.Avahi=talk
+org.freedesktop.UPower=talk
+`
+	sampleInfoNoDBus := `[Application]
+name=app.hakurei.aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+runtime=runtime/app.hakurei/` + flatpakArch() + "/" + info.Version() + `
+
+[Instance]
+instance-id=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+session-bus-proxy=false
+system-bus-proxy=false
+`
+
+	const documentsPath = wantRuntimePath + "/doc/by-app/org.chromium.Chromium"
+
+	// checkInfoData returns an extraCheckSysFunc checking the contents handed to spDBusOp.
+	checkInfoData := func(want string) extraCheckSysFunc {
+		return func(t *testing.T, state *outcomeStateSys) {
+			if got := string(state.flatpakInfoData); got != want {
+				t.Errorf("flatpakInfoData: %q, want %q", got, want)
+			}
+		}
+	}
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return new(spFlatpakOp)
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"builtin dbus proxy", func(bool, bool) outcomeOp {
+			return new(spFlatpakOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.FlatpakInfo = true
+			c.BuiltinDBusProxy = true
+			return c
+		}, nil, nil, nil, nil, newWithMessage("flatpak_info is not supported with the built-in D-Bus proxy"), nil, nil, nil, nil, nil},
+
+		{"success", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spFlatpakOp)
+			}
+			return &spFlatpakOp{Info: sampleInfo}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.FlatpakInfo = true
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{documentsPath}, (*stubFi)(nil), os.ErrNotExist),
+		}, newI(), checkInfoData(sampleInfo), nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsRoot.Append(".flatpak-info"), []byte(sampleInfo)),
+		}, nil, nil},
+
+		{"success documents", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spFlatpakOp)
+			}
+			return &spFlatpakOp{Info: sampleInfo, Documents: m(documentsPath)}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.FlatpakInfo = true
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{documentsPath}, &stubFi{isDir: true, sys: &syscall.Stat_t{Uid: hst.ToUser[uint32](0, 9)}}, nil),
+		}, newI(), checkInfoData(sampleInfo), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsRoot.Append(".flatpak-info"), []byte(sampleInfo)).
+				Bind(m(documentsPath), m("/run/user/1000/doc"), std.BindWritable|std.BindOptional),
+		}, nil, nil},
+
+		{"success documents foreign", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spFlatpakOp)
+			}
+			return &spFlatpakOp{Info: sampleInfo}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.FlatpakInfo = true
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{documentsPath}, &stubFi{isDir: true, sys: &syscall.Stat_t{Uid: 1000}}, nil),
+			call("verbosef", stub.ExpectArgs{"document portal at %s is not accessible to the container", []any{m(documentsPath)}}, nil, nil),
+		}, newI(), checkInfoData(sampleInfo), nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsRoot.Append(".flatpak-info"), []byte(sampleInfo)),
+		}, nil, nil},
+
+		{"success documents inaccessible", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spFlatpakOp)
+			}
+			return &spFlatpakOp{Info: sampleInfo}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.FlatpakInfo = true
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{documentsPath}, (*stubFi)(nil), os.ErrPermission),
+			call("verbosef", stub.ExpectArgs{"cannot access document portal: %v", []any{os.ErrPermission}}, nil, nil),
+		}, newI(), checkInfoData(sampleInfo), nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsRoot.Append(".flatpak-info"), []byte(sampleInfo)),
+		}, nil, nil},
+
+		{"success no dbus", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spFlatpakOp)
+			}
+			return &spFlatpakOp{Info: sampleInfoNoDBus}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.ID = ""
+			*c.Enablements = 0
+			c.FlatpakInfo = true
+			c.BuiltinDBusProxy = true
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{wantRuntimePath + "/doc/by-app/app.hakurei.aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, (*stubFi)(nil), os.ErrNotExist),
+		}, newI(), checkInfoData(""), nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Place(fhs.AbsRoot.Append(".flatpak-info"), []byte(sampleInfoNoDBus)),
+		}, nil, nil},
+	})
+}
@@ -0,0 +1,280 @@
+package outcome
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+)
+
+const (
+	// hostLocaltime is the pathname to the host timezone file.
+	hostLocaltime = fhs.Etc + "localtime"
+	// hostFonts is the pathname to the host fontconfig configuration directory.
+	hostFonts = fhs.Etc + "fonts"
+	// nixStore is the pathname to the Nix store, holding every target of a NixOS profile.
+	nixStore = "/nix/store/"
+	// localtimeSizeMax is the maximum size of a TZif file accepted from the host.
+	localtimeSizeMax = 1 << 16
+)
+
+var (
+	// integrationEnv are names of variables passed through as is.
+	integrationEnv = []string{
+		"LANG",
+		"LANGUAGE",
+		"LC_ALL",
+		"LC_ADDRESS",
+		"LC_COLLATE",
+		"LC_CTYPE",
+		"LC_IDENTIFICATION",
+		"LC_MEASUREMENT",
+		"LC_MESSAGES",
+		"LC_MONETARY",
+		"LC_NAME",
+		"LC_NUMERIC",
+		"LC_PAPER",
+		"LC_TELEPHONE",
+		"LC_TIME",
+		"TZ",
+		"XCURSOR_THEME",
+		"XCURSOR_SIZE",
+	}
+
+	// integrationEnvPath are names of variables holding a pathname made available in the container.
+	// Values are replaced with the resolved pathname.
+	integrationEnvPath = []string{
+		"TZDIR",
+		"LOCALE_ARCHIVE",
+		"FONTCONFIG_FILE",
+	}
+
+	// integrationPaths are pathnames made available in the container if they exist on the host.
+	integrationPaths = []string{
+		// zoneinfo and locales
+		fhs.Usr + "share/zoneinfo",
+		fhs.Usr + "lib/locale",
+
+		// fonts and their caches
+		fhs.Usr + "share/fonts",
+		fhs.Usr + "local/share/fonts",
+		fhs.Usr + "share/fontconfig",
+		fhs.Var + "cache/fontconfig",
+
+		// icon, cursor and widget themes
+		fhs.Usr + "share/icons",
+		fhs.Usr + "share/themes",
+
+		// NixOS
+		fhs.Run + "current-system/sw/share/X11/fonts",
+		fhs.Run + "current-system/sw/share/icons",
+		fhs.Run + "current-system/sw/share/themes",
+	}
+)
+
+func init() { gob.Register(new(spIntegrationOp)) }
+
+// spIntegrationOp exports host locale, timezone, font and theme resources to the container.
+type spIntegrationOp struct {
+	// Environment variables passed through to the container. Populated during toSystem.
+	Env map[string]string
+	// Resolved host pathnames bound read-only at the same pathname. Populated during toSystem.
+	Paths []*check.Absolute
+	// Resolved host fontconfig configuration directory bound at /etc/fonts, nil if it is already
+	// present in /etc. Populated during toSystem.
+	Fonts *check.Absolute
+	// Contents of host /etc/localtime, nil if absent. Populated during toSystem.
+	Localtime []byte
+}
+
+func (s *spIntegrationOp) toSystem(state *outcomeStateSys) error {
+	if state.et&hst.EIntegration == 0 {
+		return errNotEnabled
+	}
+
+	s.Env = make(map[string]string, len(integrationEnv)+len(integrationEnvPath)+1)
+	for _, key := range integrationEnv {
+		if value, ok := state.k.lookupEnv(key); ok {
+			s.Env[key] = value
+		}
+	}
+
+	for _, key := range integrationEnvPath {
+		if value, ok := state.k.lookupEnv(key); ok && path.IsAbs(value) {
+			if p, err := s.resolve(state, value, key == "FONTCONFIG_FILE"); err != nil {
+				return err
+			} else if p != "" {
+				s.Env[key] = p
+			} else {
+				s.Env[key] = value
+			}
+		}
+	}
+
+	if value, ok := state.k.lookupEnv("XCURSOR_PATH"); ok {
+		var cursorPath []string
+		for _, v := range strings.Split(value, ":") {
+			if !path.IsAbs(v) {
+				continue
+			}
+			if p, err := s.resolve(state, v, false); err != nil {
+				return err
+			} else if p != "" {
+				cursorPath = append(cursorPath, p)
+			}
+		}
+		if len(cursorPath) > 0 {
+			s.Env["XCURSOR_PATH"] = strings.Join(cursorPath, ":")
+		}
+	}
+
+	for _, pathname := range integrationPaths {
+		if _, err := s.resolve(state, pathname, false); err != nil {
+			return err
+		}
+	}
+
+	if p, err := state.k.evalSymlinks(hostFonts); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return &hst.AppError{Step: "resolve integration path " + strconv.Quote(hostFonts), Err: err}
+		}
+		state.msg.Verbosef("integration path %q does not exist", hostFonts)
+	} else if p != hostFonts {
+		if s.Fonts, err = check.NewAbs(p); err != nil {
+			return &hst.AppError{Step: "resolve integration path " + strconv.Quote(hostFonts), Err: err}
+		}
+	}
+
+	check.SortAbs(s.Paths)
+	s.Paths = check.CompactAbs(s.Paths)
+
+	// entries of NixOS profiles and generated configuration are symlinks to other store paths
+	for _, a := range append(slices.Clone(s.Paths), s.Fonts) {
+		if a != nil && storeRoot(a.String()) != nil {
+			if err := s.resolveStore(state, a.String()); err != nil {
+				return err
+			}
+		}
+	}
+	check.SortAbs(s.Paths)
+	s.Paths = check.CompactAbs(s.Paths)
+
+	if f, err := state.k.open(hostLocaltime); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return &hst.AppError{Step: "open host localtime", Err: err}
+		}
+		state.msg.Verbosef("host localtime %q does not exist", hostLocaltime)
+	} else {
+		s.Localtime, err = io.ReadAll(io.LimitReader(f, localtimeSizeMax))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return &hst.AppError{Step: "read host localtime", Err: err}
+		}
+	}
+
+	return nil
+}
+
+// resolve evaluates symlinks in pathname and arranges for the result to be bound in the container.
+// If parent is true, the parent directory of the result is bound instead. An empty string is returned
+// if pathname does not exist, or if it resolves to a file in /etc which is expected to already be present.
+func (s *spIntegrationOp) resolve(state *outcomeStateSys, pathname string, parent bool) (string, error) {
+	p, err := state.k.evalSymlinks(pathname)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", &hst.AppError{Step: "resolve integration path " + strconv.Quote(pathname), Err: err}
+		}
+		state.msg.Verbosef("integration path %q does not exist", pathname)
+		return "", nil
+	}
+	if p == fhs.Etc[:len(fhs.Etc)-1] || strings.HasPrefix(p, fhs.Etc) {
+		return "", nil
+	}
+
+	a, err := check.NewAbs(p)
+	if err != nil {
+		return "", &hst.AppError{Step: "resolve integration path " + strconv.Quote(pathname), Err: err}
+	}
+	if parent {
+		s.Paths = append(s.Paths, a.Dir())
+	} else {
+		s.Paths = append(s.Paths, a)
+	}
+	return p, nil
+}
+
+// storeRoot returns the store path holding pathname, or nil if pathname is not in the Nix store.
+func storeRoot(pathname string) *check.Absolute {
+	name, ok := strings.CutPrefix(pathname, nixStore)
+	if !ok {
+		return nil
+	}
+	if name, _, _ = strings.Cut(name, "/"); name == "" || name == "." || name == ".." {
+		return nil
+	}
+	return check.MustAbs(nixStore + name)
+}
+
+// resolveStore arranges for every store path targeted by a symlink within pathname to be bound in
+// the container. Directories are descended into, while symlinks are never followed past their target.
+func (s *spIntegrationOp) resolveStore(state *outcomeStateSys, pathname string) error {
+	entries, err := state.k.readdir(pathname)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTDIR) {
+			return nil
+		}
+		return &hst.AppError{Step: "read integration directory " + strconv.Quote(pathname), Err: err}
+	}
+
+	root := storeRoot(pathname)
+	for _, entry := range entries {
+		name := path.Join(pathname, entry.Name())
+		switch entry.Type() {
+		case fs.ModeDir:
+			if err = s.resolveStore(state, name); err != nil {
+				return err
+			}
+
+		case fs.ModeSymlink:
+			if p, err := state.k.evalSymlinks(name); err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					return &hst.AppError{Step: "resolve integration path " + strconv.Quote(name), Err: err}
+				}
+				state.msg.Verbosef("integration path %q is a dangling symlink", name)
+			} else if target := storeRoot(p); target != nil && !target.Is(root) {
+				s.Paths = append(s.Paths, target)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *spIntegrationOp) toContainer(state *outcomeStateParams) error {
+	for key, value := range s.Env {
+		// explicitly configured variables take precedence
+		if _, ok := state.Container.Env[key]; !ok {
+			state.env[key] = value
+		}
+	}
+	for _, a := range s.Paths {
+		state.params.Bind(a, a, 0)
+	}
+	if s.Fonts != nil {
+		state.params.Bind(s.Fonts, fhs.AbsEtc.Append("fonts"), 0)
+	}
+	if s.Localtime != nil {
+		state.params.Place(fhs.AbsEtc.Append("localtime"), s.Localtime)
+	}
+	return nil
+}
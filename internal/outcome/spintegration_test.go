@@ -0,0 +1,226 @@
+package outcome
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/container/fhs"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+)
+
+func TestSpIntegrationOp(t *testing.T) {
+	t.Parallel()
+	config := hst.Template()
+
+	sampleLocaltime := []byte("TZif2\x00\x00\x00")
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return new(spIntegrationOp)
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"resolve", func(bool, bool) outcomeOp {
+			return new(spIntegrationOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			*c.Enablements |= hst.Enablements(hst.EIntegration)
+			return c
+		}, nil, append(integrationEnvCalls(nil),
+			call("lookupEnv", stub.ExpectArgs{"TZDIR"}, "/etc/zoneinfo", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/zoneinfo"}, "", stub.UniqueError(0)),
+		), nil, nil, &hst.AppError{
+			Step: `resolve integration path "/etc/zoneinfo"`,
+			Err:  stub.UniqueError(0),
+		}, nil, nil, nil, nil, nil},
+
+		{"localtime", func(bool, bool) outcomeOp {
+			return new(spIntegrationOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			*c.Enablements |= hst.Enablements(hst.EIntegration)
+			return c
+		}, nil, append(append(append(integrationEnvCalls(nil),
+			call("lookupEnv", stub.ExpectArgs{"TZDIR"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"LOCALE_ARCHIVE"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"FONTCONFIG_FILE"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"XCURSOR_PATH"}, nil, nil),
+		), integrationPathsCalls(nil)...),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/fonts"}, "/etc/fonts", nil),
+			call("open", stub.ExpectArgs{"/etc/localtime"}, (*stubOsFile)(nil), stub.UniqueError(1)),
+		), nil, nil, &hst.AppError{
+			Step: "open host localtime",
+			Err:  stub.UniqueError(1),
+		}, nil, nil, nil, nil, nil},
+
+		{"readdir", func(bool, bool) outcomeOp {
+			return new(spIntegrationOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			*c.Enablements |= hst.Enablements(hst.EIntegration)
+			return c
+		}, nil, append(append(append(integrationEnvCalls(nil),
+			call("lookupEnv", stub.ExpectArgs{"TZDIR"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"LOCALE_ARCHIVE"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"FONTCONFIG_FILE"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"XCURSOR_PATH"}, nil, nil),
+		), integrationPathsCalls(map[string]string{
+			"/run/current-system/sw/share/icons": "/nix/store/system-path/share/icons",
+		})...),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/fonts"}, "", os.ErrNotExist),
+			call("verbosef", stub.ExpectArgs{"integration path %q does not exist", []any{"/etc/fonts"}}, nil, nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/system-path/share/icons"}, []os.DirEntry(nil), stub.UniqueError(2)),
+		), nil, nil, &hst.AppError{
+			Step: `read integration directory "/nix/store/system-path/share/icons"`,
+			Err:  stub.UniqueError(2),
+		}, nil, nil, nil, nil, nil},
+
+		{"success", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spIntegrationOp)
+			}
+			return &spIntegrationOp{
+				Env: map[string]string{
+					"LANG":            "en_GB.UTF-8",
+					"LC_TIME":         "en_DK.UTF-8",
+					"XCURSOR_THEME":   "Adwaita",
+					"TZDIR":           "/nix/store/tzdata/share/zoneinfo",
+					"LOCALE_ARCHIVE":  "/nix/store/glibc-locales/lib/locale/locale-archive",
+					"FONTCONFIG_FILE": "/etc/fonts/fonts.conf",
+					"XCURSOR_PATH":    "/nix/store/system-path/share/icons",
+				},
+				Paths: ms(
+					"/nix/store/font-misc",
+					"/nix/store/fontconfig-conf",
+					"/nix/store/glibc-locales/lib/locale/locale-archive",
+					"/nix/store/hicolor-icon-theme",
+					"/nix/store/system-path/share/X11/fonts",
+					"/nix/store/system-path/share/icons",
+					"/nix/store/tzdata/share/zoneinfo",
+					"/usr/share/fonts",
+					"/usr/share/zoneinfo",
+				),
+				Fonts:     m("/nix/store/etc/etc/fonts"),
+				Localtime: sampleLocaltime,
+			}
+		}, func() *hst.Config {
+			c := hst.Template()
+			*c.Enablements |= hst.Enablements(hst.EIntegration)
+			return c
+		}, nil, append(append(append(integrationEnvCalls(map[string]string{
+			"LANG":          "en_GB.UTF-8",
+			"LC_TIME":       "en_DK.UTF-8",
+			"XCURSOR_THEME": "Adwaita",
+		}),
+			call("lookupEnv", stub.ExpectArgs{"TZDIR"}, "/etc/zoneinfo", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/zoneinfo"}, "/nix/store/tzdata/share/zoneinfo", nil),
+			call("lookupEnv", stub.ExpectArgs{"LOCALE_ARCHIVE"}, "/run/current-system/sw/lib/locale/locale-archive", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/run/current-system/sw/lib/locale/locale-archive"}, "/nix/store/glibc-locales/lib/locale/locale-archive", nil),
+			call("lookupEnv", stub.ExpectArgs{"FONTCONFIG_FILE"}, "/etc/fonts/fonts.conf", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/fonts/fonts.conf"}, "/etc/fonts/fonts.conf", nil),
+			call("lookupEnv", stub.ExpectArgs{"XCURSOR_PATH"}, "~/.icons:/run/current-system/sw/share/icons:/home/ophestra/.local/share/icons", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/run/current-system/sw/share/icons"}, "/nix/store/system-path/share/icons", nil),
+			call("evalSymlinks", stub.ExpectArgs{"/home/ophestra/.local/share/icons"}, "", os.ErrNotExist),
+			call("verbosef", stub.ExpectArgs{"integration path %q does not exist", []any{"/home/ophestra/.local/share/icons"}}, nil, nil),
+		), integrationPathsCalls(map[string]string{
+			"/usr/share/zoneinfo":                    "/usr/share/zoneinfo",
+			"/usr/share/fonts":                       "/usr/share/fonts",
+			"/run/current-system/sw/share/X11/fonts": "/nix/store/system-path/share/X11/fonts",
+			"/run/current-system/sw/share/icons":     "/nix/store/system-path/share/icons",
+		})...),
+			call("evalSymlinks", stub.ExpectArgs{"/etc/fonts"}, "/nix/store/etc/etc/fonts", nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/glibc-locales/lib/locale/locale-archive"}, []os.DirEntry(nil), syscall.ENOTDIR),
+			call("readdir", stub.ExpectArgs{"/nix/store/system-path/share/X11/fonts"}, []os.DirEntry{
+				stubDentry{"misc", fs.ModeSymlink},
+			}, nil),
+			call("evalSymlinks", stub.ExpectArgs{"/nix/store/system-path/share/X11/fonts/misc"}, "/nix/store/font-misc/share/X11/fonts/misc", nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/system-path/share/icons"}, []os.DirEntry{
+				stubDentry{"Adwaita", fs.ModeSymlink},
+				stubDentry{"hicolor", fs.ModeDir},
+			}, nil),
+			call("evalSymlinks", stub.ExpectArgs{"/nix/store/system-path/share/icons/Adwaita"}, "", os.ErrNotExist),
+			call("verbosef", stub.ExpectArgs{"integration path %q is a dangling symlink", []any{"/nix/store/system-path/share/icons/Adwaita"}}, nil, nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/system-path/share/icons/hicolor"}, []os.DirEntry{
+				stubDentry{"index.theme", fs.ModeSymlink},
+			}, nil),
+			call("evalSymlinks", stub.ExpectArgs{"/nix/store/system-path/share/icons/hicolor/index.theme"}, "/nix/store/hicolor-icon-theme/share/icons/hicolor/index.theme", nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/tzdata/share/zoneinfo"}, []os.DirEntry{
+				stubDentry{"UTC", 0},
+				stubDentry{"posix", fs.ModeSymlink},
+			}, nil),
+			call("evalSymlinks", stub.ExpectArgs{"/nix/store/tzdata/share/zoneinfo/posix"}, "/nix/store/tzdata/share/zoneinfo", nil),
+			call("readdir", stub.ExpectArgs{"/nix/store/etc/etc/fonts"}, []os.DirEntry{
+				stubDentry{"fonts.conf", fs.ModeSymlink},
+			}, nil),
+			call("evalSymlinks", stub.ExpectArgs{"/nix/store/etc/etc/fonts/fonts.conf"}, "/nix/store/fontconfig-conf/etc/fonts/fonts.conf", nil),
+			call("open", stub.ExpectArgs{"/etc/localtime"}, &stubOsFile{Reader: bytes.NewReader(sampleLocaltime)}, nil),
+		), newI(), nil, nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m("/nix/store/font-misc"), m("/nix/store/font-misc"), 0).
+				Bind(m("/nix/store/fontconfig-conf"), m("/nix/store/fontconfig-conf"), 0).
+				Bind(m("/nix/store/glibc-locales/lib/locale/locale-archive"), m("/nix/store/glibc-locales/lib/locale/locale-archive"), 0).
+				Bind(m("/nix/store/hicolor-icon-theme"), m("/nix/store/hicolor-icon-theme"), 0).
+				Bind(m("/nix/store/system-path/share/X11/fonts"), m("/nix/store/system-path/share/X11/fonts"), 0).
+				Bind(m("/nix/store/system-path/share/icons"), m("/nix/store/system-path/share/icons"), 0).
+				Bind(m("/nix/store/tzdata/share/zoneinfo"), m("/nix/store/tzdata/share/zoneinfo"), 0).
+				Bind(m("/usr/share/fonts"), m("/usr/share/fonts"), 0).
+				Bind(m("/usr/share/zoneinfo"), m("/usr/share/zoneinfo"), 0).
+				Bind(m("/nix/store/etc/etc/fonts"), m("/etc/fonts"), 0).
+				Place(fhs.AbsEtc.Append("localtime"), sampleLocaltime),
+		}, paramsWantEnv(config, map[string]string{
+			"LANG":            "en_GB.UTF-8",
+			"LC_TIME":         "en_DK.UTF-8",
+			"XCURSOR_THEME":   "Adwaita",
+			"TZDIR":           "/nix/store/tzdata/share/zoneinfo",
+			"LOCALE_ARCHIVE":  "/nix/store/glibc-locales/lib/locale/locale-archive",
+			"FONTCONFIG_FILE": "/etc/fonts/fonts.conf",
+			"XCURSOR_PATH":    "/nix/store/system-path/share/icons",
+		}, nil), nil},
+	})
+}
+
+// integrationEnvCalls returns calls to lookupEnv for every variable in integrationEnv.
+func integrationEnvCalls(env map[string]string) []stub.Call {
+	calls := make([]stub.Call, len(integrationEnv))
+	for i, key := range integrationEnv {
+		if value, ok := env[key]; ok {
+			calls[i] = call("lookupEnv", stub.ExpectArgs{key}, value, nil)
+		} else {
+			calls[i] = call("lookupEnv", stub.ExpectArgs{key}, nil, nil)
+		}
+	}
+	return calls
+}
+
+// integrationPathsCalls returns calls to evalSymlinks for every pathname in integrationPaths.
+// Pathnames absent from resolved do not exist.
+func integrationPathsCalls(resolved map[string]string) []stub.Call {
+	calls := make([]stub.Call, 0, len(integrationPaths)*2)
+	for _, pathname := range integrationPaths {
+		if p, ok := resolved[pathname]; ok {
+			calls = append(calls, call("evalSymlinks", stub.ExpectArgs{pathname}, p, nil))
+		} else {
+			calls = append(calls,
+				call("evalSymlinks", stub.ExpectArgs{pathname}, "", os.ErrNotExist),
+				call("verbosef", stub.ExpectArgs{"integration path %q does not exist", []any{pathname}}, nil, nil))
+		}
+	}
+	return calls
+}
+
+// stubDentry implements the Name and Type methods on [os.DirEntry].
+type stubDentry struct {
+	name string
+	typ  fs.FileMode
+}
+
+func (d stubDentry) Name() string               { return d.name }
+func (d stubDentry) IsDir() bool                { return d.typ.IsDir() }
+func (d stubDentry) Type() fs.FileMode          { return d.typ }
+func (d stubDentry) Info() (fs.FileInfo, error) { panic("unreachable") }
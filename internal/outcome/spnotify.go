@@ -0,0 +1,148 @@
+package outcome
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/message"
+)
+
+const (
+	// envNotifySocket is the environment variable holding the pathname to the readiness notification socket.
+	envNotifySocket = "NOTIFY_SOCKET"
+	// notifySocketName is the name of the readiness notification socket in the instance directory.
+	notifySocketName = "notify"
+	// notifyMessageSizeMax is the maximum size of a readiness notification datagram.
+	notifyMessageSizeMax = 1 << 12
+)
+
+func init() { gob.Register(spNotifyOp{}) }
+
+// spNotifyOp exports the readiness notification socket to the container.
+// The socket itself is bound by the priv side once the instance directory is created.
+type spNotifyOp struct{}
+
+func (s spNotifyOp) toSystem(state *outcomeStateSys) error {
+	if state.Container.ReadyTimeout == 0 {
+		return errNotEnabled
+	}
+	state.instance()
+	return nil
+}
+
+func (s spNotifyOp) toContainer(state *outcomeStateParams) error {
+	innerPath := hst.AbsPrivateTmp.Append(notifySocketName)
+	state.params.Bind(state.instancePath().Append(notifySocketName), innerPath, 0)
+	state.env[envNotifySocket] = innerPath.String()
+	return nil
+}
+
+// parseNotify parses a readiness notification datagram. The returned status holds the value of
+// the final STATUS= assignment and is only valid if hasStatus is true.
+func parseNotify(data []byte) (ready bool, status string, hasStatus bool) {
+	for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+		key, value, ok := bytes.Cut(line, []byte{'='})
+		if !ok {
+			continue
+		}
+		switch string(key) {
+		case "READY":
+			ready = ready || string(value) == "1"
+		case "STATUS":
+			status, hasStatus = string(value), true
+		}
+	}
+	return
+}
+
+// notifier receives readiness notifications from the container on the priv side.
+type notifier struct {
+	conn     *net.UnixConn
+	pathname string
+}
+
+// newNotifier binds the readiness notification socket at pathname and grants uid write access to it.
+// A non-nil error returned by newNotifier is of type [hst.AppError].
+func newNotifier(pathname *check.Absolute, uid int) (*notifier, error) {
+	n := notifier{pathname: pathname.String()}
+	if conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: n.pathname, Net: "unixgram"}); err != nil {
+		return nil, &hst.AppError{Step: "bind readiness notification socket", Err: err}
+	} else {
+		n.conn = conn
+	}
+
+	if err := acl.Update(n.pathname, uid, acl.Write); err != nil {
+		_ = n.close()
+		return nil, &hst.AppError{Step: "update readiness notification socket ACL", Err: err}
+	}
+	return &n, nil
+}
+
+// close closes and removes the readiness notification socket, causing serve to return.
+func (n *notifier) close() error {
+	err := n.conn.Close()
+	if removeErr := os.Remove(n.pathname); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// serve receives notifications until the socket is closed. The ready function is called once after READY=1
+// is received, and status is called for every STATUS= assignment. If timeout is greater than zero, serve
+// returns an error if READY=1 is not received before timeout has elapsed.
+// A non-nil error returned by serve is of type [hst.AppError] unless returned by ready.
+func (n *notifier) serve(
+	msg message.Msg,
+	timeout time.Duration,
+	ready func() error,
+	status func(s string),
+) error {
+	if timeout > 0 {
+		if err := n.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return &hst.AppError{Step: "set readiness notification deadline", Err: err}
+		}
+	}
+
+	var (
+		buf     = make([]byte, notifyMessageSizeMax)
+		isReady bool
+	)
+	for {
+		nr, _, err := n.conn.ReadFromUnix(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return &hst.AppError{Step: "wait for readiness notification", Err: err,
+					Msg: "initial program did not notify readiness within " + timeout.String()}
+			}
+			return &hst.AppError{Step: "receive readiness notification", Err: err}
+		}
+
+		r, s, hasStatus := parseNotify(buf[:nr])
+		if hasStatus {
+			msg.Verbosef("initial program reported status %q", s)
+			status(s)
+		}
+		if r && !isReady {
+			isReady = true
+			msg.Verbose("initial program notified readiness")
+			if timeout > 0 {
+				if err = n.conn.SetReadDeadline(time.Time{}); err != nil {
+					return &hst.AppError{Step: "clear readiness notification deadline", Err: err}
+				}
+			}
+			if err = ready(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package outcome
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"hakurei.app/container"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/system"
+	"hakurei.app/message"
+)
+
+func TestSpNotifyOp(t *testing.T) {
+	t.Parallel()
+	config := hst.Template()
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return spNotifyOp{}
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"success", func(bool, bool) outcomeOp {
+			return spNotifyOp{}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Container.ReadyTimeout = 5 * time.Second
+			return c
+		}, nil, []stub.Call{
+			// this op configures the system state and does not make calls during toSystem
+		}, newI().
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711), sysUsesInstance(nil), nil, insertsOps(nil), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantInstancePrefix+"/notify"), m("/.hakurei/notify"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			"NOTIFY_SOCKET": "/.hakurei/notify",
+		}, nil), nil},
+	})
+}
+
+func TestParseNotify(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		data      string
+		ready     bool
+		status    string
+		hasStatus bool
+	}{
+		{"empty", "", false, "", false},
+		{"ready", "READY=1", true, "", false},
+		{"ready zero", "READY=0\n", false, "", false},
+		{"status", "STATUS=Loading index\n", false, "Loading index", true},
+		{"status empty", "STATUS=", false, "", true},
+		{"multiple", "MAINPID=2\nSTATUS=Loading\nREADY=1\nSTATUS=Listening on /run/user/1000/clangd.sock\nmalformed", true,
+			"Listening on /run/user/1000/clangd.sock", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ready, status, hasStatus := parseNotify([]byte(tc.data))
+			if ready != tc.ready || status != tc.status || hasStatus != tc.hasStatus {
+				t.Errorf("parseNotify: %v, %q, %v, want %v, %q, %v",
+					ready, status, hasStatus, tc.ready, tc.status, tc.hasStatus)
+			}
+		})
+	}
+}
+
+func TestNotifierServe(t *testing.T) {
+	t.Parallel()
+
+	newTestNotifier := func(t *testing.T) (*notifier, *net.UnixConn) {
+		pathname := path.Join(t.TempDir(), notifySocketName)
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: pathname, Net: "unixgram"})
+		if err != nil {
+			t.Fatalf("ListenUnixgram: error = %v", err)
+		}
+		var client *net.UnixConn
+		if client, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: pathname, Net: "unixgram"}); err != nil {
+			t.Fatalf("DialUnix: error = %v", err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		return &notifier{conn: conn, pathname: pathname}, client
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		t.Parallel()
+		n, client := newTestNotifier(t)
+
+		var (
+			readyCalled = make(chan struct{})
+			statuses    []string
+		)
+		done := make(chan error, 1)
+		go func() {
+			done <- n.serve(message.New(nil), time.Minute, func() error {
+				close(readyCalled)
+				return nil
+			}, func(s string) { statuses = append(statuses, s) })
+		}()
+
+		for _, data := range []string{"STATUS=Loading", "READY=1\nSTATUS=Idle", "READY=1"} {
+			if _, err := client.Write([]byte(data)); err != nil {
+				t.Fatalf("Write: error = %v", err)
+			}
+		}
+		<-readyCalled
+
+		if err := n.close(); err != nil {
+			t.Fatalf("close: error = %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("serve: error = %v", err)
+		}
+		if want := []string{"Loading", "Idle"}; !reflect.DeepEqual(statuses, want) {
+			t.Errorf("serve: statuses = %q, want %q", statuses, want)
+		}
+		if _, err := os.Stat(n.pathname); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat: error = %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+		n, client := newTestNotifier(t)
+		defer func() { _ = n.close() }()
+
+		if _, err := client.Write([]byte("STATUS=Loading")); err != nil {
+			t.Fatalf("Write: error = %v", err)
+		}
+		err := n.serve(message.New(nil), time.Millisecond, func() error {
+			t.Error("ready: unexpected call")
+			return nil
+		}, func(string) {})
+
+		var appError *hst.AppError
+		if !errors.As(err, &appError) || appError.Step != "wait for readiness notification" ||
+			!errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("serve: error = %v", err)
+		}
+	})
+
+	t.Run("ready error", func(t *testing.T) {
+		t.Parallel()
+		n, client := newTestNotifier(t)
+		defer func() { _ = n.close() }()
+
+		if _, err := client.Write([]byte("READY=1")); err != nil {
+			t.Fatalf("Write: error = %v", err)
+		}
+		wantErr := stub.UniqueError(0)
+		if err := n.serve(message.New(nil), 0, func() error {
+			return wantErr
+		}, func(string) {}); !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("serve: error = %v, want %v", err, wantErr)
+		}
+	})
+}
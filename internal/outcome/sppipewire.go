@@ -0,0 +1,47 @@
+package outcome
+
+import (
+	"encoding/gob"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/pipewire"
+)
+
+func init() { gob.Register(spPipeWireOp{}) }
+
+// spPipeWireOp exports the PipeWire server to the container.
+// Runs after spRuntimeOp.
+type spPipeWireOp struct{}
+
+func (s spPipeWireOp) toSystem(state *outcomeStateSys) error {
+	if state.et&hst.EPipeWire == 0 {
+		return errNotEnabled
+	}
+
+	// outer pipewire socket (usually `/run/user/%d/pipewire-0`)
+	var socketPath *check.Absolute
+	if name, ok := state.k.lookupEnv(pipewire.Remote); !ok {
+		state.msg.Verbose(pipewire.Remote + " is not set, assuming " + pipewire.FallbackName)
+		socketPath = state.sc.RuntimePath.Append(pipewire.FallbackName)
+	} else if a, err := check.NewAbs(name); err != nil {
+		socketPath = state.sc.RuntimePath.Append(name)
+	} else {
+		socketPath = a
+	}
+
+	appId := state.appId
+	if appId == "" {
+		// use instance ID in case app id is not set
+		appId = "app.hakurei." + state.id.String()
+	}
+	state.sys.PipeWire(state.runtime().Append("pipewire"), socketPath, appId, state.id.String())
+	return nil
+}
+
+func (s spPipeWireOp) toContainer(state *outcomeStateParams) error {
+	innerPath := state.runtimeDir.Append(pipewire.FallbackName)
+	state.params.Bind(state.runtimePath().Append("pipewire"), innerPath, 0)
+	state.env[pipewire.Remote] = innerPath.String()
+	return nil
+}
@@ -0,0 +1,117 @@
+package outcome
+
+import (
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/pipewire"
+	"hakurei.app/internal/system"
+)
+
+func TestSpPipeWireOp(t *testing.T) {
+	t.Parallel()
+	config := hst.Template()
+	newConfig := func() *hst.Config {
+		c := hst.Template()
+		c.Enablements = hst.NewEnablements(c.Enablements.Unwrap() | hst.EPipeWire)
+		return c
+	}
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return spPipeWireOp{}
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"success fallback", func(bool, bool) outcomeOp {
+			return spPipeWireOp{}
+		}, newConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"PIPEWIRE_REMOTE"}, nil, nil),
+			call("verbose", stub.ExpectArgs{[]any{"PIPEWIRE_REMOTE is not set, assuming pipewire-0"}}, nil, nil),
+		}, newI().
+			// state.ensureRuntimeDir
+			Ensure(m(wantRuntimePath), 0700).
+			UpdatePermType(system.User, m(wantRuntimePath), acl.Execute).
+			Ensure(m(wantRunDirPath), 0700).
+			UpdatePermType(system.User, m(wantRunDirPath), acl.Execute).
+			// state.runtime
+			Ephemeral(system.Process, m(wantRuntimeSharePath), 0700).
+			UpdatePerm(m(wantRuntimeSharePath), acl.Execute).
+			// toSystem
+			PipeWire(
+				m(wantRuntimeSharePath+"/pipewire"),
+				m(wantRuntimePath+"/pipewire-0"),
+				"org.chromium.Chromium",
+				wantAutoEtcPrefix,
+			), sysUsesRuntime(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantRuntimeSharePath+"/pipewire"), m("/run/user/1000/pipewire-0"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			pipewire.Remote: "/run/user/1000/pipewire-0",
+		}, nil), nil},
+
+		{"success notAbs defaultAppId", func(bool, bool) outcomeOp {
+			return spPipeWireOp{}
+		}, func() *hst.Config {
+			c := newConfig()
+			c.ID = ""
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"PIPEWIRE_REMOTE"}, "pipewire-1", nil),
+		}, newI().
+			// state.ensureRuntimeDir
+			Ensure(m(wantRuntimePath), 0700).
+			UpdatePermType(system.User, m(wantRuntimePath), acl.Execute).
+			Ensure(m(wantRunDirPath), 0700).
+			UpdatePermType(system.User, m(wantRunDirPath), acl.Execute).
+			// state.runtime
+			Ephemeral(system.Process, m(wantRuntimeSharePath), 0700).
+			UpdatePerm(m(wantRuntimeSharePath), acl.Execute).
+			// toSystem
+			PipeWire(
+				m(wantRuntimeSharePath+"/pipewire"),
+				m(wantRuntimePath+"/pipewire-1"),
+				"app.hakurei."+wantAutoEtcPrefix,
+				wantAutoEtcPrefix,
+			), sysUsesRuntime(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantRuntimeSharePath+"/pipewire"), m("/run/user/1000/pipewire-0"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			pipewire.Remote: "/run/user/1000/pipewire-0",
+		}, nil), nil},
+
+		{"success abs", func(bool, bool) outcomeOp {
+			return spPipeWireOp{}
+		}, newConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"PIPEWIRE_REMOTE"}, "/proc/nonexistent/pipewire-0", nil),
+		}, newI().
+			// state.ensureRuntimeDir
+			Ensure(m(wantRuntimePath), 0700).
+			UpdatePermType(system.User, m(wantRuntimePath), acl.Execute).
+			Ensure(m(wantRunDirPath), 0700).
+			UpdatePermType(system.User, m(wantRunDirPath), acl.Execute).
+			// state.runtime
+			Ephemeral(system.Process, m(wantRuntimeSharePath), 0700).
+			UpdatePerm(m(wantRuntimeSharePath), acl.Execute).
+			// toSystem
+			PipeWire(
+				m(wantRuntimeSharePath+"/pipewire"),
+				m("/proc/nonexistent/pipewire-0"),
+				"org.chromium.Chromium",
+				wantAutoEtcPrefix,
+			), sysUsesRuntime(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantRuntimeSharePath+"/pipewire"), m("/run/user/1000/pipewire-0"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			pipewire.Remote: "/run/user/1000/pipewire-0",
+		}, nil), nil},
+	})
+}
@@ -53,6 +53,26 @@ func (s *spPulseOp) toSystem(state *outcomeStateSys) error {
 		}
 	}
 
+	if state.pulseProxy != nil {
+		// the proxy authenticates on behalf of the container, so the cookie is never transmitted to the shim
+		var cookie []byte
+		if a, err := discoverPulseCookie(state.k); err != nil {
+			return err
+		} else if a != nil {
+			cookie = make([]byte, pulseCookieSizeMax)
+			if n, err := loadFile(state.msg, state.k, "PulseAudio cookie", a.String(), cookie); err != nil {
+				return err
+			} else if n != pulseCookieSizeMax {
+				return newWithMessage("unexpected PulseAudio cookie size")
+			}
+		} else {
+			state.msg.Verbose("cannot locate PulseAudio cookie, relaying cookie sent by the client")
+		}
+
+		state.sys.ProxyPulse(state.runtime().Append("pulse"), pulseSocket, cookie, state.pulseProxy)
+		return nil
+	}
+
 	// pulse socket is world writable and its parent directory DAC permissions prevents access;
 	// hard link to target-executable share directory to grant access
 	state.sys.Link(pulseSocket, state.runtime().Append("pulse"))
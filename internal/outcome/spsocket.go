@@ -0,0 +1,126 @@
+package outcome
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/fhs"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/system"
+)
+
+func init() { gob.Register(new(spSocketOp)) }
+
+// spSocketOp exports host pathname sockets described by [hst.SocketConfig] to the container.
+// Runs after spRuntimeOp.
+type spSocketOp struct {
+	// Sockets to bind in the container. Populated during toSystem.
+	Sockets []socketBind
+}
+
+// socketBind describes a socket resolved by spSocketOp.toSystem.
+type socketBind struct {
+	// Pathname to the host socket or its hard link.
+	Source *check.Absolute
+	// Copied from [hst.SocketConfig].
+	Target *check.Absolute
+	// Name of the host socket, used if Target is nil.
+	Name string
+	// Copied from [hst.SocketConfig].
+	Env, Prefix string
+}
+
+func (s *spSocketOp) toSystem(state *outcomeStateSys) error {
+	for i := range state.sockets {
+		c := &state.sockets[i]
+
+		var unset string
+		expanded := os.Expand(c.Source, func(key string) string {
+			v, ok := state.k.lookupEnv(key)
+			if !ok && unset == "" {
+				unset = key
+			}
+			return v
+		})
+		if unset != "" {
+			if c.Optional {
+				state.msg.Verbosef("skipping socket %q, %s is not set", c.Source, unset)
+				continue
+			}
+			return newWithMessage(fmt.Sprintf("socket %q references unset variable %s", c.Source, unset))
+		}
+
+		source, err := check.NewAbs(path.Clean(expanded))
+		if err != nil {
+			return &hst.AppError{Step: fmt.Sprintf("resolve socket %q", c.Source), Err: err}
+		}
+
+		if fi, err := state.k.stat(source.String()); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return &hst.AppError{Step: fmt.Sprintf("access socket %q", source), Err: err}
+			}
+			if c.Optional {
+				state.msg.Verbosef("skipping socket %q, not found", source)
+				continue
+			}
+			return newWithMessageError(fmt.Sprintf("socket %q not found", source), err)
+		} else if fi.Mode().Type() != fs.ModeSocket {
+			return newWithMessage(fmt.Sprintf("%q is not a socket", source))
+		} else if fi.Mode()&0o002 == 0 {
+			state.sys.UpdatePermType(system.User, source, acl.Read, acl.Write)
+		}
+
+		b := socketBind{source, c.Target, path.Base(source.String()), c.Env, c.Prefix}
+		if c.Link {
+			// hard link to target-executable share directory to grant access
+			b.Source = state.runtime().Append("socket." + strconv.Itoa(i))
+			state.sys.Link(source, b.Source)
+		} else {
+			// grant search access to parent directories not already searchable by others,
+			// up to XDG_RUNTIME_DIR which is made searchable by ensureRuntimeDir
+			base := state.sc.RuntimePath
+			inRuntime := strings.HasPrefix(source.String(), base.String()+"/")
+			for dir := source.Dir(); dir.String() != fhs.Root && !dir.Is(base); dir = dir.Dir() {
+				if fi, err := state.k.stat(dir.String()); err != nil {
+					return &hst.AppError{Step: fmt.Sprintf("access socket directory %q", dir), Err: err}
+				} else if fi.Mode()&0o001 == 0 {
+					if !inRuntime {
+						return newWithMessage(fmt.Sprintf("directory %q of socket %q outside XDG_RUNTIME_DIR is not searchable by others", dir, source))
+					}
+					state.sys.UpdatePermType(system.User, dir, acl.Execute)
+				}
+			}
+			if inRuntime {
+				state.ensureRuntimeDir()
+			}
+		}
+		s.Sockets = append(s.Sockets, b)
+	}
+
+	if len(s.Sockets) == 0 {
+		return errNotEnabled
+	}
+	return nil
+}
+
+func (s *spSocketOp) toContainer(state *outcomeStateParams) error {
+	for _, b := range s.Sockets {
+		target := b.Target
+		if target == nil {
+			target = state.runtimeDir.Append(b.Name)
+		}
+		state.params.Bind(b.Source, target, 0)
+		if b.Env != "" {
+			state.env[b.Env] = b.Prefix + target.String()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package outcome
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"hakurei.app/container"
+	"hakurei.app/container/check"
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/system"
+)
+
+func TestSpSocketOp(t *testing.T) {
+	t.Parallel()
+	config := hst.Template()
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"not enabled", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, hst.Template, nil, nil, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"skip optional", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{
+				{Source: "$SSH_AUTH_SOCK", Optional: true},
+				{Source: "/run/cups/cups.sock", Optional: true},
+			}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"SSH_AUTH_SOCK"}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"skipping socket %q, %s is not set", []any{"$SSH_AUTH_SOCK", "SSH_AUTH_SOCK"}}, nil, nil),
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, (*stubFi)(nil), os.ErrNotExist),
+			call("verbosef", stub.ExpectArgs{"skipping socket %q, not found", []any{m("/run/cups/cups.sock")}}, nil, nil),
+		}, nil, nil, errNotEnabled, nil, nil, nil, nil, nil},
+
+		{"unset", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "${SSH_AUTH_SOCK}"}}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"SSH_AUTH_SOCK"}, nil, nil),
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  `socket "${SSH_AUTH_SOCK}" references unset variable SSH_AUTH_SOCK`,
+		}, nil, nil, nil, nil, nil},
+
+		{"relative", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "$SSH_AUTH_SOCK"}}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"SSH_AUTH_SOCK"}, "agent.sock", nil),
+		}, nil, nil, &hst.AppError{
+			Step: `resolve socket "$SSH_AUTH_SOCK"`,
+			Err:  &check.AbsoluteError{Pathname: "agent.sock"},
+		}, nil, nil, nil, nil, nil},
+
+		{"stat", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "/run/cups/cups.sock", Optional: true}}
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, (*stubFi)(nil), stub.UniqueError(1)),
+		}, nil, nil, &hst.AppError{
+			Step: `access socket "/run/cups/cups.sock"`,
+			Err:  stub.UniqueError(1),
+		}, nil, nil, nil, nil, nil},
+
+		{"not found", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "/run/cups/cups.sock"}}
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, (*stubFi)(nil), os.ErrNotExist),
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrNotExist,
+			Msg:  `socket "/run/cups/cups.sock" not found`,
+		}, nil, nil, nil, nil, nil},
+
+		{"not socket", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "/run/cups/cups.sock"}}
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, &stubFi{mode: 0666}, nil),
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  `"/run/cups/cups.sock" is not a socket`,
+		}, nil, nil, nil, nil, nil},
+
+		{"directory stat", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "/run/cups/cups.sock"}}
+			return c
+		}, nil, []stub.Call{
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, &stubFi{mode: fs.ModeSocket | 0666}, nil),
+			call("stat", stub.ExpectArgs{"/run/cups"}, (*stubFi)(nil), stub.UniqueError(0)),
+		}, newI(), nil, &hst.AppError{
+			Step: `access socket directory "/run/cups"`,
+			Err:  stub.UniqueError(0),
+		}, nil, nil, nil, nil, nil},
+
+		{"outside runtime", func(bool, bool) outcomeOp {
+			return new(spSocketOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{{Source: "$HOME/.gnupg/S.gpg-agent.ssh", Env: "SSH_AUTH_SOCK"}}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"HOME"}, "/home/user", nil),
+			call("stat", stub.ExpectArgs{"/home/user/.gnupg/S.gpg-agent.ssh"}, &stubFi{mode: fs.ModeSocket | 0600}, nil),
+			call("stat", stub.ExpectArgs{"/home/user/.gnupg"}, &stubFi{mode: fs.ModeDir | 0700, isDir: true}, nil),
+		}, newI().
+			UpdatePermType(system.User, m("/home/user/.gnupg/S.gpg-agent.ssh"), acl.Read, acl.Write), nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  `directory "/home/user/.gnupg" of socket "/home/user/.gnupg/S.gpg-agent.ssh" outside XDG_RUNTIME_DIR is not searchable by others`,
+		}, nil, nil, nil, nil, nil},
+
+		{"success", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spSocketOp)
+			}
+			return &spSocketOp{Sockets: []socketBind{
+				{Source: m(wantRuntimePath + "/gnupg/S.gpg-agent.ssh"), Name: "S.gpg-agent.ssh", Env: "SSH_AUTH_SOCK"},
+				{Source: m(wantRuntimeSharePath + "/socket.2"), Target: m("/var/run/docker.sock"),
+					Name: "docker.sock", Env: "DOCKER_HOST", Prefix: "unix://"},
+			}}
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.Sockets = []hst.SocketConfig{
+				{Source: "$XDG_RUNTIME_DIR/gnupg/S.gpg-agent.ssh", Env: "SSH_AUTH_SOCK"},
+				{Source: "/run/cups/cups.sock", Optional: true},
+				{Source: "/run/docker.sock", Target: m("/var/run/docker.sock"), Env: "DOCKER_HOST", Prefix: "unix://", Link: true},
+			}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"XDG_RUNTIME_DIR"}, wantRuntimePath, nil),
+			call("stat", stub.ExpectArgs{wantRuntimePath + "/gnupg/S.gpg-agent.ssh"}, &stubFi{mode: fs.ModeSocket | 0600}, nil),
+			call("stat", stub.ExpectArgs{wantRuntimePath + "/gnupg"}, &stubFi{mode: fs.ModeDir | 0700, isDir: true}, nil),
+			call("stat", stub.ExpectArgs{"/run/cups/cups.sock"}, (*stubFi)(nil), os.ErrNotExist),
+			call("verbosef", stub.ExpectArgs{"skipping socket %q, not found", []any{m("/run/cups/cups.sock")}}, nil, nil),
+			call("stat", stub.ExpectArgs{"/run/docker.sock"}, &stubFi{mode: fs.ModeSocket | 0660}, nil),
+		}, newI().
+			UpdatePermType(system.User, m(wantRuntimePath+"/gnupg/S.gpg-agent.ssh"), acl.Read, acl.Write).
+			UpdatePermType(system.User, m(wantRuntimePath+"/gnupg"), acl.Execute).
+			// state.ensureRuntimeDir
+			Ensure(m(wantRuntimePath), 0700).
+			UpdatePermType(system.User, m(wantRuntimePath), acl.Execute).
+			Ensure(m(wantRunDirPath), 0700).
+			UpdatePermType(system.User, m(wantRunDirPath), acl.Execute).
+			UpdatePermType(system.User, m("/run/docker.sock"), acl.Read, acl.Write).
+			// state.runtime
+			Ephemeral(system.Process, m(wantRuntimeSharePath), 0700).
+			UpdatePerm(m(wantRuntimeSharePath), acl.Execute).
+			// toSystem
+			Link(m("/run/docker.sock"), m(wantRuntimeSharePath+"/socket.2")), sysUsesRuntime(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantRuntimePath+"/gnupg/S.gpg-agent.ssh"), m("/run/user/1000/S.gpg-agent.ssh"), 0).
+				Bind(m(wantRuntimeSharePath+"/socket.2"), m("/var/run/docker.sock"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			"SSH_AUTH_SOCK": "/run/user/1000/S.gpg-agent.ssh",
+			"DOCKER_HOST":   "unix:///var/run/docker.sock",
+		}, nil), nil},
+	})
+}
@@ -40,8 +40,19 @@ func (s *spWaylandOp) toSystem(state *outcomeStateSys) error {
 			// use instance ID in case app id is not set
 			appId = "app.hakurei." + state.id.String()
 		}
-		// downstream socket paths
-		state.sys.Wayland(state.instance().Append("wayland"), socketPath, appId, state.id.String())
+		if state.waylandProxy == nil {
+			// downstream socket paths
+			state.sys.Wayland(state.instance().Append("wayland"), socketPath, appId, state.id.String())
+		} else {
+			// the security context socket is only connected to by the proxy
+			contextPath := state.instance().Append("wayland-context")
+			state.sys.
+				Wayland(contextPath, socketPath, appId, state.id.String()).
+				ProxyWayland(state.instance().Append("wayland"), contextPath, state.waylandProxy)
+		}
+	} else if state.waylandProxy != nil { // filter the bare socket
+		state.msg.Verbose("direct wayland access via filtering proxy")
+		state.sys.ProxyWayland(state.instance().Append("wayland"), socketPath, state.waylandProxy)
 	} else { // bind mount wayland socket (insecure)
 		state.msg.Verbose("direct wayland access, PROCEED WITH CAUTION")
 		state.ensureRuntimeDir()
@@ -50,6 +50,64 @@ func TestSpWaylandOp(t *testing.T) {
 			wayland.Display: wayland.FallbackName,
 		}, nil), nil},
 
+		{"success proxy", func(bool, bool) outcomeOp {
+			return new(spWaylandOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.WaylandProxy = &hst.WaylandConfig{Deny: []string{"zwlr_screencopy_*"}}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"WAYLAND_DISPLAY"}, "wayland-1", nil),
+		}, newI().
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			// toSystem
+			Wayland(
+				m(wantInstancePrefix+"/wayland-context"),
+				m(wantRuntimePath+"/wayland-1"),
+				"org.chromium.Chromium",
+				wantAutoEtcPrefix,
+			).
+			ProxyWayland(
+				m(wantInstancePrefix+"/wayland"),
+				m(wantInstancePrefix+"/wayland-context"),
+				&hst.WaylandConfig{Deny: []string{"zwlr_screencopy_*"}},
+			), sysUsesInstance(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantInstancePrefix+"/wayland"), m("/run/user/1000/wayland-0"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			wayland.Display: wayland.FallbackName,
+		}, nil), nil},
+
+		{"success direct proxy", func(bool, bool) outcomeOp {
+			return new(spWaylandOp)
+		}, func() *hst.Config {
+			c := hst.Template()
+			c.DirectWayland = true
+			c.WaylandProxy = &hst.WaylandConfig{Allow: []string{"wl_*", "xdg_wm_base"}}
+			return c
+		}, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"WAYLAND_DISPLAY"}, "/proc/nonexistent/wayland", nil),
+			call("verbose", stub.ExpectArgs{[]any{"direct wayland access via filtering proxy"}}, nil, nil),
+		}, newI().
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			// toSystem
+			ProxyWayland(
+				m(wantInstancePrefix+"/wayland"),
+				m("/proc/nonexistent/wayland"),
+				&hst.WaylandConfig{Allow: []string{"wl_*", "xdg_wm_base"}},
+			), sysUsesInstance(nil), nil, insertsOps(afterSpRuntimeOp(nil)), []stub.Call{
+			// this op configures the container state and does not make calls during toContainer
+		}, &container.Params{
+			Ops: new(container.Ops).
+				Bind(m(wantInstancePrefix+"/wayland"), m("/run/user/1000/wayland-0"), 0),
+		}, paramsWantEnv(config, map[string]string{
+			wayland.Display: wayland.FallbackName,
+		}, nil), nil},
+
 		{"success direct", func(isShim, _ bool) outcomeOp {
 			if !isShim {
 				return new(spWaylandOp)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"path"
 	"strconv"
 	"strings"
 
@@ -22,6 +23,8 @@ func init() { gob.Register(new(spX11Op)) }
 type spX11Op struct {
 	// Value of $DISPLAY, stored during toSystem
 	Display string
+	// Path to host X11 socket. Populated during toSystem if X11Cookie is true.
+	SocketPath *check.Absolute
 }
 
 func (s *spX11Op) toSystem(state *outcomeStateSys) error {
@@ -47,6 +50,39 @@ func (s *spX11Op) toSystem(state *outcomeStateSys) error {
 			socketPath = a
 		}
 	}
+	if state.x11Cookie {
+		var number string
+		if socketPath != nil {
+			number = strings.TrimPrefix(path.Base(socketPath.String()), "X")
+		}
+		if n, err := strconv.Atoi(number); err != nil || n < 0 {
+			return newWithMessage("cannot authorize non-local X11 display " + strconv.Quote(s.Display))
+		}
+		if _, err := state.k.stat(socketPath.String()); err != nil {
+			return &hst.AppError{Step: fmt.Sprintf("access X11 socket %q", socketPath), Err: err}
+		}
+
+		// the master cookie is only copied on explicit opt-in
+		var xauthority *check.Absolute
+		if state.x11MasterCookie {
+			if p, ok := state.k.lookupEnv("XAUTHORITY"); ok {
+				xauthority, _ = check.NewAbs(p)
+			} else if p, ok = state.k.lookupEnv("HOME"); ok {
+				if a, err := check.NewAbs(p); err == nil {
+					xauthority = a.Append(".Xauthority")
+				}
+			}
+		}
+
+		// the authorization is revoked independently of other instances
+		state.sys.
+			UpdatePermType(hst.EX11, socketPath, acl.Read, acl.Write, acl.Execute).
+			Xauthority(state.instance().Append("Xauthority"), number, xauthority)
+		s.Display = ":" + number
+		s.SocketPath = socketPath
+		return nil
+	}
+
 	if socketPath != nil {
 		if _, err := state.k.stat(socketPath.String()); err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
@@ -66,6 +102,17 @@ func (s *spX11Op) toSystem(state *outcomeStateSys) error {
 
 func (s *spX11Op) toContainer(state *outcomeStateParams) error {
 	state.env["DISPLAY"] = s.Display
-	state.params.Bind(absX11SocketDir, absX11SocketDir, 0)
+	if s.SocketPath == nil {
+		state.params.Bind(absX11SocketDir, absX11SocketDir, 0)
+		return nil
+	}
+
+	if len(s.Display) < 2 || s.Display[0] != ':' {
+		return newWithMessage("unexpected X11 display " + strconv.Quote(s.Display))
+	}
+	state.params.Bind(s.SocketPath, absX11SocketDir.Append("X"+s.Display[1:]), 0)
+	innerXauthority := hst.AbsPrivateTmp.Append("Xauthority")
+	state.params.Bind(state.instancePath().Append("Xauthority"), innerXauthority, 0)
+	state.env["XAUTHORITY"] = innerXauthority.String()
 	return nil
 }
@@ -8,11 +8,23 @@ import (
 	"hakurei.app/container/stub"
 	"hakurei.app/hst"
 	"hakurei.app/internal/acl"
+	"hakurei.app/internal/system"
 )
 
 func TestSpX11Op(t *testing.T) {
 	t.Parallel()
 	config := hst.Template()
+	newCookieConfig := func() *hst.Config {
+		c := hst.Template()
+		*c.Enablements |= hst.Enablements(hst.EX11)
+		c.X11Cookie = true
+		return c
+	}
+	newMasterCookieConfig := func() *hst.Config {
+		c := newCookieConfig()
+		c.X11MasterCookie = true
+		return c
+	}
 
 	checkOpBehaviour(t, []opBehaviourTestCase{
 		{"not enabled", func(bool, bool) outcomeOp {
@@ -93,6 +105,130 @@ func TestSpX11Op(t *testing.T) {
 			"DISPLAY": "unix:/tmp/.X11-unix/X0",
 		}, nil), nil},
 
+		{"cookie remote", func(bool, bool) outcomeOp {
+			return new(spX11Op)
+		}, newCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, "localhost:0", nil),
+		}, nil, nil, &hst.AppError{
+			Step: "finalise",
+			Err:  os.ErrInvalid,
+			Msg:  `cannot authorize non-local X11 display "localhost:0"`,
+		}, nil, nil, nil, nil, nil},
+
+		{"cookie stat", func(bool, bool) outcomeOp {
+			return new(spX11Op)
+		}, newCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, ":1", nil),
+			call("stat", stub.ExpectArgs{"/tmp/.X11-unix/X1"}, (*stubFi)(nil), os.ErrNotExist),
+		}, nil, nil, &hst.AppError{
+			Step: `access X11 socket "/tmp/.X11-unix/X1"`,
+			Err:  os.ErrNotExist,
+		}, nil, nil, nil, nil, nil},
+
+		{"cookie bad shim display", func(isShim, clearUnexported bool) outcomeOp {
+			if !isShim {
+				return new(spX11Op)
+			}
+			op := &spX11Op{Display: ":1", SocketPath: m("/tmp/.X11-unix/X1")}
+			if clearUnexported {
+				op.Display = "unix:/tmp/.X11-unix/X1"
+			}
+			return op
+		}, newCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, ":1", nil),
+			call("stat", stub.ExpectArgs{"/tmp/.X11-unix/X1"}, (*stubFi)(nil), nil),
+		}, newI().
+			// toSystem
+			UpdatePermType(hst.EX11, m("/tmp/.X11-unix/X1"), acl.Read, acl.Write, acl.Execute).
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			Xauthority(m(wantInstancePrefix+"/Xauthority"), "1", nil),
+			sysUsesInstance(nil), nil, insertsOps(nil), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, nil, nil, &hst.AppError{
+				Step: "finalise",
+				Err:  os.ErrInvalid,
+				Msg:  `unexpected X11 display "unix:/tmp/.X11-unix/X1"`,
+			}},
+
+		{"success cookie", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spX11Op)
+			}
+			return &spX11Op{Display: ":1", SocketPath: m("/tmp/.X11-unix/X1")}
+		}, newCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, ":1", nil),
+			call("stat", stub.ExpectArgs{"/tmp/.X11-unix/X1"}, (*stubFi)(nil), nil),
+		}, newI().
+			// toSystem
+			UpdatePermType(hst.EX11, m("/tmp/.X11-unix/X1"), acl.Read, acl.Write, acl.Execute).
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			Xauthority(m(wantInstancePrefix+"/Xauthority"), "1", nil),
+			sysUsesInstance(nil), nil, insertsOps(nil), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, &container.Params{
+				Ops: new(container.Ops).
+					Bind(m("/tmp/.X11-unix/X1"), m("/tmp/.X11-unix/X1"), 0).
+					Bind(m(wantInstancePrefix+"/Xauthority"), m("/.hakurei/Xauthority"), 0),
+			}, paramsWantEnv(config, map[string]string{
+				"DISPLAY":    ":1",
+				"XAUTHORITY": "/.hakurei/Xauthority",
+			}, nil), nil},
+
+		{"success master cookie", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spX11Op)
+			}
+			return &spX11Op{Display: ":1", SocketPath: m("/tmp/.X11-unix/X1")}
+		}, newMasterCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, ":1", nil),
+			call("stat", stub.ExpectArgs{"/tmp/.X11-unix/X1"}, (*stubFi)(nil), nil),
+			call("lookupEnv", stub.ExpectArgs{"XAUTHORITY"}, "/run/user/1971/xauth_ophestra", nil),
+		}, newI().
+			// toSystem
+			UpdatePermType(hst.EX11, m("/tmp/.X11-unix/X1"), acl.Read, acl.Write, acl.Execute).
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			Xauthority(m(wantInstancePrefix+"/Xauthority"), "1", m("/run/user/1971/xauth_ophestra")),
+			sysUsesInstance(nil), nil, insertsOps(nil), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, &container.Params{
+				Ops: new(container.Ops).
+					Bind(m("/tmp/.X11-unix/X1"), m("/tmp/.X11-unix/X1"), 0).
+					Bind(m(wantInstancePrefix+"/Xauthority"), m("/.hakurei/Xauthority"), 0),
+			}, paramsWantEnv(config, map[string]string{
+				"DISPLAY":    ":1",
+				"XAUTHORITY": "/.hakurei/Xauthority",
+			}, nil), nil},
+
+		{"success master cookie abs home", func(isShim, _ bool) outcomeOp {
+			if !isShim {
+				return new(spX11Op)
+			}
+			return &spX11Op{Display: ":0", SocketPath: m("/proc/nonexistent/X0")}
+		}, newMasterCookieConfig, nil, []stub.Call{
+			call("lookupEnv", stub.ExpectArgs{"DISPLAY"}, "unix:/proc/nonexistent/X0", nil),
+			call("stat", stub.ExpectArgs{"/proc/nonexistent/X0"}, (*stubFi)(nil), nil),
+			call("lookupEnv", stub.ExpectArgs{"XAUTHORITY"}, nil, nil),
+			call("lookupEnv", stub.ExpectArgs{"HOME"}, "/home/ophestra", nil),
+		}, newI().
+			// toSystem
+			UpdatePermType(hst.EX11, m("/proc/nonexistent/X0"), acl.Read, acl.Write, acl.Execute).
+			// state.instance
+			Ephemeral(system.Process, m(wantInstancePrefix), 0711).
+			Xauthority(m(wantInstancePrefix+"/Xauthority"), "0", m("/home/ophestra/.Xauthority")),
+			sysUsesInstance(nil), nil, insertsOps(nil), []stub.Call{
+				// this op configures the container state and does not make calls during toContainer
+			}, &container.Params{
+				Ops: new(container.Ops).
+					Bind(m("/proc/nonexistent/X0"), m("/tmp/.X11-unix/X0"), 0).
+					Bind(m(wantInstancePrefix+"/Xauthority"), m("/.hakurei/Xauthority"), 0),
+			}, paramsWantEnv(config, map[string]string{
+				"DISPLAY":    ":0",
+				"XAUTHORITY": "/.hakurei/Xauthority",
+			}, nil), nil},
+
 		{"success", func(isShim, _ bool) outcomeOp {
 			if !isShim {
 				return new(spX11Op)
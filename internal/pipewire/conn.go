@@ -0,0 +1,136 @@
+package pipewire
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+)
+
+const (
+	// headerSize is the size of the native protocol message header.
+	headerSize = 16
+	// payloadSizeMax is the largest payload size representable in the message header.
+	payloadSizeMax = 1<<24 - 1
+	// fdsMax is the maximum number of file descriptors received alongside a message.
+	fdsMax = 28
+)
+
+// ErrMessage is returned when receiving a malformed native protocol message.
+var ErrMessage = errors.New("malformed native protocol message")
+
+// message is a native protocol message.
+type message struct {
+	// Target object of a method, or origin of an event.
+	id uint32
+	// Method or event opcode.
+	opcode uint8
+	// Sequence number assigned by the sender.
+	seq uint32
+	// Encoded SPA POD arguments.
+	payload []byte
+	// File descriptors received with the message, owned by the receiver.
+	fds []int
+}
+
+// closeFds closes file descriptors received with m.
+func (m *message) closeFds() {
+	for _, fd := range m.fds {
+		_ = syscall.Close(fd)
+	}
+	m.fds = nil
+}
+
+// conn frames native protocol messages on a connection to a PipeWire server.
+type conn struct {
+	*net.UnixConn
+	// Sequence number of the next outgoing message.
+	seq uint32
+
+	// Received data not yet consumed.
+	buf []byte
+	// Received file descriptors not yet consumed.
+	fds []int
+}
+
+// send sends a message on the connection alongside fds.
+func (c *conn) send(id uint32, opcode uint8, payload []byte, fds ...int) error {
+	if len(payload) > payloadSizeMax {
+		return syscall.EMSGSIZE
+	}
+	data := make([]byte, headerSize, headerSize+len(payload))
+	binary.NativeEndian.PutUint32(data, id)
+	binary.NativeEndian.PutUint32(data[4:], uint32(opcode)<<24|uint32(len(payload)))
+	binary.NativeEndian.PutUint32(data[8:], c.seq)
+	binary.NativeEndian.PutUint32(data[12:], uint32(len(fds)))
+	data = append(data, payload...)
+	c.seq++
+
+	var oob []byte
+	if len(fds) > 0 {
+		oob = syscall.UnixRights(fds...)
+	}
+	_, _, err := c.WriteMsgUnix(data, oob, nil)
+	return err
+}
+
+// receive returns the next message received on the connection.
+// The caller is responsible for closing file descriptors held by the returned message.
+func (c *conn) receive() (*message, error) {
+	for len(c.buf) < headerSize ||
+		len(c.buf) < headerSize+int(binary.NativeEndian.Uint32(c.buf[4:])&payloadSizeMax) {
+		if err := c.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := message{
+		id:     binary.NativeEndian.Uint32(c.buf),
+		opcode: uint8(binary.NativeEndian.Uint32(c.buf[4:]) >> 24),
+		seq:    binary.NativeEndian.Uint32(c.buf[8:]),
+	}
+	size := int(binary.NativeEndian.Uint32(c.buf[4:]) & payloadSizeMax)
+	n := int(binary.NativeEndian.Uint32(c.buf[12:]))
+	m.payload = c.buf[headerSize : headerSize+size : headerSize+size]
+	c.buf = c.buf[headerSize+size:]
+
+	if n > len(c.fds) {
+		m.fds, c.fds = c.fds, nil
+		m.closeFds()
+		return nil, ErrMessage
+	}
+	m.fds, c.fds = c.fds[:n:n], c.fds[n:]
+	return &m, nil
+}
+
+// read appends data and file descriptors received on the connection.
+func (c *conn) read() error {
+	var (
+		data = make([]byte, 1<<12)
+		oob  = make([]byte, syscall.CmsgSpace(fdsMax*4))
+	)
+	n, oobn, _, _, err := c.ReadMsgUnix(data, oob)
+	if oobn > 0 {
+		if messages, parseErr := syscall.ParseSocketControlMessage(oob[:oobn]); parseErr == nil {
+			for i := range messages {
+				if fds, rightsErr := syscall.ParseUnixRights(&messages[i]); rightsErr == nil {
+					c.fds = append(c.fds, fds...)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.buf = append(c.buf, data[:n]...)
+	return nil
+}
+
+// Close closes the connection and any unconsumed file descriptors.
+func (c *conn) Close() error {
+	for _, fd := range c.fds {
+		_ = syscall.Close(fd)
+	}
+	c.fds = nil
+	return c.UnixConn.Close()
+}
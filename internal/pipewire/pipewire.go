@@ -0,0 +1,390 @@
+// Package pipewire implements the PipeWire SecurityContext interface over the native protocol.
+package pipewire
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"hakurei.app/container/check"
+)
+
+const (
+	// Remote contains the name of the server socket
+	// (https://gitlab.freedesktop.org/pipewire/pipewire/-/blob/1.2.7/src/modules/module-protocol-native/local-socket.c#L27)
+	// which is concatenated with PIPEWIRE_RUNTIME_DIR or XDG_RUNTIME_DIR,
+	// or used as-is if absolute.
+	Remote = "PIPEWIRE_REMOTE"
+
+	// FallbackName is used as the PipeWire socket name if PIPEWIRE_REMOTE is unset.
+	FallbackName = "pipewire-0"
+
+	// Timeout is the duration after which an unresponsive server is given up on.
+	Timeout = 5 * time.Second
+)
+
+// Properties attached to clients connecting through a security context. These are
+// understood by the session manager and the access portal.
+const (
+	// PropSecEngine is the name of the sandbox engine.
+	PropSecEngine = "pipewire.sec.engine"
+	// PropAccess selects the access policy applied by the session manager.
+	PropAccess = "pipewire.access"
+	// PropPortalAppID is the application identifier checked by the access portal.
+	PropPortalAppID = "pipewire.access.portal.app_id"
+	// PropInstanceID is the identifier of the sandboxed instance.
+	PropInstanceID = "pipewire.sec.instance_id"
+
+	// SecEngine is the value of [PropSecEngine] set by [New].
+	SecEngine = "app.hakurei"
+	// AccessRestricted is the value of [PropAccess] set by [New].
+	AccessRestricted = "restricted"
+)
+
+// Native protocol object identifiers, interface names and opcodes
+// (https://gitlab.freedesktop.org/pipewire/pipewire/-/blob/1.2.7/src/pipewire/extensions/protocol-native.h).
+const (
+	// version is the native protocol version spoken by this package.
+	version = 3
+
+	idCore     = 0
+	idRegistry = 2
+	idContext  = 3
+
+	coreMethodHello       = 1
+	coreMethodSync        = 2
+	coreMethodPong        = 3
+	coreMethodGetRegistry = 5
+
+	coreEventDone  = 1
+	coreEventPing  = 2
+	coreEventError = 3
+
+	registryMethodBind  = 1
+	registryEventGlobal = 0
+
+	securityContextMethodCreate = 1
+
+	// TypeSecurityContext is the interface name of the SecurityContext global.
+	TypeSecurityContext = "PipeWire:Interface:SecurityContext"
+)
+
+type (
+	// Res is the outcome of a call to [New].
+	Res int
+
+	// An Error represents a failure during [New].
+	Error struct {
+		// Where the failure occurred.
+		Cause Res
+		// Attempted pathname socket.
+		Path string
+		// Pathname socket to host server.
+		Host string
+		// Global errno value set during the fault, or the error reported by the server.
+		Errno error
+	}
+
+	// A ServerError is an error event sent by the PipeWire server.
+	ServerError struct {
+		// Object the error refers to.
+		ID int32
+		// Negative errno value.
+		Res int32
+		// Description of the error.
+		Message string
+	}
+)
+
+const (
+	// RSuccess is returned on a successful call.
+	RSuccess Res = iota
+	// RCreate is returned if ensuring pathname availability failed.
+	RCreate
+	// RHostConnect is returned if connect failed for host server.
+	RHostConnect
+	// RRoundtrip is returned if a roundtrip to the server failed.
+	RRoundtrip
+	// RNotAvail is returned if the server does not implement SecurityContext.
+	RNotAvail
+	// RSocket is returned if socket failed.
+	RSocket
+	// RBind is returned if bind failed.
+	RBind
+	// RListen is returned if listen failed.
+	RListen
+	// RServer is returned if the server reported an error while creating the security context.
+	RServer
+)
+
+// withPrefix returns prefix suffixed with errno description if available.
+func (e *Error) withPrefix(prefix string) string {
+	if e.Errno == nil {
+		return prefix
+	}
+	return prefix + ": " + e.Errno.Error()
+}
+
+func (e *Error) Unwrap() error   { return e.Errno }
+func (e *Error) Message() string { return e.Error() }
+func (e *Error) Error() string {
+	switch e.Cause {
+	case RSuccess:
+		if e.Errno == nil {
+			return "success"
+		}
+		return e.Errno.Error()
+
+	case RCreate:
+		if e.Errno == nil {
+			return "cannot ensure pipewire pathname socket"
+		}
+		return e.Errno.Error()
+	case RHostConnect:
+		return e.withPrefix("cannot connect to " + e.Host)
+	case RRoundtrip:
+		return e.withPrefix("roundtrip to " + e.Host + " failed")
+	case RNotAvail:
+		return "server does not implement " + TypeSecurityContext
+
+	case RSocket:
+		return e.withPrefix("socket")
+	case RBind:
+		return e.withPrefix("cannot bind " + e.Path)
+	case RListen:
+		return e.withPrefix("cannot listen on " + e.Path)
+	case RServer:
+		return e.withPrefix("cannot create security context")
+
+	default:
+		return e.withPrefix("impossible outcome") /* not reached */
+	}
+}
+
+func (e *ServerError) Unwrap() error { return syscall.Errno(-e.Res) }
+func (e *ServerError) Error() string {
+	if e.Message == "" {
+		return syscall.Errno(-e.Res).Error()
+	}
+	return e.Message
+}
+
+// SecurityContext holds resources associated with a PipeWire security context.
+type SecurityContext struct {
+	// Pipe with its write end passed to SecurityContext::Create.
+	closeFds [2]int
+}
+
+// Close releases any resources held by [SecurityContext], and prevents further
+// connections to its associated socket.
+func (sc *SecurityContext) Close() error {
+	if sc == nil {
+		return os.ErrInvalid
+	}
+	return errors.Join(
+		syscall.Close(sc.closeFds[1]),
+		syscall.Close(sc.closeFds[0]),
+	)
+}
+
+// New creates a new security context on the PipeWire server at remotePath
+// and associates it with a new socket bound to bindPath.
+//
+// New does not attach a finalizer to the resulting [SecurityContext] struct.
+// The caller is responsible for calling [SecurityContext.Close].
+//
+// A non-nil error unwraps to concrete type [Error].
+func New(remotePath, bindPath *check.Absolute, appID, instanceID string) (*SecurityContext, error) {
+	// ensure bindPath is available
+	if f, err := os.Create(bindPath.String()); err != nil {
+		return nil, &Error{RCreate, bindPath.String(), remotePath.String(), err}
+	} else if err = f.Close(); err != nil {
+		return nil, &Error{RCreate, bindPath.String(), remotePath.String(), err}
+	} else if err = os.Remove(bindPath.String()); err != nil {
+		return nil, &Error{RCreate, bindPath.String(), remotePath.String(), err}
+	}
+
+	c, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: remotePath.String(), Net: "unix"})
+	if err != nil {
+		return nil, &Error{RHostConnect, bindPath.String(), remotePath.String(), err}
+	}
+	if err = c.SetDeadline(time.Now().Add(Timeout)); err != nil {
+		_ = c.Close()
+		return nil, &Error{RHostConnect, bindPath.String(), remotePath.String(), err}
+	}
+
+	// write end passed to SecurityContext::Create close_fd
+	var closeFds [2]int
+	if err = syscall.Pipe2(closeFds[0:], syscall.O_CLOEXEC); err != nil {
+		_ = c.Close()
+		return nil, &Error{RSocket, bindPath.String(), remotePath.String(), err}
+	}
+
+	pc := conn{UnixConn: c}
+	err = securityContextCreate(&pc, bindPath.String(), [][2]string{
+		{PropSecEngine, SecEngine},
+		{PropAccess, AccessRestricted},
+		{PropPortalAppID, appID},
+		{PropInstanceID, instanceID},
+	}, closeFds[1])
+	_ = pc.Close()
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			e.Host = remotePath.String()
+		}
+		// do not leak the pipe
+		return nil, errors.Join(err,
+			syscall.Close(closeFds[1]),
+			syscall.Close(closeFds[0]),
+		)
+	}
+	return &SecurityContext{closeFds}, nil
+}
+
+// securityContextCreate binds a socket to socketPath and associates it with a security context
+// created on the server connected to c, carrying props.
+//
+// A non-nil error has concrete type [Error].
+func securityContextCreate(c *conn, socketPath string, props [][2]string, closeFd int) error {
+	for _, item := range props {
+		if hasNull(item[0]) || hasNull(item[1]) {
+			return &Error{Cause: RBind, Path: socketPath, Errno: errors.New("argument contains NUL character")}
+		}
+	}
+	if len(socketPath) >= len(syscall.RawSockaddrUnix{}.Path) {
+		return &Error{Cause: RBind, Path: socketPath, Errno: errors.New("socket pathname too long")}
+	}
+
+	if err := c.send(idCore, coreMethodHello, new(podBuilder).Push().Int(version).Pop().buf); err != nil {
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	if err := c.send(idCore, coreMethodGetRegistry, new(podBuilder).Push().Int(version).Int(idRegistry).Pop().buf); err != nil {
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	var (
+		global        = int32(-1)
+		globalVersion int32
+	)
+	if err := roundtrip(c, 1, func(m *message) error {
+		if m.id != idRegistry || m.opcode != registryEventGlobal {
+			return nil
+		}
+		fields, err := parseStruct(m.payload)
+		if err != nil || len(fields) < 4 {
+			return ErrMessage
+		}
+		if t, _ := fields[2].String(); t == TypeSecurityContext {
+			if global, err = fields[0].Int(); err != nil {
+				return err
+			}
+			if globalVersion, err = fields[3].Int(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	if global < 0 {
+		return &Error{Cause: RNotAvail, Path: socketPath}
+	}
+
+	listenFd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return &Error{Cause: RSocket, Path: socketPath, Errno: err}
+	}
+	defer func() { _ = syscall.Close(listenFd) }()
+	if err = syscall.Bind(listenFd, &syscall.SockaddrUnix{Name: socketPath}); err != nil {
+		return &Error{Cause: RBind, Path: socketPath, Errno: err}
+	}
+	if err = syscall.Listen(listenFd, 0); err != nil {
+		return &Error{Cause: RListen, Path: socketPath, Errno: err}
+	}
+
+	if err = c.send(idRegistry, registryMethodBind, new(podBuilder).Push().
+		Int(global).String(TypeSecurityContext).Int(min(globalVersion, version)).Int(idContext).
+		Pop().buf); err != nil {
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	if err = c.send(idContext, securityContextMethodCreate, new(podBuilder).Push().
+		Fd(0).Fd(1).Dict(props).
+		Pop().buf, listenFd, closeFd); err != nil {
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	if err = roundtrip(c, 2, nil); err != nil {
+		var serverError *ServerError
+		if errors.As(err, &serverError) {
+			return &Error{Cause: RServer, Path: socketPath, Errno: err}
+		}
+		return &Error{Cause: RRoundtrip, Path: socketPath, Errno: err}
+	}
+	return nil
+}
+
+// roundtrip sends Core::Sync and handles events until the matching Core::Done arrives.
+// Events not handled by roundtrip are passed to f if it is not nil.
+func roundtrip(c *conn, seq int32, f func(m *message) error) error {
+	if err := c.send(idCore, coreMethodSync, new(podBuilder).Push().Int(idCore).Int(seq).Pop().buf); err != nil {
+		return err
+	}
+
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return err
+		}
+		m.closeFds()
+
+		if m.id != idCore {
+			if f != nil {
+				if err = f(m); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		switch m.opcode {
+		case coreEventDone:
+			fields, err := parseStruct(m.payload)
+			if err != nil || len(fields) < 2 {
+				return ErrMessage
+			}
+			if id, _ := fields[0].Int(); id != idCore {
+				continue
+			}
+			if s, _ := fields[1].Int(); s == seq {
+				return nil
+			}
+
+		case coreEventPing:
+			fields, err := parseStruct(m.payload)
+			if err != nil || len(fields) < 2 {
+				return ErrMessage
+			}
+			id, _ := fields[0].Int()
+			s, _ := fields[1].Int()
+			if err = c.send(idCore, coreMethodPong, new(podBuilder).Push().Int(id).Int(s).Pop().buf); err != nil {
+				return err
+			}
+
+		case coreEventError:
+			fields, err := parseStruct(m.payload)
+			if err != nil || len(fields) < 4 {
+				return ErrMessage
+			}
+			var e ServerError
+			e.ID, _ = fields[0].Int()
+			e.Res, _ = fields[2].Int()
+			e.Message, _ = fields[3].String()
+			return &e
+		}
+	}
+}
+
+// hasNull returns whether s contains the NUL character.
+func hasNull(s string) bool { return strings.IndexByte(s, 0) > -1 }
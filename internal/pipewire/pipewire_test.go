@@ -0,0 +1,273 @@
+package pipewire
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/check"
+)
+
+func TestSecurityContextClose(t *testing.T) {
+	t.Parallel()
+
+	if err := (*SecurityContext)(nil).Close(); !reflect.DeepEqual(err, os.ErrInvalid) {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	var ctx SecurityContext
+	if err := syscall.Pipe2(ctx.closeFds[0:], syscall.O_CLOEXEC); err != nil {
+		t.Fatalf("Pipe: error = %v", err)
+	}
+
+	if err := ctx.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	wantErr := errors.Join(syscall.EBADF, syscall.EBADF)
+	if err := ctx.Close(); !reflect.DeepEqual(err, wantErr) {
+		t.Fatalf("Close: error = %#v, want %#v", err, wantErr)
+	}
+}
+
+func TestPod(t *testing.T) {
+	t.Parallel()
+
+	want := [][2]string{{PropSecEngine, SecEngine}, {"", "\xff"}}
+	b := new(podBuilder).Push().Int(-1).String("pipewire-0").Fd(1).Dict(want).Pop()
+	if len(b.buf)%8 != 0 {
+		t.Fatalf("Pop: size = %d", len(b.buf))
+	}
+
+	fields, err := parseStruct(append(b.buf, new(podBuilder).Push().Pop().buf...))
+	if err != nil {
+		t.Fatalf("parseStruct: error = %v", err)
+	}
+	if len(fields) != 4 {
+		t.Fatalf("parseStruct: %d fields", len(fields))
+	}
+	if v, err := fields[0].Int(); err != nil || v != -1 {
+		t.Errorf("Int: %d, error = %v", v, err)
+	}
+	if v, err := fields[1].String(); err != nil || v != "pipewire-0" {
+		t.Errorf("String: %q, error = %v", v, err)
+	}
+	if v, err := fields[2].Fd(); err != nil || v != 1 {
+		t.Errorf("Fd: %d, error = %v", v, err)
+	}
+	if v, err := fields[3].Dict(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Errorf("Dict: %q, error = %v", v, err)
+	}
+
+	if _, err = fields[0].String(); !errors.Is(err, ErrPod) {
+		t.Errorf("String: error = %v", err)
+	}
+	if _, err = parseStruct(b.buf[:len(b.buf)-9]); !errors.Is(err, ErrPod) {
+		t.Errorf("parseStruct: error = %v", err)
+	}
+}
+
+// fakeServer implements the parts of a PipeWire server exercised by [New].
+type fakeServer struct {
+	// Whether to advertise the SecurityContext global.
+	global bool
+	// Whether to reject SecurityContext::Create.
+	reject bool
+
+	// Properties received via SecurityContext::Create.
+	props [][2]string
+	// File descriptors received via SecurityContext::Create.
+	listenFd, closeFd int
+}
+
+// serve handles a single client connected to l.
+func (s *fakeServer) serve(l *net.UnixListener) error {
+	uc, err := l.AcceptUnix()
+	if err != nil {
+		return err
+	}
+	c := conn{UnixConn: uc}
+	defer func() { _ = c.Close() }()
+
+	for {
+		var m *message
+		if m, err = c.receive(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var fields []pod
+		if fields, err = parseStruct(m.payload); err != nil {
+			return err
+		}
+
+		switch {
+		case m.id == idCore && m.opcode == coreMethodHello,
+			m.id == idCore && m.opcode == coreMethodPong:
+			m.closeFds()
+
+		case m.id == idCore && m.opcode == coreMethodGetRegistry:
+			if err = c.send(idCore, coreEventPing, new(podBuilder).Push().Int(idCore).Int(0xbad).Pop().buf); err != nil {
+				return err
+			}
+			if err = c.send(idRegistry, registryEventGlobal, new(podBuilder).Push().
+				Int(0).Int(0x1c8).String("PipeWire:Interface:Core").Int(4).Dict(nil).
+				Pop().buf); err != nil {
+				return err
+			}
+			if s.global {
+				if err = c.send(idRegistry, registryEventGlobal, new(podBuilder).Push().
+					Int(3).Int(0x1c8).String(TypeSecurityContext).Int(3).Dict(nil).
+					Pop().buf); err != nil {
+					return err
+				}
+			}
+
+		case m.id == idCore && m.opcode == coreMethodSync:
+			if err = c.send(idCore, coreEventDone, m.payload); err != nil {
+				return err
+			}
+
+		case m.id == idRegistry && m.opcode == registryMethodBind:
+			if id, _ := fields[0].Int(); id != 3 {
+				return errors.New("bind unexpected global")
+			}
+
+		case m.id == idContext && m.opcode == securityContextMethodCreate:
+			if len(m.fds) != 2 || len(fields) != 3 {
+				return errors.New("unexpected create arguments")
+			}
+			s.listenFd, s.closeFd = m.fds[0], m.fds[1]
+			if s.props, err = fields[2].Dict(); err != nil {
+				return err
+			}
+			if s.reject {
+				if err = c.send(idCore, coreEventError, new(podBuilder).Push().
+					Int(idContext).Int(int32(m.seq)).Int(-int32(syscall.EPERM)).String("not allowed").
+					Pop().buf); err != nil {
+					return err
+				}
+			}
+
+		default:
+			return errors.New("unexpected message")
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	newServer := func(t *testing.T, s *fakeServer) (remotePath, bindPath *check.Absolute, done <-chan error) {
+		d := t.TempDir()
+		remotePath, bindPath = check.MustAbs(path.Join(d, FallbackName)), check.MustAbs(path.Join(d, "pipewire"))
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: remotePath.String(), Net: "unix"})
+		if err != nil {
+			t.Fatalf("ListenUnix: error = %v", err)
+		}
+		t.Cleanup(func() { _ = l.Close() })
+
+		c := make(chan error, 1)
+		go func() { c <- s.serve(l) }()
+		return remotePath, bindPath, c
+	}
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		s := fakeServer{global: true}
+		remotePath, bindPath, done := newServer(t, &s)
+
+		ctx, err := New(remotePath, bindPath, "org.chromium.Chromium", "ebf083d1b175911782d413369b64ce7c")
+		if err != nil {
+			t.Fatalf("New: error = %v", err)
+		}
+		if err = <-done; err != nil {
+			t.Fatalf("serve: error = %v", err)
+		}
+		t.Cleanup(func() { _ = syscall.Close(s.listenFd); _ = syscall.Close(s.closeFd) })
+
+		wantProps := [][2]string{
+			{"pipewire.sec.engine", "app.hakurei"},
+			{"pipewire.access", "restricted"},
+			{"pipewire.access.portal.app_id", "org.chromium.Chromium"},
+			{"pipewire.sec.instance_id", "ebf083d1b175911782d413369b64ce7c"},
+		}
+		if !reflect.DeepEqual(s.props, wantProps) {
+			t.Errorf("New: props = %q, want %q", s.props, wantProps)
+		}
+
+		// connections to bindPath are accepted on the listening socket passed to the server
+		var client net.Conn
+		if client, err = net.Dial("unix", bindPath.String()); err != nil {
+			t.Fatalf("Dial: error = %v", err)
+		}
+		_ = client.Close()
+		if nfd, _, acceptErr := syscall.Accept(s.listenFd); acceptErr != nil {
+			t.Fatalf("Accept: error = %v", acceptErr)
+		} else {
+			_ = syscall.Close(nfd)
+		}
+
+		// closing the security context hangs up close_fd
+		if err = ctx.Close(); err != nil {
+			t.Fatalf("Close: error = %v", err)
+		}
+		if _, err = syscall.Write(s.closeFd, []byte{0}); !errors.Is(err, syscall.EPIPE) {
+			t.Errorf("Write: error = %v", err)
+		}
+	})
+
+	t.Run("not available", func(t *testing.T) {
+		t.Parallel()
+		remotePath, bindPath, done := newServer(t, new(fakeServer))
+
+		wantErr := &Error{RNotAvail, bindPath.String(), remotePath.String(), nil}
+		if _, err := New(remotePath, bindPath, "", ""); !reflect.DeepEqual(err, errors.Join(wantErr, nil, nil)) {
+			t.Errorf("New: error = %v, want %v", err, wantErr)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("serve: error = %v", err)
+		}
+		if _, err := os.Stat(bindPath.String()); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat: error = %v", err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+		s := fakeServer{global: true, reject: true}
+		remotePath, bindPath, done := newServer(t, &s)
+
+		wantErr := &Error{RServer, bindPath.String(), remotePath.String(), &ServerError{idContext, -int32(syscall.EPERM), "not allowed"}}
+		_, err := New(remotePath, bindPath, "", "")
+		if !reflect.DeepEqual(err, errors.Join(wantErr, nil, nil)) {
+			t.Errorf("New: error = %v, want %v", err, wantErr)
+		}
+		if !errors.Is(err, syscall.EPERM) {
+			t.Errorf("New: error = %v", err)
+		}
+		if err = <-done; err != nil {
+			t.Fatalf("serve: error = %v", err)
+		}
+		_ = syscall.Close(s.listenFd)
+		_ = syscall.Close(s.closeFd)
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		t.Parallel()
+		d := check.MustAbs(t.TempDir())
+		remotePath, bindPath := d.Append(FallbackName), d.Append("pipewire")
+
+		var e *Error
+		if _, err := New(remotePath, bindPath, "", ""); !errors.As(err, &e) || e.Cause != RHostConnect {
+			t.Errorf("New: error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package pipewire
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// SPA POD types used by the native protocol
+// (https://gitlab.freedesktop.org/pipewire/pipewire/-/blob/1.2.7/spa/include/spa/utils/type.h#L29).
+const (
+	podInt    = 4
+	podString = 8
+	podStruct = 14
+	podFd     = 18
+)
+
+// ErrPod is returned when decoding a malformed SPA POD.
+var ErrPod = errors.New("malformed SPA POD")
+
+// padPod returns size rounded up to the 8-byte POD alignment.
+func padPod(size int) int { return (size + 7) &^ 7 }
+
+// podBuilder appends SPA POD values to buf.
+type podBuilder struct {
+	buf []byte
+	// Offsets of headers of open structs.
+	frames []int
+}
+
+// header appends the header of a POD of the specified size and type.
+func (b *podBuilder) header(size, t uint32) {
+	b.buf = binary.NativeEndian.AppendUint32(b.buf, size)
+	b.buf = binary.NativeEndian.AppendUint32(b.buf, t)
+}
+
+// pad appends zero bytes until buf is aligned.
+func (b *podBuilder) pad() {
+	for len(b.buf)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// Int appends a 32-bit integer.
+func (b *podBuilder) Int(v int32) *podBuilder {
+	b.header(4, podInt)
+	b.buf = binary.NativeEndian.AppendUint32(b.buf, uint32(v))
+	b.pad()
+	return b
+}
+
+// String appends a NUL-terminated string.
+func (b *podBuilder) String(s string) *podBuilder {
+	b.header(uint32(len(s)+1), podString)
+	b.buf = append(append(b.buf, s...), 0)
+	b.pad()
+	return b
+}
+
+// Fd appends a reference to the file descriptor at index in the message.
+func (b *podBuilder) Fd(index int64) *podBuilder {
+	b.header(8, podFd)
+	b.buf = binary.NativeEndian.AppendUint64(b.buf, uint64(index))
+	return b
+}
+
+// Push opens a struct. Every call to Push must be matched by a call to Pop.
+func (b *podBuilder) Push() *podBuilder {
+	b.frames = append(b.frames, len(b.buf))
+	b.header(0, podStruct)
+	return b
+}
+
+// Pop closes the innermost open struct.
+func (b *podBuilder) Pop() *podBuilder {
+	off := b.frames[len(b.frames)-1]
+	b.frames = b.frames[:len(b.frames)-1]
+	binary.NativeEndian.PutUint32(b.buf[off:], uint32(len(b.buf)-off-8))
+	return b
+}
+
+// Dict appends a struct holding the number of items followed by each key and value.
+func (b *podBuilder) Dict(items [][2]string) *podBuilder {
+	b.Push().Int(int32(len(items)))
+	for _, item := range items {
+		b.String(item[0]).String(item[1])
+	}
+	return b.Pop()
+}
+
+// pod is a decoded SPA POD referring to its body.
+type pod struct {
+	t    uint32
+	body []byte
+}
+
+// parsePod decodes the POD at the start of data and returns the remaining bytes.
+func parsePod(data []byte) (p pod, rest []byte, err error) {
+	if len(data) < 8 {
+		return p, nil, ErrPod
+	}
+	size := int(binary.NativeEndian.Uint32(data))
+	p.t = binary.NativeEndian.Uint32(data[4:])
+	if size > len(data)-8 {
+		return p, nil, ErrPod
+	}
+	p.body = data[8 : 8+size]
+	rest = data[min(8+padPod(size), len(data)):]
+	return
+}
+
+// Int returns the value of an integer POD.
+func (p pod) Int() (int32, error) {
+	if p.t != podInt || len(p.body) < 4 {
+		return 0, ErrPod
+	}
+	return int32(binary.NativeEndian.Uint32(p.body)), nil
+}
+
+// String returns the value of a string POD.
+func (p pod) String() (string, error) {
+	if p.t != podString || len(p.body) < 1 || p.body[len(p.body)-1] != 0 {
+		return "", ErrPod
+	}
+	return string(p.body[:len(p.body)-1]), nil
+}
+
+// Fd returns the file descriptor index referred to by a file descriptor POD.
+func (p pod) Fd() (int64, error) {
+	if p.t != podFd || len(p.body) < 8 {
+		return 0, ErrPod
+	}
+	return int64(binary.NativeEndian.Uint64(p.body)), nil
+}
+
+// Fields returns the members of a struct POD.
+func (p pod) Fields() (fields []pod, err error) {
+	if p.t != podStruct {
+		return nil, ErrPod
+	}
+	for data := p.body; len(data) > 0; {
+		var field pod
+		if field, data, err = parsePod(data); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return
+}
+
+// Dict returns the items of a dictionary struct.
+func (p pod) Dict() (items [][2]string, err error) {
+	var fields []pod
+	if fields, err = p.Fields(); err != nil {
+		return
+	}
+	if len(fields) < 1 {
+		return nil, ErrPod
+	}
+	var n int32
+	if n, err = fields[0].Int(); err != nil {
+		return
+	}
+	if n < 0 || len(fields) != 1+2*int(n) {
+		return nil, ErrPod
+	}
+	items = make([][2]string, n)
+	for i := range items {
+		if items[i][0], err = fields[1+2*i].String(); err != nil {
+			return nil, err
+		}
+		if items[i][1], err = fields[2+2*i].String(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// parseStruct decodes the struct POD at the start of a message payload and returns its members,
+// ignoring any trailing footer.
+func parseStruct(payload []byte) ([]pod, error) {
+	if p, _, err := parsePod(payload); err != nil {
+		return nil, err
+	} else {
+		return p.Fields()
+	}
+}
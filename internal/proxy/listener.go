@@ -0,0 +1,87 @@
+// Package proxy implements the listener and connection bookkeeping shared by the built-in socket proxies.
+package proxy
+
+import (
+	"net"
+	"os"
+	"sync"
+)
+
+// Listener accepts connections on a pathname socket and tracks the connections
+// relayed on behalf of its clients, closing them all on [Listener.Close].
+type Listener struct {
+	l  *net.UnixListener
+	wg sync.WaitGroup
+
+	// Active connections, closed by Close.
+	conns  map[*net.UnixConn]struct{}
+	closed bool
+	mu     sync.Mutex
+}
+
+// Listen binds a pathname socket to pathname.
+// The pathname socket is not removed by [Listener.Close], this is the responsibility of the caller.
+func Listen(pathname string) (*Listener, error) {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: pathname, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	l.SetUnlinkOnClose(false)
+	return &Listener{l: l, conns: make(map[*net.UnixConn]struct{})}, nil
+}
+
+// Accept waits for and returns the next client connection.
+func (l *Listener) Accept() (*net.UnixConn, error) { return l.l.AcceptUnix() }
+
+// Go calls f in a new goroutine which [Listener.Close] waits for.
+func (l *Listener) Go(f func()) {
+	l.wg.Add(1)
+	go func() { defer l.wg.Done(); f() }()
+}
+
+// Track adds conns to the set of active connections, and closes them if the listener is closed.
+func (l *Listener) Track(conns ...*net.UnixConn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		l.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+// Untrack closes conns and removes them from the set of active connections. Nil conns are skipped.
+func (l *Listener) Untrack(conns ...*net.UnixConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range conns {
+		if conn != nil {
+			_ = conn.Close()
+			delete(l.conns, conn)
+		}
+	}
+}
+
+// Close stops accepting connections, closes every active connection and waits for all
+// goroutines started by [Listener.Go] to return.
+func (l *Listener) Close() error {
+	if l == nil {
+		return os.ErrInvalid
+	}
+
+	l.mu.Lock()
+	l.closed = true
+	for conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.mu.Unlock()
+
+	err := l.l.Close()
+	l.wg.Wait()
+	return err
+}
@@ -0,0 +1,73 @@
+package proxy_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"hakurei.app/internal/proxy"
+)
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+
+	pathname := path.Join(t.TempDir(), "proxy")
+	l, err := proxy.Listen(pathname)
+	if err != nil {
+		t.Fatalf("Listen: error = %v", err)
+	}
+
+	var client *net.UnixConn
+	if client, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: pathname, Net: "unix"}); err != nil {
+		t.Fatalf("DialUnix: error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	tracked, done := make(chan struct{}), make(chan error, 1)
+	l.Go(func() {
+		conn, acceptErr := l.Accept()
+		if acceptErr != nil {
+			close(tracked)
+			done <- acceptErr
+			return
+		}
+		ok := l.Track(conn)
+		close(tracked)
+		if !ok {
+			done <- errors.New("Track: unexpected false")
+			return
+		}
+		// returns once Close closes the tracked connection
+		_, acceptErr = io.ReadAll(conn)
+		l.Untrack(conn, nil)
+		done <- acceptErr
+	})
+
+	<-tracked
+	if err = l.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+	if err = <-done; err != nil && !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Go: error = %v", err)
+	}
+
+	if _, err = os.Stat(pathname); err != nil {
+		t.Errorf("Stat: error = %v", err)
+	}
+
+	var conn *net.UnixConn
+	if conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: pathname, Net: "unix"}); err == nil {
+		_ = conn.Close()
+		t.Errorf("DialUnix: unexpected success")
+	}
+	if l.Track(new(net.UnixConn)) {
+		t.Errorf("Track: unexpected true")
+	}
+
+	if err = (*proxy.Listener)(nil).Close(); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Close: error = %v", err)
+	}
+}
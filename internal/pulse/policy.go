@@ -0,0 +1,174 @@
+package pulse
+
+import "hakurei.app/hst"
+
+// Commands of the PulseAudio native protocol referred to by the proxy
+// (https://gitlab.freedesktop.org/pulseaudio/pulseaudio/-/blob/v17.0/src/pulsecore/native-common.h#L34).
+const (
+	commandError                = 0
+	commandReply                = 2
+	commandCreatePlaybackStream = 3
+	commandCreateRecordStream   = 5
+	commandAuth                 = 8
+
+	// commandMax is one above the largest known command.
+	commandMax = 105
+)
+
+const (
+	// protocolFlagSHM is set in the protocol version of AUTH if the peer supports POSIX shared memory.
+	protocolFlagSHM = 0x80000000
+	// protocolFlagMemfd is set in the protocol version of AUTH if the peer supports memfd shared memory.
+	protocolFlagMemfd = 0x40000000
+
+	// cookieSize is the size of the authentication cookie in AUTH.
+	cookieSize = 256
+
+	// errAccess is the PulseAudio error code replied to denied commands.
+	errAccess = 1
+)
+
+// class is the permission class of a command sent by the client.
+type class byte
+
+const (
+	// classDeny is never granted.
+	classDeny class = iota
+	// classAllow is always granted.
+	classAllow
+	// classRecord is granted by [hst.PulseConfig.Record].
+	classRecord
+	// classModules is granted by [hst.PulseConfig.Modules].
+	classModules
+	// classIntrospect is granted by [hst.PulseConfig.Introspect].
+	classIntrospect
+	// classControl is granted by [hst.PulseConfig.Control].
+	classControl
+
+	// classSinkInput operates on the sink input in its first argument, and is granted
+	// if the sink input is owned by the connection or by [hst.PulseConfig.Control].
+	classSinkInput
+	// classSinkInputInfo is like classSinkInput, but granted by [hst.PulseConfig.Introspect].
+	classSinkInputInfo
+	// classSourceOutput operates on the source output in its first argument, and is granted
+	// under [hst.PulseConfig.Record] if the source output is owned by the connection or by [hst.PulseConfig.Control].
+	classSourceOutput
+	// classSourceOutputInfo is like classSourceOutput, but granted by [hst.PulseConfig.Introspect].
+	classSourceOutputInfo
+)
+
+// commandClass holds the permission class of every known command. Commands sent by the server,
+// and commands absent from this table, are denied.
+var commandClass = [commandMax]class{
+	3:   classAllow,            // CREATE_PLAYBACK_STREAM
+	4:   classAllow,            // DELETE_PLAYBACK_STREAM
+	5:   classRecord,           // CREATE_RECORD_STREAM
+	6:   classRecord,           // DELETE_RECORD_STREAM
+	7:   classControl,          // EXIT
+	8:   classAllow,            // AUTH, rewritten by the proxy
+	9:   classAllow,            // SET_CLIENT_NAME
+	10:  classAllow,            // LOOKUP_SINK
+	11:  classRecord,           // LOOKUP_SOURCE
+	12:  classAllow,            // DRAIN_PLAYBACK_STREAM
+	13:  classAllow,            // STAT
+	14:  classAllow,            // GET_PLAYBACK_LATENCY
+	15:  classControl,          // CREATE_UPLOAD_STREAM
+	16:  classControl,          // DELETE_UPLOAD_STREAM
+	17:  classControl,          // FINISH_UPLOAD_STREAM
+	18:  classAllow,            // PLAY_SAMPLE
+	19:  classControl,          // REMOVE_SAMPLE
+	20:  classAllow,            // GET_SERVER_INFO
+	21:  classAllow,            // GET_SINK_INFO
+	22:  classAllow,            // GET_SINK_INFO_LIST
+	23:  classRecord,           // GET_SOURCE_INFO
+	24:  classRecord,           // GET_SOURCE_INFO_LIST
+	25:  classIntrospect,       // GET_MODULE_INFO
+	26:  classIntrospect,       // GET_MODULE_INFO_LIST
+	27:  classIntrospect,       // GET_CLIENT_INFO
+	28:  classIntrospect,       // GET_CLIENT_INFO_LIST
+	29:  classSinkInputInfo,    // GET_SINK_INPUT_INFO
+	30:  classIntrospect,       // GET_SINK_INPUT_INFO_LIST
+	31:  classSourceOutputInfo, // GET_SOURCE_OUTPUT_INFO
+	32:  classIntrospect,       // GET_SOURCE_OUTPUT_INFO_LIST
+	33:  classIntrospect,       // GET_SAMPLE_INFO
+	34:  classIntrospect,       // GET_SAMPLE_INFO_LIST
+	35:  classIntrospect,       // SUBSCRIBE
+	36:  classControl,          // SET_SINK_VOLUME
+	37:  classSinkInput,        // SET_SINK_INPUT_VOLUME
+	38:  classControl,          // SET_SOURCE_VOLUME
+	39:  classControl,          // SET_SINK_MUTE
+	40:  classControl,          // SET_SOURCE_MUTE
+	41:  classAllow,            // CORK_PLAYBACK_STREAM
+	42:  classAllow,            // FLUSH_PLAYBACK_STREAM
+	43:  classAllow,            // TRIGGER_PLAYBACK_STREAM
+	44:  classControl,          // SET_DEFAULT_SINK
+	45:  classControl,          // SET_DEFAULT_SOURCE
+	46:  classAllow,            // SET_PLAYBACK_STREAM_NAME
+	47:  classRecord,           // SET_RECORD_STREAM_NAME
+	48:  classControl,          // KILL_CLIENT
+	49:  classSinkInput,        // KILL_SINK_INPUT
+	50:  classSourceOutput,     // KILL_SOURCE_OUTPUT
+	51:  classModules,          // LOAD_MODULE
+	52:  classModules,          // UNLOAD_MODULE
+	57:  classRecord,           // GET_RECORD_LATENCY
+	58:  classRecord,           // CORK_RECORD_STREAM
+	59:  classRecord,           // FLUSH_RECORD_STREAM
+	60:  classAllow,            // PREBUF_PLAYBACK_STREAM
+	67:  classSinkInput,        // MOVE_SINK_INPUT
+	68:  classSourceOutput,     // MOVE_SOURCE_OUTPUT
+	69:  classSinkInput,        // SET_SINK_INPUT_MUTE
+	70:  classControl,          // SUSPEND_SINK
+	71:  classControl,          // SUSPEND_SOURCE
+	72:  classAllow,            // SET_PLAYBACK_STREAM_BUFFER_ATTR
+	73:  classRecord,           // SET_RECORD_STREAM_BUFFER_ATTR
+	74:  classAllow,            // UPDATE_PLAYBACK_STREAM_SAMPLE_RATE
+	75:  classRecord,           // UPDATE_RECORD_STREAM_SAMPLE_RATE
+	80:  classRecord,           // UPDATE_RECORD_STREAM_PROPLIST
+	81:  classAllow,            // UPDATE_PLAYBACK_STREAM_PROPLIST
+	82:  classAllow,            // UPDATE_CLIENT_PROPLIST
+	83:  classRecord,           // REMOVE_RECORD_STREAM_PROPLIST
+	84:  classAllow,            // REMOVE_PLAYBACK_STREAM_PROPLIST
+	85:  classAllow,            // REMOVE_CLIENT_PROPLIST
+	87:  classModules,          // EXTENSION
+	88:  classIntrospect,       // GET_CARD_INFO
+	89:  classIntrospect,       // GET_CARD_INFO_LIST
+	90:  classControl,          // SET_CARD_PROFILE
+	96:  classControl,          // SET_SINK_PORT
+	97:  classControl,          // SET_SOURCE_PORT
+	98:  classSourceOutput,     // SET_SOURCE_OUTPUT_VOLUME
+	99:  classSourceOutput,     // SET_SOURCE_OUTPUT_MUTE
+	100: classControl,          // SET_PORT_LATENCY_OFFSET
+	104: classModules,          // SEND_OBJECT_MESSAGE
+}
+
+// allow returns whether command is granted under policy. The owned function reports whether the
+// object in the first argument of command is owned by the connection, and is only called if needed.
+func allow(policy *hst.PulseConfig, command uint32, owned func(record bool) bool) bool {
+	if command >= commandMax {
+		return false
+	}
+	switch commandClass[command] {
+	case classAllow:
+		return true
+	case classRecord:
+		return policy.Record
+	case classModules:
+		return policy.Modules
+	case classIntrospect:
+		return policy.Introspect
+	case classControl:
+		return policy.Control
+
+	case classSinkInput:
+		return policy.Control || owned(false)
+	case classSinkInputInfo:
+		return policy.Introspect || owned(false)
+	case classSourceOutput:
+		return policy.Record && (policy.Control || owned(true))
+	case classSourceOutputInfo:
+		return policy.Record && (policy.Introspect || owned(true))
+
+	default:
+		return false
+	}
+}
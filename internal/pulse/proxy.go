@@ -0,0 +1,269 @@
+// Package pulse implements a filtering proxy for the PulseAudio native protocol.
+package pulse
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/proxy"
+	"hakurei.app/message"
+)
+
+// Proxy accepts connections on a pathname socket and relays them to a PulseAudio server,
+// denying commands not granted by its [hst.PulseConfig].
+type Proxy struct {
+	msg      message.Msg
+	upstream string
+	cookie   []byte
+	policy   hst.PulseConfig
+
+	l *proxy.Listener
+}
+
+// New binds a pathname socket to bindPath and relays connections accepted on it to the server
+// at upstream. If cookie is not nil, it replaces the authentication cookie sent by clients.
+//
+// New does not attach a finalizer to the resulting [Proxy] struct.
+// The caller is responsible for calling [Proxy.Close].
+func New(
+	msg message.Msg,
+	upstream, bindPath *check.Absolute,
+	cookie []byte,
+	policy *hst.PulseConfig,
+) (*Proxy, error) {
+	if policy == nil {
+		return nil, os.ErrInvalid
+	}
+	if cookie != nil && len(cookie) != cookieSize {
+		return nil, syscall.EINVAL
+	}
+
+	p := Proxy{
+		msg:      msg,
+		upstream: upstream.String(),
+		cookie:   cookie,
+		policy:   *policy,
+	}
+	var err error
+	if p.l, err = proxy.Listen(bindPath.String()); err != nil {
+		return nil, err
+	}
+
+	p.l.Go(p.serve)
+	return &p, nil
+}
+
+// Close stops accepting connections, closes every active connection and waits for all
+// goroutines started by [Proxy] to return.
+func (p *Proxy) Close() error {
+	if p == nil {
+		return os.ErrInvalid
+	}
+
+	return p.l.Close()
+}
+
+// serve accepts connections until the listener is closed.
+func (p *Proxy) serve() {
+	for {
+		client, err := p.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				p.msg.Verbosef("cannot accept PulseAudio client: %v", err)
+			}
+			return
+		}
+
+		var server *net.UnixConn
+		if server, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: p.upstream, Net: "unix"}); err != nil {
+			p.msg.Verbosef("cannot connect to PulseAudio server: %v", err)
+			_ = client.Close()
+			continue
+		}
+		if !p.l.Track(client, server) {
+			return
+		}
+
+		c := conn{p: p, client: client, server: server,
+			pending:       make(map[uint32]bool),
+			sinkInputs:    make(map[uint32]struct{}),
+			sourceOutputs: make(map[uint32]struct{}),
+		}
+		p.l.Go(func() {
+			if err := c.upstream(); !isClosed(err) {
+				p.msg.Verbosef("PulseAudio client: %v", err)
+			}
+			p.l.Untrack(client, server)
+		})
+		p.l.Go(func() {
+			if err := c.downstream(); !isClosed(err) {
+				p.msg.Verbosef("PulseAudio server: %v", err)
+			}
+			p.l.Untrack(client, server)
+		})
+	}
+}
+
+// isClosed returns whether err is nil or the result of either end closing the connection.
+func isClosed(err error) bool {
+	return err == nil || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
+
+// conn holds the state of a proxied connection.
+type conn struct {
+	p              *Proxy
+	client, server *net.UnixConn
+	// Serialises writes to client.
+	clientMu sync.Mutex
+
+	// Tags of stream creation commands awaiting a reply, mapped to whether the stream records.
+	pending map[uint32]bool
+	// Sink inputs and source outputs created on this connection.
+	sinkInputs, sourceOutputs map[uint32]struct{}
+	mu                        sync.Mutex
+}
+
+// reply sends f to the client.
+func (c *conn) reply(f *frame) error {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	_, err := c.client.Write(f.encode())
+	return err
+}
+
+// owned returns whether the sink input or source output at index was created on this connection.
+func (c *conn) owned(record bool, index uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if record {
+		_, ok := c.sourceOutputs[index]
+		return ok
+	}
+	_, ok := c.sinkInputs[index]
+	return ok
+}
+
+// upstream relays frames sent by the client to the server.
+func (c *conn) upstream() error {
+	for {
+		f, err := readFrame(c.client)
+		if err != nil {
+			return err
+		}
+
+		if f.channel != channelControl {
+			if f.flags&flagSHMMask != 0 {
+				return errors.New("client referred to shared memory")
+			}
+			if _, err = c.server.Write(f.encode()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		t := tagstruct{f.payload}
+		var command, tag uint32
+		if command, err = t.u32(); err != nil {
+			return err
+		}
+		if tag, err = t.u32(); err != nil {
+			return err
+		}
+
+		if !allow(&c.p.policy, command, func(record bool) bool {
+			index, indexErr := (&tagstruct{t.data}).u32()
+			return indexErr == nil && c.owned(record, index)
+		}) {
+			c.p.msg.Verbosef("denied PulseAudio command %d", command)
+			if err = c.reply(newControl(commandError, tag, errAccess)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch command {
+		case commandAuth:
+			if err = c.auth(tag, &t); err != nil {
+				return err
+			}
+			continue
+
+		case commandCreatePlaybackStream, commandCreateRecordStream:
+			c.mu.Lock()
+			c.pending[tag] = command == commandCreateRecordStream
+			c.mu.Unlock()
+		}
+
+		if _, err = c.server.Write(f.encode()); err != nil {
+			return err
+		}
+	}
+}
+
+// auth relays AUTH with shared memory support masked out, the cookie replaced if available,
+// and the credentials of the proxy attached.
+func (c *conn) auth(tag uint32, t *tagstruct) error {
+	version, err := t.u32()
+	if err != nil {
+		return err
+	}
+	var cookie []byte
+	if cookie, err = t.arbitrary(); err != nil {
+		return err
+	}
+	if c.p.cookie != nil {
+		cookie = c.p.cookie
+	}
+
+	f := newControl(commandAuth, tag, version&^(protocolFlagSHM|protocolFlagMemfd))
+	f.payload = appendArbitrary(f.payload, cookie)
+	_, _, err = c.server.WriteMsgUnix(f.encode(), syscall.UnixCredentials(&syscall.Ucred{
+		Pid: int32(os.Getpid()),
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}), nil)
+	return err
+}
+
+// downstream relays frames sent by the server to the client, and records streams created by the client.
+func (c *conn) downstream() error {
+	for {
+		f, err := readFrame(c.server)
+		if err != nil {
+			return err
+		}
+
+		if f.channel == channelControl {
+			t := tagstruct{f.payload}
+			command, commandErr := t.u32()
+			tag, tagErr := t.u32()
+			if commandErr == nil && tagErr == nil && (command == commandReply || command == commandError) {
+				c.mu.Lock()
+				if record, ok := c.pending[tag]; ok {
+					delete(c.pending, tag)
+					// channel followed by the sink input or source output index
+					if _, channelErr := t.u32(); command == commandReply && channelErr == nil {
+						if index, indexErr := t.u32(); indexErr == nil {
+							if record {
+								c.sourceOutputs[index] = struct{}{}
+							} else {
+								c.sinkInputs[index] = struct{}{}
+							}
+						}
+					}
+				}
+				c.mu.Unlock()
+			}
+		}
+
+		if err = c.reply(f); err != nil {
+			return err
+		}
+	}
+}
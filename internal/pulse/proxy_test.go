@@ -0,0 +1,294 @@
+package pulse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+
+	owned := func(want bool) func(bool) bool { return func(bool) bool { return want } }
+	testCases := []struct {
+		name    string
+		policy  hst.PulseConfig
+		command uint32
+		owned   func(record bool) bool
+		want    bool
+	}{
+		{"playback", hst.PulseConfig{}, commandCreatePlaybackStream, nil, true},
+		{"record", hst.PulseConfig{}, commandCreateRecordStream, nil, false},
+		{"record allowed", hst.PulseConfig{Record: true}, commandCreateRecordStream, nil, true},
+		{"module", hst.PulseConfig{Introspect: true, Control: true}, 51, nil, false},
+		{"module allowed", hst.PulseConfig{Modules: true}, 51, nil, true},
+		{"client list", hst.PulseConfig{}, 28, nil, false},
+		{"client list allowed", hst.PulseConfig{Introspect: true}, 28, nil, true},
+		{"sink volume", hst.PulseConfig{}, 36, nil, false},
+		{"sink volume allowed", hst.PulseConfig{Control: true}, 36, nil, true},
+		{"sink input owned", hst.PulseConfig{}, 37, owned(true), true},
+		{"sink input other", hst.PulseConfig{}, 37, owned(false), false},
+		{"sink input control", hst.PulseConfig{Control: true}, 37, nil, true},
+		{"sink input info other", hst.PulseConfig{Control: true}, 29, owned(false), false},
+		{"sink input info introspect", hst.PulseConfig{Introspect: true}, 29, nil, true},
+		{"source output owned", hst.PulseConfig{}, 50, nil, false},
+		{"source output owned record", hst.PulseConfig{Record: true}, 50, owned(true), true},
+		{"source output info other", hst.PulseConfig{Record: true}, 31, owned(false), false},
+		{"server command", hst.PulseConfig{Record: true, Modules: true, Introspect: true, Control: true}, 61, nil, false},
+		{"unknown", hst.PulseConfig{Record: true, Modules: true, Introspect: true, Control: true}, commandMax, nil, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := allow(&tc.policy, tc.command, tc.owned); got != tc.want {
+				t.Errorf("allow: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	f := newControl(commandAuth, 0xcafe, 35)
+	f.payload = appendArbitrary(f.payload, []byte("cookie"))
+	got, err := readFrame(bytes.NewReader(f.encode()))
+	if err != nil {
+		t.Fatalf("readFrame: error = %v", err)
+	}
+	if !reflect.DeepEqual(got, f) {
+		t.Fatalf("readFrame: %#v, want %#v", got, f)
+	}
+
+	ts := tagstruct{got.payload}
+	for _, want := range []uint32{commandAuth, 0xcafe, 35} {
+		if v, err := ts.u32(); err != nil || v != want {
+			t.Errorf("u32: %d, error = %v", v, err)
+		}
+	}
+	if _, err = (&tagstruct{ts.data}).u32(); !errors.Is(err, ErrTagstruct) {
+		t.Errorf("u32: error = %v", err)
+	}
+	if v, err := ts.arbitrary(); err != nil || string(v) != "cookie" {
+		t.Errorf("arbitrary: %q, error = %v", v, err)
+	}
+
+	if _, err = readFrame(bytes.NewReader(f.encode()[:descriptorSize+1])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readFrame: error = %v", err)
+	}
+	large := (&frame{payload: make([]byte, frameSizeMax+1)}).encode()
+	if _, err = readFrame(bytes.NewReader(large[:descriptorSize])); !errors.Is(err, ErrFrameSize) {
+		t.Errorf("readFrame: error = %v", err)
+	}
+}
+
+// fakeServer implements the parts of a PulseAudio server exercised by the proxy.
+// It replies to every command and creates sink input 0xbeef for every playback stream.
+type fakeServer struct {
+	// Frames received after AUTH.
+	frames []*frame
+	// AUTH protocol version, cookie and credentials.
+	version uint32
+	cookie  []byte
+	ucred   *syscall.Ucred
+}
+
+// serve handles a single client connected to l.
+func (s *fakeServer) serve(l *net.UnixListener) error {
+	c, err := l.AcceptUnix()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	var rc syscall.RawConn
+	if rc, err = c.SyscallConn(); err != nil {
+		return err
+	}
+	if controlErr := rc.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	}); controlErr != nil {
+		return controlErr
+	} else if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1<<10)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred))
+	n, oobn, _, _, err := c.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return err
+	}
+	var messages []syscall.SocketControlMessage
+	if messages, err = syscall.ParseSocketControlMessage(oob[:oobn]); err != nil {
+		return err
+	}
+	if len(messages) != 1 {
+		return errors.New("unexpected control messages")
+	}
+	if s.ucred, err = syscall.ParseUnixCredentials(&messages[0]); err != nil {
+		return err
+	}
+
+	var f *frame
+	if f, err = readFrame(bytes.NewReader(buf[:n])); err != nil {
+		return err
+	}
+	t := tagstruct{f.payload}
+	var command, tag uint32
+	if command, err = t.u32(); err != nil || command != commandAuth {
+		return errors.New("expected AUTH")
+	}
+	if tag, err = t.u32(); err != nil {
+		return err
+	}
+	if s.version, err = t.u32(); err != nil {
+		return err
+	}
+	if s.cookie, err = t.arbitrary(); err != nil {
+		return err
+	}
+	if _, err = c.Write(newControl(commandReply, tag, s.version).encode()); err != nil {
+		return err
+	}
+
+	for {
+		if f, err = readFrame(c); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		s.frames = append(s.frames, f)
+		if f.channel != channelControl {
+			continue
+		}
+
+		t = tagstruct{f.payload}
+		command, _ = t.u32()
+		tag, _ = t.u32()
+		reply := newControl(commandReply, tag)
+		if command == commandCreatePlaybackStream {
+			reply = newControl(commandReply, tag, 0, 0xbeef, 0)
+		}
+		if _, err = c.Write(reply.encode()); err != nil {
+			return err
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(nil, nil, nil, nil, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("New: error = %v", err)
+	}
+	if _, err := New(nil, nil, nil, make([]byte, 1), new(hst.PulseConfig)); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("New: error = %v", err)
+	}
+	if err := (*Proxy)(nil).Close(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	d := check.MustAbs(t.TempDir())
+	upstream, bindPath := d.Append("native"), d.Append("pulse")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: upstream.String(), Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix: error = %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	var s fakeServer
+	done := make(chan error, 1)
+	go func() { done <- s.serve(l) }()
+
+	cookie := bytes.Repeat([]byte{0xfd}, cookieSize)
+	var p *Proxy
+	if p, err = New(message.New(nil), upstream, bindPath, cookie, new(hst.PulseConfig)); err != nil {
+		t.Fatalf("New: error = %v", err)
+	}
+
+	var c net.Conn
+	if c, err = net.Dial("unix", bindPath.String()); err != nil {
+		t.Fatalf("Dial: error = %v", err)
+	}
+	roundtrip := func(f *frame, want *frame) {
+		t.Helper()
+		if _, err = c.Write(f.encode()); err != nil {
+			t.Fatalf("Write: error = %v", err)
+		}
+		if got, readErr := readFrame(c); readErr != nil {
+			t.Fatalf("readFrame: error = %v", readErr)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Fatalf("readFrame: %#v, want %#v", got, want)
+		}
+	}
+
+	auth := newControl(commandAuth, 0, 35|protocolFlagSHM|protocolFlagMemfd)
+	auth.payload = appendArbitrary(auth.payload, make([]byte, cookieSize))
+	roundtrip(auth, newControl(commandReply, 0, 35))
+
+	// denied without reaching the server
+	roundtrip(newControl(51, 1), newControl(commandError, 1, errAccess))
+	roundtrip(newControl(commandCreateRecordStream, 2), newControl(commandError, 2, errAccess))
+	roundtrip(newControl(28, 3), newControl(commandError, 3, errAccess))
+	roundtrip(newControl(37, 5, 0xbeef), newControl(commandError, 5, errAccess))
+
+	// own stream operations
+	roundtrip(newControl(commandCreatePlaybackStream, 6), newControl(commandReply, 6, 0, 0xbeef, 0))
+	roundtrip(newControl(37, 7, 0xbeef), newControl(commandReply, 7))
+	roundtrip(newControl(37, 8, 0xcafe), newControl(commandError, 8, errAccess))
+
+	data := &frame{channel: 0, payload: []byte{0, 1, 2, 3}}
+	if _, err = c.Write(data.encode()); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	// shared memory is rejected
+	if _, err = c.Write((&frame{channel: 0, flags: 0x80000000}).encode()); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	if _, err = readFrame(c); !errors.Is(err, io.EOF) {
+		t.Fatalf("readFrame: error = %v", err)
+	}
+
+	if err = <-done; err != nil {
+		t.Fatalf("serve: error = %v", err)
+	}
+	if err = p.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+	if _, err = net.Dial("unix", bindPath.String()); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Dial: error = %v", err)
+	}
+
+	if s.version != 35 {
+		t.Errorf("AUTH: version = %#x", s.version)
+	}
+	if !bytes.Equal(s.cookie, cookie) {
+		t.Errorf("AUTH: cookie = %x", s.cookie)
+	}
+	if wantUcred := (syscall.Ucred{
+		Pid: int32(os.Getpid()),
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}); s.ucred == nil || *s.ucred != wantUcred {
+		t.Errorf("AUTH: ucred = %#v", s.ucred)
+	}
+
+	wantFrames := []*frame{
+		newControl(commandCreatePlaybackStream, 6),
+		newControl(37, 7, 0xbeef),
+		data,
+	}
+	if !reflect.DeepEqual(s.frames, wantFrames) {
+		t.Errorf("serve: frames = %#v, want %#v", s.frames, wantFrames)
+	}
+}
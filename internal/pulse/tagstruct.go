@@ -0,0 +1,128 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Tagstruct value tags used by the proxy
+// (https://gitlab.freedesktop.org/pulseaudio/pulseaudio/-/blob/v17.0/src/pulsecore/tagstruct.h#L36).
+const (
+	tagU32       = 'L'
+	tagArbitrary = 'x'
+)
+
+var (
+	// ErrTagstruct is returned when decoding a malformed or unexpected tagstruct value.
+	ErrTagstruct = errors.New("malformed tagstruct")
+	// ErrFrameSize is returned when reading a frame larger than the proxy accepts.
+	ErrFrameSize = errors.New("frame too large")
+)
+
+// tagstruct decodes values from the payload of a control packet.
+type tagstruct struct{ data []byte }
+
+// u32 decodes an unsigned 32-bit integer.
+func (t *tagstruct) u32() (uint32, error) {
+	if len(t.data) < 5 || t.data[0] != tagU32 {
+		return 0, ErrTagstruct
+	}
+	v := binary.BigEndian.Uint32(t.data[1:])
+	t.data = t.data[5:]
+	return v, nil
+}
+
+// arbitrary decodes a length-prefixed byte slice.
+func (t *tagstruct) arbitrary() ([]byte, error) {
+	if len(t.data) < 5 || t.data[0] != tagArbitrary {
+		return nil, ErrTagstruct
+	}
+	n := binary.BigEndian.Uint32(t.data[1:])
+	if uint64(n) > uint64(len(t.data)-5) {
+		return nil, ErrTagstruct
+	}
+	v := t.data[5 : 5+n]
+	t.data = t.data[5+n:]
+	return v, nil
+}
+
+// appendU32 appends the encoding of an unsigned 32-bit integer to buf.
+func appendU32(buf []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(append(buf, tagU32), v)
+}
+
+// appendArbitrary appends the encoding of a length-prefixed byte slice to buf.
+func appendArbitrary(buf, v []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(append(buf, tagArbitrary), uint32(len(v))), v...)
+}
+
+const (
+	// descriptorSize is the size of the frame descriptor preceding every frame.
+	descriptorSize = 20
+	// frameSizeMax is the largest frame payload accepted by the proxy.
+	frameSizeMax = 1 << 24
+
+	// channelControl is the channel of control packets holding a tagstruct.
+	channelControl = 1<<32 - 1
+
+	// flagSHMMask masks frame flags referring to shared memory.
+	flagSHMMask = 0xff000000
+)
+
+// A frame is a unit of the PulseAudio native protocol: either a control packet
+// or a memory block belonging to a stream.
+type frame struct {
+	// Stream channel, or channelControl.
+	channel uint32
+	// Seek offset of a memory block.
+	offset uint64
+	// Seek mode and shared memory flags.
+	flags uint32
+
+	payload []byte
+}
+
+// readFrame reads the next frame from r.
+func readFrame(r io.Reader) (*frame, error) {
+	var d [descriptorSize]byte
+	if _, err := io.ReadFull(r, d[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(d[:])
+	if size > frameSizeMax {
+		return nil, ErrFrameSize
+	}
+	f := frame{
+		channel: binary.BigEndian.Uint32(d[4:]),
+		offset:  binary.BigEndian.Uint64(d[8:]),
+		flags:   binary.BigEndian.Uint32(d[16:]),
+		payload: make([]byte, size),
+	}
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// encode returns the descriptor and payload of f.
+func (f *frame) encode() []byte {
+	data := make([]byte, descriptorSize, descriptorSize+len(f.payload))
+	binary.BigEndian.PutUint32(data, uint32(len(f.payload)))
+	binary.BigEndian.PutUint32(data[4:], f.channel)
+	binary.BigEndian.PutUint64(data[8:], f.offset)
+	binary.BigEndian.PutUint32(data[16:], f.flags)
+	return append(data, f.payload...)
+}
+
+// newControl returns a control packet frame holding a tagstruct of unsigned 32-bit integers.
+func newControl(v ...uint32) *frame {
+	f := frame{channel: channelControl}
+	for _, u := range v {
+		f.payload = appendU32(f.payload, u)
+	}
+	return &f
+}
@@ -71,6 +71,28 @@ func (eh *EntryHandle) save(state *hst.State) error {
 	return err
 }
 
+// Update encodes [hst.State] and replaces the contents of the underlying file.
+// Must be called while holding [Handle.Lock].
+// Update does not validate the embedded [hst.Config].
+// A non-nil error returned by Update is of type [hst.AppError].
+func (eh *EntryHandle) Update(state *hst.State) error {
+	if state.ID != eh.ID {
+		return &hst.AppError{Step: "validate state identifier", Err: os.ErrInvalid,
+			Msg: fmt.Sprintf("state entry %s has unexpected id %s", eh.ID.String(), state.ID.String())}
+	}
+
+	f, err := eh.open(os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	err = entryEncode(f, state)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = &hst.AppError{Step: "close state file", Err: closeErr}
+	}
+	return err
+}
+
 // Load loads and validates the state entry header, and returns the [hst.Enablement] byte.
 // for a non-nil v, the full state payload is decoded and stored in the value pointed to by v.
 // Load validates the embedded [hst.Config] value.
@@ -185,6 +207,32 @@ func (h *Handle) Entries() (iter.Seq[*EntryHandle], int, error) {
 	}, l, nil
 }
 
+// Pod returns the [hst.State] of every instance in this segment that is a member of the named pod.
+// Must be called while holding [Handle.Lock].
+// A non-nil error returned by Pod is of type [hst.AppError].
+func (h *Handle) Pod(name string) ([]*hst.State, error) {
+	entries, _, err := h.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []*hst.State
+	for eh := range entries {
+		if eh.DecodeErr != nil {
+			return nil, eh.DecodeErr
+		}
+
+		var state hst.State
+		if _, err = eh.Load(&state); err != nil {
+			return nil, err
+		}
+		if state.Pod == name {
+			members = append(members, &state)
+		}
+	}
+	return members, nil
+}
+
 // newHandle returns the address of a new segment [Handle] rooted in base.
 func newHandle(base *check.Absolute, identity int) *Handle {
 	h := Handle{Identity: identity, Path: base.Append(strconv.Itoa(identity))}
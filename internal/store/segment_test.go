@@ -155,6 +155,36 @@ func TestStateEntryHandle(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+		eh := store.EntryHandle{Pathname: check.MustAbs(t.TempDir()).Append("entry"),
+			ID: store.NewTemplateState().ID}
+
+		if err := save(&eh, store.NewTemplateState()); err != nil {
+			t.Fatalf("save: error = %v", err)
+		}
+
+		want := store.NewTemplateState()
+		want.Ready = true
+		want.Status = "Listening on /run/user/1000/clangd.sock"
+		if err := eh.Update(want); err != nil {
+			t.Fatalf("update: error = %v", err)
+		}
+
+		var got hst.State
+		if _, err := eh.Load(&got); err != nil {
+			t.Fatalf("load: error = %v", err)
+		} else if !reflect.DeepEqual(&got, want) {
+			t.Errorf("load: %#v, want %#v", &got, want)
+		}
+
+		wantErr := &hst.AppError{Step: "validate state identifier", Err: os.ErrInvalid,
+			Msg: "state entry aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa has unexpected id 00000000000000000000000000000000"}
+		if err := eh.Update(new(hst.State)); !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("update: error = %#v, want %#v", err, wantErr)
+		}
+	})
 }
 
 func TestSegmentHandle(t *testing.T) {
@@ -261,6 +291,62 @@ func TestSegmentHandle(t *testing.T) {
 	})
 }
 
+func TestSegmentPod(t *testing.T) {
+	t.Parallel()
+
+	base := check.MustAbs(t.TempDir()).Append("store")
+	if err := os.MkdirAll(base.Append("9").String(), 0700); err != nil {
+		t.Fatal(err.Error())
+	}
+	h := newHandle(base, 9)
+
+	var want []*hst.State
+	for i, pod := range []string{"", "browser", "proxy", "browser"} {
+		state := store.NewTemplateState()
+		state.ID[0] = byte(i)
+		state.InitPID = 0xbeef + i
+		state.Config.Pod = pod
+		// not valid for pod members
+		state.Container.Flags &^= hst.FHostNet
+		state.Container.Time = nil
+		if _, err := h.Save(state); err != nil {
+			t.Fatalf("Save: error = %v", err)
+		}
+		if pod == "browser" {
+			want = append(want, state)
+		}
+	}
+
+	if got, err := h.Pod("browser"); err != nil {
+		t.Fatalf("Pod: error = %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pod: %#v, want %#v", got, want)
+	}
+
+	if got, err := h.Pod("nonexistent"); err != nil {
+		t.Fatalf("Pod: error = %v", err)
+	} else if got != nil {
+		t.Errorf("Pod: %#v, want nil", got)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		base := check.MustAbs(t.TempDir()).Append("store")
+		segment := base.Append("9")
+		if err := os.MkdirAll(segment.String(), 0700); err != nil {
+			t.Fatal(err.Error())
+		}
+		createEntries(t, segment, [2][]string{{"f0-invalid"}})
+
+		wantErr := &hst.AppError{Step: "decode store segment entry",
+			Err: hst.IdentifierDecodeError{Err: hst.ErrIdentifierLength}}
+		if _, err := newHandle(base, 9).Pod("browser"); !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("Pod: error = %#v, want %#v", err, wantErr)
+		}
+	})
+}
+
 // createEntries creates file and directory entries in the specified prefix.
 func createEntries(t *testing.T, prefix *check.Absolute, ents [2][]string) {
 	for _, s := range ents[0] {
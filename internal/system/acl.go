@@ -18,6 +18,7 @@ func (sys *I) UpdatePerm(path *check.Absolute, perms ...acl.Perm) *I {
 }
 
 // UpdatePermType maintains [acl.Perms] on a file until its [Enablement] is no longer satisfied.
+// Perms already granted to the target user are kept.
 func (sys *I) UpdatePermType(et hst.Enablement, path *check.Absolute, perms ...acl.Perm) *I {
 	sys.ops = append(sys.ops, &aclUpdateOp{et, path.String(), perms})
 	return sys
@@ -34,7 +35,8 @@ func (a *aclUpdateOp) Type() hst.Enablement { return a.et }
 
 func (a *aclUpdateOp) apply(sys *I) error {
 	sys.msg.Verbose("applying ACL", a)
-	return newOpError("acl", sys.aclUpdate(a.path, sys.uid, a.perms...), false)
+	// merged to avoid downgrading perms granted to the same file by another op or instance
+	return newOpError("acl", sys.aclMerge(a.path, sys.uid, a.perms...), false)
 }
 
 func (a *aclUpdateOp) revert(sys *I, ec *Criteria) error {
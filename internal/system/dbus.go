@@ -6,12 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
 	"syscall"
 
 	"hakurei.app/container"
+	"hakurei.app/container/check"
 	"hakurei.app/hst"
 	"hakurei.app/internal/dbus"
 )
@@ -23,8 +25,10 @@ var ErrDBusConfig = errors.New("dbus config not supplied")
 func (sys *I) MustProxyDBus(
 	session, system *hst.BusConfig,
 	sessionBus, systemBus dbus.ProxyPair,
+	events *check.Absolute,
+	info []byte,
 ) *I {
-	if err := sys.ProxyDBus(session, system, sessionBus, systemBus); err != nil {
+	if err := sys.ProxyDBus(session, system, sessionBus, systemBus, events, info); err != nil {
 		panic(err.Error())
 	} else {
 		return sys
@@ -32,12 +36,16 @@ func (sys *I) MustProxyDBus(
 }
 
 // ProxyDBus finalises configuration ahead of time and starts xdg-dbus-proxy via [dbus] and terminates it on revert.
-// This [Op] is always [Process] scoped.
+// If events is not nil, the message log of xdg-dbus-proxy is appended to the file at events as [dbus.Event].
+// If info is not nil, it is placed at /.flatpak-info in the container of xdg-dbus-proxy, as described by
+// [dbus.Final]. This [Op] is always [Process] scoped.
 func (sys *I) ProxyDBus(
 	session, system *hst.BusConfig,
 	sessionBus, systemBus dbus.ProxyPair,
+	events *check.Absolute,
+	info []byte,
 ) error {
-	d := new(dbusProxyOp)
+	d := &dbusProxyOp{events: events}
 
 	// session bus is required as otherwise this is effectively a very expensive noop
 	if session == nil {
@@ -67,6 +75,7 @@ func (sys *I) ProxyDBus(
 			sys.msg.Verbose("message bus proxy final args:", final.WriterTo)
 		}
 
+		final.FlatpakInfo = info
 		d.final = final
 	}
 
@@ -82,6 +91,12 @@ type dbusProxyOp struct {
 	out   *linePrefixWriter
 	// whether system bus proxy is enabled
 	system bool
+
+	// pathname to append events to, nil if disabled
+	events *check.Absolute
+	// populated during apply if events is not nil
+	eventsFile osFile
+	parser     *dbus.LogParser
 }
 
 func (d *dbusProxyOp) Type() hst.Enablement { return Process }
@@ -92,9 +107,22 @@ func (d *dbusProxyOp) apply(sys *I) error {
 		sys.msg.Verbosef("system bus proxy on %q for upstream %q", d.final.System[1], d.final.System[0])
 	}
 
+	if d.events != nil {
+		if f, err := sys.openFile(d.events.String(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+			return newOpError("dbus", err, false)
+		} else {
+			d.eventsFile = f
+			d.parser = dbus.NewLogParser(dbus.NewEventLog(f, ""))
+			d.out.parse = d.parser.Line
+		}
+	}
+
 	d.proxy = dbus.New(sys.ctx, sys.msg, d.final, d.out)
 	if err := sys.dbusProxyStart(d.proxy); err != nil {
 		d.out.Dump()
+		if d.eventsFile != nil {
+			_ = d.eventsFile.Close()
+		}
 		return newOpErrorMessage("dbus", err,
 			fmt.Sprintf("cannot start message bus proxy: %v", err), false)
 	}
@@ -119,6 +147,9 @@ func (d *dbusProxyOp) revert(sys *I, _ *Criteria) error {
 		exitMessage = "message bus proxy canceled upstream"
 		err = nil
 	}
+	if d.eventsFile != nil {
+		err = errors.Join(err, d.parser.Flush(), d.eventsFile.Close())
+	}
 	return newOpErrorMessage("dbus", err,
 		fmt.Sprintf("message bus proxy error: %v", err), true)
 }
@@ -127,11 +158,13 @@ func (d *dbusProxyOp) Is(o Op) bool {
 	target, ok := o.(*dbusProxyOp)
 	return ok && d != nil && target != nil &&
 		d.system == target.system &&
+		reflect.DeepEqual(d.events, target.events) &&
 		d.final != nil && target.final != nil &&
 		d.final.Session == target.final.Session &&
 		d.final.System == target.final.System &&
 		dbus.EqualAddrEntries(d.final.SessionUpstream, target.final.SessionUpstream) &&
 		dbus.EqualAddrEntries(d.final.SystemUpstream, target.final.SystemUpstream) &&
+		bytes.Equal(d.final.FlatpakInfo, target.final.FlatpakInfo) &&
 		reflect.DeepEqual(d.final.WriterTo, target.final.WriterTo)
 }
 
@@ -148,6 +181,8 @@ const (
 type linePrefixWriter struct {
 	prefix  string
 	println func(v ...any)
+	// consumes lines of the message log, nil to retain every line
+	parse func(line string) bool
 
 	n   int
 	msg []string
@@ -183,6 +218,8 @@ func (s *linePrefixWriter) write(p []byte, a int) (int, error) {
 			s.n -= len(v) + 1
 			// pass through container init messages
 			s.println(s.prefix + v)
+		} else if s.parse != nil && s.parse(v) {
+			s.n -= len(v) + 1
 		} else {
 			s.msg = append(s.msg, v)
 		}
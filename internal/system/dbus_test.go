@@ -2,6 +2,8 @@ package system
 
 import (
 	"context"
+	"errors"
+	"os"
 	"reflect"
 	"slices"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 	"syscall"
 	"testing"
 
+	"hakurei.app/container/check"
 	"hakurei.app/container/stub"
 	"hakurei.app/hst"
 	"hakurei.app/internal/dbus"
@@ -63,6 +66,34 @@ func TestDBusProxyOp(t *testing.T) {
 			call("verbose", stub.ExpectArgs{[]any{"message bus proxy canceled upstream"}}, nil, nil),
 		}, nil},
 
+		{"openFile", 0xdead, 0xff, &dbusProxyOp{
+			final:  dbusNewFinalSample(4),
+			out:    new(linePrefixWriter),
+			events: check.MustAbs("/tmp/hakurei.0/dbus/99dd71ee2146369514e0d10783368f8f"),
+		}, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"session bus proxy on %q for upstream %q", []any{"/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/bus", "unix:path=/run/user/1000/bus"}}, nil, nil),
+			call("openFile", stub.ExpectArgs{"/tmp/hakurei.0/dbus/99dd71ee2146369514e0d10783368f8f", os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.FileMode(0600)}, nil, stub.UniqueError(5)),
+		}, &OpError{Op: "dbus", Err: stub.UniqueError(5)}, nil, nil},
+
+		{"success events", 0xdead, 0xff, &dbusProxyOp{
+			final:  dbusNewFinalSample(1),
+			out:    new(linePrefixWriter),
+			events: check.MustAbs("/tmp/hakurei.0/dbus/99dd71ee2146369514e0d10783368f8f"),
+		}, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"session bus proxy on %q for upstream %q", []any{"/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/bus", "unix:path=/run/user/1000/bus"}}, nil, nil),
+			call("openFile", stub.ExpectArgs{"/tmp/hakurei.0/dbus/99dd71ee2146369514e0d10783368f8f", os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.FileMode(0600)}, &stubOsFile{closeErr: stub.UniqueError(6)}, nil),
+			call("dbusProxyStart", stub.ExpectArgs{dbusNewFinalSample(1)}, nil, nil),
+			call("verbose", stub.ExpectArgs{[]any{"starting message bus proxy", ignoreValue{}}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbose", stub.ExpectArgs{[]any{"terminating message bus proxy"}}, nil, nil),
+			call("dbusProxyClose", stub.ExpectArgs{dbusNewFinalSample(1)}, nil, nil),
+			call("dbusProxyWait", stub.ExpectArgs{dbusNewFinalSample(1)}, nil, nil),
+			call("verbose", stub.ExpectArgs{[]any{"message bus proxy exit"}}, nil, nil),
+		}, &OpError{
+			Op: "dbus", Err: errors.Join(stub.UniqueError(6)), Revert: true,
+			Msg: "message bus proxy error: unique error 6 injected by the test suite",
+		}},
+
 		{"success", 0xdead, 0xff, &dbusProxyOp{
 			final:  dbusNewFinalSample(1),
 			system: true,
@@ -85,7 +116,7 @@ func TestDBusProxyOp(t *testing.T) {
 				Op: "dbus", Err: ErrDBusConfig,
 				Msg: "attempted to create message bus proxy args without session bus config",
 			}
-			if err := sys.ProxyDBus(nil, new(hst.BusConfig), dbus.ProxyPair{}, dbus.ProxyPair{}); !reflect.DeepEqual(err, wantErr) {
+			if err := sys.ProxyDBus(nil, new(hst.BusConfig), dbus.ProxyPair{}, dbus.ProxyPair{}, nil, nil); !reflect.DeepEqual(err, wantErr) {
 				t.Errorf("ProxyDBus: error = %v, want %v", err, wantErr)
 			}
 		}, nil, stub.Expect{}},
@@ -111,7 +142,7 @@ func TestDBusProxyOp(t *testing.T) {
 				}, dbus.ProxyPair{
 					"unix:path=/run/dbus/system_bus_socket",
 					"/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/system_bus_socket",
-				})
+				}, nil, nil)
 		}, nil, stub.Expect{Calls: []stub.Call{
 			call("dbusFinalise", stub.ExpectArgs{
 				dbus.ProxyPair{"unix:path=/run/user/1000/bus", "/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/bus"},
@@ -139,7 +170,7 @@ func TestDBusProxyOp(t *testing.T) {
 				}, dbus.ProxyPair{
 					"unix:path=/run/dbus/system_bus_socket",
 					"/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/system_bus_socket",
-				}); !reflect.DeepEqual(err, wantErr) {
+				}, nil, nil); !reflect.DeepEqual(err, wantErr) {
 				t.Errorf("ProxyDBus: error = %v", err)
 			}
 		}, nil, stub.Expect{Calls: []stub.Call{
@@ -165,10 +196,14 @@ func TestDBusProxyOp(t *testing.T) {
 				}, dbus.ProxyPair{
 					"unix:path=/run/dbus/system_bus_socket",
 					"/tmp/hakurei.0/99dd71ee2146369514e0d10783368f8f/system_bus_socket",
-				})
+				}, nil, []byte("[Application]\n"))
 		}, []Op{
 			&dbusProxyOp{
-				final:  dbusNewFinalSample(0),
+				final: func() *dbus.Final {
+					final := dbusNewFinalSample(0)
+					final.FlatpakInfo = []byte("[Application]\n")
+					return final
+				}(),
 				system: true,
 			},
 		}, stub.Expect{Calls: []stub.Call{
@@ -215,6 +250,33 @@ func TestDBusProxyOp(t *testing.T) {
 		}, system: true,
 		}, false},
 
+		{"flatpak info differs", &dbusProxyOp{final: &dbus.Final{
+			Session: dbus.ProxyPair{"unix:path=/run/user/1000/bus", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/bus"},
+			System:  dbus.ProxyPair{"unix:path=/run/dbus/system_bus_socket", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/system_bus_socket"},
+
+			SessionUpstream: []dbus.AddrEntry{{Method: "unix", Values: [][2]string{{"path", "/run/user/1000/bus"}}}},
+			SystemUpstream:  []dbus.AddrEntry{{Method: "unix", Values: [][2]string{{"unix", "/run/dbus/system_bus_socket"}}}},
+			FlatpakInfo:     []byte("[Application]\n"),
+
+			WriterTo: helper.MustNewCheckedArgs(
+				"--filter", "unix:path=/run/user/1000/bus", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/bus",
+				"--filter", "unix:path=/run/dbus/system_bus_socket", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/system_bus_socket",
+			),
+		}, system: true,
+		}, &dbusProxyOp{final: &dbus.Final{
+			Session: dbus.ProxyPair{"unix:path=/run/user/1000/bus", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/bus"},
+			System:  dbus.ProxyPair{"unix:path=/run/dbus/system_bus_socket", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/system_bus_socket"},
+
+			SessionUpstream: []dbus.AddrEntry{{Method: "unix", Values: [][2]string{{"path", "/run/user/1000/bus"}}}},
+			SystemUpstream:  []dbus.AddrEntry{{Method: "unix", Values: [][2]string{{"unix", "/run/dbus/system_bus_socket"}}}},
+
+			WriterTo: helper.MustNewCheckedArgs(
+				"--filter", "unix:path=/run/user/1000/bus", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/bus",
+				"--filter", "unix:path=/run/dbus/system_bus_socket", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/system_bus_socket",
+			),
+		}, system: true,
+		}, false},
+
 		{"wt differs", &dbusProxyOp{final: &dbus.Final{
 			Session: dbus.ProxyPair{"unix:path=/run/user/1000/bus", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/bus"},
 			System:  dbus.ProxyPair{"unix:path=/run/dbus/system_bus_socket", "/tmp/hakurei.0/b186c281d9e83a39afdc66d964ef99c6/system_bus_socket"},
@@ -672,3 +734,42 @@ func TestLinePrefixWriter(t *testing.T) {
 		})
 	}
 }
+
+func TestLinePrefixWriterParse(t *testing.T) {
+	t.Parallel()
+
+	var (
+		gotPt  []string
+		parsed []string
+	)
+	out := &linePrefixWriter{
+		prefix:  "(dbus) ",
+		println: func(v ...any) { gotPt = append(gotPt, v[0].(string)) },
+		parse: func(line string) bool {
+			if !strings.HasPrefix(line, "C") {
+				return false
+			}
+			parsed = append(parsed, line)
+			return true
+		},
+		buf: new(strings.Builder),
+	}
+	if _, err := out.Write([]byte("init: received setup parameters\n" +
+		"C1: -> org.freedesktop.DBus call org.freedesktop.DBus.Hello at /org/freedesktop/DBus\n" +
+		"warning: unexpected output\n")); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+
+	if want := []string{"(dbus) init: received setup parameters"}; !slices.Equal(gotPt, want) {
+		t.Errorf("passthrough: %#v, want %#v", gotPt, want)
+	}
+	if want := []string{"C1: -> org.freedesktop.DBus call org.freedesktop.DBus.Hello at /org/freedesktop/DBus"}; !slices.Equal(parsed, want) {
+		t.Errorf("parse: %#v, want %#v", parsed, want)
+	}
+	if want := []string{"warning: unexpected output"}; !slices.Equal(out.msg, want) {
+		t.Errorf("msg: %#v, want %#v", out.msg, want)
+	}
+	if want := len("warning: unexpected output\n"); out.n != want {
+		t.Errorf("n: %d, want %d", out.n, want)
+	}
+}
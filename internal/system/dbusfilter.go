@@ -0,0 +1,86 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/dbus"
+)
+
+// FilterDBus maintains an in-process message bus proxy via [dbus.Filter] relaying connections on bus[1]
+// to the message bus at address bus[0]. The socket is pathname only and is destroyed on revert.
+// If events is not nil, messages logged by the proxy are appended to the file at events as [dbus.Event]
+// identifying the message bus by name, which is one of [dbus.SessionBus] or [dbus.SystemBus].
+// This [Op] is always [Process] scoped.
+func (sys *I) FilterDBus(name string, config *hst.BusConfig, bus dbus.ProxyPair, events *check.Absolute) *I {
+	sys.ops = append(sys.ops, &dbusFilterOp{nil, nil, name, config, bus, events})
+	return sys
+}
+
+// dbusFilterOp implements [I.FilterDBus].
+type dbusFilterOp struct {
+	filter     *dbus.Filter
+	eventsFile osFile
+	name       string
+	config     *hst.BusConfig
+	bus        dbus.ProxyPair
+	events     *check.Absolute
+}
+
+func (d *dbusFilterOp) Type() hst.Enablement { return Process }
+
+func (d *dbusFilterOp) apply(sys *I) (err error) {
+	var events *dbus.EventLog
+	if d.events != nil {
+		if d.eventsFile, err = sys.openFile(d.events.String(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+			return newOpError("dbus", err, false)
+		}
+		events = dbus.NewEventLog(d.eventsFile, d.name)
+	}
+
+	if d.filter, err = sys.dbusFilterNew(sys.msg, d.bus, d.config, events); err != nil {
+		if d.eventsFile != nil {
+			_ = d.eventsFile.Close()
+		}
+		return newOpError("dbus", err, false)
+	}
+	sys.msg.Verbosef("message bus filter on %q for upstream %q", d.bus[1], d.bus[0])
+	return nil
+}
+
+func (d *dbusFilterOp) revert(sys *I, _ *Criteria) error {
+	var (
+		closeErr  error
+		removeErr error
+		eventsErr error
+	)
+
+	sys.msg.Verbosef("terminating message bus filter on %q", d.bus[1])
+	if d.filter != nil {
+		closeErr = d.filter.Close()
+	}
+	if err := sys.remove(d.bus[1]); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeErr = err
+	}
+	if d.eventsFile != nil {
+		eventsErr = d.eventsFile.Close()
+	}
+
+	return newOpError("dbus", errors.Join(closeErr, removeErr, eventsErr), true)
+}
+
+func (d *dbusFilterOp) Is(o Op) bool {
+	target, ok := o.(*dbusFilterOp)
+	return ok && d != nil && target != nil &&
+		d.name == target.name &&
+		d.bus == target.bus &&
+		reflect.DeepEqual(d.events, target.events) &&
+		reflect.DeepEqual(d.config, target.config)
+}
+
+func (d *dbusFilterOp) Path() string   { return d.bus[1] }
+func (d *dbusFilterOp) String() string { return fmt.Sprintf("message bus filter at %q", d.bus[1]) }
@@ -0,0 +1,113 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/container/stub"
+	"hakurei.app/internal/dbus"
+)
+
+func TestDBusFilterOp(t *testing.T) {
+	t.Parallel()
+
+	bus := dbus.ProxyPair{
+		"unix:path=/run/user/1971/bus",
+		"/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/bus",
+	}
+	newOp := func() *dbusFilterOp {
+		return &dbusFilterOp{nil, nil, dbus.SessionBus, dbus.NewConfig("org.chromium.Chromium", true, true), bus, nil}
+	}
+	events := check.MustAbs("/tmp/hakurei.0/dbus/ebf083d1b175911782d413369b64ce7c")
+	newOpEvents := func() *dbusFilterOp {
+		return &dbusFilterOp{nil, nil, dbus.SessionBus, dbus.NewConfig("org.chromium.Chromium", true, true), bus, events}
+	}
+	wantRevert := []stub.Call{
+		call("verbosef", stub.ExpectArgs{"terminating message bus filter on %q", []any{bus[1]}}, nil, nil),
+		call("remove", stub.ExpectArgs{bus[1]}, nil, os.ErrNotExist),
+	}
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"dbusFilterNew", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("dbusFilterNew", stub.ExpectArgs{bus, dbus.NewConfig("org.chromium.Chromium", true, true), false}, nil, stub.UniqueError(2)),
+		}, &OpError{Op: "dbus", Err: stub.UniqueError(2)}, nil, nil},
+
+		{"remove", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("dbusFilterNew", stub.ExpectArgs{bus, dbus.NewConfig("org.chromium.Chromium", true, true), false}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"message bus filter on %q for upstream %q", []any{bus[1], bus[0]}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"terminating message bus filter on %q", []any{bus[1]}}, nil, nil),
+			call("remove", stub.ExpectArgs{bus[1]}, nil, stub.UniqueError(1)),
+		}, &OpError{Op: "dbus", Err: errors.Join(stub.UniqueError(1)), Revert: true}},
+
+		{"openFile", 0xbeef, 0xff, newOpEvents(), []stub.Call{
+			call("openFile", stub.ExpectArgs{events.String(), os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.FileMode(0600)}, nil, stub.UniqueError(4)),
+		}, &OpError{Op: "dbus", Err: stub.UniqueError(4)}, nil, nil},
+
+		{"dbusFilterNew events", 0xbeef, 0xff, newOpEvents(), []stub.Call{
+			call("openFile", stub.ExpectArgs{events.String(), os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.FileMode(0600)}, new(stubOsFile), nil),
+			call("dbusFilterNew", stub.ExpectArgs{bus, dbus.NewConfig("org.chromium.Chromium", true, true), true}, nil, stub.UniqueError(3)),
+		}, &OpError{Op: "dbus", Err: stub.UniqueError(3)}, nil, nil},
+
+		{"close events", 0xbeef, 0xff, newOpEvents(), []stub.Call{
+			call("openFile", stub.ExpectArgs{events.String(), os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.FileMode(0600)}, &stubOsFile{closeErr: stub.UniqueError(0)}, nil),
+			call("dbusFilterNew", stub.ExpectArgs{bus, dbus.NewConfig("org.chromium.Chromium", true, true), true}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"message bus filter on %q for upstream %q", []any{bus[1], bus[0]}}, nil, nil),
+		}, nil, wantRevert, &OpError{Op: "dbus", Err: errors.Join(stub.UniqueError(0)), Revert: true}},
+
+		{"success", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("dbusFilterNew", stub.ExpectArgs{bus, dbus.NewConfig("org.chromium.Chromium", true, true), false}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"message bus filter on %q for upstream %q", []any{bus[1], bus[0]}}, nil, nil),
+		}, nil, wantRevert, nil},
+	})
+
+	checkOpsBuilder(t, "FilterDBus", []opsBuilderTestCase{
+		{"session", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.FilterDBus(dbus.SessionBus, dbus.NewConfig("org.chromium.Chromium", true, true), bus, nil)
+		}, []Op{newOp()}, stub.Expect{}},
+
+		{"events", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.FilterDBus(dbus.SessionBus, dbus.NewConfig("org.chromium.Chromium", true, true), bus, events)
+		}, []Op{newOpEvents()}, stub.Expect{}},
+	})
+
+	checkOpIs(t, []opIsTestCase{
+		{"bus differs", newOp(), &dbusFilterOp{nil, nil, dbus.SessionBus,
+			dbus.NewConfig("org.chromium.Chromium", true, true),
+			dbus.ProxyPair{bus[0], bus[1] + "0"}, nil,
+		}, false},
+
+		{"config differs", newOp(), &dbusFilterOp{nil, nil, dbus.SessionBus,
+			dbus.NewConfig("org.chromium.Chromium", true, false),
+			bus, nil,
+		}, false},
+
+		{"name differs", newOp(), &dbusFilterOp{nil, nil, dbus.SystemBus,
+			dbus.NewConfig("org.chromium.Chromium", true, true),
+			bus, nil,
+		}, false},
+
+		{"events differs", newOp(), newOpEvents(), false},
+
+		{"equals", newOp(), newOp(), true},
+	})
+
+	checkOpMeta(t, []opMetaTestCase{
+		{"session", newOp(), Process, bus[1],
+			`message bus filter at "/tmp/hakurei.0/ebf083d1b175911782d413369b64ce7c/bus"`},
+	})
+}
+
+// stubOsFile implements osFile over a buffer.
+type stubOsFile struct {
+	bytes.Buffer
+	closeErr error
+}
+
+func (f *stubOsFile) Name() string               { return "" }
+func (f *stubOsFile) Stat() (fs.FileInfo, error) { panic("unreachable") }
+func (f *stubOsFile) Close() error               { return f.closeErr }
@@ -10,8 +10,11 @@ import (
 	"hakurei.app/hst"
 	"hakurei.app/internal/acl"
 	"hakurei.app/internal/dbus"
+	"hakurei.app/internal/pipewire"
+	"hakurei.app/internal/pulse"
 	"hakurei.app/internal/wayland"
 	"hakurei.app/internal/xcb"
+	"hakurei.app/message"
 )
 
 type osFile interface {
@@ -32,6 +35,12 @@ type syscallDispatcher interface {
 	stat(name string) (os.FileInfo, error)
 	// open provides [os.Open].
 	open(name string) (osFile, error)
+	// openFile provides [os.OpenFile].
+	openFile(name string, flag int, perm os.FileMode) (osFile, error)
+	// readFile provides [os.ReadFile].
+	readFile(name string) ([]byte, error)
+	// writeFile provides [os.WriteFile].
+	writeFile(name string, data []byte, perm os.FileMode) error
 	// mkdir provides os.Mkdir.
 	mkdir(name string, perm os.FileMode) error
 	// chmod provides os.Chmod.
@@ -46,11 +55,23 @@ type syscallDispatcher interface {
 
 	// aclUpdate provides [acl.Update].
 	aclUpdate(name string, uid int, perms ...acl.Perm) error
+	// aclMerge provides [acl.Merge].
+	aclMerge(name string, uid int, perms ...acl.Perm) error
 
 	waylandNew(displayPath, bindPath *check.Absolute, appID, instanceID string) (*wayland.SecurityContext, error)
+	// waylandProxyNew provides [wayland.NewProxy].
+	waylandProxyNew(msg message.Msg, upstream, bindPath *check.Absolute, policy *hst.WaylandConfig) (*wayland.Proxy, error)
+	// pipewireNew provides [pipewire.New].
+	pipewireNew(remotePath, bindPath *check.Absolute, appID, instanceID string) (*pipewire.SecurityContext, error)
+	// pulseNew provides [pulse.New].
+	pulseNew(msg message.Msg, upstream, bindPath *check.Absolute, cookie []byte, policy *hst.PulseConfig) (*pulse.Proxy, error)
 
 	// xcbChangeHosts provides [xcb.ChangeHosts].
 	xcbChangeHosts(mode xcb.HostMode, family xcb.Family, address string) error
+	// xcbGenerateAuthorization provides [xcb.GenerateAuthorization].
+	xcbGenerateAuthorization(untrusted bool) (id uint32, data []byte, err error)
+	// xcbRevokeAuthorization provides [xcb.RevokeAuthorization].
+	xcbRevokeAuthorization(id uint32) error
 
 	// dbusFinalise provides [dbus.Finalise].
 	dbusFinalise(sessionBus, systemBus dbus.ProxyPair, session, system *hst.BusConfig) (final *dbus.Final, err error)
@@ -60,6 +81,8 @@ type syscallDispatcher interface {
 	dbusProxyClose(proxy *dbus.Proxy)
 	// dbusProxyWait provides the Wait method of [dbus.Proxy].
 	dbusProxyWait(proxy *dbus.Proxy) error
+	// dbusFilterNew provides [dbus.NewFilter].
+	dbusFilterNew(msg message.Msg, bus dbus.ProxyPair, config *hst.BusConfig, events *dbus.EventLog) (*dbus.Filter, error)
 }
 
 // direct implements syscallDispatcher on the current kernel.
@@ -67,8 +90,15 @@ type direct struct{}
 
 func (k direct) new(f func(k syscallDispatcher)) { go f(k) }
 
-func (k direct) stat(name string) (os.FileInfo, error)     { return os.Stat(name) }
-func (k direct) open(name string) (osFile, error)          { return os.Open(name) }
+func (k direct) stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+func (k direct) open(name string) (osFile, error)      { return os.Open(name) }
+func (k direct) readFile(name string) ([]byte, error)  { return os.ReadFile(name) }
+func (k direct) openFile(name string, flag int, perm os.FileMode) (osFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (k direct) writeFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
 func (k direct) mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
 func (k direct) chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
 func (k direct) link(oldname, newname string) error        { return os.Link(oldname, newname) }
@@ -80,14 +110,36 @@ func (k direct) aclUpdate(name string, uid int, perms ...acl.Perm) error {
 	return acl.Update(name, uid, perms...)
 }
 
+func (k direct) aclMerge(name string, uid int, perms ...acl.Perm) error {
+	return acl.Merge(name, uid, perms...)
+}
+
 func (k direct) waylandNew(displayPath, bindPath *check.Absolute, appID, instanceID string) (*wayland.SecurityContext, error) {
 	return wayland.New(displayPath, bindPath, appID, instanceID)
 }
 
+func (k direct) waylandProxyNew(msg message.Msg, upstream, bindPath *check.Absolute, policy *hst.WaylandConfig) (*wayland.Proxy, error) {
+	return wayland.NewProxy(msg, upstream, bindPath, policy)
+}
+
+func (k direct) pipewireNew(remotePath, bindPath *check.Absolute, appID, instanceID string) (*pipewire.SecurityContext, error) {
+	return pipewire.New(remotePath, bindPath, appID, instanceID)
+}
+
+func (k direct) pulseNew(msg message.Msg, upstream, bindPath *check.Absolute, cookie []byte, policy *hst.PulseConfig) (*pulse.Proxy, error) {
+	return pulse.New(msg, upstream, bindPath, cookie, policy)
+}
+
 func (k direct) xcbChangeHosts(mode xcb.HostMode, family xcb.Family, address string) error {
 	return xcb.ChangeHosts(mode, family, address)
 }
 
+func (k direct) xcbGenerateAuthorization(untrusted bool) (uint32, []byte, error) {
+	return xcb.GenerateAuthorization(untrusted)
+}
+
+func (k direct) xcbRevokeAuthorization(id uint32) error { return xcb.RevokeAuthorization(id) }
+
 func (k direct) dbusFinalise(sessionBus, systemBus dbus.ProxyPair, session, system *hst.BusConfig) (final *dbus.Final, err error) {
 	return dbus.Finalise(sessionBus, systemBus, session, system)
 }
@@ -95,3 +147,7 @@ func (k direct) dbusFinalise(sessionBus, systemBus dbus.ProxyPair, session, syst
 func (k direct) dbusProxyStart(proxy *dbus.Proxy) error { return proxy.Start() }
 func (k direct) dbusProxyClose(proxy *dbus.Proxy)       { proxy.Close() }
 func (k direct) dbusProxyWait(proxy *dbus.Proxy) error  { return proxy.Wait() }
+
+func (k direct) dbusFilterNew(msg message.Msg, bus dbus.ProxyPair, config *hst.BusConfig, events *dbus.EventLog) (*dbus.Filter, error) {
+	return dbus.NewFilter(msg, bus, config, events)
+}
@@ -13,8 +13,11 @@ import (
 	"hakurei.app/hst"
 	"hakurei.app/internal/acl"
 	"hakurei.app/internal/dbus"
+	"hakurei.app/internal/pipewire"
+	"hakurei.app/internal/pulse"
 	"hakurei.app/internal/wayland"
 	"hakurei.app/internal/xcb"
+	"hakurei.app/message"
 )
 
 // call initialises a [stub.Call].
@@ -227,6 +230,34 @@ func (k *kstub) open(name string) (f osFile, err error) {
 	return
 }
 
+func (k *kstub) openFile(name string, flag int, perm os.FileMode) (f osFile, err error) {
+	k.Helper()
+	expect := k.Expects("openFile")
+	err = expect.Error(
+		stub.CheckArg(k.Stub, "name", name, 0),
+		stub.CheckArg(k.Stub, "flag", flag, 1),
+		stub.CheckArg(k.Stub, "perm", perm, 2))
+	if err == nil {
+		f = expect.Ret.(osFile)
+	}
+	return
+}
+
+func (k *kstub) readFile(name string) ([]byte, error) {
+	k.Helper()
+	expect := k.Expects("readFile")
+	return expect.Ret.([]byte), expect.Error(
+		stub.CheckArg(k.Stub, "name", name, 0))
+}
+
+func (k *kstub) writeFile(name string, data []byte, perm os.FileMode) error {
+	k.Helper()
+	return k.Expects("writeFile").Error(
+		stub.CheckArg(k.Stub, "name", name, 0),
+		stub.CheckArgReflect(k.Stub, "data", data, 1),
+		stub.CheckArg(k.Stub, "perm", perm, 2))
+}
+
 func (k *kstub) mkdir(name string, perm os.FileMode) error {
 	k.Helper()
 	return k.Expects("mkdir").Error(
@@ -270,6 +301,14 @@ func (k *kstub) aclUpdate(name string, uid int, perms ...acl.Perm) error {
 		stub.CheckArgReflect(k.Stub, "perms", perms, 2))
 }
 
+func (k *kstub) aclMerge(name string, uid int, perms ...acl.Perm) error {
+	k.Helper()
+	return k.Expects("aclMerge").Error(
+		stub.CheckArg(k.Stub, "name", name, 0),
+		stub.CheckArg(k.Stub, "uid", uid, 1),
+		stub.CheckArgReflect(k.Stub, "perms", perms, 2))
+}
+
 func (k *kstub) waylandNew(displayPath, bindPath *check.Absolute, appID, instanceID string) (*wayland.SecurityContext, error) {
 	k.Helper()
 	return nil, k.Expects("waylandNew").Error(
@@ -279,6 +318,32 @@ func (k *kstub) waylandNew(displayPath, bindPath *check.Absolute, appID, instanc
 		stub.CheckArg(k.Stub, "instanceID", instanceID, 3))
 }
 
+func (k *kstub) waylandProxyNew(_ message.Msg, upstream, bindPath *check.Absolute, policy *hst.WaylandConfig) (*wayland.Proxy, error) {
+	k.Helper()
+	return nil, k.Expects("waylandProxyNew").Error(
+		stub.CheckArgReflect(k.Stub, "upstream", upstream, 0),
+		stub.CheckArgReflect(k.Stub, "bindPath", bindPath, 1),
+		stub.CheckArgReflect(k.Stub, "policy", policy, 2))
+}
+
+func (k *kstub) pipewireNew(remotePath, bindPath *check.Absolute, appID, instanceID string) (*pipewire.SecurityContext, error) {
+	k.Helper()
+	return nil, k.Expects("pipewireNew").Error(
+		stub.CheckArgReflect(k.Stub, "remotePath", remotePath, 0),
+		stub.CheckArgReflect(k.Stub, "bindPath", bindPath, 1),
+		stub.CheckArg(k.Stub, "appID", appID, 2),
+		stub.CheckArg(k.Stub, "instanceID", instanceID, 3))
+}
+
+func (k *kstub) pulseNew(_ message.Msg, upstream, bindPath *check.Absolute, cookie []byte, policy *hst.PulseConfig) (*pulse.Proxy, error) {
+	k.Helper()
+	return nil, k.Expects("pulseNew").Error(
+		stub.CheckArgReflect(k.Stub, "upstream", upstream, 0),
+		stub.CheckArgReflect(k.Stub, "bindPath", bindPath, 1),
+		stub.CheckArgReflect(k.Stub, "cookie", cookie, 2),
+		stub.CheckArgReflect(k.Stub, "policy", policy, 3))
+}
+
 func (k *kstub) xcbChangeHosts(mode xcb.HostMode, family xcb.Family, address string) error {
 	k.Helper()
 	return k.Expects("xcbChangeHosts").Error(
@@ -287,6 +352,20 @@ func (k *kstub) xcbChangeHosts(mode xcb.HostMode, family xcb.Family, address str
 		stub.CheckArg(k.Stub, "address", address, 2))
 }
 
+func (k *kstub) xcbGenerateAuthorization(untrusted bool) (uint32, []byte, error) {
+	k.Helper()
+	expect := k.Expects("xcbGenerateAuthorization")
+	ret := expect.Ret.([2]any)
+	return ret[0].(uint32), ret[1].([]byte), expect.Error(
+		stub.CheckArg(k.Stub, "untrusted", untrusted, 0))
+}
+
+func (k *kstub) xcbRevokeAuthorization(id uint32) error {
+	k.Helper()
+	return k.Expects("xcbRevokeAuthorization").Error(
+		stub.CheckArg(k.Stub, "id", id, 0))
+}
+
 func (k *kstub) dbusFinalise(sessionBus, systemBus dbus.ProxyPair, session, system *hst.BusConfig) (final *dbus.Final, err error) {
 	k.Helper()
 	expect := k.Expects("dbusFinalise")
@@ -341,6 +420,14 @@ func (k *kstub) dbusProxySCW(expect *stub.Call, proxy *dbus.Proxy) error {
 	return expect.Err
 }
 
+func (k *kstub) dbusFilterNew(_ message.Msg, bus dbus.ProxyPair, config *hst.BusConfig, events *dbus.EventLog) (*dbus.Filter, error) {
+	k.Helper()
+	return nil, k.Expects("dbusFilterNew").Error(
+		stub.CheckArg(k.Stub, "bus", bus, 0),
+		stub.CheckArgReflect(k.Stub, "config", config, 1),
+		stub.CheckArg(k.Stub, "events", events != nil, 2))
+}
+
 func (k *kstub) GetLogger() *log.Logger { panic("unreachable") }
 
 func (k *kstub) IsVerbose() bool { k.Helper(); return k.Expects("isVerbose").Ret.(bool) }
@@ -0,0 +1,82 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/pipewire"
+)
+
+// PipeWire maintains a PipeWire socket at dst with a security context attached via [pipewire],
+// created on the server at src and identifying the container by appID and instanceID.
+// On revert, the socket stops accepting connections as [pipewire.SecurityContext.Close] closes
+// the pipe held by the server, and its pathname is removed.
+func (sys *I) PipeWire(dst, src *check.Absolute, appID, instanceID string) *I {
+	sys.ops = append(sys.ops, &pipewireOp{nil,
+		dst, src, appID, instanceID})
+	return sys
+}
+
+// pipewireOp implements [I.PipeWire].
+type pipewireOp struct {
+	ctx               *pipewire.SecurityContext
+	dst, src          *check.Absolute
+	appID, instanceID string
+}
+
+func (p *pipewireOp) Type() hst.Enablement { return Process }
+
+func (p *pipewireOp) apply(sys *I) (err error) {
+	if p.ctx, err = sys.pipewireNew(p.src, p.dst, p.appID, p.instanceID); err != nil {
+		return newOpError("pipewire", err, false)
+	} else {
+		sys.msg.Verbosef("pipewire pathname socket on %q via %q", p.dst, p.src)
+
+		if err = sys.chmod(p.dst.String(), 0); err != nil {
+			if closeErr := p.ctx.Close(); closeErr != nil {
+				return newOpError("pipewire", errors.Join(err, closeErr), false)
+			}
+			return newOpError("pipewire", err, false)
+		}
+
+		if err = sys.aclUpdate(p.dst.String(), sys.uid, acl.Read, acl.Write, acl.Execute); err != nil {
+			if closeErr := p.ctx.Close(); closeErr != nil {
+				return newOpError("pipewire", errors.Join(err, closeErr), false)
+			}
+			return newOpError("pipewire", err, false)
+		}
+
+		return nil
+	}
+}
+
+func (p *pipewireOp) revert(sys *I, _ *Criteria) error {
+	var (
+		hangupErr error
+		removeErr error
+	)
+
+	sys.msg.Verbosef("hanging up pipewire socket on %q", p.dst)
+	if p.ctx != nil {
+		hangupErr = p.ctx.Close()
+	}
+	if err := sys.remove(p.dst.String()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeErr = err
+	}
+
+	return newOpError("pipewire", errors.Join(hangupErr, removeErr), true)
+}
+
+func (p *pipewireOp) Is(o Op) bool {
+	target, ok := o.(*pipewireOp)
+	return ok && p != nil && target != nil &&
+		p.dst.Is(target.dst) && p.src.Is(target.src) &&
+		p.appID == target.appID && p.instanceID == target.instanceID
+}
+
+func (p *pipewireOp) Path() string   { return p.dst.String() }
+func (p *pipewireOp) String() string { return fmt.Sprintf("pipewire socket at %q", p.dst) }
@@ -0,0 +1,157 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"hakurei.app/container/stub"
+	"hakurei.app/internal/acl"
+)
+
+func TestPipeWireOp(t *testing.T) {
+	t.Parallel()
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"chmod", 0xbeef, 0xff, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, []stub.Call{
+			call("pipewireNew", stub.ExpectArgs{m("/run/user/1971/pipewire-0"), m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), "org.chromium.Chromium", "ebf083d1b175911782d413369b64ce7c"}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"pipewire pathname socket on %q via %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), m("/run/user/1971/pipewire-0")}}, nil, nil),
+			call("chmod", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", os.FileMode(0)}, nil, stub.UniqueError(3)),
+		}, &OpError{Op: "pipewire", Err: errors.Join(stub.UniqueError(3), os.ErrInvalid)}, nil, nil},
+
+		{"aclUpdate", 0xbeef, 0xff, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, []stub.Call{
+			call("pipewireNew", stub.ExpectArgs{m("/run/user/1971/pipewire-0"), m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), "org.chromium.Chromium", "ebf083d1b175911782d413369b64ce7c"}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"pipewire pathname socket on %q via %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), m("/run/user/1971/pipewire-0")}}, nil, nil),
+			call("chmod", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, stub.UniqueError(2)),
+		}, &OpError{Op: "pipewire", Err: errors.Join(stub.UniqueError(2), os.ErrInvalid)}, nil, nil},
+
+		{"remove", 0xbeef, 0xff, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, []stub.Call{
+			call("pipewireNew", stub.ExpectArgs{m("/run/user/1971/pipewire-0"), m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), "org.chromium.Chromium", "ebf083d1b175911782d413369b64ce7c"}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"pipewire pathname socket on %q via %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), m("/run/user/1971/pipewire-0")}}, nil, nil),
+			call("chmod", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"hanging up pipewire socket on %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"}, nil, stub.UniqueError(1)),
+		}, &OpError{Op: "pipewire", Err: errors.Join(stub.UniqueError(1)), Revert: true}},
+
+		{"success", 0xbeef, 0xff, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, []stub.Call{
+			call("pipewireNew", stub.ExpectArgs{m("/run/user/1971/pipewire-0"), m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), "org.chromium.Chromium", "ebf083d1b175911782d413369b64ce7c"}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"pipewire pathname socket on %q via %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"), m("/run/user/1971/pipewire-0")}}, nil, nil),
+			call("chmod", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"hanging up pipewire socket on %q", []any{m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"}, nil, nil),
+		}, nil},
+	})
+
+	checkOpsBuilder(t, "PipeWire", []opsBuilderTestCase{
+		{"chromium", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.PipeWire(
+				m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+				m("/run/user/1971/pipewire-0"),
+				"org.chromium.Chromium",
+				"ebf083d1b175911782d413369b64ce7c",
+			)
+		}, []Op{&pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}}, stub.Expect{}},
+	})
+
+	checkOpIs(t, []opIsTestCase{
+		{"dst differs", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7d/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, false},
+
+		{"src differs", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-1"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, false},
+
+		{"appID differs", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, false},
+
+		{"instanceID differs", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7d",
+		}, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, false},
+
+		{"equals", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, true},
+	})
+
+	checkOpMeta(t, []opMetaTestCase{
+		{"chromium", &pipewireOp{nil,
+			m("/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"),
+			m("/run/user/1971/pipewire-0"),
+			"org.chromium.Chromium",
+			"ebf083d1b175911782d413369b64ce7c",
+		}, Process, "/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire",
+			`pipewire socket at "/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/pipewire"`},
+	})
+}
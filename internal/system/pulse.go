@@ -0,0 +1,79 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/pulse"
+)
+
+// ProxyPulse maintains a PulseAudio protocol filtering proxy via [pulse] relaying connections on dst to src.
+// The socket is pathname only and is destroyed on revert.
+func (sys *I) ProxyPulse(dst, src *check.Absolute, cookie []byte, policy *hst.PulseConfig) *I {
+	sys.ops = append(sys.ops, &pulseProxyOp{nil, dst, src, cookie, policy})
+	return sys
+}
+
+// pulseProxyOp implements [I.ProxyPulse].
+type pulseProxyOp struct {
+	proxy    *pulse.Proxy
+	dst, src *check.Absolute
+	cookie   []byte
+	policy   *hst.PulseConfig
+}
+
+func (p *pulseProxyOp) Type() hst.Enablement { return Process }
+
+func (p *pulseProxyOp) apply(sys *I) (err error) {
+	if p.proxy, err = sys.pulseNew(sys.msg, p.src, p.dst, p.cookie, p.policy); err != nil {
+		return newOpError("pulse", err, false)
+	}
+	sys.msg.Verbosef("pulseaudio proxy on %q for upstream %q", p.dst, p.src)
+
+	if err = sys.chmod(p.dst.String(), 0); err != nil {
+		if closeErr := p.proxy.Close(); closeErr != nil {
+			return newOpError("pulse", errors.Join(err, closeErr), false)
+		}
+		return newOpError("pulse", err, false)
+	}
+
+	if err = sys.aclUpdate(p.dst.String(), sys.uid, acl.Read, acl.Write, acl.Execute); err != nil {
+		if closeErr := p.proxy.Close(); closeErr != nil {
+			return newOpError("pulse", errors.Join(err, closeErr), false)
+		}
+		return newOpError("pulse", err, false)
+	}
+	return nil
+}
+
+func (p *pulseProxyOp) revert(sys *I, _ *Criteria) error {
+	var (
+		closeErr  error
+		removeErr error
+	)
+
+	sys.msg.Verbosef("terminating pulseaudio proxy on %q", p.dst)
+	if p.proxy != nil {
+		closeErr = p.proxy.Close()
+	}
+	if err := sys.remove(p.dst.String()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeErr = err
+	}
+
+	return newOpError("pulse", errors.Join(closeErr, removeErr), true)
+}
+
+func (p *pulseProxyOp) Is(o Op) bool {
+	target, ok := o.(*pulseProxyOp)
+	return ok && p != nil && target != nil &&
+		p.dst.Is(target.dst) && p.src.Is(target.src) &&
+		string(p.cookie) == string(target.cookie) &&
+		p.policy != nil && target.policy != nil && *p.policy == *target.policy
+}
+
+func (p *pulseProxyOp) Path() string   { return p.dst.String() }
+func (p *pulseProxyOp) String() string { return fmt.Sprintf("pulseaudio proxy at %q", p.dst) }
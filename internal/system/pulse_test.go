@@ -0,0 +1,122 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+)
+
+func TestPulseProxyOp(t *testing.T) {
+	t.Parallel()
+
+	newOp := func() *pulseProxyOp {
+		return &pulseProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+			m("/run/user/1971/pulse/native"),
+			[]byte{0xfd},
+			&hst.PulseConfig{Introspect: true},
+		}
+	}
+	wantNew := call("pulseNew", stub.ExpectArgs{
+		m("/run/user/1971/pulse/native"),
+		m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+		[]byte{0xfd},
+		&hst.PulseConfig{Introspect: true},
+	}, nil, nil)
+	wantVerbose := call("verbosef", stub.ExpectArgs{"pulseaudio proxy on %q for upstream %q", []any{
+		m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+		m("/run/user/1971/pulse/native"),
+	}}, nil, nil)
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"pulseNew", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("pulseNew", stub.ExpectArgs{
+				m("/run/user/1971/pulse/native"),
+				m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+				[]byte{0xfd},
+				&hst.PulseConfig{Introspect: true},
+			}, nil, stub.UniqueError(4)),
+		}, &OpError{Op: "pulse", Err: stub.UniqueError(4)}, nil, nil},
+
+		{"chmod", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", os.FileMode(0)}, nil, stub.UniqueError(3)),
+		}, &OpError{Op: "pulse", Err: errors.Join(stub.UniqueError(3), os.ErrInvalid)}, nil, nil},
+
+		{"aclUpdate", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, stub.UniqueError(2)),
+		}, &OpError{Op: "pulse", Err: errors.Join(stub.UniqueError(2), os.ErrInvalid)}, nil, nil},
+
+		{"remove", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"terminating pulseaudio proxy on %q", []any{m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"}, nil, stub.UniqueError(1)),
+		}, &OpError{Op: "pulse", Err: errors.Join(stub.UniqueError(1)), Revert: true}},
+
+		{"success", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"terminating pulseaudio proxy on %q", []any{m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"}, nil, nil),
+		}, nil},
+	})
+
+	checkOpsBuilder(t, "ProxyPulse", []opsBuilderTestCase{
+		{"introspect", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.ProxyPulse(
+				m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+				m("/run/user/1971/pulse/native"),
+				[]byte{0xfd},
+				&hst.PulseConfig{Introspect: true},
+			)
+		}, []Op{newOp()}, stub.Expect{}},
+	})
+
+	checkOpIs(t, []opIsTestCase{
+		{"dst differs", newOp(), &pulseProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7d/pulse"),
+			m("/run/user/1971/pulse/native"),
+			[]byte{0xfd},
+			&hst.PulseConfig{Introspect: true},
+		}, false},
+
+		{"src differs", newOp(), &pulseProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+			m("/run/user/1971/pulse/native0"),
+			[]byte{0xfd},
+			&hst.PulseConfig{Introspect: true},
+		}, false},
+
+		{"cookie differs", newOp(), &pulseProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+			m("/run/user/1971/pulse/native"),
+			nil,
+			&hst.PulseConfig{Introspect: true},
+		}, false},
+
+		{"policy differs", newOp(), &pulseProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"),
+			m("/run/user/1971/pulse/native"),
+			[]byte{0xfd},
+			&hst.PulseConfig{Introspect: true, Record: true},
+		}, false},
+
+		{"equals", newOp(), newOp(), true},
+	})
+
+	checkOpMeta(t, []opMetaTestCase{
+		{"introspect", newOp(), Process, "/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse",
+			`pulseaudio proxy at "/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/pulse"`},
+	})
+}
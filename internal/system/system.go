@@ -16,7 +16,8 @@ const (
 	// Process type is unconditionally reverted on exit.
 	Process
 
-	CM
+	// CM masks all types specific to this package. [hst.Enablement] has no bits left above [Process].
+	CM = User | Process
 )
 
 // Criteria specifies types of Op to revert.
@@ -58,7 +59,7 @@ func TypeString(e hst.Enablement) string {
 			buf.WriteString(v.String())
 		}
 
-		for i := User; i < CM; i <<= 1 {
+		for i := User; i&CM != 0; i <<= 1 {
 			if e&i != 0 {
 				buf.WriteString(", " + TypeString(i))
 			}
@@ -59,6 +59,7 @@ func TestTypeString(t *testing.T) {
 		{User | Process, "user, process"},
 		{hst.EWayland | User | Process, "wayland, user, process"},
 		{hst.EX11 | Process, "x11, process"},
+		{hst.EPipeWire | User | Process, "pipewire, user, process"},
 	}
 
 	for _, tc := range testCases {
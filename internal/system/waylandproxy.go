@@ -0,0 +1,81 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/wayland"
+)
+
+// ProxyWayland maintains a Wayland protocol filtering proxy via [wayland] relaying connections on dst to src.
+// The socket is pathname only and is destroyed on revert.
+func (sys *I) ProxyWayland(dst, src *check.Absolute, policy *hst.WaylandConfig) *I {
+	sys.ops = append(sys.ops, &waylandProxyOp{nil, dst, src, policy})
+	return sys
+}
+
+// waylandProxyOp implements [I.ProxyWayland].
+type waylandProxyOp struct {
+	proxy    *wayland.Proxy
+	dst, src *check.Absolute
+	policy   *hst.WaylandConfig
+}
+
+func (w *waylandProxyOp) Type() hst.Enablement { return Process }
+
+func (w *waylandProxyOp) apply(sys *I) (err error) {
+	if w.proxy, err = sys.waylandProxyNew(sys.msg, w.src, w.dst, w.policy); err != nil {
+		return newOpError("wayland", err, false)
+	}
+	sys.msg.Verbosef("wayland proxy on %q for upstream %q", w.dst, w.src)
+
+	if err = sys.chmod(w.dst.String(), 0); err != nil {
+		if closeErr := w.proxy.Close(); closeErr != nil {
+			return newOpError("wayland", errors.Join(err, closeErr), false)
+		}
+		return newOpError("wayland", err, false)
+	}
+
+	if err = sys.aclUpdate(w.dst.String(), sys.uid, acl.Read, acl.Write, acl.Execute); err != nil {
+		if closeErr := w.proxy.Close(); closeErr != nil {
+			return newOpError("wayland", errors.Join(err, closeErr), false)
+		}
+		return newOpError("wayland", err, false)
+	}
+	return nil
+}
+
+func (w *waylandProxyOp) revert(sys *I, _ *Criteria) error {
+	var (
+		closeErr  error
+		removeErr error
+	)
+
+	sys.msg.Verbosef("terminating wayland proxy on %q", w.dst)
+	if w.proxy != nil {
+		closeErr = w.proxy.Close()
+	}
+	if err := sys.remove(w.dst.String()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeErr = err
+	}
+
+	return newOpError("wayland", errors.Join(closeErr, removeErr), true)
+}
+
+func (w *waylandProxyOp) Is(o Op) bool {
+	target, ok := o.(*waylandProxyOp)
+	return ok && w != nil && target != nil &&
+		w.dst.Is(target.dst) && w.src.Is(target.src) &&
+		w.policy != nil && target.policy != nil &&
+		slices.Equal(w.policy.Allow, target.policy.Allow) &&
+		(w.policy.Allow == nil) == (target.policy.Allow == nil) &&
+		slices.Equal(w.policy.Deny, target.policy.Deny)
+}
+
+func (w *waylandProxyOp) Path() string   { return w.dst.String() }
+func (w *waylandProxyOp) String() string { return fmt.Sprintf("wayland proxy at %q", w.dst) }
@@ -0,0 +1,114 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"hakurei.app/container/stub"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+)
+
+func TestWaylandProxyOp(t *testing.T) {
+	t.Parallel()
+
+	newOp := func() *waylandProxyOp {
+		return &waylandProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+			m("/run/user/1971/wayland-0"),
+			&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+		}
+	}
+	wantNew := call("waylandProxyNew", stub.ExpectArgs{
+		m("/run/user/1971/wayland-0"),
+		m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+		&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+	}, nil, nil)
+	wantVerbose := call("verbosef", stub.ExpectArgs{"wayland proxy on %q for upstream %q", []any{
+		m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+		m("/run/user/1971/wayland-0"),
+	}}, nil, nil)
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"waylandProxyNew", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("waylandProxyNew", stub.ExpectArgs{
+				m("/run/user/1971/wayland-0"),
+				m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+				&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+			}, nil, stub.UniqueError(4)),
+		}, &OpError{Op: "wayland", Err: stub.UniqueError(4)}, nil, nil},
+
+		{"chmod", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", os.FileMode(0)}, nil, stub.UniqueError(3)),
+		}, &OpError{Op: "wayland", Err: errors.Join(stub.UniqueError(3), os.ErrInvalid)}, nil, nil},
+
+		{"aclUpdate", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, stub.UniqueError(2)),
+		}, &OpError{Op: "wayland", Err: errors.Join(stub.UniqueError(2), os.ErrInvalid)}, nil, nil},
+
+		{"remove", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"terminating wayland proxy on %q", []any{m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"}, nil, stub.UniqueError(1)),
+		}, &OpError{Op: "wayland", Err: errors.Join(stub.UniqueError(1)), Revert: true}},
+
+		{"success", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNew, wantVerbose,
+			call("chmod", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland", 0xbeef, []acl.Perm{acl.Read, acl.Write, acl.Execute}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"terminating wayland proxy on %q", []any{m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland")}}, nil, nil),
+			call("remove", stub.ExpectArgs{"/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"}, nil, nil),
+		}, nil},
+	})
+
+	checkOpsBuilder(t, "ProxyWayland", []opsBuilderTestCase{
+		{"deny", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.ProxyWayland(
+				m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+				m("/run/user/1971/wayland-0"),
+				&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+			)
+		}, []Op{newOp()}, stub.Expect{}},
+	})
+
+	checkOpIs(t, []opIsTestCase{
+		{"dst differs", newOp(), &waylandProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7d/wayland"),
+			m("/run/user/1971/wayland-0"),
+			&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+		}, false},
+
+		{"src differs", newOp(), &waylandProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+			m("/run/user/1971/wayland-00"),
+			&hst.WaylandConfig{Deny: []string{"zwlr_*"}},
+		}, false},
+
+		{"allow differs", newOp(), &waylandProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+			m("/run/user/1971/wayland-0"),
+			&hst.WaylandConfig{Allow: []string{}, Deny: []string{"zwlr_*"}},
+		}, false},
+
+		{"deny differs", newOp(), &waylandProxyOp{nil,
+			m("/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"),
+			m("/run/user/1971/wayland-0"),
+			&hst.WaylandConfig{Deny: []string{"zwlr_*", "wl_data_device_manager"}},
+		}, false},
+
+		{"equals", newOp(), newOp(), true},
+	})
+
+	checkOpMeta(t, []opMetaTestCase{
+		{"deny", newOp(), Process, "/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland",
+			`wayland proxy at "/run/user/1971/hakurei/ebf083d1b175911782d413369b64ce7c/wayland"`},
+	})
+}
@@ -0,0 +1,105 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/xcb"
+)
+
+// ErrXauthority is returned by [I.Commit] if the SECURITY extension is not available for the X11
+// display and copying the authorization cookie in the host Xauthority file is not permitted or not possible.
+var ErrXauthority = errors.New("no X11 authorization available")
+
+// Xauthority writes an Xauthority file to dst holding an untrusted per-instance authorization
+// for the X11 display number generated via the SECURITY extension, and revokes it on revert.
+// If the X server does not implement SECURITY, the master cookie for display is copied from the
+// Xauthority file at xauthority instead if it is not nil, otherwise [ErrXauthority] is returned.
+// The file is destroyed on revert.
+func (sys *I) Xauthority(dst *check.Absolute, display string, xauthority *check.Absolute) *I {
+	sys.ops = append(sys.ops, &xauthOp{false, 0, dst, display, xauthority})
+	return sys
+}
+
+// xauthOp implements [I.Xauthority].
+type xauthOp struct {
+	// Whether id refers to an authorization generated by apply.
+	generated  bool
+	id         uint32
+	dst        *check.Absolute
+	display    string
+	xauthority *check.Absolute
+}
+
+func (x *xauthOp) Type() hst.Enablement { return Process }
+
+func (x *xauthOp) apply(sys *I) error {
+	var cookie []byte
+	if id, data, err := sys.xcbGenerateAuthorization(true); err == nil {
+		sys.msg.Verbosef("generated X11 authorization %d", id)
+		x.generated, x.id, cookie = true, id, data
+	} else if !errors.Is(err, xcb.ErrSecurityNotAvail) {
+		return newOpError("xauth", err, false)
+	} else if x.xauthority == nil {
+		return newOpError("xauth", errors.Join(err, ErrXauthority), false)
+	} else {
+		sys.println("X server does not implement SECURITY, granting trusted access via the master cookie from " +
+			strconv.Quote(x.xauthority.String()))
+		var entries []xcb.XauthEntry
+		if data, err = sys.readFile(x.xauthority.String()); err != nil {
+			return newOpError("xauth", err, false)
+		} else if entries, err = xcb.ReadXauthority(bytes.NewReader(data)); err != nil {
+			return newOpError("xauth", err, false)
+		}
+		if cookie = xcb.FindCookie(entries, x.display); cookie == nil {
+			return newOpError("xauth", ErrXauthority, false)
+		}
+	}
+
+	entry := xcb.XauthEntry{Family: xcb.FamilyWild, Number: x.display, Name: xcb.AuthName, Data: cookie}
+	if err := sys.writeFile(x.dst.String(), entry.Append(nil), 0); err != nil {
+		return newOpError("xauth", errors.Join(err, x.revoke(sys)), false)
+	}
+	if err := sys.aclUpdate(x.dst.String(), sys.uid, acl.Read); err != nil {
+		return newOpError("xauth", errors.Join(err, x.revoke(sys)), false)
+	}
+	return nil
+}
+
+// revoke revokes the authorization generated by apply, if any.
+func (x *xauthOp) revoke(sys *I) error {
+	if !x.generated {
+		return nil
+	}
+	sys.msg.Verbosef("revoking X11 authorization %d", x.id)
+	x.generated = false
+	return sys.xcbRevokeAuthorization(x.id)
+}
+
+func (x *xauthOp) revert(sys *I, _ *Criteria) error {
+	var removeErr error
+	revokeErr := x.revoke(sys)
+	sys.msg.Verbosef("removing Xauthority file %q", x.dst)
+	if err := sys.remove(x.dst.String()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		removeErr = err
+	}
+	return newOpError("xauth", errors.Join(revokeErr, removeErr), true)
+}
+
+func (x *xauthOp) Is(o Op) bool {
+	target, ok := o.(*xauthOp)
+	return ok && x != nil && target != nil &&
+		x.dst.Is(target.dst) && x.display == target.display &&
+		x.xauthority.Is(target.xauthority)
+}
+
+func (x *xauthOp) Path() string { return x.dst.String() }
+func (x *xauthOp) String() string {
+	return fmt.Sprintf("Xauthority for display %s at %q", x.display, x.dst)
+}
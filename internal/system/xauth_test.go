@@ -0,0 +1,119 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"hakurei.app/container/stub"
+	"hakurei.app/internal/acl"
+	"hakurei.app/internal/xcb"
+)
+
+func TestXauthOp(t *testing.T) {
+	t.Parallel()
+
+	const dst = "/tmp/hakurei.1971/ebf083d1b175911782d413369b64ce7c/Xauthority"
+	newOp := func() *xauthOp {
+		return &xauthOp{false, 0, m(dst), "0", m("/home/ophestra/.Xauthority")}
+	}
+	cookie := bytes.Repeat([]byte{0xfd}, 16)
+	wantFile := (&xcb.XauthEntry{Family: xcb.FamilyWild, Number: "0", Name: xcb.AuthName, Data: cookie}).Append(nil)
+	hostFile := (&xcb.XauthEntry{Family: xcb.FamilyLocal, Address: "localhost", Number: "0", Name: xcb.AuthName, Data: cookie}).Append(nil)
+
+	wantNotAvail := call("xcbGenerateAuthorization", stub.ExpectArgs{true}, [2]any{uint32(0), []byte(nil)}, xcb.ErrSecurityNotAvail)
+	wantFallback := call("println", stub.ExpectArgs{[]any{`X server does not implement SECURITY, granting trusted access via the master cookie from "/home/ophestra/.Xauthority"`}}, nil, nil)
+	wantGenerate := []stub.Call{
+		call("xcbGenerateAuthorization", stub.ExpectArgs{true}, [2]any{uint32(0xbeef), cookie}, nil),
+		call("verbosef", stub.ExpectArgs{"generated X11 authorization %d", []any{uint32(0xbeef)}}, nil, nil),
+	}
+	wantRevoke := call("verbosef", stub.ExpectArgs{"revoking X11 authorization %d", []any{uint32(0xbeef)}}, nil, nil)
+
+	checkOpBehaviour(t, []opBehaviourTestCase{
+		{"xcbGenerateAuthorization", 0xbeef, 0xff, newOp(), []stub.Call{
+			call("xcbGenerateAuthorization", stub.ExpectArgs{true}, [2]any{uint32(0), []byte(nil)}, stub.UniqueError(7)),
+		}, &OpError{Op: "xauth", Err: stub.UniqueError(7)}, nil, nil},
+
+		{"no fallback", 0xbeef, 0xff, &xauthOp{false, 0, m(dst), "0", nil}, []stub.Call{
+			wantNotAvail,
+		}, &OpError{Op: "xauth", Err: errors.Join(xcb.ErrSecurityNotAvail, ErrXauthority)}, nil, nil},
+
+		{"readFile", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNotAvail, wantFallback,
+			call("readFile", stub.ExpectArgs{"/home/ophestra/.Xauthority"}, []byte(nil), stub.UniqueError(6)),
+		}, &OpError{Op: "xauth", Err: stub.UniqueError(6)}, nil, nil},
+
+		{"malformed", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNotAvail, wantFallback,
+			call("readFile", stub.ExpectArgs{"/home/ophestra/.Xauthority"}, hostFile[:len(hostFile)-1], nil),
+		}, &OpError{Op: "xauth", Err: xcb.ErrXauthority}, nil, nil},
+
+		{"no cookie", 0xbeef, 0xff, &xauthOp{false, 0, m(dst), "1", m("/home/ophestra/.Xauthority")}, []stub.Call{
+			call("xcbGenerateAuthorization", stub.ExpectArgs{true}, [2]any{uint32(0), []byte(nil)}, xcb.ErrSecurityNotAvail),
+			wantFallback,
+			call("readFile", stub.ExpectArgs{"/home/ophestra/.Xauthority"}, hostFile, nil),
+		}, &OpError{Op: "xauth", Err: ErrXauthority}, nil, nil},
+
+		{"success fallback", 0xbeef, 0xff, newOp(), []stub.Call{
+			wantNotAvail, wantFallback,
+			call("readFile", stub.ExpectArgs{"/home/ophestra/.Xauthority"}, hostFile, nil),
+			call("writeFile", stub.ExpectArgs{dst, wantFile, os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{dst, 0xbeef, []acl.Perm{acl.Read}}, nil, nil),
+		}, nil, []stub.Call{
+			call("verbosef", stub.ExpectArgs{"removing Xauthority file %q", []any{m(dst)}}, nil, nil),
+			call("remove", stub.ExpectArgs{dst}, nil, nil),
+		}, nil},
+
+		{"writeFile", 0xbeef, 0xff, newOp(), append(wantGenerate,
+			call("writeFile", stub.ExpectArgs{dst, wantFile, os.FileMode(0)}, nil, stub.UniqueError(5)),
+			wantRevoke,
+			call("xcbRevokeAuthorization", stub.ExpectArgs{uint32(0xbeef)}, nil, nil),
+		), &OpError{Op: "xauth", Err: errors.Join(stub.UniqueError(5))}, nil, nil},
+
+		{"aclUpdate", 0xbeef, 0xff, newOp(), append(wantGenerate,
+			call("writeFile", stub.ExpectArgs{dst, wantFile, os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{dst, 0xbeef, []acl.Perm{acl.Read}}, nil, stub.UniqueError(4)),
+			wantRevoke,
+			call("xcbRevokeAuthorization", stub.ExpectArgs{uint32(0xbeef)}, nil, stub.UniqueError(3)),
+		), &OpError{Op: "xauth", Err: errors.Join(stub.UniqueError(4), stub.UniqueError(3))}, nil, nil},
+
+		{"revoke", 0xbeef, 0xff, newOp(), append(wantGenerate,
+			call("writeFile", stub.ExpectArgs{dst, wantFile, os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{dst, 0xbeef, []acl.Perm{acl.Read}}, nil, nil),
+		), nil, []stub.Call{
+			wantRevoke,
+			call("xcbRevokeAuthorization", stub.ExpectArgs{uint32(0xbeef)}, nil, stub.UniqueError(2)),
+			call("verbosef", stub.ExpectArgs{"removing Xauthority file %q", []any{m(dst)}}, nil, nil),
+			call("remove", stub.ExpectArgs{dst}, nil, stub.UniqueError(1)),
+		}, &OpError{Op: "xauth", Err: errors.Join(stub.UniqueError(2), stub.UniqueError(1)), Revert: true}},
+
+		{"success", 0xbeef, 0xff, newOp(), append(wantGenerate,
+			call("writeFile", stub.ExpectArgs{dst, wantFile, os.FileMode(0)}, nil, nil),
+			call("aclUpdate", stub.ExpectArgs{dst, 0xbeef, []acl.Perm{acl.Read}}, nil, nil),
+		), nil, []stub.Call{
+			wantRevoke,
+			call("xcbRevokeAuthorization", stub.ExpectArgs{uint32(0xbeef)}, nil, nil),
+			call("verbosef", stub.ExpectArgs{"removing Xauthority file %q", []any{m(dst)}}, nil, nil),
+			call("remove", stub.ExpectArgs{dst}, nil, nil),
+		}, nil},
+	})
+
+	checkOpsBuilder(t, "Xauthority", []opsBuilderTestCase{
+		{"display", 0xcafe, func(_ *testing.T, sys *I) {
+			sys.Xauthority(m(dst), "0", m("/home/ophestra/.Xauthority"))
+		}, []Op{newOp()}, stub.Expect{}},
+	})
+
+	checkOpIs(t, []opIsTestCase{
+		{"dst differs", newOp(), &xauthOp{false, 0, m(dst + "0"), "0", m("/home/ophestra/.Xauthority")}, false},
+		{"display differs", newOp(), &xauthOp{false, 0, m(dst), "1", m("/home/ophestra/.Xauthority")}, false},
+		{"xauthority differs", newOp(), &xauthOp{false, 0, m(dst), "0", nil}, false},
+		{"equals", newOp(), newOp(), true},
+	})
+
+	checkOpMeta(t, []opMetaTestCase{
+		{"display", newOp(), Process, dst,
+			`Xauthority for display 0 at "` + dst + `"`},
+	})
+}
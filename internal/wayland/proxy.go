@@ -0,0 +1,292 @@
+package wayland
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/internal/proxy"
+	"hakurei.app/message"
+)
+
+// errDenied is returned when a client binds a global hidden by the proxy.
+var errDenied = errors.New("client bound to a hidden global")
+
+// Proxy accepts connections on a pathname socket and relays them to a Wayland compositor,
+// hiding globals not advertised by its [hst.WaylandConfig] from the registry.
+type Proxy struct {
+	msg      message.Msg
+	upstream string
+	policy   hst.WaylandConfig
+
+	l *proxy.Listener
+}
+
+// NewProxy binds a pathname socket to bindPath and relays connections accepted on it to the
+// compositor at upstream, which may be a socket with a security context attached.
+//
+// NewProxy does not attach a finalizer to the resulting [Proxy] struct.
+// The caller is responsible for calling [Proxy.Close].
+func NewProxy(
+	msg message.Msg,
+	upstream, bindPath *check.Absolute,
+	policy *hst.WaylandConfig,
+) (*Proxy, error) {
+	if policy == nil {
+		return nil, os.ErrInvalid
+	}
+
+	p := Proxy{
+		msg:      msg,
+		upstream: upstream.String(),
+		policy:   *policy,
+	}
+	var err error
+	if p.l, err = proxy.Listen(bindPath.String()); err != nil {
+		return nil, err
+	}
+
+	p.l.Go(p.serve)
+	return &p, nil
+}
+
+// Close stops accepting connections, closes every active connection and waits for all
+// goroutines started by [Proxy] to return.
+func (p *Proxy) Close() error {
+	if p == nil {
+		return os.ErrInvalid
+	}
+
+	return p.l.Close()
+}
+
+// serve accepts connections until the listener is closed.
+func (p *Proxy) serve() {
+	for {
+		client, err := p.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				p.msg.Verbosef("cannot accept Wayland client: %v", err)
+			}
+			return
+		}
+
+		var server *net.UnixConn
+		if server, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: p.upstream, Net: "unix"}); err != nil {
+			p.msg.Verbosef("cannot connect to Wayland compositor: %v", err)
+			_ = client.Close()
+			continue
+		}
+		if !p.l.Track(client, server) {
+			return
+		}
+
+		c := conn{p: p,
+			registries: make(map[uint32]struct{}),
+			hidden:     make(map[uint32]string),
+		}
+		p.l.Go(func() {
+			if err := relay(client, server, c.request); !isClosed(err) {
+				p.msg.Verbosef("Wayland client: %v", err)
+			}
+			p.l.Untrack(client, server)
+		})
+		p.l.Go(func() {
+			if err := relay(server, client, c.event); !isClosed(err) {
+				p.msg.Verbosef("Wayland compositor: %v", err)
+			}
+			p.l.Untrack(client, server)
+		})
+	}
+}
+
+// isClosed returns whether err is nil, the result of either end closing the connection,
+// or the result of a denied bind which is logged separately.
+func isClosed(err error) bool {
+	return err == nil || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, errDenied)
+}
+
+// relay forwards messages read from src to dst if accepted by filter. File descriptors are
+// forwarded alongside the first message written after they are received, which libwayland
+// accepts as it queues file descriptors independently of the message stream.
+func relay(src, dst *net.UnixConn, filter func(m *wireMessage) (bool, error)) (err error) {
+	var (
+		buf     = make([]byte, 2*(messageSizeMax+1))
+		oob     = make([]byte, syscall.CmsgSpace(fdsMax*4))
+		pending int
+		fds     []int
+	)
+	defer func() {
+		for _, fd := range fds {
+			_ = syscall.Close(fd)
+		}
+	}()
+
+	for {
+		n, oobn, flags, _, readErr := src.ReadMsgUnix(buf[pending:], oob)
+		if oobn > 0 {
+			rights, parseErr := parseRights(oob[:oobn])
+			fds = append(fds, rights...)
+			if parseErr != nil {
+				return parseErr
+			}
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if flags&syscall.MSG_CTRUNC != 0 {
+			return syscall.EMFILE
+		}
+		if n == 0 {
+			return io.EOF
+		}
+		pending += n
+
+		var out []byte
+		// write messages accepted by filter, and file descriptors received so far
+		flush := func() error {
+			if len(out) == 0 {
+				return nil
+			}
+			var rights []byte
+			if len(fds) > 0 {
+				rights = syscall.UnixRights(fds...)
+			}
+			if _, _, writeErr := dst.WriteMsgUnix(out, rights, nil); writeErr != nil {
+				return writeErr
+			}
+			for _, fd := range fds {
+				_ = syscall.Close(fd)
+			}
+			fds = nil
+			return nil
+		}
+
+		off := 0
+		for {
+			m, size, msgErr := nextMessage(buf[off:pending])
+			if msgErr != nil {
+				return errors.Join(msgErr, flush())
+			}
+			if size == 0 {
+				break
+			}
+			if ok, filterErr := filter(&m); filterErr != nil {
+				// messages preceding the offending message are still delivered
+				return errors.Join(filterErr, flush())
+			} else if ok {
+				out = append(out, buf[off:off+size]...)
+			}
+			off += size
+		}
+		pending = copy(buf, buf[off:pending])
+
+		if err = flush(); err != nil {
+			return
+		}
+	}
+}
+
+// conn holds the registry state of a proxied connection.
+type conn struct {
+	p *Proxy
+
+	// Registry objects created by the client.
+	registries map[uint32]struct{}
+	// Globals hidden from the client, mapped to their interface name.
+	hidden map[uint32]string
+	mu     sync.Mutex
+}
+
+// isRegistry returns whether object refers to a registry created by the client.
+func (c *conn) isRegistry(object uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.registries[object]
+	return ok
+}
+
+// request filters a message sent by the client.
+func (c *conn) request(m *wireMessage) (bool, error) {
+	a := args{m.args}
+	switch {
+	case m.object == displayID && m.opcode == displayGetRegistry:
+		id, err := a.uint()
+		if err != nil {
+			return false, err
+		}
+		c.mu.Lock()
+		c.registries[id] = struct{}{}
+		c.mu.Unlock()
+
+	case m.opcode == registryBind && c.isRegistry(m.object):
+		name, err := a.uint()
+		if err != nil {
+			return false, err
+		}
+		var iface string
+		if iface, err = a.string(); err != nil {
+			return false, err
+		}
+
+		c.mu.Lock()
+		_, hidden := c.hidden[name]
+		c.mu.Unlock()
+		if hidden || !c.p.policy.Advertise(iface) {
+			c.p.msg.GetLogger().Printf("denied bind to Wayland global %d (%s)", name, iface)
+			return false, errDenied
+		}
+	}
+	return true, nil
+}
+
+// event filters a message sent by the compositor.
+func (c *conn) event(m *wireMessage) (bool, error) {
+	a := args{m.args}
+	switch {
+	case m.object == displayID && m.opcode == displayDeleteID:
+		id, err := a.uint()
+		if err != nil {
+			return false, err
+		}
+		c.mu.Lock()
+		delete(c.registries, id)
+		c.mu.Unlock()
+
+	case m.opcode == registryGlobal && c.isRegistry(m.object):
+		name, err := a.uint()
+		if err != nil {
+			return false, err
+		}
+		var iface string
+		if iface, err = a.string(); err != nil {
+			return false, err
+		}
+
+		if !c.p.policy.Advertise(iface) {
+			c.mu.Lock()
+			c.hidden[name] = iface
+			c.mu.Unlock()
+			return false, nil
+		}
+
+	case m.opcode == registryGlobalRemove && c.isRegistry(m.object):
+		name, err := a.uint()
+		if err != nil {
+			return false, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.hidden[name]; ok {
+			delete(c.hidden, name)
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,227 @@
+package wayland
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"hakurei.app/container/check"
+	"hakurei.app/hst"
+	"hakurei.app/message"
+)
+
+func TestWire(t *testing.T) {
+	t.Parallel()
+
+	m := wireMessage{object: 2, opcode: registryBind}
+	m.args = appendString(appendUint(m.args, 0xcafe), "wl_compositor")
+	m.args = appendUint(appendUint(m.args, 6), 3)
+	buf := m.encode()
+
+	if _, n, err := nextMessage(buf[:len(buf)-1]); err != nil || n != 0 {
+		t.Fatalf("nextMessage: %d, error = %v", n, err)
+	}
+	got, n, err := nextMessage(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("nextMessage: %d, error = %v", n, err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("nextMessage: %#v, want %#v", got, m)
+	}
+
+	a := args{got.args}
+	if v, err := a.uint(); err != nil || v != 0xcafe {
+		t.Errorf("uint: %#x, error = %v", v, err)
+	}
+	if v, err := a.string(); err != nil || v != "wl_compositor" {
+		t.Errorf("string: %q, error = %v", v, err)
+	}
+	for _, want := range []uint32{6, 3} {
+		if v, err := a.uint(); err != nil || v != want {
+			t.Errorf("uint: %d, error = %v", v, err)
+		}
+	}
+	if _, err = a.uint(); !errors.Is(err, ErrMessage) {
+		t.Errorf("uint: error = %v", err)
+	}
+
+	if _, err = (&args{appendUint(nil, 0)}).string(); !errors.Is(err, ErrMessage) {
+		t.Errorf("string: error = %v", err)
+	}
+	if _, err = (&args{appendUint(nil, 8)}).string(); !errors.Is(err, ErrMessage) {
+		t.Errorf("string: error = %v", err)
+	}
+	if _, _, err = nextMessage((&wireMessage{args: []byte{0}}).encode()); !errors.Is(err, ErrMessage) {
+		t.Errorf("nextMessage: error = %v", err)
+	}
+}
+
+// global returns a wl_registry.global event.
+func global(registry, name uint32, iface string, version uint32) []byte {
+	m := wireMessage{object: registry, opcode: registryGlobal}
+	m.args = appendUint(appendString(appendUint(nil, name), iface), version)
+	return m.encode()
+}
+
+// bind returns a wl_registry.bind request.
+func bind(registry, name uint32, iface string, version, id uint32) []byte {
+	m := wireMessage{object: registry, opcode: registryBind}
+	m.args = appendUint(appendUint(appendString(appendUint(nil, name), iface), version), id)
+	return m.encode()
+}
+
+// readAll reads exactly n bytes and any file descriptors sent alongside them from c.
+func readAll(c *net.UnixConn, n int) ([]byte, []int, error) {
+	buf := make([]byte, n)
+	oob := make([]byte, syscall.CmsgSpace(4*fdsMax))
+	var fds []int
+	for off := 0; off < n; {
+		nr, oobn, _, _, err := c.ReadMsgUnix(buf[off:], oob)
+		if oobn > 0 {
+			rights, parseErr := parseRights(oob[:oobn])
+			fds = append(fds, rights...)
+			if parseErr != nil {
+				return buf, fds, parseErr
+			}
+		}
+		if err != nil {
+			return buf, fds, err
+		}
+		off += nr
+	}
+	return buf, fds, nil
+}
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewProxy(nil, nil, nil, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("NewProxy: error = %v", err)
+	}
+	if err := (*Proxy)(nil).Close(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("Close: error = %v", err)
+	}
+
+	d := check.MustAbs(t.TempDir())
+	upstream, bindPath := d.Append("wayland-0"), d.Append("wayland")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: upstream.String(), Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix: error = %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	getRegistry := (&wireMessage{object: displayID, opcode: displayGetRegistry, args: appendUint(nil, 2)}).encode()
+	keymap := (&wireMessage{object: 5, opcode: 0, args: appendUint(nil, 1)}).encode()
+	globalRemove := func(name uint32) []byte {
+		return (&wireMessage{object: 2, opcode: registryGlobalRemove, args: appendUint(nil, name)}).encode()
+	}
+
+	// fakeCompositor advertises three globals, removes the hidden one and sends a file descriptor,
+	// then returns every byte received after wl_display.get_registry
+	type result struct {
+		requests []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		defer func() { done <- r }()
+
+		var c *net.UnixConn
+		if c, r.err = l.AcceptUnix(); r.err != nil {
+			return
+		}
+		defer func() { _ = c.Close() }()
+
+		var got []byte
+		if got, _, r.err = readAll(c, len(getRegistry)); r.err != nil {
+			return
+		} else if !bytes.Equal(got, getRegistry) {
+			r.err = errors.New("unexpected request")
+			return
+		}
+
+		var events []byte
+		events = append(events, global(2, 1, "wl_compositor", 6)...)
+		events = append(events, global(2, 2, "zwlr_screencopy_manager_v1", 3)...)
+		events = append(events, global(2, 3, "wl_data_device_manager", 3)...)
+		events = append(events, globalRemove(2)...)
+		if _, _, r.err = c.WriteMsgUnix(events, nil, nil); r.err != nil {
+			return
+		}
+		if _, _, r.err = c.WriteMsgUnix(keymap, syscall.UnixRights(int(os.Stdin.Fd())), nil); r.err != nil {
+			return
+		}
+
+		r.requests, r.err = io.ReadAll(c)
+	}()
+
+	var logBuf bytes.Buffer
+	var p *Proxy
+	if p, err = NewProxy(message.New(log.New(&logBuf, "", 0)), upstream, bindPath, &hst.WaylandConfig{
+		Deny: []string{"zwlr_screencopy_*"},
+	}); err != nil {
+		t.Fatalf("NewProxy: error = %v", err)
+	}
+
+	var c *net.UnixConn
+	if c, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: bindPath.String(), Net: "unix"}); err != nil {
+		t.Fatalf("DialUnix: error = %v", err)
+	}
+	if _, err = c.Write(getRegistry); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+
+	wantEvents := append(global(2, 1, "wl_compositor", 6), global(2, 3, "wl_data_device_manager", 3)...)
+	wantEvents = append(wantEvents, keymap...)
+	if got, fds, readErr := readAll(c, len(wantEvents)); readErr != nil {
+		t.Fatalf("readAll: error = %v", readErr)
+	} else {
+		for _, fd := range fds {
+			_ = syscall.Close(fd)
+		}
+		if !bytes.Equal(got, wantEvents) {
+			t.Fatalf("readAll: %x, want %x", got, wantEvents)
+		}
+		if len(fds) != 1 {
+			t.Fatalf("readAll: fds = %v", fds)
+		}
+	}
+
+	wantRequests := bind(2, 1, "wl_compositor", 6, 3)
+	if _, err = c.Write(wantRequests); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	// denied without reaching the compositor
+	if _, err = c.Write(bind(2, 2, "zwlr_screencopy_manager_v1", 3, 4)); err != nil {
+		t.Fatalf("Write: error = %v", err)
+	}
+	if _, err = c.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("Read: error = %v", err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("fakeCompositor: error = %v", r.err)
+	}
+	if !bytes.Equal(r.requests, wantRequests) {
+		t.Errorf("fakeCompositor: requests = %x, want %x", r.requests, wantRequests)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatalf("Close: error = %v", err)
+	}
+	if _, err = net.Dial("unix", bindPath.String()); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Dial: error = %v", err)
+	}
+	if got := logBuf.String(); !strings.Contains(got, "denied bind to Wayland global 2 (zwlr_screencopy_manager_v1)") {
+		t.Errorf("Close: log = %q", got)
+	}
+}
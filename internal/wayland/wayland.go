@@ -1,4 +1,4 @@
-// Package wayland implements Wayland security_context_v1 protocol.
+// Package wayland implements Wayland security_context_v1 protocol and a registry filtering proxy.
 package wayland
 
 //go:generate sh -c "wayland-scanner client-header `pkg-config --variable=datarootdir wayland-protocols`/wayland-protocols/staging/security-context/security-context-v1.xml security-context-v1-protocol.h"
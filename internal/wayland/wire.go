@@ -0,0 +1,133 @@
+package wayland
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+)
+
+// Objects, opcodes and size limits of the Wayland wire protocol referred to by the proxy
+// (https://gitlab.freedesktop.org/wayland/wayland/-/blob/1.23.1/protocol/wayland.xml).
+const (
+	// headerSize is the size of the header preceding the arguments of every message.
+	headerSize = 8
+	// messageSizeMax is the largest message representable by the header.
+	messageSizeMax = 1<<16 - 1
+	// fdsMax is the largest number of file descriptors accepted in a single read.
+	fdsMax = 253
+
+	// displayID is the object id of wl_display.
+	displayID = 1
+	// displayGetRegistry is the opcode of the wl_display.get_registry request.
+	displayGetRegistry = 1
+	// displayDeleteID is the opcode of the wl_display.delete_id event.
+	displayDeleteID = 1
+
+	// registryBind is the opcode of the wl_registry.bind request.
+	registryBind = 0
+	// registryGlobal is the opcode of the wl_registry.global event.
+	registryGlobal = 0
+	// registryGlobalRemove is the opcode of the wl_registry.global_remove event.
+	registryGlobalRemove = 1
+)
+
+// ErrMessage is returned when decoding a malformed message.
+var ErrMessage = errors.New("malformed wayland message")
+
+// A wireMessage is a single request or event in the Wayland wire protocol.
+type wireMessage struct {
+	// Object the message is sent to or emitted by.
+	object uint32
+	// Request or event opcode, interpreted according to the interface of object.
+	opcode uint16
+	// Encoded arguments of the message.
+	args []byte
+}
+
+// nextMessage returns the message at the start of buf and its encoded size, or a zero size
+// if buf does not hold the entire message.
+func nextMessage(buf []byte) (m wireMessage, n int, err error) {
+	if len(buf) < headerSize {
+		return
+	}
+	m.object = binary.NativeEndian.Uint32(buf)
+	v := binary.NativeEndian.Uint32(buf[4:])
+	m.opcode = uint16(v)
+	if n = int(v >> 16); n < headerSize || n%4 != 0 {
+		return m, 0, ErrMessage
+	}
+	if len(buf) < n {
+		return m, 0, nil
+	}
+	m.args = buf[headerSize:n]
+	return
+}
+
+// encode returns the wire representation of m.
+func (m *wireMessage) encode() []byte {
+	buf := make([]byte, headerSize, headerSize+len(m.args))
+	binary.NativeEndian.PutUint32(buf, m.object)
+	binary.NativeEndian.PutUint32(buf[4:], uint32(headerSize+len(m.args))<<16|uint32(m.opcode))
+	return append(buf, m.args...)
+}
+
+// args decodes arguments of a message.
+type args struct{ data []byte }
+
+// uint decodes an unsigned 32-bit integer, also used for object and new_id arguments.
+func (a *args) uint() (uint32, error) {
+	if len(a.data) < 4 {
+		return 0, ErrMessage
+	}
+	v := binary.NativeEndian.Uint32(a.data)
+	a.data = a.data[4:]
+	return v, nil
+}
+
+// string decodes a NUL-terminated string padded to 32 bits.
+func (a *args) string() (string, error) {
+	n, err := a.uint()
+	if err != nil {
+		return "", err
+	}
+	padded := (uint64(n) + 3) &^ 3
+	if n == 0 || padded > uint64(len(a.data)) || a.data[n-1] != 0 {
+		return "", ErrMessage
+	}
+	v := string(a.data[:n-1])
+	a.data = a.data[padded:]
+	return v, nil
+}
+
+// appendUint appends the encoding of an unsigned 32-bit integer to buf.
+func appendUint(buf []byte, v uint32) []byte { return binary.NativeEndian.AppendUint32(buf, v) }
+
+// appendString appends the encoding of a string to buf.
+func appendString(buf []byte, v string) []byte {
+	buf = appendUint(buf, uint32(len(v)+1))
+	buf = append(append(buf, v...), 0)
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// parseRights returns file descriptors held by SCM_RIGHTS messages in oob.
+func parseRights(oob []byte) ([]int, error) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for i := range messages {
+		if messages[i].Header.Level != syscall.SOL_SOCKET || messages[i].Header.Type != syscall.SCM_RIGHTS {
+			continue
+		}
+		var rights []int
+		if rights, err = syscall.ParseUnixRights(&messages[i]); err != nil {
+			return fds, err
+		}
+		fds = append(fds, rights...)
+	}
+	return fds, nil
+}
@@ -0,0 +1,189 @@
+package xcb
+
+/*
+#cgo linux pkg-config: --static xcb
+
+#include <stdlib.h>
+#include <string.h>
+#include <xcb/xcb.h>
+#include <xcb/xcbext.h>
+
+static xcb_extension_t hakurei_xcb_security_id = {"SECURITY", 0};
+
+static int hakurei_xcb_security_present(xcb_connection_t *c) {
+  const xcb_query_extension_reply_t *ext = xcb_get_extension_data(c, &hakurei_xcb_security_id);
+  return ext != NULL && ext->present;
+}
+
+// hakurei_xcb_security_request sends a SECURITY request with its header reserved at the start of buf,
+// and stores the reply in reply if the request is not void. The caller must free reply.
+static int hakurei_xcb_security_request(xcb_connection_t *c, uint8_t minor, int isvoid,
+                                        void *buf, size_t len, void **reply) {
+  int ret;
+  unsigned int sequence;
+  xcb_generic_error_t *e = NULL;
+  static char pad[4];
+  struct iovec parts[4];
+  xcb_protocol_request_t req = {
+      .count = 2,
+      .ext = &hakurei_xcb_security_id,
+      .opcode = minor,
+      .isvoid = isvoid,
+  };
+
+  parts[2].iov_base = buf;
+  parts[2].iov_len = len;
+  parts[3].iov_base = pad;
+  parts[3].iov_len = -len & 3;
+  sequence = xcb_send_request(c, XCB_REQUEST_CHECKED, parts + 2, &req);
+
+  if (isvoid) {
+    xcb_void_cookie_t cookie = {sequence};
+    e = xcb_request_check(c, cookie);
+  } else {
+    *reply = xcb_wait_for_reply(c, sequence, &e);
+  }
+
+  ret = xcb_connection_has_error(c);
+  if (ret != 0) {
+    free(e);
+    return ret;
+  }
+  if (e != NULL) {
+    free(e);
+    return -1;
+  }
+  return 0;
+}
+*/
+import "C"
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+// Requests, masks and values of the SECURITY extension
+// (https://www.x.org/releases/X11R7.7/doc/xextproto/security.html).
+const (
+	securityGenerateAuthorization = 1
+	securityRevokeAuthorization   = 2
+
+	securityAuthTimeout    = 1 << 0
+	securityAuthTrustLevel = 1 << 1
+
+	securityClientTrusted   = 0
+	securityClientUntrusted = 1
+)
+
+var (
+	// ErrSecurityNotAvail is returned if the X server does not implement the SECURITY extension.
+	ErrSecurityNotAvail = errors.New("X server does not implement SECURITY")
+	// ErrSecurity is returned if the X server rejected a SECURITY request.
+	ErrSecurity = errors.New("SECURITY request failed")
+)
+
+// securityRequest sends a SECURITY request with its arguments in args, returning the reply if not void.
+func (conn *connection) securityRequest(minor uint8, void bool, args []byte) ([]byte, error) {
+	if C.hakurei_xcb_security_present(conn.c) == 0 {
+		return nil, ErrSecurityNotAvail
+	}
+
+	// header is filled in by xcb_send_request
+	buf := C.CBytes(append(make([]byte, 4), args...))
+	defer C.free(buf)
+
+	var (
+		isVoid C.int
+		reply  unsafe.Pointer
+	)
+	if void {
+		isVoid = 1
+	}
+	ret := C.hakurei_xcb_security_request(conn.c, C.uint8_t(minor), isVoid, buf, C.size_t(4+len(args)), &reply)
+	if reply != nil {
+		defer C.free(reply)
+	}
+	switch ret {
+	case 0:
+		break
+	case -1:
+		return nil, ErrSecurity
+	default:
+		return nil, ConnectionError(ret)
+	}
+	if void {
+		return nil, nil
+	}
+
+	// replies are 32 bytes followed by the number of 4-byte units in the length field
+	length := 32 + 4*int(binary.NativeEndian.Uint32(unsafe.Slice((*byte)(reply), 32)[4:]))
+	return C.GoBytes(reply, C.int(length)), nil
+}
+
+// generateAuthorizationArgs returns the arguments of a GenerateAuthorization request for
+// an [AuthName] authorization that never times out.
+func generateAuthorizationArgs(untrusted bool) []byte {
+	trust := uint32(securityClientTrusted)
+	if untrusted {
+		trust = securityClientUntrusted
+	}
+
+	args := binary.NativeEndian.AppendUint16(nil, uint16(len(AuthName)))
+	args = binary.NativeEndian.AppendUint16(args, 0)
+	args = binary.NativeEndian.AppendUint32(args, securityAuthTimeout|securityAuthTrustLevel)
+	args = append(args, AuthName...)
+	for len(args)%4 != 0 {
+		args = append(args, 0)
+	}
+	// values are ordered by their bit in the value mask
+	args = binary.NativeEndian.AppendUint32(args, 0)
+	return binary.NativeEndian.AppendUint32(args, trust)
+}
+
+// parseGenerateAuthorizationReply returns the authorization id and data held by a GenerateAuthorization reply.
+func parseGenerateAuthorizationReply(reply []byte) (id uint32, data []byte, err error) {
+	if len(reply) < 32 {
+		return 0, nil, ErrSecurity
+	}
+	id = binary.NativeEndian.Uint32(reply[8:])
+	n := int(binary.NativeEndian.Uint16(reply[12:]))
+	if len(reply) < 32+n {
+		return 0, nil, ErrSecurity
+	}
+	return id, append([]byte(nil), reply[32:32+n]...), nil
+}
+
+// GenerateAuthorization creates a new [AuthName] authorization via the SECURITY extension
+// on the X server named by DISPLAY, which does not expire until revoked. If untrusted is true,
+// clients connecting with this authorization are untrusted.
+func GenerateAuthorization(untrusted bool) (id uint32, data []byte, err error) {
+	conn := new(connection)
+	if err = conn.connect(); err != nil {
+		conn.disconnect()
+		return
+	} else {
+		defer conn.disconnect()
+	}
+
+	var reply []byte
+	if reply, err = conn.securityRequest(securityGenerateAuthorization, false, generateAuthorizationArgs(untrusted)); err != nil {
+		return
+	}
+	return parseGenerateAuthorizationReply(reply)
+}
+
+// RevokeAuthorization revokes an authorization created by [GenerateAuthorization] and
+// closes every connection made with it.
+func RevokeAuthorization(id uint32) error {
+	conn := new(connection)
+	if err := conn.connect(); err != nil {
+		conn.disconnect()
+		return err
+	} else {
+		defer conn.disconnect()
+	}
+
+	_, err := conn.securityRequest(securityRevokeAuthorization, true, binary.NativeEndian.AppendUint32(nil, id))
+	return err
+}
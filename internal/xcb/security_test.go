@@ -0,0 +1,61 @@
+package xcb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestGenerateAuthorizationArgs(t *testing.T) {
+	t.Parallel()
+
+	args := generateAuthorizationArgs(true)
+	if len(args)%4 != 0 {
+		t.Fatalf("generateAuthorizationArgs: unaligned length %d", len(args))
+	}
+	if n := binary.NativeEndian.Uint16(args); n != uint16(len(AuthName)) {
+		t.Errorf("generateAuthorizationArgs: name length %d", n)
+	}
+	if mask := binary.NativeEndian.Uint32(args[4:]); mask != securityAuthTimeout|securityAuthTrustLevel {
+		t.Errorf("generateAuthorizationArgs: value mask %#x", mask)
+	}
+	if name := string(args[8 : 8+len(AuthName)]); name != AuthName {
+		t.Errorf("generateAuthorizationArgs: name %q", name)
+	}
+	values := args[len(args)-8:]
+	if timeout := binary.NativeEndian.Uint32(values); timeout != 0 {
+		t.Errorf("generateAuthorizationArgs: timeout %d", timeout)
+	}
+	if trust := binary.NativeEndian.Uint32(values[4:]); trust != securityClientUntrusted {
+		t.Errorf("generateAuthorizationArgs: trust level %d", trust)
+	}
+	if trust := binary.NativeEndian.Uint32(generateAuthorizationArgs(false)[len(args)-4:]); trust != securityClientTrusted {
+		t.Errorf("generateAuthorizationArgs: trust level %d", trust)
+	}
+}
+
+func TestParseGenerateAuthorizationReply(t *testing.T) {
+	t.Parallel()
+
+	cookie := bytes.Repeat([]byte{0xfd}, 16)
+	reply := make([]byte, 32)
+	reply[0] = 1
+	binary.NativeEndian.PutUint32(reply[4:], 4)
+	binary.NativeEndian.PutUint32(reply[8:], 0xbeef)
+	binary.NativeEndian.PutUint16(reply[12:], uint16(len(cookie)))
+	reply = append(reply, cookie...)
+
+	if id, data, err := parseGenerateAuthorizationReply(reply); err != nil {
+		t.Fatalf("parseGenerateAuthorizationReply: error = %v", err)
+	} else if id != 0xbeef || !bytes.Equal(data, cookie) {
+		t.Fatalf("parseGenerateAuthorizationReply: %#x, %v", id, data)
+	}
+
+	if _, _, err := parseGenerateAuthorizationReply(reply[:40]); !errors.Is(err, ErrSecurity) {
+		t.Errorf("parseGenerateAuthorizationReply: error = %v", err)
+	}
+	if _, _, err := parseGenerateAuthorizationReply(reply[:8]); !errors.Is(err, ErrSecurity) {
+		t.Errorf("parseGenerateAuthorizationReply: error = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package xcb
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// AuthName is the name of the only authorization protocol generated and read by this package.
+	AuthName = "MIT-MAGIC-COOKIE-1"
+
+	// FamilyLocal is the Xauthority family of entries matching local connections to a hostname.
+	FamilyLocal = 256
+	// FamilyWild is the Xauthority family of entries matching any address.
+	FamilyWild = 65535
+)
+
+// ErrXauthority is returned when reading a malformed Xauthority file.
+var ErrXauthority = errors.New("malformed Xauthority entry")
+
+// An XauthEntry is a single entry of an Xauthority file, as read and written by libXau.
+type XauthEntry struct {
+	// Address family, usually [FamilyLocal] or [FamilyWild].
+	Family uint16
+	// Address interpreted according to Family, the hostname for [FamilyLocal].
+	Address string
+	// Display number in decimal.
+	Number string
+	// Authorization protocol name, usually [AuthName].
+	Name string
+	// Authorization data.
+	Data []byte
+}
+
+// Append appends the encoding of e to buf.
+func (e *XauthEntry) Append(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, e.Family)
+	for _, v := range [][]byte{[]byte(e.Address), []byte(e.Number), []byte(e.Name), e.Data} {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(v)))
+		buf = append(buf, v...)
+	}
+	return buf
+}
+
+// ReadXauthority reads every entry of an Xauthority file from r.
+func ReadXauthority(r io.Reader) ([]XauthEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []XauthEntry
+	for len(data) > 0 {
+		var e XauthEntry
+		if len(data) < 2 {
+			return entries, ErrXauthority
+		}
+		e.Family = binary.BigEndian.Uint16(data)
+		data = data[2:]
+
+		var fields [4][]byte
+		for i := range fields {
+			if len(data) < 2 {
+				return entries, ErrXauthority
+			}
+			n := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+n {
+				return entries, ErrXauthority
+			}
+			fields[i] = data[2 : 2+n]
+			data = data[2+n:]
+		}
+		e.Address, e.Number, e.Name = string(fields[0]), string(fields[1]), string(fields[2])
+		e.Data = append([]byte(nil), fields[3]...)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// FindCookie returns the data of the first [AuthName] entry for a local display number,
+// or nil if no such entry exists. The address of [FamilyLocal] entries is not checked.
+func FindCookie(entries []XauthEntry, number string) []byte {
+	for i := range entries {
+		e := &entries[i]
+		if (e.Family == FamilyLocal || e.Family == FamilyWild) && e.Number == number && e.Name == AuthName {
+			return e.Data
+		}
+	}
+	return nil
+}
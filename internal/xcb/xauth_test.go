@@ -0,0 +1,53 @@
+package xcb_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"hakurei.app/internal/xcb"
+)
+
+func TestXauthority(t *testing.T) {
+	t.Parallel()
+
+	entries := []xcb.XauthEntry{
+		{Family: xcb.FamilyLocal, Address: "localhost", Number: "1", Name: xcb.AuthName, Data: []byte{0xfe}},
+		{Family: xcb.FamilyLocal, Address: "localhost", Number: "0", Name: "XDM-AUTHORIZATION-1", Data: []byte{0xfd}},
+		{Family: xcb.FamilyWild, Number: "0", Name: xcb.AuthName, Data: bytes.Repeat([]byte{0xfc}, 16)},
+	}
+	var buf []byte
+	for i := range entries {
+		buf = entries[i].Append(buf)
+	}
+
+	if want := []byte{
+		1, 0,
+		0, 9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+		0, 1, '1',
+		0, 18, 'M', 'I', 'T', '-', 'M', 'A', 'G', 'I', 'C', '-', 'C', 'O', 'O', 'K', 'I', 'E', '-', '1',
+		0, 1, 0xfe,
+	}; !bytes.Equal(buf[:len(want)], want) {
+		t.Fatalf("Append: %v, want %v", buf[:len(want)], want)
+	}
+
+	got, err := xcb.ReadXauthority(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("ReadXauthority: error = %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("ReadXauthority: %#v, want %#v", got, entries)
+	}
+
+	if _, err = xcb.ReadXauthority(bytes.NewReader(buf[:len(buf)-1])); !errors.Is(err, xcb.ErrXauthority) {
+		t.Errorf("ReadXauthority: error = %v", err)
+	}
+
+	if cookie := xcb.FindCookie(got, "0"); !bytes.Equal(cookie, entries[2].Data) {
+		t.Errorf("FindCookie: %v", cookie)
+	}
+	if cookie := xcb.FindCookie(got, "2"); cookie != nil {
+		t.Errorf("FindCookie: %v", cookie)
+	}
+}
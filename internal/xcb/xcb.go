@@ -1,4 +1,4 @@
-// Package xcb implements X11 ChangeHosts via libxcb.
+// Package xcb implements X11 ChangeHosts and SECURITY authorizations via libxcb.
 package xcb
 
 import (
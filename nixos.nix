@@ -16,10 +16,20 @@ let
     foldlAttrs
     optional
     optionals
+    optionalString
     ;
 
   cfg = config.environment.hakurei;
 
+  # hsu only accepts the hakurei it was built against, so both are rebuilt with the configured bound
+  package =
+    if cfg.waitDelayMax == null then
+      cfg.package
+    else
+      cfg.package.override { inherit (cfg) waitDelayMax; };
+  hsuPackage =
+    if cfg.waitDelayMax == null then cfg.hsuPackage else cfg.hsuPackage.override { hakurei = package; };
+
   # userid*userOffset + appStart + appid
   getsubuid = userid: appid: userid * 100000 + 10000 + appid;
   getsubname = userid: appid: "u${toString userid}_a${toString appid}";
@@ -52,7 +62,7 @@ in
     ];
 
     security.wrappers.hsu = {
-      source = "${cfg.hsuPackage}/bin/hsu";
+      source = "${hsuPackage}/bin/hsu";
       setuid = true;
       owner = "root";
       group = "root";
@@ -62,7 +72,12 @@ in
       mode = "0400";
       text = foldlAttrs (
         acc: username: fid:
-        "${toString config.users.users.${username}.uid} ${toString fid}\n" + acc
+        "${toString config.users.users.${username}.uid} ${toString fid}${
+          optionalString (
+            cfg.subordinateStart ? ${username}
+          ) " ${toString cfg.subordinateStart.${username}}"
+        }\n"
+        + acc
       ) "" cfg.users;
     };
 
@@ -107,19 +122,31 @@ in
                     inherit id;
                     inherit (app) identity groups enablements;
                     inherit (dbusConfig) session_bus system_bus;
+                    builtin_dbus_proxy = app.dbus.builtin;
                     direct_wayland = app.insecureWayland;
+                    wayland_proxy = app.waylandProxy;
+                    x11_cookie = app.x11Cookie;
+                    x11_master_cookie = app.x11MasterCookie;
+                    flatpak_info = app.flatpakInfo;
+                    inherit (app) sockets;
+                    pulse_proxy = app.pulseProxy;
 
                     container = {
                       inherit (app)
                         wait_delay
+                        termination
+                        etc
                         devel
                         userns
                         device
                         tty
+                        pty
                         multiarch
                         env
                         ;
                       map_real_uid = app.mapRealUid;
+                      fake_root = app.fakeRoot;
+                      env_passthrough = app.envPassthrough;
                       host_net = app.hostNet;
                       host_abstract = app.hostAbstract;
                       share_runtime = app.shareRuntime;
@@ -226,7 +253,7 @@ in
                     let
                       file = pkgs.writeText name (builtins.toJSON value);
                     in
-                    pkgs.runCommand "checked-${name}" { nativeBuildInputs = [ cfg.package ]; } ''
+                    pkgs.runCommand "checked-${name}" { nativeBuildInputs = [ package ]; } ''
                       ln -vs ${file} "$out"
                       hakurei show --no-store ${file}
                     '';
@@ -258,7 +285,7 @@ in
               )
             )
             ++ acc
-          ) [ cfg.package ] cfg.apps;
+          ) [ package ] cfg.apps;
         }) cfg.users;
       in
       {
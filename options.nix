@@ -22,6 +22,16 @@ in
         description = "The hsu package to use.";
       };
 
+      waitDelayMax = mkOption {
+        type = types.nullOr types.str;
+        default = null;
+        example = "2m";
+        description = ''
+          Upper bound of the container termination delay, in a format accepted by Go's time.ParseDuration.
+          Termination sequences exceeding this bound are rejected. Setting this to null uses the default of thirty seconds.
+        '';
+      };
+
       users = mkOption {
         type =
           let
@@ -33,6 +43,18 @@ in
         '';
       };
 
+      subordinateStart = mkOption {
+        type = types.attrsOf types.ints.u32;
+        default = { };
+        example = {
+          alice = 1879048192;
+        };
+        description = ''
+          First subordinate id of the block allocated to each user, overriding the default allocation past the systemd container range.
+          Each block spans 320000 ids and must not overlap app user ids, blocks of other users or ranges delegated via /etc/subuid and /etc/subgid.
+        '';
+      };
+
       extraHomeConfig = mkOption {
         type = types.anything;
         description = ''
@@ -140,6 +162,8 @@ in
               shareTmpdir = mkEnableOption "sharing of TMPDIR between containers under the same identity";
 
               dbus = {
+                builtin = mkEnableOption "the built-in D-Bus proxy instead of xdg-dbus-proxy";
+
                 session = mkOption {
                   type = nullOr (functionTo anything);
                   default = null;
@@ -167,6 +191,15 @@ in
                 '';
               };
 
+              envPassthrough = mkOption {
+                type = listOf str;
+                default = [ ];
+                description = ''
+                  Glob patterns naming host environment variables passed through to the initial process.
+                  Variables on the built-in denylist are only passed through if named exactly.
+                '';
+              };
+
               wait_delay = mkOption {
                 type = nullOr int;
                 default = null;
@@ -177,9 +210,29 @@ in
                 '';
               };
 
+              termination = mkOption {
+                type = nullOr (listOf anything);
+                default = null;
+                description = ''
+                  Ordered sequence of termination steps applied on cancellation, taking precedence over wait_delay.
+                  Each step may specify a signal name, whether to deliver it to the process group, and a duration to wait for in nanoseconds.
+                  The container is killed once every step has elapsed. Sequences whose total duration exceeds waitDelayMax are rejected.
+                '';
+              };
+
+              etc = mkOption {
+                type = nullOr (attrsOf anything);
+                default = null;
+                description = ''
+                  Generate hosts, hostname, resolv.conf and machine-id in /etc instead of exposing their host counterparts.
+                  Accepts a list of nameservers and a machine-id scope of either "app" or "instance".
+                '';
+              };
+
               devel = mkEnableOption "debugging-related kernel interfaces";
               userns = mkEnableOption "user namespace creation";
               tty = mkEnableOption "access to the controlling terminal";
+              pty = mkEnableOption "a new pseudo-terminal relayed by the shim";
               multiarch = mkEnableOption "multiarch kernel-level support";
 
               hostNet = mkEnableOption "share host net namespace" // {
@@ -189,8 +242,30 @@ in
 
               nix = mkEnableOption "nix daemon access";
               mapRealUid = mkEnableOption "mapping to priv-user uid";
+              fakeRoot = mkEnableOption "mapping to root with namespaced file capabilities, has no effect if mapRealUid is set";
               device = mkEnableOption "access to all devices";
               insecureWayland = mkEnableOption "direct access to the Wayland socket";
+              x11Cookie = mkEnableOption "per-instance X11 authorization cookie instead of a host entry for the target user";
+              x11MasterCookie = mkEnableOption "copying the trusted master cookie if the X server does not implement SECURITY, has no effect unless x11Cookie is set";
+              flatpakInfo = mkEnableOption "generated .flatpak-info identifying the app to xdg-desktop-portal";
+
+              waylandProxy = mkOption {
+                type = nullOr anything;
+                default = null;
+                description = ''
+                  Wayland protocol filtering proxy policy, hiding globals from the registry by allow and deny patterns.
+                  Setting this to null will make the Wayland socket available without filtering.
+                '';
+              };
+
+              pulseProxy = mkOption {
+                type = nullOr anything;
+                default = null;
+                description = ''
+                  PulseAudio protocol filtering proxy policy, granting record, modules, introspect and control on top of playback.
+                  Setting this to null will make the PulseAudio socket available as is.
+                '';
+              };
 
               gpu = mkOption {
                 type = nullOr bool;
@@ -213,6 +288,14 @@ in
                 '';
               };
 
+              sockets = mkOption {
+                type = listOf (attrsOf anything);
+                default = [ ];
+                description = ''
+                  Host pathname sockets to make available to the container, with access granted via acl or hard links.
+                '';
+              };
+
               enablements = {
                 wayland = mkOption {
                   type = nullOr bool;
@@ -245,6 +328,23 @@ in
                     Whether to share the PulseAudio socket and cookie.
                   '';
                 };
+
+                integration = mkOption {
+                  type = nullOr bool;
+                  default = false;
+                  description = ''
+                    Whether to expose host locale, timezone, font and theme resources.
+                    Store paths targeted by the system profile and /etc/fonts are exposed along with them.
+                  '';
+                };
+
+                pipewire = mkOption {
+                  type = nullOr bool;
+                  default = false;
+                  description = ''
+                    Whether to share the PipeWire socket via a security context.
+                  '';
+                };
               };
 
               share = mkOption {
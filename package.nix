@@ -28,6 +28,8 @@
 
   glibc, # for ldd
   withStatic ? stdenv.hostPlatform.isStatic,
+  # upper bound of the container termination delay, e.g. "2m"
+  waitDelayMax ? null,
 }:
 
 buildGoModule rec {
@@ -79,7 +81,8 @@ buildGoModule rec {
         buildVersion = "v${version}";
         hakureiPath = "${placeholder "out"}/libexec/hakurei";
         hsuPath = "/run/wrappers/bin/hsu";
-      };
+      }
+      // lib.optionalAttrs (waitDelayMax != null) { inherit waitDelayMax; };
 
   env = {
     # use clang instead of gcc